@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Access Control ---
+
+const (
+	// inviteTTL is how long an unused invite code stays valid.
+	inviteTTL = 7 * 24 * time.Hour
+	// inviteStartPrefix marks an invite in a /start deep link
+	// (t.me/<bot>?start=inv_<code>).
+	inviteStartPrefix = "inv_"
+)
+
+// Invite is a single-use code an admin created with /invite.
+type Invite struct {
+	CreatedBy int64     `json:"createdBy"`
+	At        time.Time `json:"at"`
+}
+
+// accessDeniedText is the reply to anyone not on the allowlist.
+const accessDeniedText = "🔒 Sorry, this bot is only available to invited members of the team.\n\n" +
+	"If you have an invite code, send `/join <code>`. Otherwise, ask an admin for access and give them your user ID: `%d`."
+
+// parseUserIDs parses a comma-separated list of Telegram user IDs
+// (ADMIN_IDS, ALLOWED_USERS).
+func parseUserIDs(raw string) map[int64]bool {
+	ids := make(map[int64]bool)
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid user ID %q", field)
+			continue
+		}
+		ids[id] = true
+	}
+	return ids
+}
+
+// hasAccess reports whether a user may use the bot. Without RESTRICT_ACCESS
+// (or ALLOWED_USERS) everyone may.
+func (b *Bot) hasAccess(userID int64) bool {
+	return !b.restrictAccess || b.isAdmin(userID) || b.allowedUsers[userID] || b.store.IsAllowed(userID)
+}
+
+// accessGate stops updates from users without access before anything is
+// downloaded or generated. They may still run /whoami and redeem an invite;
+// everything else gets a polite refusal. It returns true if it handled the
+// update.
+func (b *Bot) accessGate(update botUpdate) bool {
+	var userID int64
+	switch {
+	case update.CallbackQuery != nil:
+		userID = update.CallbackQuery.From.ID
+	case update.Message != nil && update.Message.From != nil:
+		userID = update.Message.From.ID
+	case update.MessageReaction != nil && update.MessageReaction.User != nil:
+		userID = update.MessageReaction.User.ID
+	case update.InlineQuery != nil:
+		userID = update.InlineQuery.From.ID
+	default:
+		return false // Channel posts and the like belong to no one user
+	}
+	if b.hasAccess(b.memberID(userID)) {
+		return false
+	}
+
+	switch {
+	case update.CallbackQuery != nil:
+		b.send(tgbotapi.NewCallback(update.CallbackQuery.ID, "Sorry, you don't have access to this bot."))
+	case update.InlineQuery != nil:
+		b.answerInline(tgbotapi.InlineConfig{
+			InlineQueryID:     update.InlineQuery.ID,
+			IsPersonal:        true,
+			SwitchPMText:      "No access yet: open the bot to join",
+			SwitchPMParameter: inlineStartParameter,
+		})
+	case update.Message != nil:
+		message := update.Message
+		switch message.Command() {
+		case "whoami", "chatid":
+			b.sendMessage(message.Chat.ID, whoamiText(message), nil)
+			return true
+		case "join":
+			b.redeemInvite(message.Chat.ID, userID, message.CommandArguments())
+			return true
+		case "start":
+			if code, ok := strings.CutPrefix(message.CommandArguments(), inviteStartPrefix); ok {
+				b.redeemInvite(message.Chat.ID, userID, code)
+				return true
+			}
+		}
+		b.sendMessage(message.Chat.ID, fmt.Sprintf(accessDeniedText, userID), nil)
+	}
+	b.userLogger(userID, updateChatID(update)).Info("Refused update from a user without access")
+	return true
+}
+
+// newInviteCode returns a random code that is easy to type.
+func newInviteCode() string {
+	var raw [5]byte
+	rand.Read(raw[:])
+	return base32.StdEncoding.EncodeToString(raw[:]) // 8 characters, A-Z and 2-7
+}
+
+// CreateInvite stores a new invite code and returns it.
+func (s *Store) CreateInvite(adminID int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code := newInviteCode()
+	s.data.Invites[code] = Invite{CreatedBy: adminID, At: time.Now()}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+	return code
+}
+
+// RedeemInvite uses up an invite code and gives the user access. It
+// returns false if the code is unknown, used or expired.
+func (s *Store) RedeemInvite(code string, userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.data.Invites[code]
+	if !ok {
+		return false
+	}
+	delete(s.data.Invites, code)
+	valid := time.Since(invite.At) <= inviteTTL
+	if valid {
+		s.data.Allowed[userID] = time.Now()
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+	return valid
+}
+
+// SetAllowed adds a user to (or removes them from) the stored allowlist.
+func (s *Store) SetAllowed(userID int64, allowed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if allowed {
+		s.data.Allowed[userID] = time.Now()
+	} else {
+		delete(s.data.Allowed, userID)
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// IsAllowed reports whether a user was given access with an invite or /allow.
+func (s *Store) IsAllowed(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.data.Allowed[userID]
+	return ok
+}
+
+// redeemInvite handles "/join <code>" and invite deep links.
+func (b *Bot) redeemInvite(chatID, userID int64, code string) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		b.sendMessage(chatID, "Please send the code with the command, e.g. `/join ABCD2345`.", nil)
+		return
+	}
+	if !b.store.RedeemInvite(code, userID) {
+		b.sendMessage(chatID, "That invite code isn't valid. It may have been used already or expired; please ask an admin for a new one.", nil)
+		return
+	}
+	log.Printf("User %d joined with an invite", userID)
+	b.sendMessage(chatID, "🎉 Welcome aboard! You now have access. Send me a **photo** of your product to get started.", nil)
+}
+
+// handleInvite handles the admin /invite command.
+func (b *Bot) handleInvite(chatID, adminID int64) {
+	code := b.store.CreateInvite(adminID)
+	log.Printf("Admin %d created an invite", adminID)
+	b.sendMessage(chatID, fmt.Sprintf("🎟 New invite code: `%s`\n\nIt works once, within %d days. Share this link:\nhttps://t.me/%s?start=%s%s\n\nor have them send `/join %s` to the bot.",
+		code, int(inviteTTL.Hours()/24), b.api.Self.UserName, inviteStartPrefix, code, code), nil)
+}
+
+// handleAllow handles "/allow <userID>" and "/revoke <userID>".
+func (b *Bot) handleAllow(chatID int64, allow bool, arg string) {
+	userID, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "Usage: `/allow <userID>` or `/revoke <userID>` (see /whoami for IDs).", nil)
+		return
+	}
+	b.store.SetAllowed(userID, allow)
+	if allow {
+		log.Printf("User %d allowed", userID)
+		b.sendMessage(chatID, fmt.Sprintf("✅ User `%d` now has access.", userID), nil)
+		return
+	}
+	b.resetState(userID)
+	log.Printf("User %d's access revoked", userID)
+	msg := fmt.Sprintf("🔒 User `%d` no longer has access.", userID)
+	if b.allowedUsers[userID] {
+		msg += " They are still listed in `ALLOWED_USERS`, though, so remove them there too."
+	}
+	b.sendMessage(chatID, msg, nil)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Admin Commands ---
+
+// isAdmin reports whether the user may run admin-only commands.
+func (b *Bot) isAdmin(userID int64) bool {
+	return b.adminIDs[userID]
+}
+
+// handleAdminCommand runs an admin-only command. It returns false if the
+// command is not an admin command, so the caller can fall through.
+func (b *Bot) handleAdminCommand(message *tgbotapi.Message) bool {
+	switch message.Command() {
+	case "cost", "cancelall", "ratings", "feedbackstats", "stats", "broadcast", "ban", "unban",
+		"addservice", "removeservice", "model", "invite", "allow", "revoke":
+	default:
+		return false
+	}
+
+	if !b.isAdmin(message.From.ID) {
+		b.sendMessage(message.Chat.ID, "Sorry, that command is for admins only.", nil)
+		return true
+	}
+
+	switch message.Command() {
+	case "cost":
+		b.sendMessage(message.Chat.ID, buildCostReport(b.store, b.pricing), nil)
+	case "ratings", "feedbackstats":
+		b.sendMessage(message.Chat.ID, buildRatingsReport(b.store.Ratings()), nil)
+	case "cancelall":
+		cleared := b.cancelAllConversations(message.From.ID)
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("🧹 Cleared %d active conversation(s).", cleared), nil)
+	case "stats":
+		b.sendMessage(message.Chat.ID, b.buildStatsReport(), nil)
+	case "broadcast":
+		b.handleBroadcast(message.Chat.ID, message.CommandArguments())
+	case "ban", "unban":
+		b.handleBan(message.Chat.ID, message.Command() == "ban", message.CommandArguments())
+	case "addservice":
+		b.handleAddService(message.Chat.ID, message.CommandArguments())
+	case "removeservice":
+		b.handleRemoveService(message.Chat.ID, message.CommandArguments())
+	case "model":
+		b.handleModelCommand(message.Chat.ID, message.CommandArguments())
+	case "invite":
+		b.handleInvite(message.Chat.ID, message.From.ID)
+	case "allow", "revoke":
+		b.handleAllow(message.Chat.ID, message.Command() == "allow", message.CommandArguments())
+	}
+	return true
+}
+
+// cancelAllConversations resets every user who is mid-conversation, strips
+// the buttons from their pending prompt and deletes their saved state, so
+// neither a restart nor another instance brings the conversation back. The
+// admin running it (callerID) already holds their own session. It returns
+// how many were cleared, so running it again straight away reports 0.
+func (b *Bot) cancelAllConversations(callerID int64) int {
+	b.mu.Lock()
+	userIDs := make([]int64, 0, len(b.userStates))
+	for userID := range b.userStates {
+		userIDs = append(userIDs, userID)
+	}
+	b.mu.Unlock()
+
+	cleared := 0
+	for _, userID := range userIDs {
+		if b.cancelConversation(userID, userID != callerID) {
+			cleared++
+		}
+	}
+
+	log.Printf("Admin cleared %d conversation(s)", cleared)
+	return cleared
+}
+
+// cancelConversation resets one user's conversation for cancelAllConversations,
+// taking their session first unless the caller holds it. It returns false if
+// there was nothing in progress.
+func (b *Bot) cancelConversation(userID int64, lock bool) bool {
+	if lock {
+		defer b.sessions.lock(userID)()
+	}
+	b.mu.Lock()
+	state, ok := b.userStates[userID]
+	if !ok || state.State == StateDefault && state.MessageID == 0 {
+		b.mu.Unlock()
+		return false
+	}
+	messageID := state.MessageID
+	b.userStates[userID] = &userState{State: StateDefault}
+	b.mu.Unlock()
+
+	if b.states != nil {
+		if err := b.states.DeleteState(userID); err != nil {
+			log.Printf("Error deleting cancelled state of user %d: %v", userID, err)
+		}
+	}
+	// Talk to Telegram outside b.mu so other users aren't blocked
+	if messageID != 0 {
+		b.removeInlineKeyboard(userID, messageID)
+	}
+	return true
+}
+
+// --- Stats, Broadcast & Bans ---
+
+// broadcastInterval spaces out broadcast messages, keeping well under
+// Telegram's limit of about 30 messages per second.
+const broadcastInterval = 50 * time.Millisecond
+
+// botStats counts generations since the bot started, for /stats.
+type botStats struct {
+	mu          sync.Mutex
+	started     time.Time
+	generations int
+	errors      int
+}
+
+// record counts one finished generation (err != nil if it failed).
+func (s *botStats) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors++
+	} else {
+		s.generations++
+	}
+}
+
+// TouchUser records that a user wrote to the bot. The store is only saved
+// for a new user or the first contact of the day, not for every message.
+func (s *Store) TouchUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := s.data.KnownUsers[userID]; ok && usageDay(last) == usageDay(now) {
+		return
+	}
+	s.data.KnownUsers[userID] = now
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// KnownUsers returns every user who has written to the bot.
+func (s *Store) KnownUsers() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]int64, 0, len(s.data.KnownUsers))
+	for userID := range s.data.KnownUsers {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// SetBanned bans or unbans a user.
+func (s *Store) SetBanned(userID int64, banned bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if banned {
+		s.data.Banned[userID] = time.Now()
+	} else {
+		delete(s.data.Banned, userID)
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// IsBanned reports whether a user is banned.
+func (s *Store) IsBanned(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.data.Banned[userID]
+	return ok
+}
+
+// isBanned reports whether the dispatcher should drop a user's updates.
+// Admins can't be banned.
+func (b *Bot) isBanned(userID int64) bool {
+	return !b.isAdmin(userID) && b.store.IsBanned(userID)
+}
+
+// buildStatsReport renders the admin /stats summary.
+func (b *Bot) buildStatsReport() string {
+	b.stats.mu.Lock()
+	generations, errors, started := b.stats.generations, b.stats.errors, b.stats.started
+	b.stats.mu.Unlock()
+
+	activeToday := len(b.store.UsageForDay(usageDay(time.Now())))
+	avg := "—"
+	if d := b.latency.get(); d > 0 {
+		avg = d.Round(100 * time.Millisecond).String()
+	}
+
+	return fmt.Sprintf("📊 **Bot Stats**\n\n"+
+		"Users: %d known, %d active today\n"+
+		"Since start (%s ago): %d generations, %d errors\n"+
+		"Average generation time: %s\n"+
+		"Queue: %d worker(s)",
+		len(b.store.KnownUsers()), activeToday,
+		time.Since(started).Round(time.Minute), generations, errors, avg, b.queue.size())
+}
+
+// handleBroadcast sends text to every known user, in the background, and
+// reports back when done. Users who blocked the bot are skipped.
+func (b *Bot) handleBroadcast(chatID int64, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		b.sendMessage(chatID, "Usage: `/broadcast <message>`", nil)
+		return
+	}
+
+	users := b.store.KnownUsers()
+	b.sendMessage(chatID, fmt.Sprintf("📣 Broadcasting to %d user(s)…", len(users)), nil)
+	go func() {
+		sent := 0
+		for _, userID := range users {
+			if b.store.IsBanned(userID) {
+				continue
+			}
+			if b.sendMessageID(userID, text, nil) != 0 {
+				sent++
+			}
+			time.Sleep(broadcastInterval)
+		}
+		log.Printf("Broadcast sent to %d of %d users", sent, len(users))
+		b.sendMessage(chatID, fmt.Sprintf("✅ Broadcast sent to %d of %d user(s).", sent, len(users)), nil)
+	}()
+}
+
+// handleBan handles "/ban <userID>" and "/unban <userID>".
+func (b *Bot) handleBan(chatID int64, ban bool, arg string) {
+	userID, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "Usage: `/ban <userID>` or `/unban <userID>` (see /whoami for IDs).", nil)
+		return
+	}
+	if ban && b.isAdmin(userID) {
+		b.sendMessage(chatID, "Admins can't be banned.", nil)
+		return
+	}
+
+	b.store.SetBanned(userID, ban)
+	if ban {
+		b.resetState(userID)
+		log.Printf("User %d banned", userID)
+		b.sendMessage(chatID, fmt.Sprintf("🚫 User `%d` is banned; the bot now ignores them.", userID), nil)
+		return
+	}
+	log.Printf("User %d unbanned", userID)
+	b.sendMessage(chatID, fmt.Sprintf("✅ User `%d` is unbanned.", userID), nil)
+}
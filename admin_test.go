@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestCancelAllDeletesSavedStates(t *testing.T) {
+	const adminID, busyID, idleID = 900, 901, 902
+	b, fake := newTestBot(t)
+	b.adminIDs = map[int64]bool{adminID: true}
+	states, err := openSQLiteStateStore(filepath.Join(t.TempDir(), "states.db"))
+	if err != nil {
+		t.Fatalf("opening state store: %v", err)
+	}
+	t.Cleanup(func() { states.Close() })
+	b.states = states
+
+	b.getState(busyID).State, b.getState(busyID).MessageID = StateWaitingForTone, 55
+	b.getState(idleID).Language = "bn"
+	b.persistState(busyID)
+	b.persistState(idleID)
+
+	b.processUpdate(botUpdate{Update: tgbotapi.Update{Message: textMessage(adminID, "/cancelall")}})
+
+	if texts := fake.Texts(adminID); len(texts) != 1 || !strings.Contains(texts[0], "Cleared 1 ") {
+		t.Errorf("admin was told %q, want 1 conversation cleared", texts)
+	}
+	saved, err := states.LoadStates(time.Hour)
+	if err != nil {
+		t.Fatalf("LoadStates: %v", err)
+	}
+	if _, ok := saved[busyID]; ok {
+		t.Error("the cancelled conversation is still saved, so a restart would bring it back")
+	}
+	if saved[idleID] == nil || saved[idleID].Language != "bn" {
+		t.Error("an idle user's saved state was touched")
+	}
+	if got := b.getState(busyID).State; got != StateDefault {
+		t.Errorf("busy user's state = %v, want it reset", got)
+	}
+	var stripped bool
+	for _, call := range fake.Calls("editMessageReplyMarkup") {
+		stripped = stripped || call.chatID() == busyID && call.Params.Get("message_id") == "55"
+	}
+	if !stripped {
+		t.Error("the pending prompt kept its buttons")
+	}
+
+	b.processUpdate(botUpdate{Update: tgbotapi.Update{Message: textMessage(adminID, "/cancelall")}})
+	if texts := fake.Texts(adminID); len(texts) != 2 || !strings.Contains(texts[1], "Cleared 0 ") {
+		t.Errorf("second run told %q, want 0 cleared", texts)
+	}
+}
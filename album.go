@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Photo Albums ---
+
+const (
+	// albumWait is how long after an album's last photo we wait for more.
+	// Telegram sends an album's photos as separate messages in quick succession.
+	albumWait = 1500 * time.Millisecond
+
+	// maxAlbumPhotos is Telegram's own album limit.
+	maxAlbumPhotos = 10
+)
+
+// albumPhoto is one photo of an album, after ImageRules.fit.
+type albumPhoto struct {
+	Data     []byte
+	MimeType string
+}
+
+// pendingAlbum collects an album's photos until no more arrive.
+type pendingAlbum struct {
+	chatID int64
+	userID int64
+	photos []albumPhoto // In arrival order; the first is the main photo
+	timer  *time.Timer
+}
+
+// albumBuffer holds the albums still arriving, by MediaGroupID.
+type albumBuffer struct {
+	mu     sync.Mutex
+	albums map[string]*pendingAlbum
+}
+
+// addAlbumPhoto adds a photo to its album and (re)starts the wait for the
+// rest. Once no more arrive, the album starts a conversation like one photo.
+func (b *Bot) addAlbumPhoto(message *tgbotapi.Message, photoData []byte, mimeType string) {
+	groupID := message.MediaGroupID
+
+	b.albums.mu.Lock()
+	defer b.albums.mu.Unlock()
+
+	album, ok := b.albums.albums[groupID]
+	if !ok {
+		album = &pendingAlbum{chatID: message.Chat.ID, userID: message.From.ID}
+		album.timer = time.AfterFunc(albumWait, func() { b.finishAlbum(groupID) })
+		b.albums.albums[groupID] = album
+	} else {
+		album.timer.Reset(albumWait)
+	}
+	if len(album.photos) < maxAlbumPhotos {
+		album.photos = append(album.photos, albumPhoto{Data: photoData, MimeType: mimeType})
+	}
+}
+
+// finishAlbum starts the conversation for a complete album.
+func (b *Bot) finishAlbum(groupID string) {
+	b.albums.mu.Lock()
+	album, ok := b.albums.albums[groupID]
+	delete(b.albums.albums, groupID)
+	b.albums.mu.Unlock()
+	if !ok || len(album.photos) == 0 {
+		return
+	}
+
+	first := album.photos[0]
+	intro := "Great photo! 📸"
+	if n := len(album.photos); n > 1 {
+		intro = fmt.Sprintf("Great, %d photos! 📸 I'll write the captions for the whole set.", n)
+	}
+	var extra []albumPhoto
+	for _, photo := range album.photos[1:] {
+		data, mimeType, _ := b.images.fit(photo.Data, photo.MimeType)
+		extra = append(extra, albumPhoto{Data: data, MimeType: mimeType})
+	}
+
+	// The timer fires outside the dispatcher, so hold the user's conversation
+	defer b.lockSession(album.userID)()
+	state := b.getState(album.userID)
+	b.startWithImage(album.chatID, state, first.Data, first.MimeType, intro)
+	state.AlbumPhotos = extra
+}
+
+// albumImages encodes the extra album photos for the caption request.
+func albumImages(photos []albumPhoto) []InlineData {
+	images := make([]InlineData, len(photos))
+	for i, photo := range photos {
+		images[i] = InlineData{MimeType: photo.MimeType, Data: base64.StdEncoding.EncodeToString(photo.Data)}
+	}
+	return images
+}
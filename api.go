@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- HTTP Generation API ---
+
+const (
+	// maxAPIUploadBytes caps the multipart body of POST /api/generate.
+	maxAPIUploadBytes = 20 << 20
+	// apiUsageUserID is the user ID API usage is recorded under for /cost.
+	apiUsageUserID = 0
+)
+
+// apiGenerateRequest is the "params" field of POST /api/generate.
+type apiGenerateRequest struct {
+	Platforms      []string `json:"platforms"`
+	Tone           string   `json:"tone"`
+	ToneIntensity  string   `json:"toneIntensity"`
+	Services       []string `json:"services"`
+	Context        string   `json:"context"`
+	Language       string   `json:"language"`       // e.g. "bn"; "" for English
+	StyleReference string   `json:"styleReference"` // A past caption to imitate
+	Brand          string   `json:"brand"`          // Preset name; "" for the default brand
+}
+
+// handleAPIGenerate serves POST /api/generate for the companion web app.
+// It takes a multipart form with an "image" file and a "params" JSON field,
+// and returns the GeneratedContent as JSON. Requests need the API_TOKEN as a
+// bearer token.
+func (b *Bot) handleAPIGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(b.apiToken)) != 1 {
+		writeAPIError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIUploadBytes)
+	if err := r.ParseMultipartForm(maxAPIUploadBytes); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("error parsing form: %v", err))
+		return
+	}
+
+	var req apiGenerateRequest
+	if err := json.Unmarshal([]byte(r.FormValue("params")), &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("error parsing params: %v", err))
+		return
+	}
+	params, err := b.apiGenerationParams(req)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "missing image file")
+		return
+	}
+	defer file.Close()
+	imageData, err := io.ReadAll(file)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("error reading image: %v", err))
+		return
+	}
+	mimeType := http.DetectContentType(imageData)
+	if err := b.images.checkType(mimeType); err != nil {
+		writeAPIError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	imageData, mimeType, note := b.images.fit(imageData, mimeType)
+	ctx := withLogger(r.Context(), slog.With("user_id", apiUsageUserID, "generation_id", newGenerationID()))
+	content, err := getB2BContent(ctx, b.llm, imageData, mimeType, b.withFooterLength(b.withRatedExamples(params), true), nil)
+	if err != nil {
+		// The error can carry the model's URL, with its key, and the body
+		// of its reply, so the caller only learns what kind of failure it was
+		logFrom(ctx).Error("API generation failed", "error", err)
+		status, message := http.StatusBadGateway, "generation failed"
+		if errors.Is(err, errCircuitOpen) || errors.Is(err, errAuthDegraded) {
+			status, message = http.StatusServiceUnavailable, "service unavailable"
+		}
+		writeAPIError(w, status, message)
+		return
+	}
+
+	b.recordUsage(apiUsageUserID, content.Usage)
+	if note != "" {
+		content.Notes = append(content.Notes, note)
+	}
+	b.applyCaptionPolicies(content, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
+
+// apiGenerationParams validates an API request against the same choices the
+// bot's buttons offer.
+func (b *Bot) apiGenerationParams(req apiGenerateRequest) (GenerationParams, error) {
+	params := GenerationParams{
+		ToneIntensity:  req.ToneIntensity,
+		Services:       req.Services,
+		Context:        req.Context,
+		Language:       req.Language,
+		StyleReference: req.StyleReference,
+		Brand:          b.defaultBrand(),
+		Output:         b.output,
+	}
+
+	if len(req.Platforms) == 0 {
+		return params, fmt.Errorf("at least one platform is required")
+	}
+	for _, p := range req.Platforms {
+		if _, ok := platformLabels[p]; !ok {
+			return params, fmt.Errorf("unknown platform %q", p)
+		}
+	}
+	params.Platforms = req.Platforms
+
+	if params.Tone = matchTone(req.Tone); params.Tone == "" {
+		return params, fmt.Errorf("unknown tone %q", req.Tone)
+	}
+	switch req.ToneIntensity {
+	case "", "Subtle", "Balanced", "Strong":
+	default:
+		return params, fmt.Errorf("unknown tone intensity %q", req.ToneIntensity)
+	}
+
+	if utf8.RuneCountInString(req.StyleReference) > maxStyleReferenceLength {
+		return params, fmt.Errorf("styleReference is longer than %d characters", maxStyleReferenceLength)
+	}
+	if _, ok := findLanguage(req.Language); !ok {
+		return params, fmt.Errorf("unknown language %q", req.Language)
+	}
+
+	if req.Brand != "" {
+		brand, ok := b.brands[strings.ToLower(req.Brand)]
+		if !ok {
+			return params, fmt.Errorf("unknown brand %q", req.Brand)
+		}
+		params.Brand = brand
+	}
+	return params, nil
+}
+
+// writeAPIError sends a JSON error body.
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
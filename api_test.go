@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// apiRequest builds a POST /api/generate request with the given params and image.
+func apiRequest(t *testing.T, token, params string, image []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("params", params)
+	if image != nil {
+		part, _ := form.CreateFormFile("image", "photo.jpg")
+		part.Write(image)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/generate", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestHandleAPIGenerate(t *testing.T) {
+	const validParams = `{"platforms":["Instagram"],"tone":"Professional"}`
+	photo := testJPEG(t, 600, 600)
+
+	tests := []struct {
+		name       string
+		token      string
+		params     string
+		image      []byte
+		wantStatus int
+		wantBody   string
+	}{
+		{"no token", "", validParams, photo, http.StatusUnauthorized, "bearer token"},
+		{"wrong token", "guess", validParams, photo, http.StatusUnauthorized, "bearer token"},
+		{"params aren't JSON", "secret", "platforms=Instagram", photo, http.StatusBadRequest, "error parsing params"},
+		{"no platform", "secret", `{"tone":"Professional"}`, photo, http.StatusBadRequest, "at least one platform"},
+		{"unknown platform", "secret", `{"platforms":["MySpace"],"tone":"Professional"}`, photo, http.StatusBadRequest, `unknown platform \"MySpace\"`},
+		{"unknown tone", "secret", `{"platforms":["Instagram"],"tone":"Sarcastic"}`, photo, http.StatusBadRequest, "unknown tone"},
+		{"no image", "secret", validParams, nil, http.StatusBadRequest, "missing image"},
+		{"not an image", "secret", validParams, []byte("%PDF-1.4 not a photo"), http.StatusUnsupportedMediaType, "I can work with"},
+		{"success", "secret", validParams, photo, http.StatusOK, "First caption"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			var called func() []string
+			b.llm, called = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+				return http.StatusOK, captionsReply
+			})
+			b.apiToken = "secret"
+
+			rec := httptest.NewRecorder()
+			b.handleAPIGenerate(rec, apiRequest(t, tt.token, tt.params, tt.image))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to mention %q", rec.Body, tt.wantBody)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if n := len(called()); n != 0 {
+					t.Errorf("%d Gemini calls for a rejected request", n)
+				}
+				return
+			}
+
+			var content GeneratedContent
+			if err := json.Unmarshal(rec.Body.Bytes(), &content); err != nil {
+				t.Fatalf("decoding the response: %v", err)
+			}
+			if len(content.Results) != 1 || content.Results[0].Platform != "Instagram" || len(content.Results[0].Captions) != 3 {
+				t.Errorf("results = %+v, want three Instagram captions", content.Results)
+			}
+		})
+	}
+}
+
+func TestAPIErrorsHideProviderDetails(t *testing.T) {
+	b, _ := newTestBot(t)
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusInternalServerError, `{"error":{"message":"upstream failure for https://generativelanguage.googleapis.com/v1beta/models/model:generateContent?key=test-key"}}`
+	})
+	b.apiToken = "secret"
+
+	rec := httptest.NewRecorder()
+	b.handleAPIGenerate(rec, apiRequest(t, "secret", `{"platforms":["Instagram"],"tone":"Professional"}`, testJPEG(t, 600, 600)))
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "generation failed") {
+		t.Errorf("got %d %s, want 502 saying the generation failed", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "test-key") || strings.Contains(body, "upstream failure") {
+		t.Errorf("body = %s, leaks the provider's error", body)
+	}
+}
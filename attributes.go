@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Product Attributes ---
+
+// ProductAttributes are the facts about the product read from the photo
+// (DETECT_ATTRIBUTES) and confirmed or corrected by the user.
+type ProductAttributes struct {
+	GarmentType string `json:"garmentType"` // e.g. "Women's shorts"
+	Color       string `json:"color"`
+	Material    string `json:"material"`
+	Style       string `json:"style"` // e.g. "Casual", "Streetwear"
+}
+
+// schemaForAttributes asks for each attribute as a short phrase.
+var schemaForAttributes = &Schema{
+	Type: "OBJECT",
+	Properties: map[string]Property{
+		"garmentType": {Type: "STRING"},
+		"color":       {Type: "STRING"},
+		"material":    {Type: "STRING"},
+		"style":       {Type: "STRING"},
+	},
+	Required: []string{"garmentType", "color", "material", "style"},
+}
+
+// attributesKeyboard is shown under the detected attributes.
+var attributesKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Looks right", "attr:confirm"),
+		tgbotapi.NewInlineKeyboardButtonData("✏️ Correct", "attr:edit"),
+	),
+)
+
+// attributeFields maps the names users may type when correcting an
+// attribute to the field they set.
+var attributeFields = map[string]func(*ProductAttributes) *string{
+	"garment":  func(a *ProductAttributes) *string { return &a.GarmentType },
+	"type":     func(a *ProductAttributes) *string { return &a.GarmentType },
+	"color":    func(a *ProductAttributes) *string { return &a.Color },
+	"colour":   func(a *ProductAttributes) *string { return &a.Color },
+	"material": func(a *ProductAttributes) *string { return &a.Material },
+	"fabric":   func(a *ProductAttributes) *string { return &a.Material },
+	"style":    func(a *ProductAttributes) *string { return &a.Style },
+}
+
+// buildAttributesSystemPrompt asks for plain, specific attribute values.
+func buildAttributesSystemPrompt() string {
+	return `You are a garment merchandiser cataloguing a B2B clothing product photo.
+Identify the main product and describe it in short, specific phrases (1-3 words each):
+- "garmentType": the garment, including who it is for if clear (e.g. "Women's shorts", "Polo shirt").
+- "color": the main color (e.g. "Navy", "Off-white").
+- "material": the most likely fabric (e.g. "Linen", "Cotton twill", "Denim").
+- "style": the style (e.g. "Casual", "Formal", "Streetwear").
+If you can't tell, give your best guess rather than leaving a field empty.`
+}
+
+// extractAttributes runs the attribute detection call on a photo.
+func extractAttributes(ctx context.Context, client ContentGenerator, photoData []byte, mimeType string) (*ProductAttributes, UsageMetadata, error) {
+	request := GeminiRequest{
+		Contents: []Content{
+			{
+				Role: "user",
+				Parts: []Part{
+					{Text: "What product is this?"},
+					{InlineData: &InlineData{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(photoData)}},
+				},
+			},
+		},
+		SystemInstruction: SystemInstruction{
+			Parts: []Part{{Text: buildAttributesSystemPrompt()}},
+		},
+		GenerationConfig: GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schemaForAttributes,
+		},
+	}
+
+	jsonResponse, usage, err := client.generateContent(ctx, request)
+	if err != nil {
+		return nil, usage, err
+	}
+	attrs, err := parseAttributes(jsonResponse)
+	return attrs, usage, err
+}
+
+// parseAttributes reads the attributes JSON, trimming each value.
+func parseAttributes(jsonResponse string) (*ProductAttributes, error) {
+	var attrs ProductAttributes
+	if err := json.Unmarshal([]byte(jsonResponse), &attrs); err != nil {
+		return nil, fmt.Errorf("error parsing attributes JSON: %w", err)
+	}
+	attrs.GarmentType = strings.TrimSpace(attrs.GarmentType)
+	attrs.Color = strings.TrimSpace(attrs.Color)
+	attrs.Material = strings.TrimSpace(attrs.Material)
+	attrs.Style = strings.TrimSpace(attrs.Style)
+	if attrs == (ProductAttributes{}) {
+		return nil, fmt.Errorf("attributes JSON has no values")
+	}
+	return &attrs, nil
+}
+
+// applyAttributeEdits applies corrections like "color: navy, material:
+// cotton twill" (comma- or newline-separated).
+func applyAttributeEdits(attrs *ProductAttributes, text string) error {
+	edited := false
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return fmt.Errorf("%q is missing a colon", strings.TrimSpace(part))
+		}
+		field, ok := attributeFields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("I don't know the detail %q", strings.TrimSpace(name))
+		}
+		*field(attrs) = strings.TrimSpace(value)
+		edited = true
+	}
+	if !edited {
+		return fmt.Errorf("no corrections found")
+	}
+	return nil
+}
+
+// hashtagWord turns a phrase into a hashtag body, e.g. "women's shorts"
+// becomes "WomensShorts".
+func hashtagWord(phrase string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(phrase, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' }) {
+		word = strings.ReplaceAll(word, "'", "")
+		if word == "" {
+			continue
+		}
+		runes := []rune(word)
+		sb.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	return sb.String()
+}
+
+// attributeHashtags derives specific hashtags from the attributes, e.g.
+// #WomensShorts and #LinenFabric.
+func attributeHashtags(attrs *ProductAttributes) []string {
+	if attrs == nil {
+		return nil
+	}
+	var tags []string
+	if w := hashtagWord(attrs.GarmentType); w != "" {
+		tags = append(tags, "#"+w)
+	}
+	if w := hashtagWord(attrs.Material); w != "" {
+		if !strings.HasSuffix(strings.ToLower(w), "fabric") {
+			w += "Fabric"
+		}
+		tags = append(tags, "#"+w)
+	}
+	if w := hashtagWord(attrs.Style); w != "" {
+		tags = append(tags, "#"+w+"Fashion")
+	}
+	return tags
+}
+
+// attributesSection gives the model the confirmed attributes as facts.
+func attributesSection(attrs *ProductAttributes) string {
+	if attrs == nil || *attrs == (ProductAttributes{}) {
+		return ""
+	}
+	return fmt.Sprintf("\n**Product Details (confirmed by the client, treat as facts):** Garment: %s; Color: %s; Material: %s; Style: %s.\n"+
+		"- Use these details in the captions and for specific hashtags.\n",
+		orDash(attrs.GarmentType), orDash(attrs.Color), orDash(attrs.Material), orDash(attrs.Style))
+}
+
+// formatAttributes renders the attributes for the confirmation message.
+func formatAttributes(attrs *ProductAttributes) string {
+	return fmt.Sprintf("🔎 **Detected product details**\n\nGarment: %s\nColor: %s\nMaterial: %s\nStyle: %s\n\n"+
+		"Look right? Tap ✅ to generate, or ✏️ to correct them.",
+		orDash(attrs.GarmentType), orDash(attrs.Color), orDash(attrs.Material), orDash(attrs.Style))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// detectProductAttributes reads the product attributes on the generation queue and
+// asks the user to confirm them. If detection fails, generation goes ahead
+// without them.
+func (b *Bot) detectProductAttributes(userID int64, state *userState) {
+	thinking, _ := b.send(b.newMessage(userID, "🔎 Checking the product details…"))
+	state.MessageID = thinking.MessageID
+	state.State = StateWaitingForAttributes
+
+	photoData, mimeType := state.PhotoData, state.MimeType
+	err := b.queue.submit(userID, func() {
+		attrs, usage, err := extractAttributes(context.Background(), b.llm, photoData, mimeType)
+		b.recordUsage(userID, usage)
+
+		defer b.lockSession(userID)()
+		state := b.getState(userID)
+		if state.State != StateWaitingForAttributes {
+			return // Cancelled meanwhile
+		}
+		if err != nil {
+			log.Printf("Warning: Could not detect product attributes: %v", err)
+			b.send(tgbotapi.NewDeleteMessage(userID, thinking.MessageID))
+			state.Attributes = &ProductAttributes{} // Don't ask again for this job
+			b.generateContent(userID)
+			return
+		}
+		state.Attributes = attrs
+		b.editMessageID(userID, thinking.MessageID, formatAttributes(attrs), attributesKeyboard)
+	})
+	if err != nil {
+		// The queue is full; skip the check rather than refusing the job
+		b.send(tgbotapi.NewDeleteMessage(userID, thinking.MessageID))
+		state.Attributes = &ProductAttributes{}
+		b.generateContent(userID)
+	}
+}
+
+// handleAttributesCallback handles the "Looks right" / "Correct" buttons.
+func (b *Bot) handleAttributesCallback(userID int64, state *userState, data string) {
+	if state.Attributes == nil {
+		return // Still detecting
+	}
+	switch data {
+	case "attr:confirm":
+		b.removeInlineKeyboard(userID, state.MessageID)
+		b.generateContent(userID)
+	case "attr:edit":
+		b.editMessage(userID, formatAttributes(state.Attributes)+
+			"\n\n✏️ Send your corrections, e.g. `color: navy, material: cotton twill`.", attributesKeyboard)
+	}
+}
+
+// handleAttributeEdits applies a typed correction and shows the result.
+func (b *Bot) handleAttributeEdits(message *tgbotapi.Message, state *userState) {
+	if state.Attributes == nil {
+		b.sendMessage(message.Chat.ID, "Still checking the product details, one moment… 🔎", nil)
+		return
+	}
+	if err := applyAttributeEdits(state.Attributes, message.Text); err != nil {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("Sorry, I couldn't use that (%s). Try e.g. `color: navy, material: linen`.", err.Error()), nil)
+		return
+	}
+
+	// Move the confirmation below the user's message
+	b.removeInlineKeyboard(message.Chat.ID, state.MessageID)
+	msg := b.newMessage(message.Chat.ID, formatAttributes(state.Attributes))
+	msg.ReplyMarkup = attributesKeyboard
+	if sentMsg, err := b.send(msg); err == nil {
+		state.MessageID = sentMsg.MessageID
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	attrs, err := parseAttributes(`{"garmentType":" Women's shorts ","color":"Navy\n","material":"Linen","style":""}`)
+	if err != nil {
+		t.Fatalf("parseAttributes: %v", err)
+	}
+	want := ProductAttributes{GarmentType: "Women's shorts", Color: "Navy", Material: "Linen"}
+	if *attrs != want {
+		t.Errorf("parseAttributes = %+v, want %+v", *attrs, want)
+	}
+
+	for _, bad := range []string{`{"garmentType":"  ","color":""}`, `{}`, `not JSON`} {
+		if attrs, err := parseAttributes(bad); err == nil {
+			t.Errorf("parseAttributes(%q) = %+v, want an error", bad, attrs)
+		}
+	}
+}
+
+func TestAttributesSchemaRequiresEveryField(t *testing.T) {
+	for _, field := range []string{"garmentType", "color", "material", "style"} {
+		if _, ok := schemaForAttributes.Properties[field]; !ok {
+			t.Errorf("schema has no %q property", field)
+		}
+		if !slices.Contains(schemaForAttributes.Required, field) {
+			t.Errorf("schema doesn't require %q", field)
+		}
+	}
+}
+
+func TestExtractAttributes(t *testing.T) {
+	client, calls := fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"{\"garmentType\":\"Jacket\",\"color\":\"Indigo\",\"material\":\"Denim\",\"style\":\"Casual\"}"}]}}]}`
+	})
+	attrs, _, err := extractAttributes(context.Background(), client, testJPEG(t, 8, 8), "image/jpeg")
+	if err != nil {
+		t.Fatalf("extractAttributes: %v", err)
+	}
+	if want := (ProductAttributes{GarmentType: "Jacket", Color: "Indigo", Material: "Denim", Style: "Casual"}); *attrs != want {
+		t.Errorf("extractAttributes = %+v, want %+v", *attrs, want)
+	}
+	if got := len(calls()); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestApplyAttributeEdits(t *testing.T) {
+	attrs := ProductAttributes{GarmentType: "Jacket", Color: "Indigo", Material: "Denim", Style: "Casual"}
+	if err := applyAttributeEdits(&attrs, "Colour: navy, fabric: cotton twill\nType : Chore coat"); err != nil {
+		t.Fatalf("applyAttributeEdits: %v", err)
+	}
+	want := ProductAttributes{GarmentType: "Chore coat", Color: "navy", Material: "cotton twill", Style: "Casual"}
+	if attrs != want {
+		t.Errorf("after edits = %+v, want %+v", attrs, want)
+	}
+
+	tests := []struct {
+		text    string
+		wantErr string
+	}{
+		{"navy", "missing a colon"},
+		{"size: M", `don't know the detail "size"`},
+		{" , \n", "no corrections found"},
+	}
+	for _, tt := range tests {
+		err := applyAttributeEdits(&attrs, tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("applyAttributeEdits(%q) error = %v, want one containing %q", tt.text, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAttributeHashtags(t *testing.T) {
+	tests := []struct {
+		attrs *ProductAttributes
+		want  []string
+	}{
+		{&ProductAttributes{GarmentType: "women's shorts", Color: "Navy", Material: "linen", Style: "casual"},
+			[]string{"#WomensShorts", "#LinenFabric", "#CasualFashion"}},
+		{&ProductAttributes{Material: "Cotton fabric"}, []string{"#CottonFabric"}},
+		{&ProductAttributes{}, nil},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		if got := attributeHashtags(tt.attrs); !slices.Equal(got, tt.want) {
+			t.Errorf("attributeHashtags(%+v) = %v, want %v", tt.attrs, got, tt.want)
+		}
+	}
+}
+
+func TestAttributesSection(t *testing.T) {
+	if got := attributesSection(nil); got != "" {
+		t.Errorf("attributesSection(nil) = %q, want empty", got)
+	}
+	got := attributesSection(&ProductAttributes{GarmentType: "Jacket", Color: "Indigo"})
+	for _, want := range []string{"Garment: Jacket", "Color: Indigo", "Material: —"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("attributesSection missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Bad API Key Detection ---
+
+// errAuthDegraded is returned without calling Gemini while the API key is
+// believed to be revoked or invalid.
+var errAuthDegraded = errors.New("the Gemini API key was rejected")
+
+// authProbeInterval is how often a request is let through while degraded,
+// to notice when the key works again.
+const authProbeInterval = 5 * time.Minute
+
+// authError is a 401/403 (or "API key not valid") response from Gemini.
+type authError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *authError) Error() string {
+	return fmt.Sprintf("API key rejected (status %d): %s", e.StatusCode, e.Body)
+}
+
+// isAuthFailure reports whether a Gemini response means the key itself is bad.
+// Gemini answers an invalid key with 400 API_KEY_INVALID rather than 401.
+func isAuthFailure(statusCode int, body string) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden ||
+		(statusCode == http.StatusBadRequest && strings.Contains(body, "API_KEY_INVALID"))
+}
+
+// authGuard counts consecutive auth failures. After threshold of them it
+// turns degraded: requests fail fast with errAuthDegraded, except for one
+// probe every authProbeInterval. A successful request recovers it.
+// onChange is called (outside the lock) when it degrades or recovers.
+type authGuard struct {
+	mu        sync.Mutex
+	threshold int // 0 disables the guard
+	failures  int
+	degraded  bool
+	lastProbe time.Time
+	now       func() time.Time // Swappable clock
+	onChange  func(degraded bool)
+}
+
+// newAuthGuard creates a healthy guard.
+func newAuthGuard(threshold int) *authGuard {
+	return &authGuard{threshold: threshold, now: time.Now}
+}
+
+// allow reports whether a request may be sent.
+func (g *authGuard) allow() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.degraded {
+		return true
+	}
+	if g.now().Sub(g.lastProbe) >= authProbeInterval {
+		g.lastProbe = g.now()
+		return true
+	}
+	return false
+}
+
+// record updates the guard with a request's outcome. Errors other than auth
+// failures neither count nor recover, since they say nothing about the key.
+func (g *authGuard) record(err error) {
+	var authErr *authError
+	isAuth := errors.As(err, &authErr)
+	if err != nil && !isAuth {
+		return
+	}
+
+	g.mu.Lock()
+	changed := false
+	if isAuth {
+		g.failures++
+		if !g.degraded && g.threshold > 0 && g.failures >= g.threshold {
+			g.degraded, changed = true, true
+			g.lastProbe = g.now()
+			log.Printf("Gemini API key rejected %d times in a row, pausing generation", g.failures)
+		}
+	} else {
+		g.failures = 0
+		if g.degraded {
+			g.degraded, changed = false, true
+			log.Println("Gemini API key accepted again, resuming generation")
+		}
+	}
+	degraded, onChange := g.degraded, g.onChange
+	g.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(degraded)
+	}
+}
+
+// alertAdmins sends a message to everyone in ADMIN_IDS.
+func (b *Bot) alertAdmins(text string) {
+	for adminID := range b.adminIDs {
+		b.sendMessage(adminID, text, nil)
+	}
+}
+
+// onAuthChange tells the admins when the API key stops or starts working.
+func (b *Bot) onAuthChange(degraded bool) {
+	if degraded {
+		b.alertAdmins("🚨 **Gemini API key rejected.** Generation is paused for all users. " +
+			"Please check or rotate `GEMINI_API_KEY`; I'll retry every few minutes.")
+		return
+	}
+	b.alertAdmins("✅ The Gemini API key works again. Generation has resumed.")
+}
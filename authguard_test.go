@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAuthGuardTripsAndRecovers(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := newAuthGuard(3)
+	g.now = func() time.Time { return clock }
+	var changes []bool
+	g.onChange = func(degraded bool) { changes = append(changes, degraded) }
+	rejected := &authError{StatusCode: 403, Body: "API key not valid"}
+
+	// Other errors neither count towards the threshold nor reset it
+	g.record(rejected)
+	g.record(rejected)
+	g.record(errors.New("API request failed with status 400"))
+	if !g.allow() || len(changes) != 0 {
+		t.Fatal("guard degraded below the threshold")
+	}
+
+	g.record(rejected)
+	if g.allow() {
+		t.Fatal("request allowed right after the third rejection")
+	}
+	if !slices.Equal(changes, []bool{true}) {
+		t.Errorf("onChange calls = %v, want one for degrading", changes)
+	}
+
+	// One probe per interval, and another rejection keeps it degraded
+	clock = clock.Add(authProbeInterval)
+	if !g.allow() {
+		t.Fatal("probe refused after the interval")
+	}
+	if g.allow() {
+		t.Error("second request allowed in the same interval")
+	}
+	g.record(rejected)
+
+	clock = clock.Add(authProbeInterval)
+	if !g.allow() {
+		t.Fatal("probe refused after the next interval")
+	}
+	g.record(nil)
+	if !g.allow() || !g.allow() {
+		t.Error("requests refused after the key worked again")
+	}
+	if !slices.Equal(changes, []bool{true, false}) {
+		t.Errorf("onChange calls = %v, want degrading then recovering", changes)
+	}
+}
+
+func TestLLMClientFailsFastWhileKeyRejected(t *testing.T) {
+	client, called := fakeGemini(t, []string{"primary"}, func(string) (int, string) {
+		return http.StatusBadRequest, `{"error":{"status":"INVALID_ARGUMENT","details":[{"reason":"API_KEY_INVALID"}]}}`
+	})
+	client.auth = newAuthGuard(1)
+
+	client.generateContent(context.Background(), GeminiRequest{})
+	_, _, err := client.generateContent(context.Background(), GeminiRequest{})
+	if !errors.Is(err, errAuthDegraded) {
+		t.Errorf("err = %v, want errAuthDegraded", err)
+	}
+	if got := len(called()); got != 1 {
+		t.Errorf("API called %d times, want 1", got)
+	}
+}
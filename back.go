@@ -0,0 +1,27 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Back Button ---
+
+// backRow is the "⬅️ Back" row at the bottom of every question after the
+// first, so an answer can be changed without starting over.
+var backRow = tgbotapi.NewInlineKeyboardRow(
+	tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", "control:back"),
+)
+
+// backKeyboard is for questions answered by typing.
+var backKeyboard = tgbotapi.NewInlineKeyboardMarkup(backRow)
+
+// goBack moves the conversation one question back and redraws it, keeping
+// the earlier answers so they show as selected. Steps skipped by a
+// deep-link preset are asked on the way back, so they can be changed too.
+func (b *Bot) goBack(userID int64, state *userState) {
+	step, ok := b.steps[state.State]
+	if !ok || step.Back == StateDefault {
+		return
+	}
+	b.showStep(userID, state, step.Back, "", false)
+}
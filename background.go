@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// --- Background Check ---
+
+// backdropAdvice is added to the feedback when the background is cluttered.
+const backdropAdvice = "Tip: reshoot on a plain, neutral backdrop (white, light grey or beige) so the product stands out."
+
+// BackgroundAssessment is the model's verdict on the photo's background.
+type BackgroundAssessment struct {
+	Cluttered bool   `json:"cluttered"`
+	Reason    string `json:"reason"` // What makes it cluttered (or why it's fine)
+}
+
+// schemaForBackground asks for a yes/no verdict with a short reason.
+var schemaForBackground = &Schema{
+	Type: "OBJECT",
+	Properties: map[string]Property{
+		"cluttered": {Type: "BOOLEAN"},
+		"reason":    {Type: "STRING"},
+	},
+	Required: []string{"cluttered", "reason"},
+}
+
+// buildBackgroundSystemPrompt asks for a strict but fair background verdict.
+func buildBackgroundSystemPrompt() string {
+	return `You are a professional product photographer reviewing a B2B clothing product photo.
+Decide only whether the BACKGROUND is cluttered or distracting: busy patterns, other objects, mess, people, or strong colors that compete with the product.
+A plain wall, studio backdrop, simple surface or softly blurred background is NOT cluttered.
+- Set "cluttered" to true or false.
+- In "reason", say in one short sentence what in the background distracts (or why it works).`
+}
+
+// assessBackground runs the background check on a base64 image.
+func assessBackground(ctx context.Context, client ContentGenerator, base64Image, mimeType, language string) (*BackgroundAssessment, UsageMetadata, error) {
+	request := GeminiRequest{
+		Contents: []Content{
+			{
+				Role: "user",
+				Parts: []Part{
+					{Text: "Is the background of this product photo cluttered?"},
+					{InlineData: &InlineData{MimeType: mimeType, Data: base64Image}},
+				},
+			},
+		},
+		SystemInstruction: SystemInstruction{
+			Parts: []Part{{Text: buildBackgroundSystemPrompt() + languageInstruction(language)}},
+		},
+		GenerationConfig: GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schemaForBackground,
+		},
+	}
+
+	jsonResponse, usage, err := client.generateContent(ctx, request)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	var assessment BackgroundAssessment
+	if err := json.Unmarshal([]byte(jsonResponse), &assessment); err != nil {
+		return nil, usage, fmt.Errorf("error parsing background JSON: %w", err)
+	}
+	assessment.Reason = strings.TrimSpace(assessment.Reason)
+	return &assessment, usage, nil
+}
+
+// addBackgroundAssessment runs the background check (BG_CLEANUP) and records
+// it on the content. It is fail-soft: errors are logged and the job goes on.
+func addBackgroundAssessment(ctx context.Context, client ContentGenerator, content *GeneratedContent, base64Image, mimeType string) {
+	logFrom(ctx).Info("Checking the photo background")
+	assessment, usage, err := assessBackground(ctx, client, base64Image, mimeType, content.Language)
+	content.Usage.Add(usage)
+	if err != nil {
+		logFrom(ctx).Warn("Could not assess the background", "error", err)
+		return
+	}
+	content.Background = assessment
+}
+
+// backgroundNote renders the advice for a cluttered background, or "".
+func backgroundNote(assessment *BackgroundAssessment) string {
+	if assessment == nil || !assessment.Cluttered {
+		return ""
+	}
+	note := "🧹 **Busy background.**"
+	if assessment.Reason != "" {
+		note += " " + assessment.Reason
+	}
+	return note + " " + backdropAdvice
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Batch Mode ---
+
+// batchItem is one photo collected in batch mode.
+type batchItem struct {
+	MessageID int // The user's photo message, so results can reply to it
+	PhotoData []byte
+	MimeType  string
+	ImageNote string
+}
+
+// batchKeyboard is shown while photos are being collected.
+var batchKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Done", "batch:done"),
+		tgbotapi.NewInlineKeyboardButtonData("✖️ Cancel", "batch:cancel"),
+	),
+)
+
+// startBatchMode handles /batch: the following photos are collected and
+// each gets its own caption set, with the questions answered once for all.
+func (b *Bot) startBatchMode(chatID, userID int64) {
+	b.resetState(userID)
+	state := b.getState(userID)
+	state.State = StateCollectingBatch
+
+	msg := b.newMessage(chatID, fmt.Sprintf("📦 **Batch mode**\n\nSend up to %d product photos. I'll ask the questions once and write separate captions for each photo.\n\nTap 'Done' when you've sent them all.", b.maxBatchSize))
+	msg.ReplyMarkup = batchKeyboard
+	if sentMsg, err := b.send(msg); err == nil {
+		state.MessageID = sentMsg.MessageID
+	}
+}
+
+// addBatchPhoto downloads a photo sent in batch mode and adds it to the batch.
+func (b *Bot) addBatchPhoto(message *tgbotapi.Message, state *userState) {
+	if len(state.Batch) >= b.maxBatchSize {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("That's the maximum of %d photos for one batch. Tap 'Done' to continue.", b.maxBatchSize), nil)
+		return
+	}
+
+	photoData, mimeType, err := b.downloadFile(photoFileID(message), true)
+	var unsupported *unsupportedImageError
+	if errors.As(err, &unsupported) {
+		b.sendMessage(message.Chat.ID, unsupported.Error(), nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Error downloading batch photo: %v", err)
+		b.sendMessage(message.Chat.ID, "Sorry, I had trouble downloading that photo. Please send it again.", nil)
+		return
+	}
+
+	item := batchItem{MessageID: message.MessageID}
+	item.PhotoData, item.MimeType, item.ImageNote = b.images.fit(photoData, mimeType)
+	state.Batch = append(state.Batch, item)
+
+	b.editMessage(message.Chat.ID, fmt.Sprintf("📦 **Batch mode**\n\n%d of up to %d photo(s) received. Send more, or tap 'Done'.", len(state.Batch), b.maxBatchSize), batchKeyboard)
+}
+
+// handleBatchCallback handles the "Done" / "Cancel" buttons while collecting.
+func (b *Bot) handleBatchCallback(userID int64, state *userState, data string) {
+	switch data {
+	case "batch:cancel":
+		b.removeInlineKeyboard(userID, state.MessageID)
+		b.resetState(userID)
+		b.sendMessage(userID, "Batch cancelled. Send a photo or /batch to start again.", nil)
+
+	case "batch:done":
+		if len(state.Batch) == 0 {
+			b.sendMessage(userID, "Please send at least one photo first.", nil)
+			return
+		}
+		b.removeInlineKeyboard(userID, state.MessageID)
+		// The first photo drives the normal questions; generateContent sees the batch
+		first := state.Batch[0]
+		b.startWithImage(userID, state, first.PhotoData, first.MimeType, fmt.Sprintf("📦 Got %d photo(s)!", len(state.Batch)))
+	}
+}
+
+// batchRun tracks a batch's progress. Its photos are generated one after
+// another: each job queues the next when it finishes, so a batch holds at
+// most one place in the fair queue and never crowds out other users.
+type batchRun struct {
+	userID        int64
+	state         userState // Shared answers (platforms, tone, services, ...)
+	thinkingMsgID int
+	failed        []int        // 1-based numbers of the photos that failed
+	logger        *slog.Logger // The user's logger when the batch started
+}
+
+// startBatch queues the first photo of a batch.
+func (b *Bot) startBatch(userID int64, snapshot *userState) {
+	thinkingMsg, _ := b.send(b.newMessage(userID, fmt.Sprintf("Got it! ✨ Generating captions for %d photos, one at a time. This might take a few minutes.", len(snapshot.Batch))))
+
+	run := &batchRun{userID: userID, state: *snapshot, thinkingMsgID: thinkingMsg.MessageID, logger: b.userLogger(userID, userID)}
+	if err := b.queue.submit(userID, func() { b.runBatchItem(run, 0) }); err != nil {
+		b.send(tgbotapi.NewDeleteMessage(userID, thinkingMsg.MessageID))
+		b.sendMessage(userID, "You already have several posts being generated. Please wait for them to finish, then try the batch again.", nil)
+		return
+	}
+	b.useQuota(userID, generationCount(snapshot))
+}
+
+// runBatchItem generates and sends the captions for photo i, then queues the
+// next photo or, after the last one, sends the summary.
+func (b *Bot) runBatchItem(run *batchRun, i int) {
+	item := run.state.Batch[i]
+	total := len(run.state.Batch)
+
+	state := run.state
+	state.PhotoData, state.MimeType, state.ImageNote = item.PhotoData, item.MimeType, item.ImageNote
+
+	ctx := withLogger(context.Background(), run.logger.With("generation_id", newGenerationID(), "batch_photo", i+1, "batch_size", total))
+	typingCtx, stopTyping := context.WithCancel(ctx)
+	go b.keepChatAction(typingCtx, run.userID, tgbotapi.ChatTyping)
+	content, err := getB2BContent(ctx, b.llm, state.PhotoData, state.MimeType, b.withFooterLength(b.withRatedExamples(state.generationParams(b.output)), b.store.GetUserSettings(b.memberID(run.userID)).ctaEnabled()), nil)
+	stopTyping()
+	b.stats.record(err)
+	if err != nil {
+		logFrom(ctx).Error("Batch generation failed", "error", err)
+		run.failed = append(run.failed, i+1)
+	} else {
+		b.finishContent(run.userID, &state, content)
+		b.rememberResult(run.userID, state.PhotoData, content)
+		b.recordResult(run.userID, &state, content)
+
+		header := b.newMessage(run.userID, fmt.Sprintf("📦 **Photo %d of %d**", i+1, total))
+		header.ReplyToMessageID = item.MessageID
+		b.send(header)
+		b.sendResults(run.userID, content, nil, nil)
+	}
+
+	if i+1 < total {
+		err := b.queue.submit(run.userID, func() { b.runBatchItem(run, i+1) })
+		if err == nil {
+			return
+		}
+		// Shouldn't happen (this job has already left the queue), but don't lose the summary
+		log.Printf("Error queueing batch photo %d/%d: %v", i+2, total, err)
+		for n := i + 2; n <= total; n++ {
+			run.failed = append(run.failed, n)
+		}
+	}
+
+	b.send(tgbotapi.NewDeleteMessage(run.userID, run.thinkingMsgID))
+	b.sendMessage(run.userID, batchSummary(total, run.failed), nil)
+}
+
+// batchSummary reports how many photos got captions.
+func batchSummary(total int, failed []int) string {
+	if len(failed) == 0 {
+		return fmt.Sprintf("✅ Generated captions for all %d images.", total)
+	}
+	numbers := make([]string, len(failed))
+	for i, n := range failed {
+		numbers[i] = fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("📦 Generated captions for %d/%d images; %d failed (photo %s). Send those again to retry.",
+		total-len(failed), total, len(failed), strings.Join(numbers, ", "))
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Brand Presets ---
+
+// BrandConfig describes the business the captions are written for.
+// Presets are JSON files in BRAND_PRESETS_DIR, named after the preset
+// (e.g. "acme.json" is selected with "/brand acme").
+type BrandConfig struct {
+	Name            string          `json:"name"`            // Full name used in the prompt
+	Description     string          `json:"description"`     // What the business does; defaults to the name
+	Mentions        []string        `json:"mentions"`        // Names the captions should mention
+	Examples        []string        `json:"examples"`        // Gold-standard captions for tone/style
+	Services        []ServiceOption `json:"services"`        // Options on the services keyboard
+	DefaultHashtags []string        `json:"defaultHashtags"` // Always added to the hashtags
+	ContextPresets  []ContextPreset `json:"contextPresets"`  // Quick replies at the context step
+	ContactCTA      string          `json:"contactCta"`      // How to get in touch, woven into each caption
+}
+
+// ContextPreset is a quick-reply button at the "additional context" step.
+// Tapping it uses Text as the context, just as if it had been typed.
+type ContextPreset struct {
+	Label string `json:"label"`
+	Text  string `json:"text"`
+}
+
+// ServiceOption is one button on the services keyboard. Prompt, if set,
+// tells the model what the service means for this brand.
+type ServiceOption struct {
+	Key    string `json:"key"`
+	Label  string `json:"label"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// defaultBrand is used when a user hasn't picked a preset.
+var defaultBrand = &BrandConfig{
+	Name:     "AR Sourcing Bangladesh (arsourcingbd)",
+	Mentions: []string{"AR Sourcing Bangladesh", "arsourcingbd"},
+	Examples: []string{`Custom-Made for Global Brands
+At AR Sourcing Bangladesh, we specialize in manufacturing high-quality women’s shorts...
+🧵 What We Offer:
+✅ Premium fabric & professional stitching
+✅ OEM & Private Label production
+...
+🌍 From Bangladesh to the world...
+📩 Partner with us for your next clothing collection.
+#ApparelManufacturer ... #ARsourcingBangladesh ...`},
+	Services: []ServiceOption{
+		{Key: "OEM", Label: "OEM / Private Label", Prompt: "we manufacture under the client's own brand with full customization"},
+		{Key: "Custom", Label: "Custom Branding", Prompt: "custom labels, tags, prints and packaging in the client's branding"},
+		{Key: "Bulk", Label: "Bulk Manufacturing", Prompt: "large-volume production runs with consistent quality and reliable lead times"},
+		{Key: "Fabric", Label: "Premium Fabric", Prompt: "carefully sourced, high-quality fabrics chosen for comfort and durability"},
+	},
+	DefaultHashtags: []string{"#ARsourcingBangladesh"},
+	ContextPresets: []ContextPreset{
+		{Label: "🆕 New collection", Text: "This is part of our new collection."},
+		{Label: "🏷 Clearance sale", Text: "This is a clearance sale item; limited stock at a special price."},
+		{Label: "🌱 Sustainable line", Text: "This is for our new sustainable line."},
+	},
+}
+
+// description is the business identity for the prompt: the description,
+// or just the name if there is none.
+func (bc *BrandConfig) description() string {
+	if bc.Description != "" {
+		return bc.Description
+	}
+	return bc.Name
+}
+
+// serviceLabel returns the display label for a service key.
+func (bc *BrandConfig) serviceLabel(key string) string {
+	for _, s := range bc.Services {
+		if s.Key == key {
+			return s.Label
+		}
+	}
+	return key
+}
+
+// serviceSnippet describes a service for the prompt: "Label: prompt", or just
+// the label when no prompt is configured.
+func (bc *BrandConfig) serviceSnippet(key string) string {
+	for _, s := range bc.Services {
+		if s.Key == key && s.Prompt != "" {
+			return s.Label + ": " + s.Prompt
+		}
+	}
+	return bc.serviceLabel(key)
+}
+
+// validate checks that a preset has what the prompt and keyboard need.
+func (bc *BrandConfig) validate() error {
+	if strings.TrimSpace(bc.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(bc.Services) == 0 {
+		return fmt.Errorf("at least one service is required")
+	}
+	seen := make(map[string]bool)
+	for _, s := range bc.Services {
+		if s.Key == "" || s.Label == "" {
+			return fmt.Errorf("every service needs a key and a label")
+		}
+		if strings.Contains(s.Key, ":") {
+			return fmt.Errorf("service key %q must not contain ':'", s.Key)
+		}
+		if seen[s.Key] {
+			return fmt.Errorf("duplicate service key %q", s.Key)
+		}
+		seen[s.Key] = true
+	}
+	for _, p := range bc.ContextPresets {
+		if p.Label == "" || p.Text == "" {
+			return fmt.Errorf("every context preset needs a label and a text")
+		}
+	}
+	return nil
+}
+
+// loadBrandPresets reads every *.json file in dir. The preset name is the
+// lowercased file name without the extension; "default" and "custom" are
+// taken by /brand default and /brand custom, so those files are refused
+// rather than left unreachable.
+func loadBrandPresets(dir string) (map[string]*BrandConfig, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing brand presets: %w", err)
+	}
+
+	presets := make(map[string]*BrandConfig)
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading brand preset %s: %w", path, err)
+		}
+		var bc BrandConfig
+		if err := json.Unmarshal(raw, &bc); err != nil {
+			return nil, fmt.Errorf("error parsing brand preset %s: %w", path, err)
+		}
+		if err := bc.validate(); err != nil {
+			return nil, fmt.Errorf("invalid brand preset %s: %w", path, err)
+		}
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		if name == "default" || name == customBrandPreset {
+			return nil, fmt.Errorf("invalid brand preset %s: %q is a reserved name, rename the file", path, name)
+		}
+		presets[name] = &bc
+	}
+	log.Printf("Loaded %d brand preset(s) from %s", len(presets), dir)
+	return presets, nil
+}
+
+// SetUserBrand stores a user's active preset ("" means the default brand).
+func (s *Store) SetUserBrand(userID int64, preset string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if preset == "" {
+		delete(s.data.UserBrands, userID)
+	} else {
+		s.data.UserBrands[userID] = preset
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// UserBrand returns a user's active preset name, or "" for the default brand.
+func (s *Store) UserBrand(userID int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.UserBrands[userID]
+}
+
+// brandFor returns the brand a user's next job should use.
+// A preset that has since been removed falls back to the default.
+func (b *Bot) brandFor(userID int64) *BrandConfig {
+	userID = b.memberID(userID)
+	preset := b.store.UserBrand(userID)
+	if preset == customBrandPreset {
+		if bc, ok := b.store.CustomBrand(userID); ok {
+			return bc
+		}
+	}
+	if bc, ok := b.brands[preset]; ok {
+		return bc
+	}
+	return b.defaultBrand()
+}
+
+// brandNames returns the preset names in alphabetical order.
+func (b *Bot) brandNames() []string {
+	names := make([]string, 0, len(b.brands))
+	for name := range b.brands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleBrandCommand handles "/brand" (show), "/brand <name>", "/brand default"
+// and "/brand set <field> <value>" for the user's own brand.
+func (b *Bot) handleBrandCommand(chatID, userID int64, arg string) {
+	if sub, rest, _ := strings.Cut(strings.TrimSpace(arg), " "); strings.EqualFold(sub, "set") {
+		b.handleBrandSet(chatID, userID, rest)
+		return
+	}
+
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	switch {
+	case arg == "":
+		current := b.store.UserBrand(userID)
+		if current == customBrandPreset {
+			bc := b.brandFor(userID)
+			b.sendMessage(chatID, fmt.Sprintf("Your active brand is your own, **%s**.\n\n%s\n\nUse `/brand set <field> <value>` to change it, or `/brand default` to switch back.",
+				bc.Name, formatCustomBrand(bc)), nil)
+			return
+		}
+		if _, ok := b.brands[current]; !ok {
+			current = "default"
+		}
+		b.sendMessage(chatID, fmt.Sprintf("Your active brand is **%s** (%s).\n\nUse `/brand <name>` to switch, or /brands to see them all.",
+			current, b.brandFor(userID).Name), nil)
+	case arg == "default":
+		b.store.SetUserBrand(userID, "")
+		b.sendMessage(chatID, fmt.Sprintf("✅ Switched back to the default brand: **%s**.", defaultBrand.Name), nil)
+	case arg == customBrandPreset:
+		bc, ok := b.store.CustomBrand(userID)
+		if !ok {
+			b.sendMessage(chatID, customBrandUsage, nil)
+			return
+		}
+		b.store.SetUserBrand(userID, customBrandPreset)
+		b.sendMessage(chatID, fmt.Sprintf("✅ Your next posts will be written for **%s**.", bc.Name), nil)
+	default:
+		bc, ok := b.brands[arg]
+		if !ok {
+			b.sendMessage(chatID, fmt.Sprintf("I don't know a brand called `%s`. Use /brands to see the available ones.", arg), nil)
+			return
+		}
+		b.store.SetUserBrand(userID, arg)
+		b.sendMessage(chatID, fmt.Sprintf("✅ Your next posts will be written for **%s**.", bc.Name), nil)
+	}
+}
+
+// listBrands handles /brands.
+func (b *Bot) listBrands(chatID, userID int64) {
+	text := "🏷 **Available brands:**\n\n"
+	text += fmt.Sprintf("• `default` — %s\n", defaultBrand.Name)
+	if bc, ok := b.store.CustomBrand(userID); ok {
+		text += fmt.Sprintf("• `%s` — %s (your own)\n", customBrandPreset, bc.Name)
+	}
+	for _, name := range b.brandNames() {
+		if name == customBrandPreset {
+			continue // Hidden by the user's own brand
+		}
+		text += fmt.Sprintf("• `%s` — %s\n", name, b.brands[name].Name)
+	}
+	text += "\nUse `/brand <name>` to switch, or `/brand set name <your business>` to set up your own."
+	b.sendMessage(chatID, text, nil)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadBrandPresetsRefusesReservedNames(t *testing.T) {
+	const preset = `{"name": "Acme Apparel", "services": [{"key": "OEM", "label": "OEM / Private Label"}]}`
+	for _, file := range []string{"acme.json", "Default.json", "custom.json"} {
+		t.Run(file, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(preset), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			presets, err := loadBrandPresets(dir)
+			if file == "acme.json" {
+				if err != nil || presets["acme"] == nil {
+					t.Errorf("loadBrandPresets = %v, %v; want the acme preset", presets, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "reserved name") {
+				t.Errorf("loadBrandPresets = %v, %v; want %s refused", presets, err, file)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Result Cache ---
+
+// cachedNote tells the user a result came from the cache.
+const cachedNote = "♻️ Served from cache: you asked for this exact photo with the same answers before. Tap 🔄 Regenerate for fresh captions."
+
+// resultCache stores finished results by resultCacheKey. Results are stored
+// before the user's own settings (emoji policy, contact footer) are applied.
+type resultCache interface {
+	get(key string) (*GeneratedContent, bool)
+	set(key string, content *GeneratedContent)
+}
+
+// newResultCache builds the cache from the configuration: Redis if
+// REDIS_URL is set, else an in-memory LRU. It returns nil (no caching) if
+// RESULT_CACHE_SIZE is 0.
+func newResultCache(cfg Config) (resultCache, error) {
+	if cfg.ResultCacheSize <= 0 {
+		return nil, nil
+	}
+	if cfg.RedisURL != "" {
+		return newRedisCache(cfg.RedisURL, cfg.ResultCacheTTL)
+	}
+	return newLRUCache(cfg.ResultCacheSize, cfg.ResultCacheTTL), nil
+}
+
+// resultCacheKey is the SHA-256 of the image and everything else that goes
+// into the prompt. Rated examples are left out: they change as ratings come
+// in, and would otherwise make every key unique.
+func resultCacheKey(imageData []byte, params GenerationParams) string {
+	h := sha256.New()
+	h.Write(imageData)
+	for _, album := range params.AlbumImages {
+		h.Write([]byte(album.Data))
+	}
+	params.AlbumImages, params.RatedExamples = nil, nil
+	// The brand pointer would encode as its contents, which is what we want
+	raw, _ := json.Marshal(params)
+	h.Write(raw)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cloneContent deep-copies a result, so the copy in the cache isn't changed
+// by what happens to the one sent.
+func cloneContent(content *GeneratedContent) *GeneratedContent {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil
+	}
+	var copied GeneratedContent
+	if err := json.Unmarshal(raw, &copied); err != nil {
+		return nil
+	}
+	return &copied
+}
+
+// cachedResult returns a copy of the cached result for key, if any.
+func (b *Bot) cachedResult(key string) (*GeneratedContent, bool) {
+	if b.cache == nil {
+		return nil, false
+	}
+	content, ok := b.cache.get(key)
+	if !ok {
+		return nil, false
+	}
+	copied := cloneContent(content)
+	return copied, copied != nil
+}
+
+// cacheResult stores a copy of a finished result under key.
+func (b *Bot) cacheResult(key string, content *GeneratedContent) {
+	if b.cache == nil || content == nil {
+		return
+	}
+	copied := cloneContent(content)
+	if copied == nil {
+		return
+	}
+	copied.Usage = UsageMetadata{} // Serving it again costs nothing
+	b.cache.set(key, copied)
+}
+
+// serveCachedResult delivers the cached result for a job, if there is one,
+// without queueing it or using the user's quota. It returns true if it did.
+func (b *Bot) serveCachedResult(userID int64, state *userState) bool {
+	if b.cache == nil || state.SkipCache {
+		return false
+	}
+	params := b.withFooterLength(state.generationParams(b.output), b.store.GetUserSettings(b.memberID(userID)).ctaEnabled())
+	content, ok := b.cachedResult(resultCacheKey(state.PhotoData, params))
+	if !ok {
+		return false
+	}
+	b.userLogger(userID, userID).Info("Serving a cached result")
+
+	content.Notes = append(content.Notes, cachedNote)
+	b.finishContent(userID, state, content)
+	live := b.getState(userID)
+	live.LastRequest = requestSnapshot(state)
+	b.deliverResults(userID, live, content)
+	if content.Feedback != nil {
+		b.sendMessage(userID, strings.TrimPrefix(feedbackSection(content), "\n\n"), nil)
+	}
+	b.recordResult(userID, state, content)
+	return true
+}
+
+// --- In-Memory LRU ---
+
+// lruCache keeps the most recently used results in memory.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration // 0 keeps entries until evicted
+	order   *list.List    // Front is the most recently used
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	content *GeneratedContent
+	stored  time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{size: size, ttl: ttl, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get implements resultCache.
+func (c *lruCache) get(key string) (*GeneratedContent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if c.ttl > 0 && time.Since(entry.stored) > c.ttl {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.content, true
+}
+
+// set implements resultCache.
+func (c *lruCache) set(key string, content *GeneratedContent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = &lruEntry{key: key, content: content, stored: time.Now()}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, content: content, stored: time.Now()})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// --- Redis Result Cache ---
+
+// redisKeyPrefix namespaces our keys in a shared Redis.
+const redisKeyPrefix = "caption-bot:result:"
+
+// redisCache stores results in Redis, so they are shared between instances
+// and survive a restart. Errors are logged and treated as misses.
+type redisCache struct {
+	*redisClient
+	ttl time.Duration
+}
+
+// newRedisCache connects the cache to REDIS_URL.
+func newRedisCache(rawURL string, ttl time.Duration) (*redisCache, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	return &redisCache{redisClient: client, ttl: ttl}, nil
+}
+
+// get implements resultCache.
+func (c *redisCache) get(key string) (*GeneratedContent, bool) {
+	reply, err := c.do("GET", redisKeyPrefix+key)
+	if err != nil {
+		log.Printf("Redis GET failed: %v", err)
+		return nil, false
+	}
+	raw, ok := reply.(string)
+	if !ok {
+		return nil, false // nil reply: a miss
+	}
+	var content GeneratedContent
+	if err := json.Unmarshal([]byte(raw), &content); err != nil {
+		log.Printf("Error parsing cached result: %v", err)
+		return nil, false
+	}
+	return &content, true
+}
+
+// set implements resultCache.
+func (c *redisCache) set(key string, content *GeneratedContent) {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return
+	}
+	args := []string{"SET", redisKeyPrefix + key, string(raw)}
+	if c.ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(c.ttl.Milliseconds(), 10))
+	}
+	if _, err := c.do(args...); err != nil {
+		log.Printf("Redis SET failed: %v", err)
+	}
+}
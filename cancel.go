@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Cancelling In-Flight Generation ---
+
+// cancelGenKeyboard is attached to the "thinking" message.
+var cancelGenKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✖️ Cancel", "control:cancel_gen"),
+	),
+)
+
+// jobKey identifies a generation job by its "thinking" message.
+type jobKey struct {
+	userID        int64
+	thinkingMsgID int
+}
+
+// startJob registers a queued generation and returns the context it must run under.
+func (b *Bot) startJob(key jobKey) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+	b.jobs[key] = cancel
+	return ctx
+}
+
+// finishJob unregisters a job once it has a result. It returns false if the
+// job was cancelled first, in which case the result must not be sent.
+// Cancel and finish both take jobsMu, so exactly one of them wins.
+func (b *Bot) finishJob(key jobKey) bool {
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+
+	cancel, ok := b.jobs[key]
+	if !ok {
+		return false
+	}
+	cancel() // Release the context's resources
+	delete(b.jobs, key)
+	return true
+}
+
+// cancelJob stops a queued or running job. It returns false if the job has
+// already finished (or was never started).
+func (b *Bot) cancelJob(key jobKey) bool {
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+
+	cancel, ok := b.jobs[key]
+	if !ok {
+		return false
+	}
+	cancel()
+	delete(b.jobs, key)
+	return true
+}
+
+// handleCancelGeneration handles the "Cancel" button on the thinking message.
+func (b *Bot) handleCancelGeneration(query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		return
+	}
+	userID := query.From.ID
+	key := jobKey{userID: userID, thinkingMsgID: query.Message.MessageID}
+
+	if !b.cancelJob(key) {
+		// Results are already on their way; just drop the button
+		b.removeInlineKeyboard(userID, key.thinkingMsgID)
+		return
+	}
+
+	b.untrackQueuedJob(key) // Free its place in the queue if it hasn't started
+	log.Printf("User %d cancelled generation", userID)
+	b.send(tgbotapi.NewDeleteMessage(userID, key.thinkingMsgID))
+	b.resetState(userID)
+	b.sendMessage(userID, "Cancelled. ✖️ Send a new photo whenever you're ready, or use /same to try again.", nil)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// thinkingMessageID finds the "thinking" message, the one with the Cancel button.
+func thinkingMessageID(t *testing.T, fake *fakeTelegram, userID int64) int {
+	t.Helper()
+	for _, call := range fake.Calls("sendMessage") {
+		if call.chatID() == userID && strings.Contains(call.Params.Get("reply_markup"), "control:cancel_gen") {
+			return call.MessageID
+		}
+	}
+	t.Fatal("no thinking message was sent")
+	return 0
+}
+
+// tapCancel taps Cancel on the given thinking message.
+func tapCancel(b *Bot, userID int64, thinkingMsgID int) {
+	query := callbackQuery(userID, "control:cancel_gen")
+	query.Message = &tgbotapi.Message{MessageID: thinkingMsgID, Chat: testChat(userID)}
+	b.handleCallbackQuery(query)
+}
+
+// queueGeneration answers every question and queues the generation.
+func queueGeneration(t *testing.T, b *Bot, userID int64) {
+	state := b.getState(userID)
+	state.PhotoData, state.MimeType = testJPEG(t, 600, 600), "image/jpeg"
+	state.Platforms = []string{"Instagram"}
+	b.generateContent(userID)
+}
+
+func TestCancelMidGenerationSendsNoResults(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	b, fake := newTestBot(t)
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		select {
+		case started <- struct{}{}:
+			<-release // The captions arrive after all, despite the cancelled context
+		default:
+		}
+		return http.StatusOK, captionsReply
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 401
+	queueGeneration(t, b, userID)
+	<-started
+	tapCancel(b, userID, thinkingMessageID(t, fake, userID))
+	close(release)
+	flushQueue(b.queue)
+
+	texts := fake.Texts(userID)
+	if len(messagesWith(texts, "Cancelled")) != 1 {
+		t.Errorf("no cancellation notice in %q", texts)
+	}
+	if len(messagesWith(texts, "First caption")) != 0 {
+		t.Errorf("results sent after cancelling: %q", texts)
+	}
+	if b.getState(userID).LastResult != nil {
+		t.Error("the cancelled result was kept for the result buttons")
+	}
+}
+
+func TestCancelWhileQueued(t *testing.T) {
+	b, fake := newTestBot(t)
+	var called func() []string
+	b.llm, called = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, captionsReply
+	})
+	b.queue = newFairQueue(0)
+
+	const userID = 402
+	queueGeneration(t, b, userID) // No workers yet, so it waits
+	tapCancel(b, userID, thinkingMessageID(t, fake, userID))
+	b.queue.start(1)
+	flushQueue(b.queue)
+
+	if n := len(called()); n != 0 {
+		t.Errorf("%d Gemini call(s) for a job cancelled in the queue", n)
+	}
+	if len(messagesWith(fake.Texts(userID), "First caption")) != 0 {
+		t.Error("results sent after cancelling")
+	}
+}
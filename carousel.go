@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Carousel Result View ---
+
+// Result styles (RESULT_STYLE).
+const (
+	resultStyleMessages = "messages" // One message per caption, plus hashtags/feedback
+	resultStyleCarousel = "carousel" // One message, browsed with ◀ ▶ buttons
+)
+
+// carouselItem points at one caption inside a GeneratedContent.
+type carouselItem struct {
+	result  int // Index into GeneratedContent.Results
+	caption int // Index into that result's Captions
+}
+
+// carouselItems flattens every platform's captions into one browsable list.
+func carouselItems(content *GeneratedContent) []carouselItem {
+	var items []carouselItem
+	for r, result := range content.Results {
+		for c := range result.Captions {
+			items = append(items, carouselItem{result: r, caption: c})
+		}
+	}
+	return items
+}
+
+// sendCarousel sends the results as a single message showing the first caption.
+// The user's state keeps the content and position for the navigation buttons.
+func (b *Bot) sendCarousel(userID int64, state *userState, content *GeneratedContent) {
+	state.LastResult = content
+	state.CarouselIndex = 0
+	state.CarouselHashtags = false
+
+	text, markup := renderCarousel(content, 0, false, b.postRows(userID))
+	msg := b.newMessage(userID, text)
+	msg.ReplyMarkup = markup
+
+	sentMsg, err := b.send(msg)
+	if err != nil {
+		log.Printf("Error sending carousel: %v", err)
+		return
+	}
+	state.CarouselMessageID = sentMsg.MessageID
+}
+
+// handleCarouselCallback handles the ◀ / ▶ / hashtags buttons.
+func (b *Bot) handleCarouselCallback(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	state := b.getState(userID)
+
+	// Only the newest carousel can be browsed; older ones (or any after a
+	// restart or /cancel) no longer have their content in memory.
+	if state.LastResult == nil || query.Message == nil || query.Message.MessageID != state.CarouselMessageID {
+		if query.Message != nil {
+			b.removeInlineKeyboard(userID, query.Message.MessageID)
+		}
+		b.sendMessage(userID, "These results have expired. Send a photo to generate new ones. 📸", nil)
+		return
+	}
+
+	total := len(carouselItems(state.LastResult))
+	switch query.Data {
+	case "nav:prev":
+		state.CarouselIndex = (state.CarouselIndex - 1 + total) % total
+	case "nav:next":
+		state.CarouselIndex = (state.CarouselIndex + 1) % total
+	case "nav:hashtags":
+		state.CarouselHashtags = !state.CarouselHashtags
+	default:
+		return
+	}
+
+	text, markup := renderCarousel(state.LastResult, state.CarouselIndex, state.CarouselHashtags, b.postRows(userID))
+	b.editMessageID(userID, state.CarouselMessageID, text, markup)
+}
+
+// renderCarousel builds the text and buttons for one caption, with the
+// post buttons in postRows.
+func renderCarousel(content *GeneratedContent, index int, showHashtags bool, postRows [][]tgbotapi.InlineKeyboardButton) (string, tgbotapi.InlineKeyboardMarkup) {
+	items := carouselItems(content)
+	item := items[index]
+	result := content.Results[item.result]
+
+	var sb strings.Builder
+	if len(content.Results) > 1 {
+		fmt.Fprintf(&sb, "📣 **%s** · ", platformLabels[result.Platform])
+	}
+	caption := result.Captions[item.caption]
+	fmt.Fprintf(&sb, "**%s**%s\n\n%s", result.optionLabel(item.caption), charCountLabel(caption, result.Platform), caption)
+
+	hashtagButton := "#️⃣ Show hashtags"
+	if showHashtags {
+		fmt.Fprintf(&sb, "\n\n👇 **%s** 👇\n`%s`", tr(content.Language, "hashtags"), strings.Join(result.Hashtags, " "))
+		sb.WriteString(feedbackSection(content))
+		hashtagButton = "#️⃣ Hide hashtags"
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("◀", "nav:prev"),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", index+1, len(items)), "nav:noop"),
+			tgbotapi.NewInlineKeyboardButtonData("▶", "nav:next"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(hashtagButton, "nav:hashtags"),
+		),
+	}
+	rows = append(rows, ratingRow)
+	rows = append(rows, postRows...)
+	rows = append(rows, resultKeyboard.InlineKeyboard...)
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
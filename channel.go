@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Channel Publishing ---
+
+// maxPhotoCaptionLength is Telegram's limit for a photo caption; longer
+// captions are posted as a separate message under the photo.
+const maxPhotoCaptionLength = 1024
+
+// LinkedChannel is the channel a user's captions can be posted to.
+type LinkedChannel struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// channelPostMarkup is attached to each caption when the user has a channel.
+var channelPostMarkup = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📢 Post to channel", "channel:post"),
+		tgbotapi.NewInlineKeyboardButtonData("🗓 Schedule post", "channel:schedule"),
+	),
+)
+
+// SetChannel links a channel to a user.
+func (s *Store) SetChannel(userID int64, channel LinkedChannel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Channels[userID] = channel
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// RemoveChannel unlinks a user's channel. It returns false if there was none.
+func (s *Store) RemoveChannel(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.Channels[userID]; !ok {
+		return false
+	}
+	delete(s.data.Channels, userID)
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+	return true
+}
+
+// Channel returns a user's linked channel.
+func (s *Store) Channel(userID int64) (LinkedChannel, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel, ok := s.data.Channels[userID]
+	return channel, ok
+}
+
+// captionMarkup is the keyboard for each caption message: the rating
+// buttons, and the post buttons the user may use.
+func (b *Bot) captionMarkup(userID int64) interface{} {
+	rows := append([][]tgbotapi.InlineKeyboardButton{ratingRow}, b.postRows(userID)...)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// hasChannel reports whether the user has connected a channel.
+func (b *Bot) hasChannel(userID int64) bool {
+	_, ok := b.store.Channel(userID)
+	return ok
+}
+
+// channelChatConfig addresses a channel by "@username" or numeric ID.
+func channelChatConfig(arg string) (tgbotapi.ChatConfig, error) {
+	if strings.HasPrefix(arg, "@") && len(arg) > 1 {
+		return tgbotapi.ChatConfig{SuperGroupUsername: arg}, nil
+	}
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return tgbotapi.ChatConfig{}, fmt.Errorf("%q is not a @username or a channel ID", arg)
+	}
+	return tgbotapi.ChatConfig{ChatID: id}, nil
+}
+
+// verifyChannel checks that arg is a channel the user administers and the
+// bot can post to.
+func (b *Bot) verifyChannel(userID int64, arg string) (LinkedChannel, error) {
+	config, err := channelChatConfig(arg)
+	if err != nil {
+		return LinkedChannel{}, err
+	}
+	chat, err := b.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: config})
+	if err != nil {
+		return LinkedChannel{}, fmt.Errorf("I can't see that channel. Add me as an admin first")
+	}
+	if !chat.IsChannel() {
+		return LinkedChannel{}, fmt.Errorf("%s is not a channel", chat.Title)
+	}
+
+	user, err := b.api.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: userID}})
+	if err != nil || !(user.IsCreator() || user.IsAdministrator()) {
+		return LinkedChannel{}, fmt.Errorf("only admins of %s can connect it", chat.Title)
+	}
+	bot, err := b.api.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: b.api.Self.ID}})
+	if err != nil || !bot.IsAdministrator() || !bot.CanPostMessages {
+		return LinkedChannel{}, fmt.Errorf("I need to be an admin of %s with permission to post messages", chat.Title)
+	}
+	return LinkedChannel{ID: chat.ID, Title: chat.Title}, nil
+}
+
+// handleConnectChannel handles "/connectchannel [@channel]".
+func (b *Bot) handleConnectChannel(chatID, userID int64, arg string) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		text := "📢 To post captions straight to your channel, add me to it as an admin (with \"Post messages\"), then send `/connectchannel @yourchannel` (or the channel's numeric ID)."
+		if channel, ok := b.store.Channel(userID); ok {
+			text = fmt.Sprintf("📢 Captions are posted to **%s**. Use `/connectchannel @otherchannel` to change it, or /disconnectchannel to stop.", channel.Title)
+		}
+		b.sendMessage(chatID, text, nil)
+		return
+	}
+
+	channel, err := b.verifyChannel(userID, arg)
+	if err != nil {
+		b.sendMessage(chatID, fmt.Sprintf("Sorry, I couldn't connect that channel: %s.", err.Error()), nil)
+		return
+	}
+	b.store.SetChannel(userID, channel)
+	b.store.MarkChatActive(channel.ID) // In case an earlier post found us removed
+	b.sendMessage(chatID, fmt.Sprintf("✅ Connected **%s**. Tap 📢 **Post to channel** under a caption to publish it with your photo.", channel.Title), nil)
+}
+
+// handleDisconnectChannel handles /disconnectchannel.
+func (b *Bot) handleDisconnectChannel(chatID, userID int64) {
+	if !b.store.RemoveChannel(userID) {
+		b.sendMessage(chatID, "You don't have a channel connected.", nil)
+		return
+	}
+	b.sendMessage(chatID, "✅ Channel disconnected.", nil)
+}
+
+// postedCaption finds the caption shown on the message whose post button
+// was tapped. Only captions of the latest result (or their latest edit) can
+// be posted, since that's the only photo we keep.
+func (b *Bot) postedCaption(query *tgbotapi.CallbackQuery, state *userState) (captionRef, bool) {
+	content := state.LastResult
+	if content == nil || query.Message == nil {
+		return captionRef{}, false
+	}
+	if query.Message.MessageID == state.CarouselMessageID {
+		item := carouselItems(content)[state.CarouselIndex]
+		return captionRefFor(content, item.result, item.caption), true
+	}
+	ref, ok := b.store.ResultMessage(query.From.ID, query.Message.MessageID)
+	if !ok {
+		return captionRef{}, false
+	}
+	if state.Refining != nil && ref.Text == state.Refining.Text {
+		return ref, true // The latest edit of one of its captions
+	}
+	for _, result := range content.Results {
+		for _, caption := range result.Captions {
+			if caption == ref.Text {
+				ref.Platform = result.Platform
+				return ref, true
+			}
+		}
+	}
+	return captionRef{}, false
+}
+
+// handleChannelPost posts the photo with the chosen caption to the user's channel.
+func (b *Bot) handleChannelPost(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	state := b.getState(userID)
+
+	channel, ok := b.store.Channel(userID)
+	if !ok {
+		b.sendMessage(userID, "You don't have a channel connected. Use /connectchannel to set one up.", nil)
+		return
+	}
+	ref, ok := b.postedCaption(query, state)
+	if !ok || state.LastRequest == nil || len(state.LastRequest.PhotoData) == 0 {
+		b.sendMessage(userID, "Sorry, I only keep the photo of your latest result, so I can't post this one. Send the photo again to get new captions. 📸", nil)
+		return
+	}
+
+	if err := b.postToChannel(channel, state.LastRequest.PhotoData, ref.Text); err != nil {
+		log.Printf("Error posting to channel %d for user %d: %v", channel.ID, userID, err)
+		b.sendMessage(userID, "Sorry, posting to your channel failed: "+channelPostError(err), nil)
+		return
+	}
+	b.sendMessage(userID, fmt.Sprintf("📢 Posted to **%s**!", channel.Title), nil)
+}
+
+// postToChannel posts a photo with a caption to a channel.
+func (b *Bot) postToChannel(channel LinkedChannel, photoData []byte, caption string) error {
+	photo := tgbotapi.NewPhoto(channel.ID, tgbotapi.FileBytes{Name: "product", Bytes: photoData})
+	long := len([]rune(caption)) > maxPhotoCaptionLength
+	if !long {
+		photo.Caption = caption
+	}
+	_, err := b.send(photo)
+	if err == nil && long {
+		_, err = b.send(tgbotapi.NewMessage(channel.ID, caption))
+	}
+	return err
+}
+
+// channelPostError tells the user what to do about a failed channel post.
+func channelPostError(err error) string {
+	if errors.Is(err, errChatInactive) {
+		return "I can't post there any more. Check I'm still an admin of the channel, then run /connectchannel again."
+	}
+	return "please try again."
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Blocked & Migrated Chats ---
+
+// errChatInactive is returned instead of sending to a chat that blocked the
+// bot or whose user was deactivated.
+var errChatInactive = errors.New("chat is inactive")
+
+// sendErrorAction is what a failed send tells us to do about the chat.
+type sendErrorAction int
+
+const (
+	sendErrorNone     sendErrorAction = iota // An ordinary failure
+	sendErrorInactive                        // Stop sending to this chat
+	sendErrorMigrated                        // Resend to the new chat ID
+)
+
+// inactiveChatErrors are the 403 descriptions that mean we can't reach the
+// chat until the user comes back.
+var inactiveChatErrors = []string{
+	"bot was blocked by the user",
+	"user is deactivated",
+	"bot was kicked",
+}
+
+// classifySendError maps a Telegram API error to the action to take. For a
+// migrated group it also returns the new chat ID.
+func classifySendError(err error) (sendErrorAction, int64) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return sendErrorNone, 0
+	}
+	if apiErr.MigrateToChatID != 0 {
+		return sendErrorMigrated, apiErr.MigrateToChatID
+	}
+	if apiErr.Code == 403 {
+		description := strings.ToLower(apiErr.Message)
+		for _, s := range inactiveChatErrors {
+			if strings.Contains(description, s) {
+				return sendErrorInactive, 0
+			}
+		}
+	}
+	return sendErrorNone, 0
+}
+
+// MarkChatInactive records that a chat can't be reached.
+func (s *Store) MarkChatInactive(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.InactiveChats[chatID] = time.Now()
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// MarkChatActive clears the inactive mark, e.g. once the user writes again.
+func (s *Store) MarkChatActive(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.InactiveChats[chatID]; !ok {
+		return
+	}
+	delete(s.data.InactiveChats, chatID)
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// IsChatInactive reports whether a chat has blocked the bot.
+func (s *Store) IsChatInactive(chatID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.data.InactiveChats[chatID]
+	return ok
+}
+
+// SetChatMigration records that a group chat moved to a new ID.
+func (s *Store) SetChatMigration(from, to int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.ChatMigrations[from] = to
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// CurrentChatID follows any recorded migration of a chat.
+func (s *Store) CurrentChatID(chatID int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if to, ok := s.data.ChatMigrations[chatID]; ok {
+		return to
+	}
+	return chatID
+}
+
+// chattableChatID returns the chat a request targets, or 0 if it has none
+// (e.g. callback answers) or is a kind we don't redirect.
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return c.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		return c.ChatID
+	case tgbotapi.DocumentConfig:
+		return c.ChatID
+	case tgbotapi.PhotoConfig:
+		return c.ChatID
+	}
+	return 0
+}
+
+// retargetChattable returns a copy of c addressed to chatID.
+func retargetChattable(c tgbotapi.Chattable, chatID int64) tgbotapi.Chattable {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		c.ChatID = chatID
+		return c
+	case tgbotapi.EditMessageTextConfig:
+		c.ChatID = chatID
+		return c
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		c.ChatID = chatID
+		return c
+	case tgbotapi.DeleteMessageConfig:
+		c.ChatID = chatID
+		return c
+	case tgbotapi.DocumentConfig:
+		c.ChatID = chatID
+		return c
+	case tgbotapi.PhotoConfig:
+		c.ChatID = chatID
+		return c
+	}
+	return c
+}
+
+// send is the single path to Telegram for everything the bot sends. It
+// skips chats that blocked the bot, and follows group chats that migrated
+// to a supergroup, resending once to the new ID. In dry-run mode nothing
+// is sent; the request is only logged.
+func (b *Bot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	c = b.toGroupChat(c)
+	chatID := chattableChatID(c)
+	if chatID != 0 {
+		if current := b.store.CurrentChatID(chatID); current != chatID {
+			chatID = current
+			c = retargetChattable(c, chatID)
+		}
+		if b.store.IsChatInactive(chatID) {
+			return tgbotapi.Message{}, errChatInactive
+		}
+	}
+
+	if b.dryRun {
+		return b.dryRunSend(c)
+	}
+
+	msg, err := b.api.Send(c)
+	if err == nil || chatID == 0 {
+		return msg, err
+	}
+
+	switch action, newChatID := classifySendError(err); action {
+	case sendErrorInactive:
+		log.Printf("Chat %d is unreachable (%v); not sending to it until it writes again", chatID, err)
+		b.store.MarkChatInactive(chatID)
+		return msg, errChatInactive
+	case sendErrorMigrated:
+		log.Printf("Chat %d migrated to %d, resending", chatID, newChatID)
+		b.store.SetChatMigration(chatID, newChatID)
+		return b.api.Send(retargetChattable(c, newChatID))
+	}
+	b.reportSendError(chatID, err)
+	return msg, err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestClassifySendError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantAction sendErrorAction
+		wantChatID int64
+	}{
+		{"blocked", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, sendErrorInactive, 0},
+		{"deactivated", &tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}, sendErrorInactive, 0},
+		{"kicked from a group", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was kicked from the group chat"}, sendErrorInactive, 0},
+		{"migrated", &tgbotapi.Error{Code: 400, Message: "Bad Request: group chat was upgraded to a supergroup chat",
+			ResponseParameters: tgbotapi.ResponseParameters{MigrateToChatID: -1001234}}, sendErrorMigrated, -1001234},
+		{"wrapped", fmt.Errorf("sending: %w", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}), sendErrorInactive, 0},
+		{"other 403", &tgbotapi.Error{Code: 403, Message: "Forbidden: not enough rights to send photos"}, sendErrorNone, 0},
+		{"bad request", &tgbotapi.Error{Code: 400, Message: "Bad Request: message text is empty"}, sendErrorNone, 0},
+		{"network error", errors.New("connection reset by peer"), sendErrorNone, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, chatID := classifySendError(tt.err)
+			if action != tt.wantAction || chatID != tt.wantChatID {
+				t.Errorf("classifySendError(%v) = %d, %d; want %d, %d", tt.err, action, chatID, tt.wantAction, tt.wantChatID)
+			}
+		})
+	}
+}
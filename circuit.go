@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// --- Circuit Breaker ---
+
+// errCircuitOpen is returned without touching the network while the breaker is open.
+var errCircuitOpen = errors.New("the AI service is temporarily unavailable")
+
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // Normal operation
+	circuitOpen                         // Failing fast until the cooldown passes
+	circuitHalfOpen                     // Letting a single probe request through
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calling Gemini during a sustained outage.
+// After threshold consecutive failures it opens for cooldown, then half-opens
+// to let one probe through: success closes it again, failure re-opens it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int // 0 disables the breaker
+	cooldown  time.Duration
+	state     circuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool             // A half-open probe is in flight
+	now       func() time.Time // Swappable clock
+}
+
+// newCircuitBreaker creates a closed breaker.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a request may go out now.
+func (cb *circuitBreaker) allow() bool {
+	if cb == nil || cb.threshold <= 0 {
+		return true
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.setState(circuitHalfOpen)
+		cb.probing = true
+		return true
+	case circuitHalfOpen:
+		// Only one probe at a time; everyone else keeps failing fast
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// recordSuccess closes the breaker and clears the failure count.
+func (cb *circuitBreaker) recordSuccess() {
+	if cb == nil || cb.threshold <= 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.probing = false
+	cb.setState(circuitClosed)
+}
+
+// recordFailure counts an outage-type failure, opening the breaker when
+// the threshold is reached or when a half-open probe fails.
+func (cb *circuitBreaker) recordFailure() {
+	if cb == nil || cb.threshold <= 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == circuitHalfOpen || cb.failures >= cb.threshold {
+		cb.openedAt = cb.now()
+		cb.setState(circuitOpen)
+	}
+}
+
+// setState changes state and logs transitions. The caller must hold cb.mu.
+func (cb *circuitBreaker) setState(s circuitState) {
+	if cb.state != s {
+		log.Printf("Gemini circuit breaker: %s -> %s", cb.state, s)
+		cb.state = s
+	}
+}
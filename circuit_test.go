@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// breakerAt is a breaker whose clock reads *clock.
+func breakerAt(clock *time.Time, threshold int, cooldown time.Duration) *circuitBreaker {
+	cb := newCircuitBreaker(threshold, cooldown)
+	cb.now = func() time.Time { return *clock }
+	return cb
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb := breakerAt(&clock, 3, time.Minute)
+
+	// Closed: failures below the threshold still let requests through
+	for i := 0; i < 2; i++ {
+		if !cb.allow() {
+			t.Fatalf("request %d refused while closed", i+1)
+		}
+		cb.recordFailure()
+	}
+	if cb.state != circuitClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", cb.state)
+	}
+
+	// Open: the third failure trips it, and it fails fast during the cooldown
+	cb.allow()
+	cb.recordFailure()
+	if cb.state != circuitOpen {
+		t.Fatalf("state after 3 failures = %s, want open", cb.state)
+	}
+	clock = clock.Add(59 * time.Second)
+	if cb.allow() {
+		t.Error("request allowed before the cooldown passed")
+	}
+
+	// Half-open: after the cooldown one probe goes out, the rest still wait
+	clock = clock.Add(time.Second)
+	if !cb.allow() {
+		t.Fatal("probe refused after the cooldown")
+	}
+	if cb.state != circuitHalfOpen {
+		t.Fatalf("state during the probe = %s, want half-open", cb.state)
+	}
+	if cb.allow() {
+		t.Error("second request allowed while the probe is in flight")
+	}
+
+	// Closed again once the probe succeeds, with the failure count reset
+	cb.recordSuccess()
+	if cb.state != circuitClosed {
+		t.Fatalf("state after a successful probe = %s, want closed", cb.state)
+	}
+	cb.recordFailure()
+	if cb.state != circuitClosed || !cb.allow() {
+		t.Error("a single failure after recovering re-opened the breaker")
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb := breakerAt(&clock, 1, time.Minute)
+
+	cb.recordFailure()
+	clock = clock.Add(time.Minute)
+	if !cb.allow() {
+		t.Fatal("probe refused after the cooldown")
+	}
+	cb.recordFailure()
+	if cb.state != circuitOpen {
+		t.Fatalf("state after a failed probe = %s, want open", cb.state)
+	}
+	if cb.allow() {
+		t.Error("request allowed right after a failed probe; the cooldown should restart")
+	}
+}
+
+func TestLLMClientFailsFastWhileOpen(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := http.StatusServiceUnavailable
+	client, called := fakeGemini(t, []string{"primary"}, func(model string) (int, string) {
+		return status, `{"error":{"message":"unavailable"}}`
+	})
+	client.breaker = breakerAt(&clock, 2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		client.generateContent(context.Background(), GeminiRequest{})
+	}
+	_, _, err := client.generateContent(context.Background(), GeminiRequest{})
+	if !errors.Is(err, errCircuitOpen) {
+		t.Errorf("err = %v, want errCircuitOpen", err)
+	}
+	if got := len(called()); got != 2 {
+		t.Errorf("API called %d times, want 2: an open breaker must not hit the API", got)
+	}
+
+	// A request the API answers, even with an error, proves it is up
+	clock = clock.Add(time.Minute)
+	status = http.StatusBadRequest
+	client.generateContent(context.Background(), GeminiRequest{})
+	if client.breaker.state != circuitClosed {
+		t.Errorf("state after the API answered the probe = %s, want closed", client.breaker.state)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Configuration ---
+
+// Config is everything the bot reads from the environment. LoadConfig fills
+// it in with defaults applied; the env var for each field is noted beside it.
+type Config struct {
+	// Credentials
+	TelegramToken string // TELEGRAM_BOT_TOKEN (required)
+	GeminiKey     string // GEMINI_API_KEY (required for LLM_PROVIDER=gemini with GEMINI_AUTH=key)
+	OpenAIKey     string // OPENAI_API_KEY (required for LLM_PROVIDER=openai)
+	AnthropicKey  string // ANTHROPIC_API_KEY (required for LLM_PROVIDER=anthropic)
+	APIToken      string // API_TOKEN; "" disables POST /api/generate
+
+	// Runtime
+	DataFile        string         // DATA_FILE
+	StateDB         string         // STATE_DB: a SQLite file or redis:// URL; "off" (stored as "") keeps conversations in memory
+	SessionTTL      time.Duration  // SESSION_TTL; 0 keeps idle sessions forever
+	ShutdownTimeout time.Duration  // SHUTDOWN_TIMEOUT
+	Port            string         // PORT
+	Workers         int            // WORKERS
+	UpdateHandlers  int            // UPDATE_HANDLERS
+	MaxQueued       int            // MAX_QUEUED_PER_USER
+	Location        *time.Location // TIMEZONE
+	TelegramDebug   bool           // TELEGRAM_DEBUG
+	DryRun          bool           // DRY_RUN
+	AdminIDs        map[int64]bool // ADMIN_IDS
+	ErrorReportChat int64          // ERROR_REPORT_CHAT; 0 turns error reports off
+	AllowedUsers    map[int64]bool // ALLOWED_USERS
+	RestrictAccess  bool           // RESTRICT_ACCESS; also on if ALLOWED_USERS is set
+	Quota           QuotaConfig    // RATE_LIMIT_PER_MINUTE, RATE_LIMIT_BURST, DAILY_GENERATION_LIMIT
+
+	VersionAdminOnly bool // VERSION_ADMIN_ONLY
+
+	LogFormat string // LOG_FORMAT: json or text
+	LogLevel  string // LOG_LEVEL: debug, info, warn or error
+
+	// LLM
+	LLMProvider          string                 // LLM_PROVIDER
+	GeminiAuth           string                 // GEMINI_AUTH: "key" or "vertex"
+	VertexProject        string                 // VERTEX_PROJECT (or GOOGLE_CLOUD_PROJECT)
+	VertexLocation       string                 // VERTEX_LOCATION
+	GoogleCredentials    string                 // GOOGLE_APPLICATION_CREDENTIALS; "" uses gcloud's or the metadata server
+	Models               []string               // LLM_MODELS, or GEMINI_MODELS for Gemini
+	TaskModels           map[modelTask][]string // CAPTION_MODELS, FEEDBACK_MODELS, INLINE_MODELS; unset tasks use Models
+	OllamaURL            string                 // OLLAMA_URL
+	Retry                RetryPolicy            // LLM_RETRY_ATTEMPTS/BASE_DELAY/JITTER
+	BreakerThreshold     int                    // GEMINI_BREAKER_THRESHOLD
+	BreakerCooldown      time.Duration          // GEMINI_BREAKER_COOLDOWN
+	AuthFailureThreshold int                    // GEMINI_AUTH_FAILURE_THRESHOLD
+	Pricing              Pricing                // GEMINI_INPUT/OUTPUT_PRICE_PER_MILLION
+	MonthlyBudget        float64                // MONTHLY_BUDGET_USD
+
+	// Prompts and results
+	PromptTemplatePath      string         // CAPTION_PROMPT_TEMPLATE
+	BrandPresetsDir         string         // BRAND_PRESETS_DIR
+	ServicesFile            string         // SERVICES_FILE
+	Output                  OutputSettings // HASHTAG_MAX_LENGTH, FEEDBACK_POINTS, BG_CLEANUP, CAPTION_STYLES, DISCLAIMER_TEXT
+	RatedExamples           int            // RATED_EXAMPLES, capped at maxRatedExamples
+	DetectAttributes        bool           // DETECT_ATTRIBUTES
+	ResultStyle             string         // RESULT_STYLE
+	ResponseFormat          ResponseFormat // RESPONSE_FORMAT
+	EnforceEmojiPolicy      bool           // ENFORCE_EMOJI_POLICY
+	RequireServiceSelection bool           // REQUIRE_SERVICE_SELECTION
+	MaxPlatforms            int            // MAX_PLATFORMS
+	CTA                     CTAConfig      // CTA_TEXT, CTA_EMAIL, CTA_WHATSAPP, CTA_WEBSITE
+
+	// Images and files
+	Images            ImageRules    // MIN_IMAGE_SIDE, MAX_IMAGE_SIDE, JPEG_QUALITY, ACCEPTED_MIME_TYPES
+	ImageQualityCheck bool          // IMAGE_QUALITY_CHECK
+	DownloadTimeout   time.Duration // DOWNLOAD_TIMEOUT
+	DownloadAttempts  int           // DOWNLOAD_ATTEMPTS
+	LastPhotoTTL      time.Duration // LAST_PHOTO_TTL
+	LastPhotoMaxBytes int           // LAST_PHOTO_MAX_MB
+	MaxPDFBytes       int64         // MAX_PDF_SIZE_MB
+	MaxImageFileBytes int64         // MAX_IMAGE_FILE_MB
+	MaxPDFPages       int           // MAX_PDF_PAGES
+	MaxBatchSize      int           // MAX_BATCH_SIZE
+
+	// Result cache
+	ResultCacheSize int           // RESULT_CACHE_SIZE; 0 disables the cache
+	ResultCacheTTL  time.Duration // RESULT_CACHE_TTL; 0 keeps results until evicted
+	RedisURL        string        // REDIS_URL; "" keeps the cache in memory
+
+	// Google Sheets export
+	SheetsSpreadsheetID string // SHEETS_SPREADSHEET_ID; "" turns the export off
+	SheetsSheet         string // SHEETS_SHEET
+
+	// Publishing to social networks
+	PublishUsers      map[int64]bool // PUBLISH_USERS; admins may always publish
+	FacebookPageID    string         // FACEBOOK_PAGE_ID
+	FacebookPageToken string         // FACEBOOK_PAGE_TOKEN
+	LinkedIn          LinkedInConfig // LINKEDIN_*
+	X                 XConfig        // X_*
+}
+
+// XConfig is the OAuth 1.0a user context of the X account captions can be
+// posted to: the app's API key and secret, and the account's access token.
+type XConfig struct {
+	APIKey            string // X_API_KEY
+	APISecret         string // X_API_SECRET
+	AccessToken       string // X_ACCESS_TOKEN
+	AccessTokenSecret string // X_ACCESS_TOKEN_SECRET
+}
+
+// LinkedInConfig is the company page captions can be posted to, and the
+// OAuth credentials of one of its admins.
+type LinkedInConfig struct {
+	Organization string // LINKEDIN_ORGANIZATION, as a URN
+	AccessToken  string // LINKEDIN_ACCESS_TOKEN
+	ClientID     string // LINKEDIN_CLIENT_ID
+	ClientSecret string // LINKEDIN_CLIENT_SECRET
+	RefreshToken string // LINKEDIN_REFRESH_TOKEN
+}
+
+// LoadConfig reads the configuration from the environment. Unset values get
+// their defaults; a missing token or a malformed setting is an error.
+// Malformed numbers, durations and booleans only log a warning and fall
+// back to the default, as they always have.
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		TelegramToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
+		GeminiKey:     os.Getenv("GEMINI_API_KEY"),
+		OpenAIKey:     os.Getenv("OPENAI_API_KEY"),
+		AnthropicKey:  os.Getenv("ANTHROPIC_API_KEY"),
+		APIToken:      os.Getenv("API_TOKEN"),
+
+		DataFile:        envString("DATA_FILE", "bot_data.json"),
+		StateDB:         envString("STATE_DB", "bot_state.db"),
+		SessionTTL:      envDuration("SESSION_TTL", 30*time.Minute),
+		ShutdownTimeout: envDuration("SHUTDOWN_TIMEOUT", 25*time.Second),
+		Port:            envString("PORT", "8080"),
+		Workers:         envInt("WORKERS", 4),
+		UpdateHandlers:  envInt("UPDATE_HANDLERS", 16),
+		MaxQueued:       envInt("MAX_QUEUED_PER_USER", 3),
+		Location:        time.Local,
+		TelegramDebug:   envBool("TELEGRAM_DEBUG", false),
+		DryRun:          envBool("DRY_RUN", false),
+		AdminIDs:        parseUserIDs(os.Getenv("ADMIN_IDS")),
+		AllowedUsers:    parseUserIDs(os.Getenv("ALLOWED_USERS")),
+		RestrictAccess:  envBool("RESTRICT_ACCESS", false),
+		Quota: QuotaConfig{
+			PerMinute: envFloat("RATE_LIMIT_PER_MINUTE", 0),
+			Burst:     envInt("RATE_LIMIT_BURST", 3),
+			Daily:     envInt("DAILY_GENERATION_LIMIT", 0),
+		},
+
+		VersionAdminOnly: envBool("VERSION_ADMIN_ONLY", false),
+		LogFormat:        envString("LOG_FORMAT", "json"),
+		LogLevel:         envString("LOG_LEVEL", "info"),
+
+		LLMProvider: strings.ToLower(envString("LLM_PROVIDER", providerGemini)),
+		OllamaURL:   envString("OLLAMA_URL", defaultOllamaURL),
+
+		GeminiAuth:        strings.ToLower(envString("GEMINI_AUTH", geminiAuthKey)),
+		VertexProject:     envString("VERTEX_PROJECT", os.Getenv("GOOGLE_CLOUD_PROJECT")),
+		VertexLocation:    envString("VERTEX_LOCATION", "us-central1"),
+		GoogleCredentials: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		Retry: RetryPolicy{
+			MaxAttempts: envInt("LLM_RETRY_ATTEMPTS", 3),
+			BaseDelay:   envDuration("LLM_RETRY_BASE_DELAY", time.Second),
+			Jitter:      envFloat("LLM_RETRY_JITTER", 0.2),
+		},
+		BreakerThreshold:     envInt("GEMINI_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:      envDuration("GEMINI_BREAKER_COOLDOWN", 2*time.Minute),
+		AuthFailureThreshold: envInt("GEMINI_AUTH_FAILURE_THRESHOLD", 3),
+		Pricing: Pricing{
+			// Defaults match Gemini 2.5 Flash list prices (USD per 1M tokens)
+			InputPerMillion:  envFloat("GEMINI_INPUT_PRICE_PER_MILLION", 0.30),
+			OutputPerMillion: envFloat("GEMINI_OUTPUT_PRICE_PER_MILLION", 2.50),
+		},
+		MonthlyBudget: envFloat("MONTHLY_BUDGET_USD", 0),
+
+		PromptTemplatePath: os.Getenv("CAPTION_PROMPT_TEMPLATE"),
+		BrandPresetsDir:    os.Getenv("BRAND_PRESETS_DIR"),
+		ServicesFile:       os.Getenv("SERVICES_FILE"),
+		Output: OutputSettings{
+			HashtagMaxLength: envInt("HASHTAG_MAX_LENGTH", defaultOutputSettings.HashtagMaxLength),
+			FeedbackPoints:   envInt("FEEDBACK_POINTS", defaultOutputSettings.FeedbackPoints),
+			BackgroundCheck:  envBool("BG_CLEANUP", defaultOutputSettings.BackgroundCheck),
+			Disclaimer:       strings.TrimSpace(os.Getenv("DISCLAIMER_TEXT")),
+		},
+		RatedExamples:           min(envInt("RATED_EXAMPLES", 0), maxRatedExamples),
+		DetectAttributes:        envBool("DETECT_ATTRIBUTES", false),
+		ResultStyle:             envString("RESULT_STYLE", resultStyleMessages),
+		EnforceEmojiPolicy:      envBool("ENFORCE_EMOJI_POLICY", false),
+		RequireServiceSelection: envBool("REQUIRE_SERVICE_SELECTION", false),
+		MaxPlatforms:            envInt("MAX_PLATFORMS", 3),
+		CTA: CTAConfig{
+			Text:     envString("CTA_TEXT", defaultCTAText),
+			Email:    os.Getenv("CTA_EMAIL"),
+			WhatsApp: os.Getenv("CTA_WHATSAPP"),
+			Website:  os.Getenv("CTA_WEBSITE"),
+		},
+
+		Images: ImageRules{
+			MinSide:     envInt("MIN_IMAGE_SIDE", defaultImageRules.MinSide),
+			MaxSide:     envInt("MAX_IMAGE_SIDE", defaultImageRules.MaxSide),
+			JPEGQuality: envInt("JPEG_QUALITY", defaultImageRules.JPEGQuality),
+		},
+		ImageQualityCheck: envBool("IMAGE_QUALITY_CHECK", true),
+		DownloadTimeout:   envDuration("DOWNLOAD_TIMEOUT", 30*time.Second),
+		DownloadAttempts:  envInt("DOWNLOAD_ATTEMPTS", 3),
+		LastPhotoTTL:      envDuration("LAST_PHOTO_TTL", 24*time.Hour),
+		LastPhotoMaxBytes: envInt("LAST_PHOTO_MAX_MB", 10) << 20,
+		MaxPDFBytes:       int64(envInt("MAX_PDF_SIZE_MB", 20)) << 20,
+		MaxImageFileBytes: int64(envInt("MAX_IMAGE_FILE_MB", 20)) << 20,
+		MaxPDFPages:       envInt("MAX_PDF_PAGES", 50),
+		MaxBatchSize:      envInt("MAX_BATCH_SIZE", 10),
+
+		ResultCacheSize: envInt("RESULT_CACHE_SIZE", 200),
+		ResultCacheTTL:  envDuration("RESULT_CACHE_TTL", 24*time.Hour),
+		RedisURL:        os.Getenv("REDIS_URL"),
+
+		SheetsSpreadsheetID: strings.TrimSpace(os.Getenv("SHEETS_SPREADSHEET_ID")),
+		SheetsSheet:         envString("SHEETS_SHEET", "Sheet1"),
+
+		PublishUsers:      parseUserIDs(os.Getenv("PUBLISH_USERS")),
+		FacebookPageID:    strings.TrimSpace(os.Getenv("FACEBOOK_PAGE_ID")),
+		FacebookPageToken: strings.TrimSpace(os.Getenv("FACEBOOK_PAGE_TOKEN")),
+		LinkedIn: LinkedInConfig{
+			AccessToken:  strings.TrimSpace(os.Getenv("LINKEDIN_ACCESS_TOKEN")),
+			ClientID:     strings.TrimSpace(os.Getenv("LINKEDIN_CLIENT_ID")),
+			ClientSecret: strings.TrimSpace(os.Getenv("LINKEDIN_CLIENT_SECRET")),
+			RefreshToken: strings.TrimSpace(os.Getenv("LINKEDIN_REFRESH_TOKEN")),
+		},
+		X: XConfig{
+			APIKey:            strings.TrimSpace(os.Getenv("X_API_KEY")),
+			APISecret:         strings.TrimSpace(os.Getenv("X_API_SECRET")),
+			AccessToken:       strings.TrimSpace(os.Getenv("X_ACCESS_TOKEN")),
+			AccessTokenSecret: strings.TrimSpace(os.Getenv("X_ACCESS_TOKEN_SECRET")),
+		},
+	}
+
+	if cfg.StateDB == "off" {
+		cfg.StateDB = ""
+	}
+	if raw := strings.TrimSpace(os.Getenv("ERROR_REPORT_CHAT")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid ERROR_REPORT_CHAT %q: must be a chat ID", raw)
+		}
+		cfg.ErrorReportChat = id
+	}
+	if len(cfg.AllowedUsers) > 0 {
+		cfg.RestrictAccess = true
+	}
+
+	if cfg.TelegramToken == "" {
+		return cfg, errors.New("TELEGRAM_BOT_TOKEN must be set in .env or environment")
+	}
+	defaultModel, ok := defaultProviderModels[cfg.LLMProvider]
+	if !ok {
+		return cfg, fmt.Errorf("invalid LLM_PROVIDER %q: must be %s, %s, %s or %s",
+			cfg.LLMProvider, providerGemini, providerOpenAI, providerAnthropic, providerOllama)
+	}
+	modelsVar := "LLM_MODELS"
+	if cfg.LLMProvider == providerGemini && os.Getenv("LLM_MODELS") == "" {
+		modelsVar = "GEMINI_MODELS"
+	}
+	cfg.Models = parseModelList(os.Getenv(modelsVar), defaultModel)
+	cfg.TaskModels = make(map[modelTask][]string)
+	for task, key := range map[modelTask]string{taskCaptions: "CAPTION_MODELS", taskFeedback: "FEEDBACK_MODELS", taskInline: "INLINE_MODELS"} {
+		if raw := os.Getenv(key); strings.TrimSpace(raw) != "" {
+			cfg.TaskModels[task] = parseModelList(raw, defaultModel)
+		}
+	}
+	if cfg.GeminiAuth != geminiAuthKey && cfg.GeminiAuth != geminiAuthVertex {
+		return cfg, fmt.Errorf("invalid GEMINI_AUTH %q: must be %s or %s", cfg.GeminiAuth, geminiAuthKey, geminiAuthVertex)
+	}
+	switch {
+	case cfg.LLMProvider == providerGemini && cfg.GeminiAuth == geminiAuthKey && cfg.GeminiKey == "":
+		return cfg, errors.New("GEMINI_API_KEY must be set in .env or environment")
+	case cfg.LLMProvider == providerOpenAI && cfg.OpenAIKey == "":
+		return cfg, errors.New("OPENAI_API_KEY must be set for LLM_PROVIDER=openai")
+	case cfg.LLMProvider == providerAnthropic && cfg.AnthropicKey == "":
+		return cfg, errors.New("ANTHROPIC_API_KEY must be set for LLM_PROVIDER=anthropic")
+	}
+
+	if cfg.Quota.PerMinute > 0 && cfg.Quota.Burst < 1 {
+		return cfg, fmt.Errorf("invalid RATE_LIMIT_BURST %d: must be at least 1", cfg.Quota.Burst)
+	}
+	if cfg.Retry.MaxAttempts < 1 {
+		return cfg, fmt.Errorf("invalid LLM_RETRY_ATTEMPTS %d: must be at least 1", cfg.Retry.MaxAttempts)
+	}
+	if cfg.Retry.Jitter < 0 || cfg.Retry.Jitter > 1 {
+		return cfg, fmt.Errorf("invalid LLM_RETRY_JITTER %g: must be between 0 and 1", cfg.Retry.Jitter)
+	}
+	if cfg.Images.JPEGQuality < 1 || cfg.Images.JPEGQuality > 100 {
+		return cfg, fmt.Errorf("invalid JPEG_QUALITY %d: must be between 1 and 100", cfg.Images.JPEGQuality)
+	}
+
+	var err error
+	if tz := os.Getenv("TIMEZONE"); tz != "" {
+		if cfg.Location, err = time.LoadLocation(tz); err != nil {
+			return cfg, fmt.Errorf("invalid TIMEZONE %q: %w", tz, err)
+		}
+	}
+	if cfg.Output.CaptionStyles, err = parseCaptionStyles(os.Getenv("CAPTION_STYLES")); err != nil {
+		return cfg, fmt.Errorf("invalid CAPTION_STYLES: %w", err)
+	}
+	if cfg.Images.AcceptedTypes, err = parseMimeTypes(os.Getenv("ACCEPTED_MIME_TYPES")); err != nil {
+		return cfg, fmt.Errorf("invalid ACCEPTED_MIME_TYPES: %w", err)
+	}
+	if cfg.ResponseFormat, err = parseResponseFormat(os.Getenv("RESPONSE_FORMAT")); err != nil {
+		return cfg, fmt.Errorf("invalid RESPONSE_FORMAT: %w", err)
+	}
+	switch cfg.ResultStyle {
+	case resultStyleMessages, resultStyleCarousel:
+	default:
+		return cfg, fmt.Errorf("invalid RESULT_STYLE %q: must be %q or %q", cfg.ResultStyle, resultStyleMessages, resultStyleCarousel)
+	}
+	if (cfg.FacebookPageID == "") != (cfg.FacebookPageToken == "") {
+		return cfg, errors.New("FACEBOOK_PAGE_ID and FACEBOOK_PAGE_TOKEN must be set together")
+	}
+	if org := os.Getenv("LINKEDIN_ORGANIZATION"); org != "" {
+		if cfg.LinkedIn.Organization, err = linkedinOrganization(org); err != nil {
+			return cfg, fmt.Errorf("invalid LINKEDIN_ORGANIZATION: %w", err)
+		}
+		if cfg.LinkedIn.AccessToken == "" && cfg.LinkedIn.RefreshToken == "" {
+			return cfg, errors.New("LINKEDIN_ORGANIZATION needs LINKEDIN_ACCESS_TOKEN or LINKEDIN_REFRESH_TOKEN")
+		}
+		if cfg.LinkedIn.RefreshToken != "" && (cfg.LinkedIn.ClientID == "" || cfg.LinkedIn.ClientSecret == "") {
+			return cfg, errors.New("LINKEDIN_REFRESH_TOKEN needs LINKEDIN_CLIENT_ID and LINKEDIN_CLIENT_SECRET")
+		}
+	}
+	if x := cfg.X; x != (XConfig{}) && (x.APIKey == "" || x.APISecret == "" || x.AccessToken == "" || x.AccessTokenSecret == "") {
+		return cfg, errors.New("X_API_KEY, X_API_SECRET, X_ACCESS_TOKEN and X_ACCESS_TOKEN_SECRET must be set together")
+	}
+
+	return cfg, nil
+}
+
+// --- Environment Helpers ---
+
+// envString reads a string from the environment, falling back to def if unset.
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envFloat reads a float from the environment, falling back to def if unset or invalid.
+func envFloat(key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %v", key, raw, def)
+		return def
+	}
+	return v
+}
+
+// envInt reads an integer from the environment, falling back to def if unset or invalid.
+func envInt(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %v", key, raw, def)
+		return def
+	}
+	return v
+}
+
+// envDuration reads a duration (e.g. "90s", "2m") from the environment,
+// falling back to def if unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %v", key, raw, def)
+		return def
+	}
+	return v
+}
+
+// envBool reads a boolean from the environment, falling back to def if unset or invalid.
+func envBool(key string, def bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %v", key, raw, def)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setMinimalEnv sets the only settings LoadConfig requires and clears the
+// ones the tests below look at, so values from the environment running the
+// tests don't leak in.
+func setMinimalEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		"LLM_PROVIDER", "GEMINI_AUTH", "LLM_MODELS", "GEMINI_MODELS", "STATE_DB", "WORKERS",
+		"HASHTAG_MAX_LENGTH", "FEEDBACK_POINTS", "BG_CLEANUP", "CAPTION_STYLES", "DISCLAIMER_TEXT",
+		"MIN_IMAGE_SIDE", "MAX_IMAGE_SIDE", "JPEG_QUALITY", "ACCEPTED_MIME_TYPES",
+		"RESULT_STYLE", "RESPONSE_FORMAT", "TIMEZONE", "ALLOWED_USERS", "RESTRICT_ACCESS", "REQUIRE_SERVICE_SELECTION",
+		"FACEBOOK_PAGE_ID", "FACEBOOK_PAGE_TOKEN", "LINKEDIN_ORGANIZATION", "X_API_KEY", "ERROR_REPORT_CHAT",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123:test")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	setMinimalEnv(t)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Workers != 4 || cfg.StateDB != "bot_state.db" || cfg.SessionTTL != 30*time.Minute {
+		t.Errorf("runtime defaults = workers %d, state DB %q, session TTL %v", cfg.Workers, cfg.StateDB, cfg.SessionTTL)
+	}
+	if !slices.Equal(cfg.Models, []string{defaultGeminiModel}) {
+		t.Errorf("Models = %v, want [%s]", cfg.Models, defaultGeminiModel)
+	}
+	if cfg.Images.MinSide != defaultImageRules.MinSide || cfg.Images.MaxSide != defaultImageRules.MaxSide ||
+		cfg.Images.JPEGQuality != defaultImageRules.JPEGQuality || !slices.Equal(cfg.Images.AcceptedTypes, defaultAcceptedMimeTypes) {
+		t.Errorf("Images = %+v, want %+v", cfg.Images, defaultImageRules)
+	}
+	if cfg.Output.HashtagMaxLength != 30 || cfg.Output.FeedbackPoints != 1 || cfg.Output.BackgroundCheck ||
+		cfg.Output.CaptionStyles != nil || cfg.Output.Disclaimer != "" {
+		t.Errorf("Output = %+v, want %+v", cfg.Output, defaultOutputSettings)
+	}
+	if cfg.ResultStyle != resultStyleMessages || cfg.RestrictAccess {
+		t.Errorf("ResultStyle = %q, RestrictAccess = %v", cfg.ResultStyle, cfg.RestrictAccess)
+	}
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	setMinimalEnv(t)
+	t.Setenv("STATE_DB", "off")
+	t.Setenv("MAX_IMAGE_SIDE", "2048")
+	t.Setenv("HASHTAG_MAX_LENGTH", "not a number") // Falls back to the default
+	t.Setenv("CAPTION_STYLES", "Hook-led, Benefit-led, Story-led")
+	t.Setenv("ALLOWED_USERS", "42")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.StateDB != "" {
+		t.Errorf("StateDB = %q, want it off", cfg.StateDB)
+	}
+	if cfg.Images.MaxSide != 2048 {
+		t.Errorf("Images.MaxSide = %d, want 2048", cfg.Images.MaxSide)
+	}
+	if cfg.Output.HashtagMaxLength != 30 {
+		t.Errorf("Output.HashtagMaxLength = %d, want the default 30", cfg.Output.HashtagMaxLength)
+	}
+	if want := []string{"Hook-led", "Benefit-led", "Story-led"}; !slices.Equal(cfg.Output.CaptionStyles, want) {
+		t.Errorf("Output.CaptionStyles = %v, want %v", cfg.Output.CaptionStyles, want)
+	}
+	if !cfg.RestrictAccess || !cfg.AllowedUsers[42] {
+		t.Errorf("ALLOWED_USERS should restrict access to user 42, got %v / %v", cfg.RestrictAccess, cfg.AllowedUsers)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"no Telegram token", map[string]string{"TELEGRAM_BOT_TOKEN": ""}, "TELEGRAM_BOT_TOKEN must be set"},
+		{"no Gemini key", map[string]string{"GEMINI_API_KEY": ""}, "GEMINI_API_KEY must be set"},
+		{"no OpenAI key", map[string]string{"LLM_PROVIDER": "openai"}, "OPENAI_API_KEY must be set"},
+		{"unknown provider", map[string]string{"LLM_PROVIDER": "acme"}, "invalid LLM_PROVIDER"},
+		{"JPEG quality out of range", map[string]string{"JPEG_QUALITY": "101"}, "invalid JPEG_QUALITY"},
+		{"two caption styles", map[string]string{"CAPTION_STYLES": "a,b"}, "invalid CAPTION_STYLES"},
+		{"non-image MIME type", map[string]string{"ACCEPTED_MIME_TYPES": "application/pdf"}, "invalid ACCEPTED_MIME_TYPES"},
+		{"unknown result style", map[string]string{"RESULT_STYLE": "grid"}, "invalid RESULT_STYLE"},
+		{"unknown time zone", map[string]string{"TIMEZONE": "Mars/Olympus"}, "invalid TIMEZONE"},
+		{"Facebook page without token", map[string]string{"FACEBOOK_PAGE_ID": "123"}, "FACEBOOK_PAGE_ID and FACEBOOK_PAGE_TOKEN"},
+		{"bad error report chat", map[string]string{"ERROR_REPORT_CHAT": "@admins"}, "invalid ERROR_REPORT_CHAT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setMinimalEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewBotUsesExplicitConfig(t *testing.T) {
+	cfg := Config{
+		Images: ImageRules{AcceptedTypes: []string{"image/png"}},
+		Output: OutputSettings{HashtagMaxLength: 12, Disclaimer: "AI-written"},
+	}
+	b := NewBot(nil, cfg, nil, nil, nil)
+
+	if err := b.images.checkType("image/jpeg"); err == nil {
+		t.Error("JPEG accepted, want only PNG")
+	}
+	if err := b.images.checkType("image/png"); err != nil {
+		t.Errorf("PNG rejected: %v", err)
+	}
+	params := (&userState{}).generationParams(b.output)
+	if params.Output.HashtagMaxLength != 12 || params.Output.Disclaimer != "AI-written" {
+		t.Errorf("job output settings = %+v, want the bot's %+v", params.Output, b.output)
+	}
+}
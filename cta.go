@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- Contact / Call-to-Action Footer ---
+
+// CTAConfig is the contact footer appended to every caption.
+type CTAConfig struct {
+	Text     string // Lead-in line, e.g. "Get in touch:"
+	Email    string
+	WhatsApp string
+	Website  string
+}
+
+// enabled reports whether any contact detail is configured.
+func (c CTAConfig) enabled() bool {
+	return c.Email != "" || c.WhatsApp != "" || c.Website != ""
+}
+
+// footer renders the contact lines in the given language. It is plain text
+// (no emojis) so it survives the LinkedIn emoji policy unchanged.
+func (c CTAConfig) footer(lang string) string {
+	var lines []string
+	if c.Text == defaultCTAText {
+		lines = append(lines, tr(lang, "cta"))
+	} else if c.Text != "" {
+		lines = append(lines, c.Text)
+	}
+	if c.Email != "" {
+		lines = append(lines, tr(lang, "email")+": "+c.Email)
+	}
+	if c.WhatsApp != "" {
+		lines = append(lines, tr(lang, "whatsapp")+": "+c.WhatsApp)
+	}
+	if c.Website != "" {
+		lines = append(lines, tr(lang, "web")+": "+c.Website)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// appendCTA adds the contact footer to a caption, in the caption's language.
+// If the caption already mentions any of the configured contact details (the
+// model sometimes adds its own), it is returned unchanged so the contact
+// isn't doubled.
+func appendCTA(caption string, cfg CTAConfig, lang string) string {
+	if !cfg.enabled() {
+		return caption
+	}
+
+	lower := strings.ToLower(caption)
+	digits := onlyDigits(caption)
+	if (cfg.Email != "" && strings.Contains(lower, strings.ToLower(cfg.Email))) ||
+		(cfg.Website != "" && strings.Contains(lower, strings.ToLower(stripScheme(cfg.Website)))) ||
+		(cfg.WhatsApp != "" && onlyDigits(cfg.WhatsApp) != "" && strings.Contains(digits, onlyDigits(cfg.WhatsApp))) {
+		return caption
+	}
+
+	return strings.TrimRight(caption, " \n") + "\n\n" + cfg.footer(lang)
+}
+
+// onlyDigits keeps just the digits, so "+880 1711-000000" matches "8801711000000".
+func onlyDigits(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// stripScheme turns "https://www.example.com/" into "www.example.com".
+func stripScheme(url string) string {
+	url = strings.TrimPrefix(url, "https://")
+	url = strings.TrimPrefix(url, "http://")
+	return strings.TrimSuffix(url, "/")
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestAppendCTA(t *testing.T) {
+	cfg := CTAConfig{Text: defaultCTAText, Email: "sales@example.com", WhatsApp: "+880 1711-000000", Website: "https://www.example.com/"}
+	const footer = "Get in touch:\nEmail: sales@example.com\nWhatsApp: +880 1711-000000\nWeb: https://www.example.com/"
+
+	tests := []struct {
+		name, caption string
+		cfg           CTAConfig
+		lang, want    string
+	}{
+		{"appended", "Indigo denim jacket.  \n", cfg, "en", "Indigo denim jacket.\n\n" + footer},
+		{"translated", "Denim", cfg, "bn", "Denim\n\nযোগাযোগ করুন:\nইমেইল: sales@example.com\nহোয়াটসঅ্যাপ: +880 1711-000000\nওয়েব: https://www.example.com/"},
+		{"custom lead-in is not translated", "Denim", CTAConfig{Text: "Order now:", Email: "a@b.co"}, "bn", "Denim\n\nOrder now:\nইমেইল: a@b.co"},
+		{"email already present", "Write to SALES@example.com today", cfg, "en", "Write to SALES@example.com today"},
+		{"website already present", "See www.example.com for sizes", cfg, "en", "See www.example.com for sizes"},
+		{"WhatsApp written differently", "WhatsApp us: 8801711000000", cfg, "en", "WhatsApp us: 8801711000000"},
+		{"WhatsApp with other separators", "Call (+880) 1711 000 000", cfg, "en", "Call (+880) 1711 000 000"},
+		{"other digits don't count", "Only 1711 pieces left", cfg, "en", "Only 1711 pieces left\n\n" + footer},
+		{"disabled", "Denim", CTAConfig{Text: defaultCTAText}, "en", "Denim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendCTA(tt.caption, tt.cfg, tt.lang); got != tt.want {
+				t.Errorf("appendCTA(%q) = %q, want %q", tt.caption, got, tt.want)
+			}
+		})
+	}
+}
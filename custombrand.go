@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// --- Custom Brand ---
+
+// customBrandPreset is the preset name of a user's own brand, set up with
+// "/brand set <field> <value>" and kept in the store.
+const customBrandPreset = "custom"
+
+// customBrandFields are the fields "/brand set" accepts, with how each is
+// applied to the brand. Hashtags are only cleaned up here; over-long ones
+// are dropped along with the model's when captions are generated.
+var customBrandFields = map[string]func(bc *BrandConfig, value string){
+	"name": func(bc *BrandConfig, value string) {
+		bc.Name = value
+		bc.Mentions = []string{value}
+	},
+	"description": func(bc *BrandConfig, value string) { bc.Description = value },
+	"example":     func(bc *BrandConfig, value string) { bc.Examples = []string{value} },
+	"hashtags":    func(bc *BrandConfig, value string) { bc.DefaultHashtags = normalizeHashtags(strings.Fields(value), 0) },
+	"cta":         func(bc *BrandConfig, value string) { bc.ContactCTA = value },
+}
+
+// customBrandUsage is shown for a malformed "/brand set".
+const customBrandUsage = "Set up your own brand, one field at a time:\n\n" +
+	"`/brand set name Acme Apparel`\n" +
+	"`/brand set description Knitwear manufacturer in Dhaka, 20 years of OEM experience`\n" +
+	"`/brand set example <a post you love>`\n" +
+	"`/brand set hashtags #AcmeApparel #MadeInBangladesh`\n" +
+	"`/brand set cta DM us or email sales@acme.com for a quote`\n\n" +
+	"Start with the name. Use `/brand custom` to switch back to it later."
+
+// newCustomBrand starts a user's brand from the default brand's services
+// and quick replies, with the default's identity removed.
+func newCustomBrand(name string, base *BrandConfig) *BrandConfig {
+	return &BrandConfig{
+		Name:           name,
+		Mentions:       []string{name},
+		Services:       base.Services,
+		ContextPresets: base.ContextPresets,
+	}
+}
+
+// SetCustomBrand stores a user's own brand and makes it their active brand.
+func (s *Store) SetCustomBrand(userID int64, bc *BrandConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.CustomBrands[userID] = bc
+	s.data.UserBrands[userID] = customBrandPreset
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// CustomBrand returns a copy of a user's own brand, if they have one.
+func (s *Store) CustomBrand(userID int64) (*BrandConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bc, ok := s.data.CustomBrands[userID]
+	if !ok {
+		return nil, false
+	}
+	copied := *bc
+	return &copied, true
+}
+
+// handleBrandSet handles "/brand set <field> <value>".
+func (b *Bot) handleBrandSet(chatID, userID int64, args string) {
+	field, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	field = strings.ToLower(field)
+	value = strings.TrimSpace(value)
+	apply, ok := customBrandFields[field]
+	if !ok || value == "" {
+		b.sendMessage(chatID, customBrandUsage, nil)
+		return
+	}
+
+	bc, exists := b.store.CustomBrand(userID)
+	switch {
+	case exists:
+	case field == "name":
+		bc = newCustomBrand(value, b.defaultBrand())
+	default:
+		b.sendMessage(chatID, "Please set your business name first, e.g. `/brand set name Acme Apparel`.", nil)
+		return
+	}
+	apply(bc, value)
+	if field == "hashtags" && len(bc.DefaultHashtags) == 0 {
+		b.sendMessage(chatID, "I couldn't find any hashtags in that. Try e.g. `/brand set hashtags #AcmeApparel`.", nil)
+		return
+	}
+
+	b.store.SetCustomBrand(userID, bc)
+	b.sendMessage(chatID, fmt.Sprintf("✅ Saved. Your next posts will be written for **%s**.\n\n%s", bc.Name, formatCustomBrand(bc)), nil)
+}
+
+// formatCustomBrand summarizes a user's own brand, marking unset fields.
+func formatCustomBrand(bc *BrandConfig) string {
+	example := ""
+	if len(bc.Examples) > 0 {
+		example = captionSnippet(bc.Examples[0])
+	}
+	return fmt.Sprintf("Name: %s\nDescription: %s\nExample post: %s\nHashtags: %s\nCall to action: %s",
+		orDash(bc.Name), orDash(bc.Description), orDash(example), orDash(strings.Join(bc.DefaultHashtags, " ")), orDash(bc.ContactCTA))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Custom Tone ---
+
+// maxCustomToneLength caps a typed tone description, in characters.
+const maxCustomToneLength = 100
+
+// customTonePromptText asks for a tone in the user's own words.
+const customTonePromptText = "✏️ Describe the **tone** you want in a few words, e.g. _playful but premium_ or _warm and reassuring_."
+
+// takeCustomTone records a typed tone, as if it had been one of the tone
+// buttons. It returns false, after saying why, if the text won't do.
+func (b *Bot) takeCustomTone(chatID int64, state *userState, text string) bool {
+	// One line: it goes into the prompt as the tone's name
+	tone := strings.Join(strings.Fields(text), " ")
+	switch {
+	case tone == "":
+		b.sendMessage(chatID, customTonePromptText, nil)
+		return false
+	case utf8.RuneCountInString(tone) > maxCustomToneLength:
+		b.sendMessage(chatID, fmt.Sprintf("That's a bit long for a tone. Please keep it under %d characters.", maxCustomToneLength), nil)
+		return false
+	}
+	state.Tone = tone
+	return true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- Deep-Link Presets ---
+
+// startPresetAliases maps lowercase deep-link tokens to platform keys.
+var startPresetAliases = map[string]string{
+	"linkedin":  "LinkedIn",
+	"instagram": "Instagram",
+	"insta":     "Instagram",
+	"facebook":  "Facebook",
+	"fb":        "Facebook",
+	"x":         "X",
+	"twitter":   "X",
+}
+
+// toneOrder lists the tones offered on the tone keyboard.
+var toneOrder = []string{"Professional", "Enthusiastic", "Luxury", "Technical"}
+
+// parseStartPreset parses a /start deep-link argument such as "linkedin",
+// "luxury" or "instagram_luxury" (t.me/<bot>?start=instagram_luxury).
+// Tokens may be separated by "_" or "-". It returns ok=false if the argument
+// is empty or contains anything it doesn't recognize.
+func parseStartPreset(arg string) (platforms []string, tone string, ok bool) {
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	if arg == "" {
+		return nil, "", false
+	}
+
+	tokens := strings.FieldsFunc(arg, func(r rune) bool { return r == '_' || r == '-' })
+	for _, token := range tokens {
+		if platform, found := startPresetAliases[token]; found {
+			if !containsString(platforms, platform) {
+				platforms = append(platforms, platform)
+			}
+			continue
+		}
+		if t := matchTone(token); t != "" && tone == "" {
+			tone = t
+			continue
+		}
+		return nil, "", false
+	}
+	return platforms, tone, true
+}
+
+// matchTone returns the tone whose name matches token (case-insensitively), or "".
+func matchTone(token string) string {
+	for _, t := range toneOrder {
+		if strings.EqualFold(t, token) {
+			return t
+		}
+	}
+	return ""
+}
+
+// describePreset renders a preset for the welcome message, e.g. "LinkedIn · Luxury".
+func describePreset(platforms []string, tone string) string {
+	var parts []string
+	for _, p := range platforms {
+		parts = append(parts, platformLabels[p])
+	}
+	if tone != "" {
+		parts = append(parts, tone)
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseStartPreset(t *testing.T) {
+	tests := []struct {
+		arg           string
+		wantPlatforms []string
+		wantTone      string
+		wantOK        bool
+	}{
+		{"linkedin", []string{"LinkedIn"}, "", true},
+		{"luxury", nil, "Luxury", true},
+		{"instagram_luxury", []string{"Instagram"}, "Luxury", true},
+		{"FB-twitter-Technical", []string{"Facebook", "X"}, "Technical", true},
+		{"insta_instagram", []string{"Instagram"}, "", true},
+		{"instagram_myspace", nil, "", false},
+		{"summer_sale", nil, "", false},
+		{"", nil, "", false},
+		{"   ", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			platforms, tone, ok := parseStartPreset(tt.arg)
+			if ok != tt.wantOK || tone != tt.wantTone || !slices.Equal(platforms, tt.wantPlatforms) {
+				t.Errorf("parseStartPreset(%q) = %v, %q, %v; want %v, %q, %v",
+					tt.arg, platforms, tone, ok, tt.wantPlatforms, tt.wantTone, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStartPresetSkipsQuestions(t *testing.T) {
+	tests := []struct {
+		command    string
+		wantState  ConversationState
+		wantPreset string // In the welcome message; "" for none
+	}{
+		{"/start instagram_luxury", StateWaitingForServices, "Instagram · Luxury"},
+		{"/start summer_sale", StateWaitingForPlatform, ""},
+		{"/start", StateWaitingForPlatform, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			userID := int64(1104 + i)
+
+			b.handleCommand(textMessage(userID, tt.command))
+			welcome := fake.Texts(userID)
+			if len(welcome) != 1 {
+				t.Fatalf("sent %d messages, want the welcome", len(welcome))
+			}
+			hasPreset := strings.Contains(welcome[0], "Preset:")
+			if hasPreset != (tt.wantPreset != "") || !strings.Contains(welcome[0], tt.wantPreset) {
+				t.Errorf("welcome = %q, want preset %q", welcome[0], tt.wantPreset)
+			}
+
+			b.startWithImage(userID, b.getState(userID), []byte("photo"), "image/jpeg", "Great photo! 📸")
+			if got := b.getState(userID).State; got != tt.wantState {
+				t.Errorf("after the photo, state = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync"
+)
+
+// --- Update Dispatcher ---
+
+// updateDispatcher handles updates concurrently across users while keeping
+// each user's updates in the order they arrived. At most `handlers` users
+// are served at once; the rest wait their turn.
+type updateDispatcher struct {
+	handle  func(botUpdate)
+	blocked func(key int64) bool // Users whose updates are dropped (banned)
+	slots   chan struct{}        // One token per running handler
+
+	mu      sync.Mutex
+	pending map[int64][]botUpdate // Waiting updates per user
+	active  map[int64]bool        // Users with a goroutine draining their updates
+	running sync.WaitGroup        // The draining goroutines, for a graceful shutdown
+}
+
+// newUpdateDispatcher creates a dispatcher running up to handlers users'
+// updates at the same time, dropping those of blocked users.
+func newUpdateDispatcher(handlers int, handle func(botUpdate), blocked func(key int64) bool) *updateDispatcher {
+	return &updateDispatcher{
+		handle:  handle,
+		blocked: blocked,
+		slots:   make(chan struct{}, max(handlers, 1)),
+		pending: make(map[int64][]botUpdate),
+		active:  make(map[int64]bool),
+	}
+}
+
+// updateKey is who an update belongs to: the user whose conversation it
+// moves on, or the chat for updates without one.
+func updateKey(update botUpdate) int64 {
+	switch {
+	case update.MessageReaction != nil:
+		if update.MessageReaction.User != nil {
+			return update.MessageReaction.User.ID
+		}
+		return update.MessageReaction.Chat.ID
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID
+	case update.InlineQuery != nil:
+		return update.InlineQuery.From.ID
+	case update.Message != nil:
+		if update.Message.From != nil {
+			return update.Message.From.ID
+		}
+		return update.Message.Chat.ID
+	}
+	return 0
+}
+
+// dispatch queues an update behind any earlier ones from the same user.
+func (d *updateDispatcher) dispatch(update botUpdate) {
+	key := updateKey(update)
+	if d.blocked(key) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[key] = append(d.pending[key], update)
+	if !d.active[key] {
+		d.active[key] = true
+		d.running.Add(1)
+		go d.drain(key)
+	}
+}
+
+// drain handles a user's updates one at a time until none are left.
+func (d *updateDispatcher) drain(key int64) {
+	defer d.running.Done()
+	d.slots <- struct{}{}
+	defer func() { <-d.slots }()
+
+	for {
+		d.mu.Lock()
+		queue := d.pending[key]
+		if len(queue) == 0 {
+			delete(d.pending, key)
+			delete(d.active, key)
+			d.mu.Unlock()
+			return
+		}
+		update := queue[0]
+		d.pending[key] = queue[1:]
+		d.mu.Unlock()
+
+		d.handle(update)
+	}
+}
+
+// finished returns a channel that is closed once every dispatched update
+// has been handled. Nothing may be dispatched after it is called.
+func (d *updateDispatcher) finished() <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(ch)
+	}()
+	return ch
+}
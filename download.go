@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Resilient File Downloads ---
+
+const (
+	// downloadDeadline bounds the whole download, including retries.
+	downloadDeadline = 2 * time.Minute
+	// downloadBaseBackoff is the wait before the first retry; it doubles each time.
+	downloadBaseBackoff = 500 * time.Millisecond
+	// maxLinkRefreshes is how many times we ask Telegram for a new file link
+	// after the current one has expired.
+	maxLinkRefreshes = 2
+	// fileCacheTTL and fileCacheMaxBytes bound the downloaded-file cache.
+	fileCacheTTL      = 5 * time.Minute
+	fileCacheMaxBytes = 64 << 20
+)
+
+// downloadStatusError is a non-200/206 response from the file server.
+type downloadStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.Status)
+}
+
+// isTransientDownloadError reports whether retrying might help.
+func isTransientDownloadError(err error) bool {
+	var statusErr *downloadStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	// Network errors and truncated bodies
+	return true
+}
+
+// isExpiredLinkError reports whether the file link is no longer valid.
+// Telegram file links are short-lived; a fresh GetFile gives a new one.
+func isExpiredLinkError(err error) bool {
+	var statusErr *downloadStatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusForbidden || statusErr.StatusCode == http.StatusGone)
+}
+
+// fetchFile resolves a file link and downloads it. getLink is retried like a
+// download, and is called again whenever the link turns out to have expired.
+func fetchFile(ctx context.Context, client *http.Client, getLink func() (string, error), attempts int) ([]byte, error) {
+	for refresh := 0; ; refresh++ {
+		url, err := resolveLink(ctx, getLink, attempts)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fetchWithRetry(ctx, client, url, attempts)
+		if err == nil || !isExpiredLinkError(err) || refresh >= maxLinkRefreshes {
+			return data, err
+		}
+		log.Printf("File link expired (%v), requesting a new one", err)
+	}
+}
+
+// resolveLink calls getLink, retrying failures with exponential backoff.
+func resolveLink(ctx context.Context, getLink func() (string, error), attempts int) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			backoff := downloadBaseBackoff << (attempt - 1)
+			log.Printf("GetFile attempt %d failed (%v), retrying in %v", attempt, lastErr, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return "", fmt.Errorf("download cancelled: %w", ctx.Err())
+			}
+		}
+
+		url, err := getLink()
+		if err == nil {
+			return url, nil
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf("error getting file link: %w", lastErr)
+}
+
+// fetchWithRetry GETs url, retrying transient failures with exponential
+// backoff. If an attempt breaks off mid-body, the next attempt asks for just
+// the remaining bytes with a Range header; servers that ignore Range simply
+// send the whole file again.
+func fetchWithRetry(ctx context.Context, client *http.Client, url string, attempts int) ([]byte, error) {
+	var data []byte
+	var lastErr error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			backoff := downloadBaseBackoff << (attempt - 1)
+			log.Printf("Download attempt %d failed (%v), retrying in %v", attempt, lastErr, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("download cancelled: %w", ctx.Err())
+			}
+		}
+
+		var err error
+		data, err = fetchOnce(ctx, client, url, data)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+		if !isTransientDownloadError(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// fetchOnce makes a single GET, resuming after the bytes already in partial.
+// It returns everything received so far, even on error, so the caller can resume.
+func fetchOnce(ctx context.Context, client *http.Client, url string, partial []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating download request: %w", err)
+	}
+	if len(partial) > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(partial)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return partial, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Server honoured the Range header; append to what we have
+	case http.StatusOK:
+		// Full body (first attempt, or Range was ignored); start over
+		partial = nil
+	default:
+		return partial, &downloadStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	rest, err := io.ReadAll(resp.Body)
+	partial = append(partial, rest...)
+	if err != nil {
+		return partial, fmt.Errorf("error reading download body: %w", err)
+	}
+	return partial, nil
+}
+
+// --- Downloaded File Cache ---
+
+// fileCache keeps recently downloaded files by Telegram FileID, so a retry or
+// a repeated step doesn't download the same file twice.
+type fileCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedFile
+	size    int
+}
+
+type cachedFile struct {
+	data    []byte
+	expires time.Time
+}
+
+func newFileCache() *fileCache {
+	return &fileCache{entries: make(map[string]cachedFile)}
+}
+
+// get returns a cached file if it hasn't expired.
+func (c *fileCache) get(fileID string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[fileID]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put caches a file, dropping expired entries first. Files that would push
+// the cache past fileCacheMaxBytes are not cached.
+func (c *fileCache) put(fileID string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			c.size -= len(entry.data)
+			delete(c.entries, id)
+		}
+	}
+	if old, ok := c.entries[fileID]; ok {
+		c.size -= len(old.data)
+	}
+	if c.size+len(data) > fileCacheMaxBytes {
+		return
+	}
+
+	c.entries[fileID] = cachedFile{data: data, expires: now.Add(fileCacheTTL)}
+	c.size += len(data)
+}
+
+// --- Accepted Image Types ---
+
+// defaultAcceptedMimeTypes are the image types we send to the model unless
+// ACCEPTED_MIME_TYPES says otherwise.
+var defaultAcceptedMimeTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+// mimeTypeNames are the friendly names used when talking about image types.
+var mimeTypeNames = map[string]string{
+	"image/jpeg": "JPEG",
+	"image/png":  "PNG",
+	"image/webp": "WebP",
+	"image/gif":  "GIF",
+	"image/bmp":  "BMP",
+	"image/tiff": "TIFF",
+	"image/heic": "HEIC",
+	"image/heif": "HEIF",
+}
+
+// parseMimeTypes parses a comma-separated list of MIME types. An empty
+// value keeps the defaults.
+func parseMimeTypes(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return defaultAcceptedMimeTypes, nil
+	}
+	var types []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if !strings.HasPrefix(field, "image/") {
+			return nil, fmt.Errorf("%q is not an image type", field)
+		}
+		types = append(types, field)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no MIME types given")
+	}
+	return types, nil
+}
+
+// mimeTypeName returns a friendly name for a MIME type, e.g. "GIF".
+func mimeTypeName(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";") // Drop "; charset=..."
+	if name, ok := mimeTypeNames[mimeType]; ok {
+		return name
+	}
+	if sub, ok := strings.CutPrefix(mimeType, "image/"); ok {
+		return strings.ToUpper(sub)
+	}
+	return mimeType
+}
+
+// unsupportedImageError is an image whose type isn't accepted. Its message
+// is written for the user.
+type unsupportedImageError struct {
+	MimeType string
+	Accepted []string
+}
+
+func (e *unsupportedImageError) Error() string {
+	names := make([]string, len(e.Accepted))
+	for i, t := range e.Accepted {
+		names[i] = mimeTypeName(t)
+	}
+	var list string
+	switch n := len(names); n {
+	case 1:
+		list = names[0]
+	case 2:
+		list = names[0] + " or " + names[1]
+	default:
+		list = strings.Join(names[:n-1], ", ") + ", or " + names[n-1]
+	}
+	text := fmt.Sprintf("I can work with %s images — this looks like a %s.", list, mimeTypeName(e.MimeType))
+	if e.MimeType == "image/heic" || e.MimeType == "image/heif" {
+		// iPhone photos; Telegram converts them to JPEG when sent as a photo
+		text += " Please send it as a photo rather than a file, and Telegram will convert it for me."
+	}
+	return text
+}
+
+// checkType returns an *unsupportedImageError unless mimeType is accepted.
+func (r ImageRules) checkType(mimeType string) error {
+	accepted := r.AcceptedTypes
+	if len(accepted) == 0 {
+		accepted = defaultAcceptedMimeTypes
+	}
+	if slices.Contains(accepted, mimeType) {
+		return nil
+	}
+	return &unsupportedImageError{MimeType: mimeType, Accepted: accepted}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fileServer serves a file through handle and records each request's Range header.
+type fileServer struct {
+	mu     sync.Mutex
+	ranges []string
+	handle func(w http.ResponseWriter, r *http.Request, n int)
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	n := len(s.ranges)
+	s.mu.Unlock()
+	s.handle(w, r, n)
+}
+
+// Ranges returns the Range header of every request so far.
+func (s *fileServer) Ranges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func newFileServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request, n int)) (*fileServer, *httptest.Server) {
+	t.Helper()
+	fs := &fileServer{handle: handle}
+	srv := httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+var downloadBody = bytes.Repeat([]byte("0123456789"), 1000)
+
+func TestFetchWithRetryRecoversFromServerError(t *testing.T) {
+	fs, srv := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(downloadBody)
+	})
+
+	data, err := fetchWithRetry(context.Background(), srv.Client(), srv.URL+"/photo", 3)
+	if err != nil {
+		t.Fatalf("fetchWithRetry: %v", err)
+	}
+	if !bytes.Equal(data, downloadBody) {
+		t.Errorf("got %d bytes, want the %d-byte file", len(data), len(downloadBody))
+	}
+	if got := len(fs.Ranges()); got != 2 {
+		t.Errorf("%d requests, want 2", got)
+	}
+}
+
+func TestFetchWithRetryResumesWithRange(t *testing.T) {
+	const cut = 4000
+	fs, srv := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			// Promise the whole file, send part of it, then drop the connection
+			w.Header().Set("Content-Length", fmt.Sprint(len(downloadBody)))
+			w.Write(downloadBody[:cut])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", cut, len(downloadBody)-1, len(downloadBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(downloadBody[cut:])
+	})
+
+	data, err := fetchWithRetry(context.Background(), srv.Client(), srv.URL+"/photo", 3)
+	if err != nil {
+		t.Fatalf("fetchWithRetry: %v", err)
+	}
+	if !bytes.Equal(data, downloadBody) {
+		t.Errorf("got %d bytes, want the %d-byte file put back together", len(data), len(downloadBody))
+	}
+	if ranges := fs.Ranges(); len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", cut) {
+		t.Errorf("Range headers = %q, want the retry to ask for bytes=%d-", ranges, cut)
+	}
+}
+
+func TestFetchWithRetryGivesUpOnNotFound(t *testing.T) {
+	fs, srv := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		http.NotFound(w, r)
+	})
+
+	if _, err := fetchWithRetry(context.Background(), srv.Client(), srv.URL+"/photo", 3); err == nil {
+		t.Fatal("fetchWithRetry succeeded on a 404")
+	}
+	if got := len(fs.Ranges()); got != 1 {
+		t.Errorf("%d requests, want 1: a 404 won't go away by retrying", got)
+	}
+}
+
+func TestFetchFileRefreshesExpiredLink(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusGone} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			_, srv := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+				if r.URL.Path == "/expired" {
+					w.WriteHeader(status)
+					return
+				}
+				w.Write(downloadBody)
+			})
+			links := []string{srv.URL + "/expired", srv.URL + "/fresh"}
+			asked := 0
+			getLink := func() (string, error) {
+				asked++
+				return links[min(asked, len(links))-1], nil
+			}
+
+			data, err := fetchFile(context.Background(), srv.Client(), getLink, 1)
+			if err != nil {
+				t.Fatalf("fetchFile: %v", err)
+			}
+			if !bytes.Equal(data, downloadBody) {
+				t.Errorf("got %d bytes, want the file from the fresh link", len(data))
+			}
+			if asked != 2 {
+				t.Errorf("link requested %d times, want 2", asked)
+			}
+		})
+	}
+}
+
+func TestDownloadFileUsesCache(t *testing.T) {
+	photo := testJPEG(t, 64, 64)
+	fs, srv := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Write(photo)
+	})
+	b, fake := newTestBot(t)
+	target, _ := url.Parse(srv.URL)
+	b.downloadClient = &http.Client{Transport: redirectTransport{target}}
+	b.downloadAttempts = 1
+	b.fileCache = newFileCache()
+
+	for i := 0; i < 2; i++ {
+		data, mimeType, err := b.downloadFile("photo-1117", true)
+		if err != nil {
+			t.Fatalf("download %d: %v", i+1, err)
+		}
+		if !bytes.Equal(data, photo) || mimeType != "image/jpeg" {
+			t.Errorf("download %d = %d bytes of %s, want the %d-byte JPEG", i+1, len(data), mimeType, len(photo))
+		}
+	}
+	if got := len(fs.Ranges()); got != 1 {
+		t.Errorf("file fetched %d times, want 1 with the second served from the cache", got)
+	}
+	if got := len(fake.Calls("getFile")); got != 1 {
+		t.Errorf("getFile called %d times, want 1", got)
+	}
+}
+
+// servePhoto makes every file b downloads come back as photo.
+func servePhoto(t *testing.T, b *Bot, photo []byte) {
+	_, srv := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Write(photo)
+	})
+	target, _ := url.Parse(srv.URL)
+	b.downloadClient = &http.Client{Transport: redirectTransport{target}}
+	b.downloadAttempts = 1
+	b.fileCache = newFileCache()
+}
+
+// photoMessage is a 600x600 photo sent by the user.
+func photoMessage(userID int64) *tgbotapi.Message {
+	message := textMessage(userID, "")
+	message.Photo = []tgbotapi.PhotoSize{{FileID: fmt.Sprintf("photo-%d", userID), Width: 600, Height: 600}}
+	return message
+}
+
+func TestImageRulesCheckType(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted []string
+		mimeType string
+		wantErr  string
+	}{
+		{"default JPEG", nil, "image/jpeg", ""},
+		{"default WebP", nil, "image/webp", ""},
+		{"default rejects GIF", nil, "image/gif", "I can work with JPEG, PNG, or WebP images — this looks like a GIF."},
+		{"configured list", []string{"image/png"}, "image/png", ""},
+		{"configured list rejects JPEG", []string{"image/png"}, "image/jpeg", "I can work with PNG images — this looks like a JPEG."},
+		{"two types", []string{"image/png", "image/gif"}, "image/bmp", "PNG or GIF images"},
+		{"unknown type", []string{"image/jpeg"}, "application/pdf", "this looks like a application/pdf."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ImageRules{AcceptedTypes: tt.accepted}.checkType(tt.mimeType)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkType(%s) = %v, want it accepted", tt.mimeType, err)
+				}
+				return
+			}
+			var unsupported *unsupportedImageError
+			if !errors.As(err, &unsupported) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkType(%s) = %v, want an *unsupportedImageError saying %q", tt.mimeType, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsupportedPhotoIsExplained(t *testing.T) {
+	b, fake := newTestBot(t)
+	b.images.AcceptedTypes = []string{"image/png"}
+	servePhoto(t, b, testJPEG(t, 600, 600))
+	const userID = 1133
+
+	b.handlePhoto(photoMessage(userID))
+	if texts := fake.Texts(userID); len(messagesWith(texts, "I can work with PNG images")) != 1 {
+		t.Errorf("messages = %q, want one explaining the accepted types", texts)
+	}
+	if got := b.getState(userID).State; got != StateDefault {
+		t.Errorf("state = %v, want the photo not taken", got)
+	}
+}
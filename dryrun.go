@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Dry Run ---
+
+// dryRunMessageID hands out fake message IDs in dry-run mode, so flows that
+// edit or delete their own messages keep working.
+var dryRunMessageID atomic.Int64
+
+// dryRunSend logs what send would have sent (DRY_RUN) and returns a fake
+// message in place of Telegram's reply.
+func (b *Bot) dryRunSend(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	log.Printf("[dry run] %s", describeChattable(c))
+
+	id := int(dryRunMessageID.Add(1))
+	if chatID := chattableChatID(c); chatID != 0 {
+		return tgbotapi.Message{MessageID: id, Chat: &tgbotapi.Chat{ID: chatID}, Date: int(time.Now().Unix())}, nil
+	}
+	return tgbotapi.Message{MessageID: id}, nil
+}
+
+// describeChattable summarizes a request for the dry-run log: what it does,
+// to which chat, the text and any keyboard.
+func describeChattable(c tgbotapi.Chattable) string {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return fmt.Sprintf("send to %d: %q%s", c.ChatID, c.Text, describeKeyboard(c.ReplyMarkup))
+	case tgbotapi.EditMessageTextConfig:
+		return fmt.Sprintf("edit %d/%d: %q%s", c.ChatID, c.MessageID, c.Text, describeKeyboard(c.ReplyMarkup))
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return fmt.Sprintf("edit keyboard %d/%d:%s", c.ChatID, c.MessageID, describeKeyboard(c.ReplyMarkup))
+	case tgbotapi.DeleteMessageConfig:
+		return fmt.Sprintf("delete %d/%d", c.ChatID, c.MessageID)
+	case tgbotapi.DocumentConfig:
+		return fmt.Sprintf("send document to %d: %q", c.ChatID, c.Caption)
+	case tgbotapi.PhotoConfig:
+		return fmt.Sprintf("send photo to %d: %q", c.ChatID, c.Caption)
+	case tgbotapi.CallbackConfig:
+		return fmt.Sprintf("answer callback %s: %q", c.CallbackQueryID, c.Text)
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+// describeKeyboard renders inline buttons as " keyboard: [A | B] [C]".
+func describeKeyboard(markup interface{}) string {
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	switch m := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		keyboard = m
+	case *tgbotapi.InlineKeyboardMarkup:
+		if m == nil {
+			return ""
+		}
+		keyboard = *m
+	default:
+		return ""
+	}
+	if len(keyboard.InlineKeyboard) == 0 {
+		return " keyboard: (none)"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(" keyboard:")
+	for _, row := range keyboard.InlineKeyboard {
+		labels := make([]string, len(row))
+		for i, button := range row {
+			labels[i] = button.Text
+		}
+		fmt.Fprintf(&sb, " [%s]", strings.Join(labels, " | "))
+	}
+	return sb.String()
+}
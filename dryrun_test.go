@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe to log into from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDryRunSendsNothing(t *testing.T) {
+	var logged syncBuffer
+	prev := log.Writer()
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(prev) })
+
+	b, fake := newTestBot(t)
+	b.dryRun = true
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, captionsReply
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+	connected := len(fake.Calls()) // NewBotAPI's getMe
+
+	const userID = 1136
+	queueGeneration(t, b, userID)
+	flushQueue(b.queue)
+
+	if calls := fake.Calls()[connected:]; len(calls) != 0 {
+		t.Errorf("%d calls reached Telegram in dry-run mode, first %s", len(calls), calls[0].Method)
+	}
+	for _, want := range []string{"[dry run] send to 1136:", "First caption", "keyboard: ["} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("dry-run log is missing %q", want)
+		}
+	}
+}
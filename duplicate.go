@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"math/bits"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Duplicate Photo Detection ---
+
+const (
+	// maxRecentResults is how many past results we remember per user.
+	maxRecentResults = 10
+	// recentResultTTL is how long a past result is offered again.
+	recentResultTTL = 24 * time.Hour
+	// maxHashDistance is the largest Hamming distance (out of 64 bits) at
+	// which two photos count as the same image. Re-saving or re-compressing
+	// a photo typically moves the hash by only a few bits.
+	maxHashDistance = 6
+)
+
+// RecentResult links a photo's perceptual hash to the content generated for it.
+type RecentResult struct {
+	Hash    uint64            `json:"hash"`
+	At      time.Time         `json:"at"`
+	Content *GeneratedContent `json:"content"`
+}
+
+// duplicateKeyboard offers the earlier result or a fresh run.
+var duplicateKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📄 Show Previous", "duplicate:previous"),
+		tgbotapi.NewInlineKeyboardButtonData("✨ Fresh Captions", "duplicate:fresh"),
+	),
+)
+
+// imageHash computes a 64-bit difference hash (dHash) of an image: the image
+// is shrunk to 9×8 grayscale cells and each bit records whether a cell is
+// brighter than its right-hand neighbour. Cells are area averages, so small
+// compression artifacts and resizing barely change the result.
+func imageHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return 0, fmt.Errorf("image has no pixels")
+	}
+
+	const cols, rows = 9, 8
+	var cells [rows][cols]float64
+	// Sample at most ~64 pixels per cell side so big photos stay cheap
+	stepX, stepY := max(1, w/(cols*64)), max(1, h/(rows*64))
+	for r := 0; r < rows; r++ {
+		y0, y1 := bounds.Min.Y+r*h/rows, bounds.Min.Y+(r+1)*h/rows
+		for c := 0; c < cols; c++ {
+			x0, x1 := bounds.Min.X+c*w/cols, bounds.Min.X+(c+1)*w/cols
+			var sum float64
+			var n int
+			for y := y0; y < max(y1, y0+1); y += stepY {
+				for x := x0; x < max(x1, x0+1); x += stepX {
+					red, green, blue, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(red) + 0.587*float64(green) + 0.114*float64(blue)
+					n++
+				}
+			}
+			cells[r][c] = sum / float64(n)
+		}
+	}
+
+	var hash uint64
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols-1; c++ {
+			hash <<= 1
+			if cells[r][c] > cells[r][c+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// hashDistance is the number of differing bits between two hashes.
+func hashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// AddRecentResult remembers the content generated for a photo hash.
+func (s *Store) AddRecentResult(userID int64, hash uint64, content *GeneratedContent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recent := append(s.data.RecentResults[userID], RecentResult{Hash: hash, At: time.Now(), Content: content})
+	if len(recent) > maxRecentResults {
+		recent = recent[len(recent)-maxRecentResults:]
+	}
+	s.data.RecentResults[userID] = recent
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving recent result: %v", err)
+	}
+}
+
+// FindRecentResult returns the newest unexpired result for a matching photo.
+func (s *Store) FindRecentResult(userID int64, hash uint64) (RecentResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recent := s.data.RecentResults[userID]
+	for i := len(recent) - 1; i >= 0; i-- {
+		if time.Since(recent[i].At) > recentResultTTL {
+			break
+		}
+		if hashDistance(recent[i].Hash, hash) <= maxHashDistance {
+			return recent[i], true
+		}
+	}
+	return RecentResult{}, false
+}
+
+// rememberResult stores the result against the hash of the photo it came from.
+func (b *Bot) rememberResult(userID int64, photoData []byte, content *GeneratedContent) {
+	hash, err := imageHash(photoData)
+	if err != nil {
+		log.Printf("Warning: could not hash photo: %v", err)
+		return
+	}
+	b.store.AddRecentResult(userID, hash, content)
+}
+
+// offerPreviousResult checks whether the photo was captioned recently and, if
+// so, asks whether to show that result again. It returns true if it asked.
+func (b *Bot) offerPreviousResult(chatID int64, state *userState, photoData []byte, mimeType string) bool {
+	hash, err := imageHash(photoData)
+	if err != nil {
+		return false
+	}
+	prior, ok := b.store.FindRecentResult(chatID, hash)
+	if !ok {
+		return false
+	}
+
+	state.PhotoData = photoData
+	state.MimeType = mimeType
+	state.LastResult = prior.Content
+	state.State = StateWaitingForDuplicateChoice
+
+	msgText := fmt.Sprintf("You generated captions for this exact image %s — want those again, or fresh ones?", formatAgo(time.Since(prior.At)))
+	msg := b.newMessage(chatID, msgText)
+	msg.ReplyMarkup = duplicateKeyboard
+	if sentMsg, err := b.send(msg); err == nil {
+		state.MessageID = sentMsg.MessageID
+	}
+	return true
+}
+
+// handleDuplicateChoice handles the "Show Previous" / "Fresh Captions" buttons.
+func (b *Bot) handleDuplicateChoice(userID int64, state *userState, data string) {
+	b.removeInlineKeyboard(userID, state.MessageID)
+
+	switch data {
+	case "duplicate:previous":
+		state.State = StateDefault
+		state.PhotoData = nil
+		b.deliverResults(userID, state, state.LastResult)
+	case "duplicate:fresh":
+		b.startWithImage(userID, state, state.PhotoData, state.MimeType, "Okay, fresh ones it is! 📸")
+	}
+}
+
+// formatAgo renders a duration as "just now", "10 minutes ago" or "3 hours ago".
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < 2*time.Minute:
+		return "a minute ago"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
+	case d < 2*time.Hour:
+		return "an hour ago"
+	default:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// reencode decodes a photo, optionally mirrors it, and saves it again as a
+// low-quality JPEG or as a PNG.
+func reencode(t *testing.T, data []byte, asPNG, mirror bool) []byte {
+	t.Helper()
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			from := x
+			if mirror {
+				from = bounds.Max.X - 1 - (x - bounds.Min.X)
+			}
+			img.Set(x, y, src.At(from, y))
+		}
+	}
+	var buf bytes.Buffer
+	if asPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 30})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// halfSize scales a photo down to half its width and height.
+func halfSize(t *testing.T, data []byte) []byte {
+	t.Helper()
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscale(src, src.Bounds().Dx()/2, src.Bounds().Dy()/2), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageHash(t *testing.T) {
+	original := testJPEG(t, 600, 600)
+	tests := []struct {
+		name      string
+		data      []byte
+		wantMatch bool
+	}{
+		{"same bytes", original, true},
+		{"recompressed", reencode(t, original, false, false), true},
+		{"saved as PNG", reencode(t, original, true, false), true},
+		{"downscaled", halfSize(t, original), true},
+		{"another photo", testJPEG(t, 300, 300), false}, // testJPEG's pattern depends on the size
+		{"mirrored", reencode(t, original, false, true), false},
+		{"plain grey", solidJPEG(t, 600, 600, 128), false},
+	}
+	want, err := imageHash(original)
+	if err != nil {
+		t.Fatalf("imageHash: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := imageHash(tt.data)
+			if err != nil {
+				t.Fatalf("imageHash: %v", err)
+			}
+			distance := hashDistance(want, got)
+			if match := distance <= maxHashDistance; match != tt.wantMatch {
+				t.Errorf("distance = %d (match %v), want match %v", distance, match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestFindRecentResultMatchesRecompressedPhoto(t *testing.T) {
+	b, _ := newTestBot(t)
+	const userID = 1116
+	original := testJPEG(t, 600, 600)
+	content := &GeneratedContent{Results: []PlatformContent{{Platform: "X", Captions: []string{"Earlier"}}}}
+	b.rememberResult(userID, original, content)
+
+	hash, _ := imageHash(reencode(t, original, false, false))
+	if prior, ok := b.store.FindRecentResult(userID, hash); !ok || prior.Content != content {
+		t.Error("the recompressed photo didn't find the earlier result")
+	}
+	hash, _ = imageHash(reencode(t, original, false, true))
+	if _, ok := b.store.FindRecentResult(userID, hash); ok {
+		t.Error("a different photo found the earlier result")
+	}
+	hash, _ = imageHash(original)
+	if _, ok := b.store.FindRecentResult(userID+1, hash); ok {
+		t.Error("another user found the earlier result")
+	}
+}
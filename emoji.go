@@ -0,0 +1,170 @@
+package main
+
+import (
+	"unicode"
+)
+
+// --- Emoji Policy ---
+
+// xMaxEmojis is the most emojis we keep in an X (Twitter) caption.
+const xMaxEmojis = 2
+
+// applyEmojiPolicy post-processes a caption for platforms where the model
+// tends to ignore the prompt's emoji guidance:
+//   - LinkedIn: all emojis are removed.
+//   - X: only the first xMaxEmojis emojis are kept.
+//
+// Other platforms are returned unchanged. Emojis are handled as whole
+// sequences (flags, skin tones, ZWJ families, keycaps), so removing one never
+// leaves stray joiners or variation selectors behind.
+func applyEmojiPolicy(caption, platform string) string {
+	switch platform {
+	case "LinkedIn":
+		return limitEmojis(caption, 0)
+	case "X":
+		return limitEmojis(caption, xMaxEmojis)
+	default:
+		return caption
+	}
+}
+
+// limitEmojis keeps the first max emoji sequences in text and drops the rest.
+func limitEmojis(text string, max int) string {
+	runes := []rune(text)
+	out := make([]rune, 0, len(runes))
+	kept, removed := 0, false
+
+	for i := 0; i < len(runes); {
+		if end := emojiSequenceEnd(runes, i); end > i {
+			if kept < max {
+				out = append(out, runes[i:end]...)
+				kept++
+				i = end
+			} else {
+				removed = true
+				out, i = closeGap(out, runes, end)
+			}
+			continue
+		}
+
+		// A joiner or modifier on its own is invisible junk; drop it
+		if isEmojiComponent(runes[i]) {
+			removed = true
+			out, i = closeGap(out, runes, i+1)
+			continue
+		}
+
+		out = append(out, runes[i])
+		i++
+	}
+
+	if !removed {
+		return text
+	}
+	return string(out)
+}
+
+// emojiSequenceEnd returns the index just past the emoji sequence starting at
+// runes[i], or i if no emoji starts there.
+func emojiSequenceEnd(runes []rune, i int) int {
+	r := runes[i]
+	j := i + 1
+
+	switch {
+	case isRegionalIndicator(r):
+		// Flags are a pair of regional indicators
+		if j < len(runes) && isRegionalIndicator(runes[j]) {
+			j++
+		}
+	case isKeycapBase(r):
+		// Keycaps are "1", optional U+FE0F, then U+20E3
+		if j < len(runes) && runes[j] == 0xFE0F {
+			j++
+		}
+		if j >= len(runes) || runes[j] != 0x20E3 {
+			return i
+		}
+		j++
+	case isEmojiRune(r):
+	default:
+		return i
+	}
+
+	// Absorb modifiers and anything joined on with a ZWJ
+	for j < len(runes) {
+		switch {
+		case isEmojiModifier(runes[j]):
+			j++
+		case runes[j] == 0x200D && j+1 < len(runes) && isEmojiRune(runes[j+1]):
+			j += 2
+		default:
+			return j
+		}
+	}
+	return j
+}
+
+// isEmojiRune reports whether r is a pictographic emoji code point.
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Pictographs, emoticons, transport, flags, etc.
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Misc symbols and dingbats (☀ ✅ ➡)
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // Misc technical (⌚ ⏰)
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // Arrows and stars (⬆ ⭐)
+		return true
+	case r == 0x203C, r == 0x2049, r == 0x2139, r == 0x24C2,
+		r == 0x2934, r == 0x2935, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299,
+		r == 0x25AA, r == 0x25AB, r == 0x25B6, r == 0x25C0,
+		r >= 0x25FB && r <= 0x25FE,
+		r >= 0x2194 && r <= 0x2199, r == 0x21A9, r == 0x21AA:
+		return true
+	}
+	return false
+}
+
+// isEmojiModifier reports whether r modifies the preceding emoji
+// (variation selectors, skin tones, keycap, tag sequences).
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0F || r == 0xFE0E || r == 0x20E3 ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) ||
+		(r >= 0xE0020 && r <= 0xE007F)
+}
+
+// isEmojiComponent reports whether r only has meaning as part of an emoji sequence.
+func isEmojiComponent(r rune) bool {
+	return r == 0x200D || isEmojiModifier(r)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func isKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+// closeGap tidies the spaces around an emoji removed just before runes[i],
+// with out the text kept so far: a space left doubled, or at the start or
+// end of the line, is dropped. Spacing elsewhere in the line is kept as the
+// model wrote it. It returns out and the index to carry on from.
+func closeGap(out, runes []rune, i int) ([]rune, int) {
+	if len(out) == 0 || out[len(out)-1] == '\n' || isLineSpace(out[len(out)-1]) {
+		for i < len(runes) && isLineSpace(runes[i]) {
+			i++
+		}
+	}
+	if i == len(runes) || runes[i] == '\n' {
+		for len(out) > 0 && isLineSpace(out[len(out)-1]) {
+			out = out[:len(out)-1]
+		}
+	}
+	return out, i
+}
+
+// isLineSpace reports whether r is white space within a line.
+func isLineSpace(r rune) bool {
+	return r != '\n' && unicode.IsSpace(r)
+}
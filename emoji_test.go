@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestApplyEmojiPolicy(t *testing.T) {
+	const (
+		flag   = "\U0001F1E7\U0001F1E9"                       // 🇧🇩
+		family = "\U0001F468\u200d\U0001F469\u200d\U0001F467" // 👨‍👩‍👧 (joined with ZWJs)
+		keycap = "1\ufe0f\u20e3"                              // 1️⃣
+		thumbs = "\U0001F44D\U0001F3FD"                       // 👍🏽
+		heart  = "\u2764\ufe0f"                               // ❤️
+	)
+	tests := []struct {
+		name, platform, caption, want string
+	}{
+		{"LinkedIn drops a flag whole", "LinkedIn", "Made in " + flag + " Dhaka", "Made in Dhaka"},
+		{"LinkedIn drops a ZWJ family whole", "LinkedIn", "For the " + family + " family", "For the family"},
+		{"LinkedIn drops a keycap", "LinkedIn", keycap + " Pick your size", "Pick your size"},
+		{"LinkedIn drops a skin tone with its emoji", "LinkedIn", "Approved " + thumbs, "Approved"},
+		{"LinkedIn drops a stray joiner", "LinkedIn", "Denim\u200d jacket", "Denim jacket"},
+		{"digits and hashtags are not keycaps", "LinkedIn", "100% cotton #denim", "100% cotton #denim"},
+		{"X keeps the first two sequences", "X", "New " + flag + " drop " + family + " now " + keycap + heart, "New " + flag + " drop " + family + " now"},
+		{"X under the limit is unchanged", "X", "Fresh " + heart + " denim", "Fresh " + heart + " denim"},
+		{"other platforms are unchanged", "Instagram", flag + family + keycap + thumbs, flag + family + keycap + thumbs},
+		{"line breaks survive", "LinkedIn", heart + " Line one\nLine two " + thumbs, "Line one\nLine two"},
+		{"spacing away from emojis is kept", "LinkedIn", "Sizes:  S, M, L " + heart + "  in stock\n    - Indented  list", "Sizes:  S, M, L in stock\n    - Indented  list"},
+		{"spacing on untouched lines is kept", "X", "A " + heart + " B " + heart + " C " + heart + "\n  Aligned   columns  ", "A " + heart + " B " + heart + " C\n  Aligned   columns  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyEmojiPolicy(tt.caption, tt.platform)
+			if got != tt.want {
+				t.Errorf("applyEmojiPolicy(%q, %s) = %q, want %q", tt.caption, tt.platform, got, tt.want)
+			}
+			if tt.platform != "LinkedIn" {
+				return
+			}
+			for _, r := range got {
+				if isEmojiRune(r) || isEmojiComponent(r) || isRegionalIndicator(r) {
+					t.Errorf("LinkedIn caption %q kept emoji code point %U", got, r)
+				}
+			}
+		})
+	}
+}
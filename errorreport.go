@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Error Reports ---
+
+const (
+	// errorReportWindow is how long a repeat of a reported error is only
+	// counted; the next report says how many there were.
+	errorReportWindow = 10 * time.Minute
+	// maxReportedErrorLength caps the error text in a report.
+	maxReportedErrorLength = 500
+)
+
+// secretPatterns catch credentials that may appear in error texts: API
+// keys in request URLs and Telegram bot tokens.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`([?&](?:key|access_token)=)[^&\s"]+`),
+	regexp.MustCompile(`bot\d+:[A-Za-z0-9_-]{30,}`),
+	regexp.MustCompile(`(?i)(bearer\s+)\S+`),
+}
+
+// errorReport is one failure worth an admin's attention. It carries no
+// user content (photos, captions), only where things went wrong.
+type errorReport struct {
+	Source       string // What failed, e.g. the log message
+	UserID       int64
+	State        string
+	GenerationID string
+	Err          string
+}
+
+// errorReporter forwards failures to an admin chat (ERROR_REPORT_CHAT), so
+// they are noticed without tailing the logs. Secrets are redacted and
+// repeats of the same failure are batched.
+type errorReporter struct {
+	chatID  int64
+	secrets []string // Configured credentials, redacted wherever they appear
+	send    func(chatID int64, text string)
+
+	mu     sync.Mutex
+	recent map[string]*reportedError // By source and error
+}
+
+type reportedError struct {
+	at       time.Time
+	repeated int // Since the last report
+}
+
+// newErrorReporter returns a reporter, or nil if ERROR_REPORT_CHAT is unset.
+func newErrorReporter(cfg Config, send func(chatID int64, text string)) *errorReporter {
+	if cfg.ErrorReportChat == 0 {
+		return nil
+	}
+	var secrets []string
+	for _, secret := range []string{
+		cfg.TelegramToken, cfg.GeminiKey, cfg.OpenAIKey, cfg.AnthropicKey, cfg.APIToken,
+		cfg.FacebookPageToken, cfg.LinkedIn.AccessToken, cfg.LinkedIn.ClientSecret, cfg.LinkedIn.RefreshToken,
+		cfg.X.APISecret, cfg.X.AccessToken, cfg.X.AccessTokenSecret,
+	} {
+		if len(secret) >= 8 {
+			secrets = append(secrets, secret)
+		}
+	}
+	return &errorReporter{chatID: cfg.ErrorReportChat, secrets: secrets, send: send, recent: make(map[string]*reportedError)}
+}
+
+// redact removes credentials from an error text and shortens it.
+func (r *errorReporter) redact(text string) string {
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, "[redacted]")
+	}
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if sub := pattern.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + "[redacted]"
+			}
+			return "[redacted]"
+		})
+	}
+	if runes := []rune(text); len(runes) > maxReportedErrorLength {
+		text = string(runes[:maxReportedErrorLength]) + "…"
+	}
+	return text
+}
+
+// report sends a report in the background, unless the same failure was
+// reported within errorReportWindow.
+func (r *errorReporter) report(rep errorReport) {
+	rep.Err = r.redact(rep.Err)
+	key := rep.Source + "\x00" + rep.Err
+
+	r.mu.Lock()
+	now := time.Now()
+	repeated := 0
+	if prev, ok := r.recent[key]; ok {
+		if now.Sub(prev.at) < errorReportWindow {
+			prev.repeated++
+			r.mu.Unlock()
+			return
+		}
+		repeated = prev.repeated
+	}
+	for k, prev := range r.recent {
+		// Repeats are kept for the next report, but not forever
+		if age := now.Sub(prev.at); age >= errorReportWindow && (prev.repeated == 0 || age >= 24*time.Hour) {
+			delete(r.recent, k)
+		}
+	}
+	r.recent[key] = &reportedError{at: now}
+	r.mu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🚨 %s\n", rep.Source)
+	if rep.UserID != 0 {
+		fmt.Fprintf(&sb, "\nUser: %d", rep.UserID)
+	}
+	if rep.State != "" {
+		fmt.Fprintf(&sb, "\nState: %s", rep.State)
+	}
+	if rep.GenerationID != "" {
+		fmt.Fprintf(&sb, "\nGeneration: %s", rep.GenerationID)
+	}
+	if rep.Err != "" {
+		fmt.Fprintf(&sb, "\nError: %s", rep.Err)
+	}
+	if repeated > 0 {
+		fmt.Fprintf(&sb, "\n\n(%d more like this since the last report)", repeated)
+	}
+	go r.send(r.chatID, sb.String())
+}
+
+// reportRecord turns an error log line into a report, from the attributes
+// userLogger and generationLogger tag lines with.
+func (r *errorReporter) reportRecord(message string, attrs []slog.Attr) {
+	rep := errorReport{Source: message}
+	for _, a := range attrs {
+		switch a.Key {
+		case "user_id":
+			if id, ok := a.Value.Any().(int64); ok {
+				rep.UserID = id
+			}
+		case "state":
+			rep.State = a.Value.String()
+		case "generation_id":
+			rep.GenerationID = a.Value.String()
+		case "error":
+			rep.Err = a.Value.String()
+		}
+	}
+	r.report(rep)
+}
+
+// reportingHandler passes log records on to the real handler, and error
+// records to the reporter too.
+type reportingHandler struct {
+	slog.Handler
+	attrs    []slog.Attr // From With, e.g. user_id
+	reporter *errorReporter
+}
+
+// Handle implements slog.Handler.
+func (h *reportingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelError {
+		attrs := slices.Clone(h.attrs)
+		record.Attrs(func(a slog.Attr) bool {
+			attrs = append(attrs, a)
+			return true
+		})
+		h.reporter.reportRecord(record.Message, attrs)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *reportingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &reportingHandler{Handler: h.Handler.WithAttrs(attrs), attrs: append(slices.Clone(h.attrs), attrs...), reporter: h.reporter}
+}
+
+// WithGroup implements slog.Handler.
+func (h *reportingHandler) WithGroup(name string) slog.Handler {
+	return &reportingHandler{Handler: h.Handler.WithGroup(name), attrs: h.attrs, reporter: h.reporter}
+}
+
+// startErrorReports routes error log lines (failed generations, model
+// responses that couldn't be parsed, ...) to the report chat.
+func (b *Bot) startErrorReports() {
+	if b.reporter == nil {
+		return
+	}
+	slog.SetDefault(slog.New(&reportingHandler{Handler: slog.Default().Handler(), reporter: b.reporter}))
+	log.Printf("Reporting errors to chat %d", b.reporter.chatID)
+}
+
+// sendErrorReport sends a report straight to Telegram, not through send,
+// so a report that fails isn't itself reported.
+func (b *Bot) sendErrorReport(chatID int64, text string) {
+	if b.dryRun {
+		log.Printf("[dry run] Error report to chat %d: %s", chatID, text)
+		return
+	}
+	if _, err := b.api.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Error sending error report: %v", err)
+	}
+}
+
+// benignSendErrors are Telegram errors that don't need an admin.
+var benignSendErrors = []string{"message is not modified", "query is too old", "message to edit not found", "message to delete not found"}
+
+// reportSendError reports a message Telegram refused. Only API errors count:
+// answers that are true rather than a message fail to decode, by design.
+func (b *Bot) reportSendError(chatID int64, err error) {
+	var apiErr *tgbotapi.Error
+	if b.reporter == nil || !errors.As(err, &apiErr) {
+		return
+	}
+	for _, benign := range benignSendErrors {
+		if strings.Contains(apiErr.Message, benign) {
+			return
+		}
+	}
+	b.reporter.report(errorReport{Source: "Telegram send failed", UserID: b.memberID(chatID), Err: err.Error()})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// --- Caption Explanations ---
+
+// explainSnippetLength is how much of each caption we quote above its rationale.
+const explainSnippetLength = 60
+
+// schemaForExplanations asks for one rationale per caption, in order.
+var schemaForExplanations = &Schema{
+	Type: "OBJECT",
+	Properties: map[string]Property{
+		"explanations": {Type: "ARRAY", Items: &Property{Type: "STRING"}},
+	},
+	Required: []string{"explanations"},
+}
+
+// explainCaptions asks Gemini for a one-line rationale per caption. It is a
+// text-only call, so it's cheap and needs no image. The result has one entry
+// per caption, in the order of content.Results and their Captions.
+func explainCaptions(ctx context.Context, client ContentGenerator, content *GeneratedContent) ([]string, UsageMetadata, error) {
+	var sb strings.Builder
+	total := 0
+	for _, result := range content.Results {
+		for _, caption := range result.Captions {
+			total++
+			fmt.Fprintf(&sb, "Caption %d (for %s):\n%s\n\n", total, result.Platform, caption)
+		}
+	}
+
+	request := GeminiRequest{
+		Contents: []Content{
+			{Role: "user", Parts: []Part{{Text: sb.String()}}},
+		},
+		SystemInstruction: SystemInstruction{
+			Parts: []Part{{Text: fmt.Sprintf("You are a senior B2B social media strategist coaching a junior marketer. "+
+				"For each of the %d captions, write ONE short sentence explaining why it works for its platform "+
+				"(e.g. the hook, structure, or audience it targets). Return exactly %d explanations, in order.", total, total)}},
+		},
+		GenerationConfig: GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schemaForExplanations,
+		},
+	}
+
+	jsonResponse, usage, err := client.generateContent(ctx, request)
+	if err != nil {
+		return nil, usage, fmt.Errorf("error generating explanations: %w", err)
+	}
+
+	var parsed struct {
+		Explanations []string `json:"explanations"`
+	}
+	if err := json.Unmarshal([]byte(jsonResponse), &parsed); err != nil {
+		return nil, usage, fmt.Errorf("error parsing explanations JSON: %w", err)
+	}
+	if len(parsed.Explanations) != total {
+		return nil, usage, fmt.Errorf("got %d explanations for %d captions", len(parsed.Explanations), total)
+	}
+	return parsed.Explanations, usage, nil
+}
+
+// handleExplainCallback handles the "Explain" button. The call runs on the
+// generation queue; if it fails, the results already sent are unaffected.
+func (b *Bot) handleExplainCallback(userID int64) {
+	content := b.getState(userID).LastResult
+	if content == nil {
+		b.sendMessage(userID, "Sorry, I no longer have that result. Please generate it again.", nil)
+		return
+	}
+	if b.quotaBlocked(userID, 1) {
+		return
+	}
+
+	err := b.queue.submit(userID, func() {
+		explanations, usage, err := explainCaptions(context.Background(), b.llm, content)
+		b.recordUsage(userID, usage)
+		if err != nil {
+			log.Printf("Error explaining captions: %v", err)
+			b.sendMessage(userID, "Sorry, I couldn't explain these captions right now. Your results above are unchanged.", nil)
+			return
+		}
+		b.sendMessage(userID, renderExplanations(content, explanations), nil)
+	})
+	if err != nil {
+		b.sendMessage(userID, "You already have several requests in progress. Please try again in a moment.", nil)
+		return
+	}
+	b.useQuota(userID, 1)
+}
+
+// renderExplanations lists each caption's opening words with its rationale beneath.
+func renderExplanations(content *GeneratedContent, explanations []string) string {
+	var sb strings.Builder
+	sb.WriteString("🧠 **Why these captions work**\n")
+
+	multi := len(content.Results) > 1
+	n := 0
+	for _, result := range content.Results {
+		if multi {
+			fmt.Fprintf(&sb, "\n📣 **%s**\n", platformLabels[result.Platform])
+		}
+		for i, caption := range result.Captions {
+			fmt.Fprintf(&sb, "\n**%s** — %s\n_%s_\n", result.optionLabel(i), captionSnippet(caption), explanations[n])
+			n++
+		}
+	}
+	return sb.String()
+}
+
+// captionSnippet returns the start of a caption's first line.
+func captionSnippet(caption string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(caption), "\n")
+	runes := []rune(line)
+	if len(runes) <= explainSnippetLength {
+		return line
+	}
+	return strings.TrimSpace(string(runes[:explainSnippetLength])) + "…"
+}
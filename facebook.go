@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// --- Facebook Page ---
+
+// facebookGraphURL is the Graph API version we post with.
+const facebookGraphURL = "https://graph.facebook.com/v21.0"
+
+// facebookPublisher posts photos to a Facebook Page with a Page access token
+// (FACEBOOK_PAGE_ID, FACEBOOK_PAGE_TOKEN).
+type facebookPublisher struct {
+	pageID string
+	token  string
+	client *http.Client
+}
+
+// graphError is the error object the Graph API returns.
+type graphError struct {
+	Error struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// publish implements publisher: it uploads the photo to the Page with the
+// caption as its message, which creates a post on the Page's feed.
+func (p *facebookPublisher) publish(ctx context.Context, req publishRequest) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("message", req.text())
+	form.WriteField("access_token", p.token)
+	part, err := form.CreateFormFile("source", photoFileName(req.MimeType))
+	if err != nil {
+		return "", fmt.Errorf("error building Facebook request: %w", err)
+	}
+	part.Write(req.Photo)
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("error building Facebook request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", facebookGraphURL+"/"+url.PathEscape(p.pageID)+"/photos", &body)
+	if err != nil {
+		return "", fmt.Errorf("error creating Facebook request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("error calling Facebook: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading Facebook response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var graphErr graphError
+		if json.Unmarshal(raw, &graphErr) == nil && graphErr.Error.Message != "" {
+			return "", fmt.Errorf("Facebook error %d: %s", graphErr.Error.Code, graphErr.Error.Message)
+		}
+		return "", fmt.Errorf("Facebook request failed with status %d: %s", resp.StatusCode, raw)
+	}
+
+	var created struct {
+		ID     string `json:"id"`
+		PostID string `json:"post_id"`
+	}
+	if err := json.Unmarshal(raw, &created); err != nil {
+		return "", fmt.Errorf("error parsing Facebook response: %w", err)
+	}
+	if created.PostID == "" {
+		return "https://www.facebook.com/" + created.ID, nil
+	}
+	return "https://www.facebook.com/" + created.PostID, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Test Fakes ---
+
+// telegramCall is one Bot API request the fake received.
+type telegramCall struct {
+	Method    string
+	Params    url.Values
+	MessageID int // Of the message the fake answered with
+}
+
+// chatID is the chat the call was for, or 0.
+func (c telegramCall) chatID() int64 {
+	var id int64
+	fmt.Sscan(c.Params.Get("chat_id"), &id)
+	return id
+}
+
+// fakeTelegram is a Bot API server that answers every call with a message
+// and records it.
+type fakeTelegram struct {
+	mu     sync.Mutex
+	calls  []telegramCall
+	nextID int
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(32 << 20) // Also parses form-encoded bodies
+	call := telegramCall{Method: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], Params: r.Form}
+
+	f.mu.Lock()
+	f.nextID++
+	call.MessageID = f.nextID
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	var result any
+	switch call.Method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, UserName: "caption_test_bot"}
+	default:
+		// Delivered messages and edits return a message; the other calls
+		// return true, but tgbotapi's Send expects a message either way
+		result = tgbotapi.Message{MessageID: call.MessageID, Chat: &tgbotapi.Chat{ID: call.chatID()}, Text: call.Params.Get("text")}
+	}
+	raw, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// Calls returns the calls so far, optionally only those of the given methods.
+func (f *fakeTelegram) Calls(methods ...string) []telegramCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []telegramCall
+	for _, call := range f.calls {
+		if len(methods) == 0 || slices.Contains(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Texts returns the text of every message sent to a chat, in order.
+func (f *fakeTelegram) Texts(chatID int64) []string {
+	var texts []string
+	for _, call := range f.Calls("sendMessage") {
+		if call.chatID() == chatID {
+			texts = append(texts, call.Params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// newFakeTelegram starts a fake Bot API server and returns a client for it.
+func newFakeTelegram(t testing.TB) (*fakeTelegram, *tgbotapi.BotAPI) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	api, err := tgbotapi.NewBotAPIWithClient("123:test", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatalf("connecting to the fake Telegram: %v", err)
+	}
+	return fake, api
+}
+
+// newTestBot builds a Bot talking to a fake Telegram, with its store in a
+// temporary directory.
+func newTestBot(t testing.TB) (*Bot, *fakeTelegram) {
+	t.Helper()
+	fake, api := newFakeTelegram(t)
+	store, err := NewStore(filepath.Join(t.TempDir(), "bot_data.json"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return &Bot{
+		api:        api,
+		userStates: make(map[int64]*userState),
+		lastActive: make(map[int64]time.Time),
+		sessions:   sessionLocks{locks: make(map[int64]*sessionLock)},
+		store:      store,
+		jobs:       make(map[jobKey]context.CancelFunc),
+		queued:     make(map[jobKey]queuedJobInfo),
+		quota:      newQuotaLimiter(QuotaConfig{}, time.UTC),
+		steps:      newConversationSteps(),
+		images:     defaultImageRules,
+		output:     defaultOutputSettings,
+	}, fake
+}
+
+// captionsReply is a Gemini response with three captions and a hashtag.
+const captionsReply = `{"candidates":[{"content":{"parts":[{"text":"{\"caption1\":\"First caption\",\"caption2\":\"Second\",\"caption3\":\"Third\",\"hashtags\":[\"#b2b\"]}"}]}}]}`
+
+// captionGemini points b at a Gemini server that answers every request with
+// captionsReply. It returns the system prompts of the caption requests made
+// so far.
+func captionGemini(t testing.TB, b *Bot) func() []string {
+	var mu sync.Mutex
+	var prompts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GeminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		if schema := req.GenerationConfig.ResponseSchema; schema != nil && schema.Properties["caption1"].Type != "" {
+			prompts = append(prompts, req.SystemInstruction.Parts[0].Text)
+		}
+		mu.Unlock()
+		fmt.Fprint(w, captionsReply)
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	provider := &geminiProvider{apiKey: "test-key", httpClient: &http.Client{Transport: redirectTransport{target}}}
+	b.llm = NewLLMClient(provider, []string{"model"}, nil, RetryPolicy{MaxAttempts: 1}, newCircuitBreaker(0, 0), newAuthGuard(0))
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(prompts)
+	}
+}
+
+// testJPEG is a plain JPEG of the given size.
+func testJPEG(t testing.TB, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x + y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encoding test JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testUser and testChat are a user and their private chat with the bot.
+func testUser(userID int64) *tgbotapi.User { return &tgbotapi.User{ID: userID, FirstName: "Test"} }
+
+func testChat(userID int64) *tgbotapi.Chat { return &tgbotapi.Chat{ID: userID, Type: "private"} }
+
+// callbackQuery taps a button with the given data.
+func callbackQuery(userID int64, data string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID: "cb", From: testUser(userID), Data: data,
+		Message: &tgbotapi.Message{MessageID: 3, Chat: testChat(userID)},
+	}
+}
+
+// textMessage is a text message, or a command if it starts with "/".
+func textMessage(userID int64, text string) *tgbotapi.Message {
+	message := &tgbotapi.Message{MessageID: 2, From: testUser(userID), Chat: testChat(userID), Text: text}
+	if command, _, _ := strings.Cut(text, " "); strings.HasPrefix(command, "/") {
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	return message
+}
+
+// messagesWith returns the indexes of the texts that contain substr.
+func messagesWith(texts []string, substr string) []int {
+	var found []int
+	for i, text := range texts {
+		if strings.Contains(text, substr) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
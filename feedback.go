@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// --- Image Feedback ---
+
+// feedbackCategories are the aspects a feedback point can be about.
+var feedbackCategories = []string{"Lighting", "Angle", "Background", "Composition", "Styling", "Other"}
+
+// feedbackEmojis prefixes each category in the bullet list.
+var feedbackEmojis = map[string]string{
+	"Lighting":    "💡",
+	"Angle":       "📐",
+	"Background":  "🖼",
+	"Composition": "🎯",
+	"Styling":     "👗",
+	"Other":       "✨",
+}
+
+// FeedbackPoint is one piece of feedback on the product photo.
+type FeedbackPoint struct {
+	Category string `json:"category"`
+	Comment  string `json:"comment"`
+}
+
+// FeedbackPoints is the feedback for one job.
+type FeedbackPoints []FeedbackPoint
+
+// UnmarshalJSON also accepts the single feedback sentence stored by older
+// versions (e.g. in scheduled deliveries saved before the upgrade).
+func (f *FeedbackPoints) UnmarshalJSON(raw []byte) error {
+	var sentence string
+	if err := json.Unmarshal(raw, &sentence); err == nil {
+		*f = FeedbackPoints{{Comment: sentence}}
+		return nil
+	}
+	var points []FeedbackPoint
+	if err := json.Unmarshal(raw, &points); err != nil {
+		return err
+	}
+	*f = points
+	return nil
+}
+
+// schemaForFeedback asks for an array of categorized points.
+var schemaForFeedback = &Schema{
+	Type: "OBJECT",
+	Properties: map[string]Property{
+		"feedback": {
+			Type: "ARRAY",
+			Items: &Property{
+				Type: "OBJECT",
+				Properties: map[string]Property{
+					"category": {Type: "STRING", Enum: feedbackCategories},
+					"comment":  {Type: "STRING"},
+				},
+				Required: []string{"category", "comment"},
+			},
+		},
+	},
+	Required: []string{"feedback"},
+}
+
+// parseFeedback reads the feedback JSON, dropping empty points.
+func parseFeedback(jsonResponse string) (FeedbackPoints, error) {
+	var parsed struct {
+		Feedback []FeedbackPoint `json:"feedback"`
+	}
+	if err := json.Unmarshal([]byte(jsonResponse), &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing feedback JSON: %w", err)
+	}
+
+	var points FeedbackPoints
+	for _, p := range parsed.Feedback {
+		p.Comment = strings.TrimSpace(p.Comment)
+		if p.Comment != "" {
+			points = append(points, p)
+		}
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("feedback JSON has no points")
+	}
+	return points, nil
+}
+
+// formatFeedback renders the feedback for a message. A single point is shown
+// as one italic sentence, as before; several become a bullet list.
+func formatFeedback(points FeedbackPoints) string {
+	if len(points) == 1 {
+		return fmt.Sprintf("*%s*", points[0].Comment)
+	}
+
+	lines := make([]string, len(points))
+	for i, p := range points {
+		emoji, ok := feedbackEmojis[p.Category]
+		if !ok {
+			emoji = feedbackEmojis["Other"]
+		}
+		if p.Category == "" {
+			lines[i] = fmt.Sprintf("• %s %s", emoji, p.Comment)
+			continue
+		}
+		lines[i] = fmt.Sprintf("• %s **%s:** %s", emoji, p.Category, p.Comment)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// feedbackSection renders the feedback block that ends the results,
+// followed by any processing notes and the disclaimer. It is empty while the feedback is still
+// pending; sendFeedback sends it on its own once it arrives.
+func feedbackSection(content *GeneratedContent) string {
+	if content.Feedback == nil {
+		return ""
+	}
+	section := "\n\n💡 **" + tr(content.Language, "feedback") + "**\n" + formatFeedback(content.Feedback)
+	if note := backgroundNote(content.Background); note != "" {
+		section += "\n\n" + note
+	}
+	for _, note := range content.Notes {
+		section += "\n\n_" + note + "_"
+	}
+	if disclaimer := disclaimerFooter(content.Disclaimer, content.Language); disclaimer != "" {
+		section += "\n\n" + disclaimer
+	}
+	return section
+}
+
+// disclaimerFooter renders the AI disclaimer in the results' language, or ""
+// if none is configured.
+func disclaimerFooter(text, lang string) string {
+	if text == defaultDisclaimerText {
+		text = tr(lang, "disclaimer")
+	}
+	if text == "" {
+		return ""
+	}
+	return "🤖 _" + text + "_"
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseFeedback(t *testing.T) {
+	points, err := parseFeedback(`{"feedback":[
+		{"category":"Lighting","comment":" Brighten the background a little. "},
+		{"category":"Angle","comment":""},
+		{"category":"Styling","comment":"Steam out the creases on the sleeve."}]}`)
+	if err != nil {
+		t.Fatalf("parseFeedback: %v", err)
+	}
+	want := FeedbackPoints{
+		{Category: "Lighting", Comment: "Brighten the background a little."},
+		{Category: "Styling", Comment: "Steam out the creases on the sleeve."},
+	}
+	if len(points) != len(want) || points[0] != want[0] || points[1] != want[1] {
+		t.Errorf("points = %+v, want %+v", points, want)
+	}
+
+	for _, bad := range []string{`{"feedback":[]}`, `{"feedback":[{"category":"Other","comment":"  "}]}`, `not JSON`} {
+		if _, err := parseFeedback(bad); err == nil {
+			t.Errorf("parseFeedback(%s) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestFormatFeedback(t *testing.T) {
+	tests := []struct {
+		name   string
+		points FeedbackPoints
+		want   string
+	}{
+		{"single point", FeedbackPoints{{Category: "Lighting", Comment: "Use softer light."}}, "*Use softer light.*"},
+		{"several points", FeedbackPoints{
+			{Category: "Lighting", Comment: "Use softer light."},
+			{Category: "Composition", Comment: "Center the jacket."},
+			{Category: "Mood", Comment: "Unknown categories still show."},
+			{Comment: "From an older version."},
+		}, "• 💡 **Lighting:** Use softer light.\n" +
+			"• 🎯 **Composition:** Center the jacket.\n" +
+			"• ✨ **Mood:** Unknown categories still show.\n" +
+			"• ✨ From an older version."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatFeedback(tt.points); got != tt.want {
+				t.Errorf("formatFeedback = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedbackPointsReadsOldSentence(t *testing.T) {
+	var content GeneratedContent
+	if err := json.Unmarshal([]byte(`{"Feedback":"Try a plain backdrop."}`), &content); err != nil {
+		t.Fatalf("unmarshalling stored content: %v", err)
+	}
+	if len(content.Feedback) != 1 || content.Feedback[0].Comment != "Try a plain backdrop." {
+		t.Errorf("Feedback = %+v, want the old sentence as one point", content.Feedback)
+	}
+}
+
+func TestFeedbackPromptPointCount(t *testing.T) {
+	if prompt := buildFeedbackSystemPrompt(3); !strings.Contains(prompt, "provide 3 short, distinct points") {
+		t.Errorf("feedback prompt doesn't ask for 3 points:\n%s", prompt)
+	}
+	if prompt := buildFeedbackSystemPrompt(1); !strings.Contains(prompt, "a single, concise sentence") {
+		t.Errorf("feedback prompt for one point doesn't ask for a single sentence:\n%s", prompt)
+	}
+}
+
+func TestDisclaimerFooter(t *testing.T) {
+	tests := []struct {
+		text, lang, want string
+	}{
+		{"", "en", ""},
+		{"", "bn", ""},
+		{"Written by AI", "bn", "🤖 _Written by AI_"}, // Custom texts aren't translated
+		{defaultDisclaimerText, "", "🤖 _" + defaultDisclaimerText + "_"},
+		{defaultDisclaimerText, "bn", "🤖 _" + messages["bn"]["disclaimer"] + "_"},
+	}
+	for _, tt := range tests {
+		if got := disclaimerFooter(tt.text, tt.lang); got != tt.want {
+			t.Errorf("disclaimerFooter(%q, %q) = %q, want %q", tt.text, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestDisclaimerSentOnce(t *testing.T) {
+	const disclaimer = "Written by AI"
+	tests := []struct {
+		name       string
+		disclaimer string
+		layout     string
+	}{
+		{"split", disclaimer, resultLayoutSplit},
+		{"combined", disclaimer, resultLayoutCombined},
+		{"none configured", "", resultLayoutSplit},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.output.Disclaimer = tt.disclaimer
+			captionGemini(t, b)
+			b.queue = newFairQueue(0)
+			b.queue.start(1)
+
+			userID := int64(1148 + i)
+			b.store.UpdateUserSettings(userID, func(s *UserSettings) { s.Layout = tt.layout })
+			// Two platforms, so the results span several messages
+			state := b.getState(userID)
+			state.PhotoData, state.MimeType = testJPEG(t, 600, 600), "image/jpeg"
+			state.Platforms = []string{"Instagram", "LinkedIn"}
+			b.generateContent(userID)
+			flushQueue(b.queue)
+
+			texts := fake.Texts(userID)
+			if len(messagesWith(texts, "First caption")) == 0 {
+				t.Fatalf("no results sent: %q", texts)
+			}
+			count := 0
+			for _, text := range texts {
+				count += strings.Count(text, "🤖")
+			}
+			want := 0
+			if tt.disclaimer != "" {
+				want = 1
+			}
+			if count != want || len(messagesWith(texts, disclaimer)) != want {
+				t.Errorf("disclaimer shown %d times, want %d: %q", count, want, texts)
+			}
+		})
+	}
+}
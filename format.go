@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Outgoing Message Formatting ---
+
+// ResponseFormat controls how outgoing messages are formatted (RESPONSE_FORMAT).
+// Messages are written with light Markdown markers (**bold**, *italic*,
+// _italic_, `code`); formatOutgoing converts them for the chosen format.
+type ResponseFormat string
+
+const (
+	formatMarkdownV2 ResponseFormat = "markdownv2"
+	formatMarkdown   ResponseFormat = "markdown" // Telegram's legacy Markdown, with no escaping
+	formatHTML       ResponseFormat = "html"
+	formatPlain      ResponseFormat = "plain"
+)
+
+// parseResponseFormat validates a RESPONSE_FORMAT value. Empty means markdownv2.
+func parseResponseFormat(raw string) (ResponseFormat, error) {
+	switch f := ResponseFormat(strings.ToLower(strings.TrimSpace(raw))); f {
+	case "":
+		return formatMarkdownV2, nil
+	case formatMarkdownV2, formatMarkdown, formatHTML, formatPlain:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown response format %q (want markdownv2, markdown, html or plain)", raw)
+	}
+}
+
+// parseMode returns the Telegram parse mode for the format.
+func (f ResponseFormat) parseMode() string {
+	switch f {
+	case formatHTML:
+		return tgbotapi.ModeHTML
+	case formatPlain:
+		return ""
+	case formatMarkdown:
+		return tgbotapi.ModeMarkdown
+	default:
+		return tgbotapi.ModeMarkdownV2
+	}
+}
+
+var (
+	boldMarkerRe   = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	starItalicRe   = regexp.MustCompile(`\*([^*\n]+)\*`)
+	underItalicRe  = regexp.MustCompile(`(^|[^\w])_([^_\n]+)_([^\w]|$)`)
+	codeMarkerRe   = regexp.MustCompile("`([^`\n]+)`")
+	htmlReplacer   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	markdownFormat = strings.NewReplacer("**", "*")
+
+	// markdownV2Escaper escapes every character MarkdownV2 treats as markup.
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	// markdownV2CodeEscaper escapes the two characters that matter inside `code`.
+	markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+)
+
+// formatOutgoing converts a message written with our Markdown markers into
+// the given format. HTML escapes <, > and & before adding tags; plain strips
+// the markers entirely.
+func formatOutgoing(text string, format ResponseFormat) string {
+	switch format {
+	case formatHTML:
+		text = htmlReplacer.Replace(text)
+		text = codeMarkerRe.ReplaceAllString(text, "<code>$1</code>")
+		text = boldMarkerRe.ReplaceAllString(text, "<b>$1</b>")
+		text = starItalicRe.ReplaceAllString(text, "<i>$1</i>")
+		return underItalicRe.ReplaceAllString(text, "$1<i>$2</i>$3")
+
+	case formatPlain:
+		text = codeMarkerRe.ReplaceAllString(text, "$1")
+		text = boldMarkerRe.ReplaceAllString(text, "$1")
+		text = starItalicRe.ReplaceAllString(text, "$1")
+		return underItalicRe.ReplaceAllString(text, "$1$2$3")
+
+	case formatMarkdown:
+		// Telegram's Markdown uses single asterisks for bold
+		return markdownFormat.Replace(text)
+
+	default:
+		return formatMarkdownV2Text(text)
+	}
+}
+
+// newMessage builds a message formatted for the configured response format.
+// Every outgoing text message should be built here.
+func (b *Bot) newMessage(chatID int64, text string) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(chatID, formatOutgoing(text, b.responseFormat))
+	msg.ParseMode = b.responseFormat.parseMode()
+	return msg
+}
+
+// newEditMessage is newMessage for edits of an existing message.
+func (b *Bot) newEditMessage(chatID int64, messageID int, text string) tgbotapi.EditMessageTextConfig {
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, formatOutgoing(text, b.responseFormat))
+	msg.ParseMode = b.responseFormat.parseMode()
+	return msg
+}
+
+// markdownV2Spans are our markers in order of precedence, with the
+// MarkdownV2 delimiter each becomes and the regexp group holding its text.
+// underItalicRe's other groups are the boundary characters around it.
+var markdownV2Spans = []struct {
+	re    *regexp.Regexp
+	group int
+	delim string
+}{
+	{codeMarkerRe, 1, "`"},
+	{boldMarkerRe, 1, "*"},
+	{starItalicRe, 1, "_"},
+	{underItalicRe, 2, "_"},
+}
+
+// formatMarkdownV2Text converts our markers to MarkdownV2 and escapes all
+// other text, so captions full of "_", "#", "." or "!" can't break the
+// message. Markers without a closing pair are sent as literal characters.
+func formatMarkdownV2Text(text string) string {
+	var sb strings.Builder
+	for text != "" {
+		// Take the span whose text starts first; on a tie (e.g. "**x**"
+		// also matching as "*x*") the earlier entry wins
+		best, bestLoc := -1, []int(nil)
+		for i, span := range markdownV2Spans {
+			loc := span.re.FindStringSubmatchIndex(text)
+			if loc == nil {
+				continue
+			}
+			if bestLoc == nil || loc[2*span.group] < bestLoc[2*markdownV2Spans[best].group] {
+				best, bestLoc = i, loc
+			}
+		}
+		if bestLoc == nil {
+			sb.WriteString(markdownV2Escaper.Replace(text))
+			break
+		}
+
+		span := markdownV2Spans[best]
+		start, end := bestLoc[2*span.group], bestLoc[2*span.group+1]
+		before, after := bestLoc[0], bestLoc[1]
+		if span.group == 2 {
+			// Keep the boundary characters as text
+			before, after = bestLoc[3], bestLoc[6]
+		}
+		inner := markdownV2Escaper.Replace(text[start:end])
+		if span.delim == "`" {
+			inner = markdownV2CodeEscaper.Replace(text[start:end])
+		}
+		sb.WriteString(markdownV2Escaper.Replace(text[:before]))
+		sb.WriteString(span.delim + inner + span.delim)
+		text = text[after:]
+	}
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestFormatMarkdownV2Text(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"punctuation and hashtags", "Sale ends 31.12! #denim_jacket (limited) - 50% off + free ship",
+			`Sale ends 31\.12\! \#denim\_jacket \(limited\) \- 50% off \+ free ship`},
+		{"bold", "**New** drop", "*New* drop"},
+		{"star italic", "*soft* feel", "_soft_ feel"},
+		{"underscore italic", "so _soft_ now", "so _soft_ now"},
+		{"underscores inside words", "snake_case_name", `snake\_case\_name`},
+		{"code keeps its text", "Use `SAVE_10.5` today", "Use `SAVE_10.5` today"},
+		{"escapes inside bold", "**Fit: S-M.**", `*Fit: S\-M\.*`},
+		{"unclosed marker", "Unclosed **bold", `Unclosed \*\*bold`},
+		{"lone star", "5*2=10", `5\*2\=10`},
+		{"brackets and pipes", "[link](x) a|b {c} ~d~ >e", `\[link\]\(x\) a\|b \{c\} \~d\~ \>e`},
+		{"backslash", `C:\denim`, `C:\\denim`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatMarkdownV2Text(tt.text); got != tt.want {
+				t.Errorf("formatMarkdownV2Text(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOutgoing(t *testing.T) {
+	const text = "**Done!** Your _3_ captions for <Denim & Co> are `ready`."
+	tests := []struct {
+		format   ResponseFormat
+		want     string
+		wantMode string
+	}{
+		{formatMarkdownV2, `*Done\!* Your _3_ captions for <Denim & Co\> are ` + "`ready`" + `\.`, "MarkdownV2"},
+		{formatMarkdown, "*Done!* Your _3_ captions for <Denim & Co> are `ready`.", "Markdown"},
+		{formatHTML, "<b>Done!</b> Your <i>3</i> captions for &lt;Denim &amp; Co&gt; are <code>ready</code>.", "HTML"},
+		{formatPlain, "Done! Your 3 captions for <Denim & Co> are ready.", ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			b := &Bot{responseFormat: tt.format}
+			msg := b.newMessage(1, text)
+			if msg.Text != tt.want {
+				t.Errorf("%s text = %q, want %q", tt.format, msg.Text, tt.want)
+			}
+			if msg.ParseMode != tt.wantMode {
+				t.Errorf("%s parse mode = %q, want %q", tt.format, msg.ParseMode, tt.wantMode)
+			}
+		})
+	}
+}
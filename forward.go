@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Forwarded Photos ---
+
+// forwardAttribution names where a forwarded message came from, e.g.
+// "@somechannel" or "Jane Doe". It returns "" for messages that weren't
+// forwarded.
+func forwardAttribution(message *tgbotapi.Message) string {
+	switch {
+	case message.ForwardFromChat != nil:
+		chat := message.ForwardFromChat
+		if chat.UserName != "" {
+			return "@" + chat.UserName
+		}
+		return chat.Title
+	case message.ForwardFrom != nil:
+		user := message.ForwardFrom
+		if user.UserName != "" {
+			return "@" + user.UserName
+		}
+		return strings.TrimSpace(user.FirstName + " " + user.LastName)
+	case message.ForwardSenderName != "":
+		// The sender hides their account, so only the name is known
+		return message.ForwardSenderName
+	}
+	return ""
+}
+
+// forwardNote reminds the user that a forwarded photo may not be theirs.
+func forwardNote(source string) string {
+	return fmt.Sprintf("📢 This photo was forwarded from %s. Make sure you have the rights to use it before posting.", source)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestForwardAttribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		message tgbotapi.Message
+		want    string
+	}{
+		{"not forwarded", tgbotapi.Message{}, ""},
+		{"public channel", tgbotapi.Message{ForwardFromChat: &tgbotapi.Chat{UserName: "somechannel", Title: "Some Channel"}}, "@somechannel"},
+		{"private channel", tgbotapi.Message{ForwardFromChat: &tgbotapi.Chat{Title: "Some Channel"}}, "Some Channel"},
+		{"user with a username", tgbotapi.Message{ForwardFrom: &tgbotapi.User{UserName: "jane", FirstName: "Jane"}}, "@jane"},
+		{"user without a username", tgbotapi.Message{ForwardFrom: &tgbotapi.User{FirstName: "Jane", LastName: "Doe"}}, "Jane Doe"},
+		{"hidden sender", tgbotapi.Message{ForwardSenderName: "Jane D."}, "Jane D."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forwardAttribution(&tt.message); got != tt.want {
+				t.Errorf("forwardAttribution = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardedPhotoFlaggedInResults(t *testing.T) {
+	for i, forwarded := range []bool{false, true} {
+		b, fake := newTestBot(t)
+		captionGemini(t, b)
+		servePhoto(t, b, testJPEG(t, 600, 600))
+		b.queue = newFairQueue(0)
+		b.queue.start(1)
+
+		userID := int64(1143 + i)
+		photo := photoMessage(userID)
+		if forwarded {
+			photo.ForwardFromChat = &tgbotapi.Chat{ID: -100, Type: "channel", UserName: "somechannel"}
+		}
+		b.handlePhoto(photo)
+		b.getState(userID).Platforms = []string{"Instagram"}
+		b.generateContent(userID)
+		flushQueue(b.queue)
+
+		texts := fake.Texts(userID)
+		notes := messagesWith(texts, "forwarded from @somechannel")
+		feedback := messagesWith(texts, "💡")
+		switch {
+		case !forwarded && len(notes) != 0:
+			t.Errorf("photo sent directly, but the results flag it as forwarded: %q", texts)
+		case forwarded && (len(notes) != 1 || len(feedback) != 1 || notes[0] != feedback[0]):
+			t.Errorf("forwarded photo flagged in messages %v, feedback in %v; want the note once, with the feedback", notes, feedback)
+		}
+	}
+}
@@ -2,19 +2,26 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
 // --- Structs for API Payloads and Responses ---
 
-const geminiAPIURL = "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash-preview-09-2025:generateContent?key="
+const (
+	geminiAPIBaseURL   = "https://generativelanguage.googleapis.com/v1beta/models/"
+	defaultGeminiModel = "gemini-2.5-flash"
+)
 
 // GeminiRequest is the top-level structure for a Gemini API call.
 type GeminiRequest struct {
@@ -59,12 +66,14 @@ type Schema struct {
 	Required   []string            `json:"required"`
 }
 
-// Property defines a single field in the JSON schema.
+// Property defines a single field in the JSON schema. Arrays describe their
+// elements with Items; objects (e.g. array elements) use Properties.
 type Property struct {
-	Type  string `json:"type"`
-	Items *struct {
-		Type string `json:"type"`
-	} `json:"items,omitempty"`
+	Type       string              `json:"type"`
+	Items      *Property           `json:"items,omitempty"`
+	Properties map[string]Property `json:"properties,omitempty"`
+	Required   []string            `json:"required,omitempty"`
+	Enum       []string            `json:"enum,omitempty"`
 }
 
 // GeminiResponse is the raw response from the API.
@@ -75,15 +84,111 @@ type GeminiResponse struct {
 	PromptFeedback struct {
 		BlockReason string `json:"blockReason"`
 	} `json:"promptFeedback"`
+	UsageMetadata UsageMetadata `json:"usageMetadata"`
+}
+
+// UsageMetadata reports how many tokens a single request consumed.
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// Add accumulates the token counts of another request into u.
+func (u *UsageMetadata) Add(other UsageMetadata) {
+	u.PromptTokenCount += other.PromptTokenCount
+	u.CandidatesTokenCount += other.CandidatesTokenCount
+	u.TotalTokenCount += other.TotalTokenCount
 }
 
 // --- Specific Structs for Our Bot ---
 
 // GeneratedContent holds the final, parsed data we want.
 type GeneratedContent struct {
+	Results    []PlatformContent     // One entry per selected platform, in selection order
+	Feedback   FeedbackPoints        // nil while the feedback is still being generated
+	Background *BackgroundAssessment // BG_CLEANUP verdict; nil if off or the check failed
+	Notes      []string              // Processing notes shown with the feedback (e.g. downscaling)
+	Language   string                // Output language code, for the labels around the results
+	Tone       string                // Tone and brand name the captions were written for
+	Brand      string
+	Disclaimer string        // DISCLAIMER_TEXT when generated, shown once after the feedback; "" for none
+	Usage      UsageMetadata // Tokens consumed across all API calls for this job
+}
+
+// GenerationParams are the answers a caption job is generated from.
+type GenerationParams struct {
+	Platforms     []string
+	Tone          string
+	ToneIntensity string
+	Services      []string
+	Context       string
+	Language      string       // Output language code; "" means English
+	Brand         *BrandConfig // Never nil
+
+	StyleReference string             // The user's own past caption to imitate; usually ""
+	Attributes     *ProductAttributes // Confirmed product details; usually nil
+	AlbumImages    []InlineData       // More photos of the same product set (an album); usually empty
+
+	// RatedExamples are well-rated past captions per platform, shown to the
+	// model alongside the brand's examples. Usually empty.
+	RatedExamples map[string][]string
+
+	FooterLength int // Characters the contact footer will add to each caption; 0 without one
+
+	Output OutputSettings // From the configuration, the same for every job
+}
+
+// OutputSettings shape what a job produces beyond the user's answers. The
+// zero value asks for one feedback point and puts no limit on hashtags.
+type OutputSettings struct {
+	HashtagMaxLength int      // HASHTAG_MAX_LENGTH, counting the "#"; 0 means no limit
+	FeedbackPoints   int      // FEEDBACK_POINTS
+	BackgroundCheck  bool     // BG_CLEANUP: the extra background assessment call
+	CaptionStyles    []string // CAPTION_STYLES: one per caption, or none to let the model label them
+	Disclaimer       string   // DISCLAIMER_TEXT; "" leaves it out
+}
+
+// defaultOutputSettings apply when nothing is configured.
+var defaultOutputSettings = OutputSettings{HashtagMaxLength: 30, FeedbackPoints: 1}
+
+// PlatformContent holds the captions and hashtags generated for one platform.
+type PlatformContent struct {
+	Platform string
 	Captions []string
+	Styles   []string // Style label per caption (e.g. "Hook-led"); may be empty
 	Hashtags []string
-	Feedback string
+}
+
+// ProgressStage is a step of a generation job, reported as it starts.
+type ProgressStage string
+
+// Generation stages, in order. The text is shown on the "thinking" message.
+const (
+	StageAnalyzing  ProgressStage = "📸 Analyzing image…"
+	StageCaptions   ProgressStage = "✍️ Writing captions…"
+	StageShortening ProgressStage = "✂️ Trimming captions to the platform limits…"
+	StageFeedback   ProgressStage = "💡 Reviewing photo quality…"
+)
+
+// captionsStage is StageCaptions with how many platforms are done, for jobs
+// with more than one.
+func captionsStage(done, total int) ProgressStage {
+	if total < 2 {
+		return StageCaptions
+	}
+	return ProgressStage(fmt.Sprintf("%s (%d/%d platforms done)", StageCaptions, done, total))
+}
+
+// ProgressFunc is told when a generation job reaches a new stage. A nil
+// ProgressFunc is valid and reports nothing.
+type ProgressFunc func(stage ProgressStage)
+
+// report calls f, if set.
+func (f ProgressFunc) report(stage ProgressStage) {
+	if f != nil {
+		f(stage)
+	}
 }
 
 // APIJSONResponse is the struct that matches our JSON schema.
@@ -91,6 +196,9 @@ type APIJSONResponse struct {
 	Caption1 string   `json:"caption1"`
 	Caption2 string   `json:"caption2"`
 	Caption3 string   `json:"caption3"`
+	Style1   string   `json:"style1"`
+	Style2   string   `json:"style2"`
+	Style3   string   `json:"style3"`
 	Hashtags []string `json:"hashtags"`
 }
 
@@ -101,72 +209,278 @@ var schemaForCaptions = &Schema{
 		"caption1": {Type: "STRING"},
 		"caption2": {Type: "STRING"},
 		"caption3": {Type: "STRING"},
-		"hashtags": {
-			Type: "ARRAY",
-			Items: &struct {
-				Type string `json:"type"`
-			}{Type: "STRING"},
-		},
+		"style1":   {Type: "STRING"},
+		"style2":   {Type: "STRING"},
+		"style3":   {Type: "STRING"},
+		"hashtags": {Type: "ARRAY", Items: &Property{Type: "STRING"}},
 	},
 	Required: []string{"caption1", "caption2", "caption3", "hashtags"},
 }
 
 // --- Main API Call Function ---
 
-// generateContentFromGemini is the main function that calls the Gemini API.
+// ContentGenerator runs one request against the configured LLM and returns
+// its text. Prompts are built as GeminiRequests whatever the provider; each
+// provider translates them into its own API.
+type ContentGenerator interface {
+	generateContent(ctx context.Context, requestBody GeminiRequest) (string, UsageMetadata, error)
+}
+
+// modelProvider sends a request to one model of a specific LLM API.
+type modelProvider interface {
+	callModel(ctx context.Context, model string, requestBody GeminiRequest) (string, UsageMetadata, error)
+}
+
+// LLMClient is the ContentGenerator used by the bot: it tries the ordered
+// list of models on its provider, behind the circuit breaker and auth guard.
+type LLMClient struct {
+	provider modelProvider
+	models   []string // Primary model first, then fallbacks
+	retry    RetryPolicy
+	breaker  *circuitBreaker
+	auth     *authGuard
+
+	modelsMu   sync.RWMutex
+	taskModels map[modelTask][]string // CAPTION_MODELS, FEEDBACK_MODELS
+	overrides  map[modelTask][]string // Set at runtime with /model
+}
+
+// NewLLMClient creates a client that retries transient failures, falls back
+// through models in order, and stops calling the API while the breaker is open.
+// taskModels, if set for a task, replaces models for that task's requests.
+func NewLLMClient(provider modelProvider, models []string, taskModels map[modelTask][]string, retry RetryPolicy, breaker *circuitBreaker, auth *authGuard) *LLMClient {
+	return &LLMClient{
+		provider:   provider,
+		models:     models,
+		retry:      retry,
+		breaker:    breaker,
+		auth:       auth,
+		taskModels: taskModels,
+		overrides:  make(map[modelTask][]string),
+	}
+}
+
+// parseModelList parses a comma-separated model list (GEMINI_MODELS,
+// LLM_MODELS), falling back to def if it is empty.
+func parseModelList(raw, def string) []string {
+	var models []string
+	for _, m := range strings.Split(raw, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	if len(models) == 0 {
+		models = []string{def}
+	}
+	return models
+}
+
+// modelUnavailableError means the model is overloaded or rate limited.
+// Unlike other errors, the same request may succeed on a different model.
+type modelUnavailableError struct {
+	Model      string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // From the Retry-After header; 0 if none
+}
+
+func (e *modelUnavailableError) Error() string {
+	return fmt.Sprintf("model %s unavailable (status %d): %s", e.Model, e.StatusCode, e.Body)
+}
+
+// isModelUnavailableStatus reports whether an HTTP status means "try another model".
+func isModelUnavailableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isOutageError reports whether err means the LLM API itself is unreachable or
+// overloaded, as opposed to a problem with this particular request.
+func isOutageError(err error) bool {
+	var unavailable *modelUnavailableError
+	var urlErr *url.Error
+	return errors.As(err, &unavailable) || errors.As(err, &urlErr)
+}
+
+// generateContent is the main function that calls the LLM API.
 // It's a single, reusable function that can handle both JSON and text requests.
-func generateContentFromGemini(apiKey string, requestBody GeminiRequest) (string, error) {
-	apiURL := geminiAPIURL + apiKey
+// While the circuit breaker is open it fails fast with errCircuitOpen.
+// The token usage reported by the API is returned alongside the text.
+// Cancelling ctx aborts the request without counting against the breaker.
+// While the API key is being rejected it fails fast with errAuthDegraded.
+func (c *LLMClient) generateContent(ctx context.Context, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	if !c.auth.allow() {
+		return "", UsageMetadata{}, errAuthDegraded
+	}
+	if !c.breaker.allow() {
+		return "", UsageMetadata{}, errCircuitOpen
+	}
+
+	text, usage, err := c.generateWithFallback(ctx, requestBody)
+	if ctx.Err() == nil {
+		c.auth.record(err)
+	}
+	switch {
+	case err == nil:
+		c.breaker.recordSuccess()
+	case ctx.Err() != nil:
+		// Cancelled by us; says nothing about the API's health
+	case isOutageError(err):
+		c.breaker.recordFailure()
+	default:
+		// The API answered, so it's up even though this request failed
+		c.breaker.recordSuccess()
+	}
+	return text, usage, err
+}
+
+// generateWithFallback tries each of the task's models in order (with
+// retries), moving on only when a model is still unavailable; errors like
+// blocked prompts are returned immediately.
+func (c *LLMClient) generateWithFallback(ctx context.Context, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	var lastErr error
+	for i, model := range c.modelsFor(modelTaskFrom(ctx)) {
+		text, usage, err := c.callWithRetry(ctx, model, requestBody)
+		if err == nil {
+			if i > 0 {
+				logFrom(ctx).Info("Request served by fallback model", "model", model)
+			}
+			return text, usage, nil
+		}
+
+		var unavailable *modelUnavailableError
+		if !errors.As(err, &unavailable) || ctx.Err() != nil {
+			return "", usage, err
+		}
+		logFrom(ctx).Warn("Model unavailable, trying the next one", "model", model, "error", err)
+		lastErr = err
+	}
+	return "", UsageMetadata{}, fmt.Errorf("all models unavailable: %w", lastErr)
+}
+
+// geminiProvider calls the Google Gemini API (LLM_PROVIDER=gemini), with
+// an API key or, with vertex set (GEMINI_AUTH=vertex), through Vertex AI.
+type geminiProvider struct {
+	apiKey     string
+	httpClient *http.Client
+
+	vertex         *vertexAuth
+	vertexProject  string
+	vertexLocation string
+}
+
+// callModel implements modelProvider.
+func (c *geminiProvider) callModel(ctx context.Context, model string, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	apiURL := geminiAPIBaseURL + model + ":generateContent?key=" + c.apiKey
+	if c.vertex != nil {
+		apiURL = vertexEndpoint(c.vertexProject, c.vertexLocation, model)
+	}
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("error marshalling request: %w", err)
+		return "", UsageMetadata{}, fmt.Errorf("error marshalling request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("error creating new request: %w", err)
+		return "", UsageMetadata{}, fmt.Errorf("error creating new request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if c.vertex != nil {
+		token, err := c.vertex.accessToken(ctx)
+		if err != nil {
+			return "", UsageMetadata{}, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error making API request: %w", err)
+		return "", UsageMetadata{}, fmt.Errorf("error making API request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
+		return "", UsageMetadata{}, fmt.Errorf("error reading response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("API Error Response Body: %s", string(body))
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		logFrom(ctx).Warn("API error response", "provider", "Gemini", "model", model, "status", resp.StatusCode, "body", string(body))
+		if isAuthFailure(resp.StatusCode, string(body)) {
+			if c.vertex != nil {
+				c.vertex.invalidate() // Fetch a fresh token next time
+			}
+			return "", UsageMetadata{}, &authError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		if isModelUnavailableStatus(resp.StatusCode) {
+			return "", UsageMetadata{}, &modelUnavailableError{Model: model, StatusCode: resp.StatusCode, Body: string(body),
+				RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return "", UsageMetadata{}, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var geminiResponse GeminiResponse
 	if err := json.Unmarshal(body, &geminiResponse); err != nil {
-		return "", fmt.Errorf("error unmarshalling response: %w", err)
+		return "", UsageMetadata{}, fmt.Errorf("error unmarshalling response: %w", err)
 	}
 
 	// Handle blocked prompts
 	if geminiResponse.PromptFeedback.BlockReason != "" {
-		return "", fmt.Errorf("prompt was blocked: %s", geminiResponse.PromptFeedback.BlockReason)
+		return "", geminiResponse.UsageMetadata, fmt.Errorf("prompt was blocked: %s", geminiResponse.PromptFeedback.BlockReason)
 	}
 
+	usage := geminiResponse.UsageMetadata
+	logUsage(ctx, "Gemini", model, usage)
+
 	// Extract and return the generated text
-	if len(geminiResponse.Candidates) > 0 && len(geminiResponse.Candidates[0].Content.Parts) > 0 {
-		return geminiResponse.Candidates[0].Content.Parts[0].Text, nil
+	if text, ok := responseText(geminiResponse); ok {
+		return text, usage, nil
 	}
 
-	return "", fmt.Errorf("no content found in API response")
+	return "", usage, fmt.Errorf("no content found in API response")
+}
+
+// responseText joins the text of every part of the first candidate. Long
+// outputs can arrive split across parts, so reading only the first one
+// would truncate them (and break JSON parsing).
+func responseText(resp GeminiResponse) (string, bool) {
+	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String(), true
 }
 
 // --- Bot-Specific Helper Functions ---
 
+// toneIntensityInstruction turns an intensity level into a prompt modifier.
+// An empty intensity means Balanced, which matches the behaviour before
+// intensity could be chosen.
+func toneIntensityInstruction(tone, intensity string) string {
+	if intensity == "" {
+		intensity = "Balanced"
+	}
+	var strength string
+	switch intensity {
+	case "Subtle":
+		strength = "subtle (light touch, understated)"
+	case "Strong":
+		strength = "strong (bold and unmistakable)"
+	default:
+		strength = "balanced (clear but not overdone)"
+	}
+	return fmt.Sprintf("Apply the %s tone with %s intensity.", tone, strength)
+}
+
 // buildCaptionSystemPrompt creates the detailed prompt for the AI.
-func buildCaptionSystemPrompt(platform, tone string, services []string, context string) string {
+func buildCaptionSystemPrompt(brand *BrandConfig, platform, tone, toneIntensity string, services []string, context string, ratedExamples, captionStyles []string) string {
 	var platformInstruction string
 	switch platform {
 	case "Facebook":
@@ -183,70 +497,114 @@ func buildCaptionSystemPrompt(platform, tone string, services []string, context
 
 	var servicesList string
 	if len(services) > 0 {
-		servicesList = strings.Join(services, ", ")
+		snippets := make([]string, len(services))
+		for i, key := range services {
+			snippets[i] = brand.serviceSnippet(key)
+		}
+		servicesList = strings.Join(snippets, "; ")
 	} else {
 		servicesList = "our full range of manufacturing services"
 	}
 
+	// A prompt template file (CAPTION_PROMPT_TEMPLATE) replaces the built-in prompt
+	if captionPromptTemplate != nil {
+		rendered, err := renderCaptionPromptTemplate(captionPromptTemplate, CaptionPromptData{
+			Platform:            platform,
+			PlatformInstruction: platformInstruction,
+			Tone:                tone,
+			ToneIntensity:       toneIntensityInstruction(tone, toneIntensity),
+			Services:            servicesList,
+			Context:             context,
+			Brand:               brand.Name,
+			BrandDescription:    brand.description(),
+			ContactCTA:          brand.ContactCTA,
+			HashtagCount:        captionHashtagCount,
+		})
+		if err == nil {
+			return rendered + ratedExamplesSection(ratedExamples) + captionStyleInstruction(captionStyles)
+		}
+		log.Printf("Error rendering caption prompt template, using built-in prompt: %v", err)
+	}
+
+	mentions := brand.Mentions
+	if len(mentions) == 0 {
+		mentions = []string{brand.Name}
+	}
+	mentionList := `"` + strings.Join(mentions, `" or "`) + `"`
+
+	brandedHashtags := "#" + strings.ReplaceAll(mentions[0], " ", "")
+	if len(brand.DefaultHashtags) > 0 {
+		brandedHashtags = strings.Join(brand.DefaultHashtags, ", ")
+	}
+
 	// This is the core "brain" of the AI, taken from our web app.
-	systemPrompt := fmt.Sprintf(`You are a professional B2B (business-to-business) marketing copywriter for **AR Sourcing Bangladesh (arsourcingbd)**, a high-quality clothing manufacturer. Your task is to analyze the provided image of a clothing product and generate compelling social media content.
+	systemPrompt := fmt.Sprintf(`You are a professional B2B (business-to-business) marketing copywriter for **%s**, a high-quality clothing manufacturer. Your task is to analyze the provided image of a clothing product and generate compelling social media content.
             
-**Business Identity:** AR Sourcing Bangladesh (arsourcingbd)
+**Business Identity:** %s
 **Target Platform:** %s (%s)
-**Desired Tone:** %s
+**Desired Tone:** %s. %s
 **Services to Highlight:** %s
 **Additional Context:** %s
 
 **Gold-Standard Example (Use for tone/style):**
 ---
-Custom-Made for Global Brands
-At AR Sourcing Bangladesh, we specialize in manufacturing high-quality women’s shorts...
-🧵 What We Offer:
-✅ Premium fabric & professional stitching
-✅ OEM & Private Label production
-...
-🌍 From Bangladesh to the world...
-📩 Partner with us for your next clothing collection.
-#ApparelManufacturer ... #ARsourcingBangladesh ...
+%s
 ---
 
 **Your Task:**
-Based on all the above, generate a JSON object with three (3) unique captions and a list of 15 relevant hashtags.
+Based on all the above, generate a JSON object with three (3) unique captions and a list of %d relevant hashtags.
 - The captions must follow the style of the example, be tailored to the product image, and incorporate the specified platform, tone, and services.
-- Mention "AR Sourcing Bangladesh" or "arsourcingbd" in the captions.
-- The hashtags should be a mix of general (#ApparelManufacturer), specific (#WomensShorts), and branded (#ARsourcingBangladesh).
-`, platform, platformInstruction, tone, servicesList, context)
+- Mention %s in the captions.
+- The hashtags should be a mix of general (#ApparelManufacturer), specific (#WomensShorts), and branded (%s).
+`, brand.Name, brand.description(), platform, platformInstruction, tone, toneIntensityInstruction(tone, toneIntensity), servicesList, context,
+		strings.Join(brand.Examples, "\n---\n"), captionHashtagCount, mentionList, brandedHashtags)
+	if brand.ContactCTA != "" {
+		systemPrompt += fmt.Sprintf("- End each caption with a call to action based on: %s\n", brand.ContactCTA)
+	}
 
-	return systemPrompt
+	return systemPrompt + ratedExamplesSection(ratedExamples) + captionStyleInstruction(captionStyles)
 }
 
-// buildFeedbackSystemPrompt creates a simpler prompt for image feedback.
-func buildFeedbackSystemPrompt() string {
-	return "You are a helpful B2B marketing assistant. Analyze the user's product image and provide a single, concise sentence of constructive feedback for its use on social media. Focus on lighting, angle, or professionalism. Be polite."
+// ratedExamplesSection shows the model past captions users rated highly.
+func ratedExamplesSection(examples []string) string {
+	if len(examples) == 0 {
+		return ""
+	}
+	return "\n**Highly-Rated Past Captions (also use for tone/style, but don't copy them):**\n---\n" +
+		strings.Join(examples, "\n---\n") + "\n---\n"
 }
 
-// getB2BContent is the main entry point called by the bot.
-// It orchestrates the two API calls to Gemini.
-func getB2BContent(apiKey string, photoData []byte, mimeType string, state *userState) (*GeneratedContent, error) {
-	base64Image := base64.StdEncoding.EncodeToString(photoData)
-	finalContent := GeneratedContent{}
-
-	// --- 1. Generate Captions and Hashtags (JSON Mode) ---
-	log.Println("Generating captions and hashtags...")
-	captionContext := state.Context
-	if captionContext == "" {
-		captionContext = "None provided."
+// buildFeedbackSystemPrompt creates a simpler prompt for image feedback.
+func buildFeedbackSystemPrompt(points int) string {
+	if points <= 1 {
+		return "You are a helpful B2B marketing assistant. Analyze the user's product image and provide a single, concise sentence of constructive feedback for its use on social media. " +
+			"Focus on lighting, angle, or professionalism. Be polite. Return it as a one-item JSON array."
 	}
+	return fmt.Sprintf("You are a helpful B2B marketing assistant. Analyze the user's product image and provide %d short, distinct points of constructive feedback for its use on social media, "+
+		"each one concise sentence about a different aspect (%s). Be polite.", points, strings.Join(feedbackCategories, ", "))
+}
 
-	captionPrompt := buildCaptionSystemPrompt(state.Platform, state.Tone, state.Services, captionContext)
+// generateCaptions makes the JSON-mode caption request for a single platform.
+func generateCaptions(ctx context.Context, client ContentGenerator, base64Image, mimeType, platform string, params GenerationParams, captionContext string) (PlatformContent, UsageMetadata, error) {
+	captionPrompt := buildCaptionSystemPrompt(params.Brand, platform, params.Tone, params.ToneIntensity, params.Services, captionContext, params.RatedExamples[platform], params.Output.CaptionStyles) +
+		styleReferenceSection(params.StyleReference) +
+		attributesSection(params.Attributes) +
+		languageInstruction(params.Language)
+	parts := []Part{
+		{Text: "Analyze this image and generate the B2B content as requested in the system prompt."},
+		{InlineData: &InlineData{MimeType: mimeType, Data: base64Image}},
+	}
+	if len(params.AlbumImages) > 0 {
+		parts[0].Text = fmt.Sprintf("These %d images show one product set. Analyze them together and generate the B2B content for the whole set as requested in the system prompt.", len(params.AlbumImages)+1)
+		for i := range params.AlbumImages {
+			parts = append(parts, Part{InlineData: &params.AlbumImages[i]})
+		}
+	}
 	captionRequest := GeminiRequest{
 		Contents: []Content{
 			{
-				Role: "user",
-				Parts: []Part{
-					{Text: "Analyze this image and generate the B2B content as requested in the system prompt."},
-					{InlineData: &InlineData{MimeType: mimeType, Data: base64Image}},
-				},
+				Role:  "user",
+				Parts: parts,
 			},
 		},
 		SystemInstruction: SystemInstruction{
@@ -258,23 +616,126 @@ func getB2BContent(apiKey string, photoData []byte, mimeType string, state *user
 		},
 	}
 
-	jsonResponse, err := generateContentFromGemini(apiKey, captionRequest)
+	jsonResponse, usage, err := client.generateContent(withModelTask(ctx, taskCaptions), captionRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error generating captions: %w", err)
+		return PlatformContent{}, usage, fmt.Errorf("error generating %s captions: %w", platform, err)
 	}
 
 	var apiJSONResponse APIJSONResponse
 	if err := json.Unmarshal([]byte(jsonResponse), &apiJSONResponse); err != nil {
-		log.Printf("Failed to unmarshal JSON: %s", jsonResponse)
-		return nil, fmt.Errorf("error parsing caption JSON: %w", err)
+		logFrom(ctx).Warn("Failed to unmarshal caption JSON", "platform", platform, "response", jsonResponse)
+		return PlatformContent{}, usage, fmt.Errorf("error parsing %s caption JSON: %w", platform, err)
 	}
 
-	finalContent.Captions = []string{apiJSONResponse.Caption1, apiJSONResponse.Caption2, apiJSONResponse.Caption3}
-	finalContent.Hashtags = apiJSONResponse.Hashtags
+	return PlatformContent{
+		Platform: platform,
+		Captions: []string{apiJSONResponse.Caption1, apiJSONResponse.Caption2, apiJSONResponse.Caption3},
+		Styles:   captionStylesFor(apiJSONResponse, params.Output.CaptionStyles),
+		Hashtags: normalizeHashtags(append(append(append([]string{}, params.Brand.DefaultHashtags...), attributeHashtags(params.Attributes)...), apiJSONResponse.Hashtags...), params.Output.HashtagMaxLength),
+	}, usage, nil
+}
 
-	// --- 2. Generate Image Feedback (Text Mode) ---
-	log.Println("Generating AI feedback...")
-	feedbackPrompt := buildFeedbackSystemPrompt()
+// getB2BContent is the main entry point for the API and batch mode.
+// It orchestrates the API calls to Gemini: one caption request per selected
+// platform (run concurrently), then one request for image feedback.
+// progress (which may be nil) is told as each of those steps starts.
+func getB2BContent(ctx context.Context, client ContentGenerator, photoData []byte, mimeType string, params GenerationParams, progress ProgressFunc) (*GeneratedContent, error) {
+	base64Image := base64.StdEncoding.EncodeToString(photoData)
+
+	// The feedback doesn't depend on the captions, so both run at once
+	feedbackCtx, cancelFeedback := context.WithCancel(ctx)
+	defer cancelFeedback()
+	waitFeedback := startFeedback(feedbackCtx, client, base64Image, mimeType, params.Language, params.Output)
+
+	content, err := getCaptionContent(ctx, client, base64Image, mimeType, params, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.report(StageFeedback)
+	waitFeedback(content)
+	return content, nil
+}
+
+// startFeedback runs addFeedback in the background, so it overlaps the
+// caption calls. The returned function waits for it and adds the feedback
+// (and its usage) to content. The feedback never fails, so captions are
+// never held back by it.
+func startFeedback(ctx context.Context, client ContentGenerator, base64Image, mimeType, language string, output OutputSettings) func(content *GeneratedContent) {
+	feedback := &GeneratedContent{Language: language}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		addFeedback(ctx, client, feedback, base64Image, mimeType, output)
+	}()
+
+	return func(content *GeneratedContent) {
+		<-done
+		content.Feedback = feedback.Feedback
+		content.Background = feedback.Background
+		content.Usage.Add(feedback.Usage)
+	}
+}
+
+// getCaptionContent generates the captions and hashtags for every platform,
+// without the feedback, so they can be sent before addFeedback runs.
+func getCaptionContent(ctx context.Context, client ContentGenerator, base64Image, mimeType string, params GenerationParams, progress ProgressFunc) (*GeneratedContent, error) {
+	finalContent := GeneratedContent{Language: params.Language, Tone: params.Tone, Brand: params.Brand.Name, Disclaimer: params.Output.Disclaimer}
+
+	// Generate Captions and Hashtags (JSON Mode), one set per platform
+	progress.report(captionsStage(0, len(params.Platforms)))
+	logFrom(ctx).Info("Generating captions and hashtags", "platforms", params.Platforms)
+	captionContext := params.Context
+	if captionContext == "" {
+		captionContext = "None provided."
+	}
+
+	results := make([]PlatformContent, len(params.Platforms))
+	usages := make([]UsageMetadata, len(params.Platforms))
+	errs := make([]error, len(params.Platforms))
+	var wg sync.WaitGroup
+	var doneMu sync.Mutex
+	done := 0
+	for i, platform := range params.Platforms {
+		wg.Add(1)
+		go func(i int, platform string) {
+			defer wg.Done()
+			results[i], usages[i], errs[i] = generateCaptions(ctx, client, base64Image, mimeType, platform, params, captionContext)
+			if errs[i] == nil {
+				// Reported under the lock, so the counts never go backwards
+				doneMu.Lock()
+				done++
+				progress.report(captionsStage(done, len(params.Platforms)))
+				doneMu.Unlock()
+			}
+		}(i, platform)
+	}
+	wg.Wait()
+
+	for i := range params.Platforms {
+		finalContent.Usage.Add(usages[i])
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+	}
+	finalContent.Results = results
+
+	// Shorten anything too long for its platform
+	if overCharLimits(&finalContent, params.FooterLength) {
+		progress.report(StageShortening)
+	}
+	enforceCharLimits(ctx, client, &finalContent, params.FooterLength)
+	return &finalContent, nil
+}
+
+// addFeedback generates the image feedback (and, if output asks for it, the
+// background check) for content. It never fails: if the feedback call does,
+// a fallback sentence is used instead.
+func addFeedback(ctx context.Context, client ContentGenerator, content *GeneratedContent, base64Image, mimeType string, output OutputSettings) {
+	ctx = withModelTask(ctx, taskFeedback)
+	// --- 1. Generate Image Feedback (Text Mode) ---
+	logFrom(ctx).Info("Generating AI feedback")
+	feedbackPrompt := buildFeedbackSystemPrompt(output.FeedbackPoints) + languageInstruction(content.Language)
 	feedbackRequest := GeminiRequest{
 		Contents: []Content{
 			{
@@ -288,15 +749,27 @@ func getB2BContent(apiKey string, photoData []byte, mimeType string, state *user
 		SystemInstruction: SystemInstruction{
 			Parts: []Part{{Text: feedbackPrompt}},
 		},
+		GenerationConfig: GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schemaForFeedback,
+		},
 	}
 
-	feedbackText, err := generateContentFromGemini(apiKey, feedbackRequest)
+	feedbackJSON, usage, err := client.generateContent(ctx, feedbackRequest)
+	content.Usage.Add(usage)
+	var feedback FeedbackPoints
+	if err == nil {
+		feedback, err = parseFeedback(feedbackJSON)
+	}
 	if err != nil {
-		log.Printf("Warning: Could not generate AI feedback: %v", err)
-		finalContent.Feedback = "Could not generate AI feedback at this time."
-	} else {
-		finalContent.Feedback = feedbackText
+		logFrom(ctx).Warn("Could not generate AI feedback", "error", err)
+		feedback = FeedbackPoints{{Comment: "Could not generate AI feedback at this time."}}
 	}
 
-	return &finalContent, nil
+	// --- 2. Optionally check for a cluttered background ---
+	if output.BackgroundCheck {
+		addBackgroundAssessment(ctx, client, content, base64Image, mimeType)
+	}
+
+	content.Feedback = feedback
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// redirectTransport sends every request to target instead, keeping the path.
+type redirectTransport struct{ target *url.URL }
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeGemini serves the Gemini API with reply(model) and returns a client
+// that tries models in order, plus the models it was asked for.
+func fakeGemini(t *testing.T, models []string, reply func(model string) (status int, body string)) (*LLMClient, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var called []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, _, _ := strings.Cut(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ":")
+		mu.Lock()
+		called = append(called, model)
+		mu.Unlock()
+		status, body := reply(model)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	provider := &geminiProvider{apiKey: "test-key", httpClient: &http.Client{Transport: redirectTransport{target}}}
+	return NewLLMClient(provider, models, nil, RetryPolicy{MaxAttempts: 1}, newCircuitBreaker(0, 0), newAuthGuard(0)), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(called)
+	}
+}
+
+func TestGenerateContentFallsBackToNextModel(t *testing.T) {
+	client, called := fakeGemini(t, []string{"primary", "fallback"}, func(model string) (int, string) {
+		if model == "primary" {
+			return http.StatusServiceUnavailable, `{"error":{"message":"The model is overloaded."}}`
+		}
+		return http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"From the fallback"}]}}]}`
+	})
+
+	text, _, err := client.generateContent(context.Background(), GeminiRequest{})
+	if err != nil {
+		t.Fatalf("generateContent: %v", err)
+	}
+	if text != "From the fallback" {
+		t.Errorf("text = %q, want the fallback model's reply", text)
+	}
+	if want := []string{"primary", "fallback"}; !slices.Equal(called(), want) {
+		t.Errorf("models called = %v, want %v", called(), want)
+	}
+}
+
+func TestGenerateContentDoesNotFallBackOnBlockedPrompt(t *testing.T) {
+	client, called := fakeGemini(t, []string{"primary", "fallback"}, func(model string) (int, string) {
+		return http.StatusOK, `{"promptFeedback":{"blockReason":"SAFETY"}}`
+	})
+
+	_, _, err := client.generateContent(context.Background(), GeminiRequest{})
+	if err == nil || !strings.Contains(err.Error(), "blocked: SAFETY") {
+		t.Errorf("err = %v, want the block reason", err)
+	}
+	if want := []string{"primary"}; !slices.Equal(called(), want) {
+		t.Errorf("models called = %v, want %v: another model won't unblock the prompt", called(), want)
+	}
+}
+
+// recordStages is a ProgressFunc that records the stages it is told.
+func recordStages() (ProgressFunc, func() []ProgressStage) {
+	var mu sync.Mutex
+	var stages []ProgressStage
+	return func(stage ProgressStage) {
+			mu.Lock()
+			defer mu.Unlock()
+			stages = append(stages, stage)
+		}, func() []ProgressStage {
+			mu.Lock()
+			defer mu.Unlock()
+			return slices.Clone(stages)
+		}
+}
+
+func TestProgressReportsEachStage(t *testing.T) {
+	client, _ := fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, captionsReply
+	})
+	tests := []struct {
+		name      string
+		platforms []string
+		want      []ProgressStage
+	}{
+		// A single platform's count adds nothing, so its captions stage
+		// repeats; thinkingProgress skips the repeat
+		{"one platform", []string{"Instagram"}, []ProgressStage{StageCaptions, StageCaptions, StageFeedback}},
+		{"two platforms", []string{"Instagram", "LinkedIn"},
+			[]ProgressStage{captionsStage(0, 2), captionsStage(1, 2), captionsStage(2, 2), StageFeedback}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress, stages := recordStages()
+			params := (&userState{Platforms: tt.platforms}).generationParams(defaultOutputSettings)
+			if _, err := getB2BContent(context.Background(), client, testJPEG(t, 8, 8), "image/jpeg", params, progress); err != nil {
+				t.Fatalf("getB2BContent: %v", err)
+			}
+			if got := stages(); !slices.Equal(got, tt.want) {
+				t.Errorf("stages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A nil ProgressFunc reports nothing, and doesn't get in the way
+	params := (&userState{Platforms: []string{"Instagram"}}).generationParams(defaultOutputSettings)
+	if _, err := getB2BContent(context.Background(), client, testJPEG(t, 8, 8), "image/jpeg", params, nil); err != nil {
+		t.Fatalf("getB2BContent with no progress: %v", err)
+	}
+}
+
+func TestThinkingMessageShowsStages(t *testing.T) {
+	b, fake := newTestBot(t)
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, captionsReply
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 1132
+	queueGeneration(t, b, userID)
+	flushQueue(b.queue)
+
+	var edits []string
+	for _, call := range fake.Calls("editMessageText") {
+		if call.chatID() == userID {
+			edits = append(edits, call.Params.Get("text"))
+		}
+	}
+	if len(messagesWith(edits, string(StageCaptions))) != 1 {
+		t.Errorf("thinking message edits = %q, want one showing the captions stage", edits)
+	}
+}
+
+func TestResponseTextJoinsParts(t *testing.T) {
+	var resp GeminiResponse
+	resp.Candidates = append(resp.Candidates, struct {
+		Content Content `json:"content"`
+	}{Content{Parts: []Part{{Text: `{"caption1":"Indigo denim, `}, {Text: `","caption2":"cut to last"}`}}}})
+	body, _ := json.Marshal(resp)
+	client, _ := fakeGemini(t, []string{"primary"}, func(model string) (int, string) {
+		return http.StatusOK, string(body)
+	})
+
+	text, _, err := client.generateContent(context.Background(), GeminiRequest{})
+	if err != nil {
+		t.Fatalf("generateContent: %v", err)
+	}
+	var reply APIJSONResponse
+	if err := json.Unmarshal([]byte(text), &reply); err != nil {
+		t.Fatalf("reply split across parts doesn't parse: %v (%q)", err, text)
+	}
+	if reply.Caption1 != "Indigo denim, " || reply.Caption2 != "cut to last" {
+		t.Errorf("parsed %+v, want both halves", reply)
+	}
+}
+
+func TestResponseTextWithoutParts(t *testing.T) {
+	if _, ok := responseText(GeminiResponse{}); ok {
+		t.Error("responseText found text in a response without candidates")
+	}
+}
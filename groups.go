@@ -0,0 +1,227 @@
+package main
+
+import (
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Group Chats ---
+
+// In a group, each member's conversation is a session of its own, keyed by
+// a session key that stands in for their user ID: incoming group updates are
+// rewritten to use it as both the user and the chat, so the conversation
+// code works as in a private chat, and send() readdresses everything sent
+// to it to the group, as a reply to the member's latest message.
+
+// groupSessionBit marks session keys. Telegram IDs fit in 52 bits, so a
+// key can never be mistaken for a real user or chat.
+const groupSessionBit = int64(1) << 62
+
+// groupCommands are the commands members can use in a group; the rest
+// deal with personal data (settings, history, ...) and are private only.
+var groupCommands = map[string]bool{"start": true, "cancel": true, "style": true, "same": true}
+
+// GroupSession is one member's conversation in a group chat.
+type GroupSession struct {
+	ChatID     int64  `json:"chatId"`
+	UserID     int64  `json:"userId"`
+	ReplyTo    int    `json:"replyTo"`              // The member's latest message to the bot
+	MediaGroup string `json:"mediaGroup,omitempty"` // Their latest album, whose other photos carry no mention
+}
+
+// isGroupSession reports whether id is a session key rather than a user ID.
+func isGroupSession(id int64) bool {
+	return id >= groupSessionBit
+}
+
+// groupSessionKey is the session key of a member in a group chat.
+func groupSessionKey(chatID, userID int64) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(userID, 10)))
+	return int64(h.Sum64()>>2) | groupSessionBit
+}
+
+// SetGroupSession records (or updates) a member's session.
+func (s *Store) SetGroupSession(key int64, session GroupSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.GroupSessions[key] == session {
+		return
+	}
+	s.data.GroupSessions[key] = session
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// GroupSession returns the session behind a session key.
+func (s *Store) GroupSession(key int64) (GroupSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.data.GroupSessions[key]
+	return session, ok
+}
+
+// memberID returns the real user behind an ID: the member for a session
+// key, else the ID itself. Quotas, usage, brand, settings and history
+// follow the person, not the chat.
+func (b *Bot) memberID(id int64) int64 {
+	if !isGroupSession(id) {
+		return id
+	}
+	if session, ok := b.store.GroupSession(id); ok {
+		return session.UserID
+	}
+	return id
+}
+
+// toGroupChat readdresses a request for a session key to its group chat.
+// New messages reply to the member's latest message, so in a busy group
+// it's clear whose results they are.
+func (b *Bot) toGroupChat(c tgbotapi.Chattable) tgbotapi.Chattable {
+	key := chattableChatID(c)
+	if !isGroupSession(key) {
+		return c
+	}
+	session, ok := b.store.GroupSession(key)
+	if !ok {
+		log.Printf("Unknown group session %d", key)
+		return c
+	}
+	c = retargetChattable(c, session.ChatID)
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		c.ReplyToMessageID, c.AllowSendingWithoutReply = session.ReplyTo, true
+		return c
+	case tgbotapi.PhotoConfig:
+		c.ReplyToMessageID, c.AllowSendingWithoutReply = session.ReplyTo, true
+		return c
+	case tgbotapi.DocumentConfig:
+		c.ReplyToMessageID, c.AllowSendingWithoutReply = session.ReplyTo, true
+		return c
+	}
+	return c
+}
+
+// isGroupChat reports whether a chat is a group or supergroup.
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// routeGroupUpdate decides whether a group update is for the bot and, if
+// so, rewrites it onto the member's session. Only these are: a photo or
+// text mentioning the bot, a reply to one of the bot's messages, the rest
+// of an album started that way, commands, and taps on buttons. It returns
+// false if the update should be ignored (or was answered here).
+func (b *Bot) routeGroupUpdate(update *botUpdate) bool {
+	switch {
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil && isGroupChat(update.CallbackQuery.Message.Chat):
+		return b.routeGroupCallback(update.CallbackQuery)
+	case update.Message != nil && update.Message.From != nil && isGroupChat(update.Message.Chat):
+		return b.routeGroupMessage(update)
+	case update.MessageReaction != nil && isGroupChat(&update.MessageReaction.Chat):
+		return false // Ratings by reaction are for private chats
+	}
+	return true
+}
+
+// routeGroupMessage handles routeGroupUpdate for messages.
+func (b *Bot) routeGroupMessage(update *botUpdate) bool {
+	message := update.Message
+	chatID, userID := message.Chat.ID, message.From.ID
+	key := groupSessionKey(chatID, userID)
+	session, known := b.store.GroupSession(key)
+
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	mentioned := b.mentionsBot(text)
+	replied := message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && message.ReplyToMessage.From.ID == b.api.Self.ID
+	albumRest := known && message.MediaGroupID != "" && message.MediaGroupID == session.MediaGroup
+
+	if message.IsCommand() {
+		if at := message.CommandWithAt(); strings.Contains(at, "@") && !strings.EqualFold(at[strings.Index(at, "@")+1:], b.api.Self.UserName) {
+			return false // For another bot
+		}
+		switch cmd := message.Command(); {
+		case cmd == "whoami" || cmd == "chatid":
+			b.replyInGroup(message, whoamiText(message))
+			return false
+		case !groupCommands[cmd]:
+			b.replyInGroup(message, "That command only works in a private chat with me.")
+			return false
+		}
+	} else if !mentioned && !replied && !albumRest {
+		return false
+	}
+
+	b.store.MarkChatActive(chatID)
+	b.store.TouchUser(userID)
+	b.store.SetGroupSession(key, GroupSession{ChatID: chatID, UserID: userID, ReplyTo: message.MessageID, MediaGroup: message.MediaGroupID})
+
+	// The conversation code sees the member's session as a private chat
+	rewritten := *message
+	from, chat := *message.From, *message.Chat
+	from.ID, chat.ID = key, key
+	rewritten.From, rewritten.Chat = &from, &chat
+	if mentioned {
+		rewritten.Text = b.stripMention(rewritten.Text)
+		rewritten.Caption = b.stripMention(rewritten.Caption)
+	}
+	update.Message = &rewritten
+	return true
+}
+
+// routeGroupCallback handles routeGroupUpdate for button taps. Buttons
+// belong to the member whose message they reply to; others are turned away.
+func (b *Bot) routeGroupCallback(query *tgbotapi.CallbackQuery) bool {
+	chatID, userID := query.Message.Chat.ID, query.From.ID
+	if owner := query.Message.ReplyToMessage; owner != nil && owner.From != nil && owner.From.ID != userID {
+		b.send(tgbotapi.NewCallback(query.ID, "These buttons belong to someone else's request. Mention me with a photo to start your own."))
+		return false
+	}
+	key := groupSessionKey(chatID, userID)
+	if _, ok := b.store.GroupSession(key); !ok {
+		b.store.SetGroupSession(key, GroupSession{ChatID: chatID, UserID: userID})
+	}
+
+	message, from := *query.Message, *query.From
+	chat := *message.Chat
+	chat.ID, from.ID = key, key
+	message.Chat = &chat
+	query.Message, query.From = &message, &from
+	return true
+}
+
+// replyInGroup answers a group message directly, outside any session.
+func (b *Bot) replyInGroup(message *tgbotapi.Message, text string) {
+	msg := b.newMessage(message.Chat.ID, text)
+	msg.ReplyToMessageID, msg.AllowSendingWithoutReply = message.MessageID, true
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Error replying in group %d: %v", message.Chat.ID, err)
+	}
+}
+
+// mentionsBot reports whether text mentions the bot's @username.
+func (b *Bot) mentionsBot(text string) bool {
+	return b.api.Self.UserName != "" && strings.Contains(strings.ToLower(text), "@"+strings.ToLower(b.api.Self.UserName))
+}
+
+// stripMention removes the bot's @username from text.
+func (b *Bot) stripMention(text string) string {
+	mention := "@" + strings.ToLower(b.api.Self.UserName)
+	for {
+		i := strings.Index(strings.ToLower(text), mention)
+		if i < 0 {
+			return strings.TrimSpace(text)
+		}
+		text = text[:i] + text[i+len(mention):]
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// --- Hashtag Normalization ---
+
+// normalizeHashtags cleans up the hashtags returned by the model:
+//   - whitespace inside a tag is removed ("#Apparel Manufacturer" -> "#ApparelManufacturer")
+//   - characters other than letters, digits, "_" and combining marks (the
+//     vowel signs of scripts like Bengali) are dropped
+//   - a leading "#" is added if missing
+//   - duplicates are removed case-insensitively, keeping the first spelling
+//   - tags longer than maxLength (counting the "#") are dropped, unless it is 0
+//
+// An entry holding several tags ("#a #b") is split into separate tags.
+func normalizeHashtags(tags []string, maxLength int) []string {
+	var out []string
+	seen := make(map[string]bool)
+
+	for _, raw := range tags {
+		for _, part := range splitHashtagEntry(raw) {
+			var sb strings.Builder
+			for _, r := range part {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_' {
+					sb.WriteRune(r)
+				}
+			}
+			body := sb.String()
+			if body == "" {
+				continue
+			}
+
+			tag := "#" + body
+			if maxLength > 0 && len([]rune(tag)) > maxLength {
+				continue
+			}
+
+			key := strings.ToLower(tag)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// splitHashtagEntry splits "#a #b" into ["a", "b"]. An entry with at most one
+// "#" is returned whole, so "#Apparel Manufacturer" stays a single tag.
+func splitHashtagEntry(raw string) []string {
+	if strings.Count(raw, "#") <= 1 {
+		return []string{raw}
+	}
+	return strings.Split(raw, "#")
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeHashtags(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      []string
+		maxLength int
+		want      []string
+	}{
+		{"duplicates keep the first spelling", []string{"#Denim", "#denim", "#DENIM", "#Jacket"}, 30, []string{"#Denim", "#Jacket"}},
+		{"spaces are removed", []string{"#Apparel Manufacturer", " #eco friendly "}, 30, []string{"#ApparelManufacturer", "#ecofriendly"}},
+		{"missing # is added", []string{"denim", "#jacket"}, 30, []string{"#denim", "#jacket"}},
+		{"several tags in one entry", []string{"#made #inBD"}, 30, []string{"#made", "#inBD"}},
+		{"punctuation is dropped", []string{"#ready-to-wear!", "#", "#!!"}, 30, []string{"#readytowear"}},
+		{"non-Latin letters are kept", []string{"#ডেনিম", "#fashion_week"}, 30, []string{"#ডেনিম", "#fashion_week"}},
+		{"over-length tags are dropped", []string{"#denim", "#sustainablefashion"}, 10, []string{"#denim"}},
+		{"the limit counts the # and runes", []string{"#abcdefghi", "#ডেনিমজ্যাকেট"}, 10, []string{"#abcdefghi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeHashtags(tt.tags, tt.maxLength); !slices.Equal(got, tt.want) {
+				t.Errorf("normalizeHashtags(%q, %d) = %q, want %q", tt.tags, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Caption History ---
+
+// maxHistoryEntries is how many past results /history keeps per user.
+const maxHistoryEntries = 50
+
+// HistoryEntry is one generated result with the answers it was made from.
+type HistoryEntry struct {
+	At            time.Time         `json:"at"`
+	Platforms     []string          `json:"platforms"`
+	Tone          string            `json:"tone"`
+	ToneIntensity string            `json:"toneIntensity,omitempty"`
+	Services      []string          `json:"services,omitempty"`
+	Context       string            `json:"context,omitempty"`
+	Content       *GeneratedContent `json:"content"`
+}
+
+// newHistoryEntry records a finished job's result and answers.
+func newHistoryEntry(state *userState, content *GeneratedContent) HistoryEntry {
+	return HistoryEntry{
+		At:            time.Now(),
+		Platforms:     state.Platforms,
+		Tone:          state.Tone,
+		ToneIntensity: state.ToneIntensity,
+		Services:      state.Services,
+		Context:       state.Context,
+		Content:       content,
+	}
+}
+
+// AddHistory appends a result to a user's history, dropping the oldest.
+func (s *Store) AddHistory(userID int64, entry HistoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := append(s.data.History[userID], entry)
+	if len(history) > maxHistoryEntries {
+		history = history[len(history)-maxHistoryEntries:]
+	}
+	s.data.History[userID] = history
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving history: %v", err)
+	}
+}
+
+// HistoryEntry returns a user's n-th newest result (0 is the newest) and
+// how many there are.
+func (s *Store) HistoryEntry(userID int64, n int) (HistoryEntry, int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.data.History[userID]
+	if n < 0 || n >= len(history) {
+		return HistoryEntry{}, len(history), false
+	}
+	return history[len(history)-1-n], len(history), true
+}
+
+// historyKeyboard pages through the history around entry n of total.
+func historyKeyboard(n, total int) tgbotapi.InlineKeyboardMarkup {
+	var nav []tgbotapi.InlineKeyboardButton
+	if n+1 < total {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️ Older", fmt.Sprintf("history:%d", n+1)))
+	}
+	if n > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Newer ▶️", fmt.Sprintf("history:%d", n-1)))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📤 Resend", fmt.Sprintf("history:resend:%d", n))),
+	}
+	if len(nav) > 0 {
+		rows = append([][]tgbotapi.InlineKeyboardButton{nav}, rows...)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// renderHistoryEntry summarizes a past result: when, the answers, and the
+// start of each caption.
+func renderHistoryEntry(entry HistoryEntry, n, total int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🗂 **History** (%d of %d)\n\n", n+1, total)
+	fmt.Fprintf(&sb, "🕒 %s\n", entry.At.Format("Mon 2 Jan 2006 15:04"))
+	fmt.Fprintf(&sb, "📣 %s · %s\n", orDash(strings.Join(entry.Platforms, ", ")), orDash(entry.Tone))
+	if len(entry.Services) > 0 {
+		fmt.Fprintf(&sb, "🧵 %s\n", strings.Join(entry.Services, ", "))
+	}
+	if entry.Context != "" {
+		fmt.Fprintf(&sb, "📝 %s\n", captionSnippet(entry.Context))
+	}
+
+	if entry.Content != nil {
+		for _, result := range entry.Content.Results {
+			fmt.Fprintf(&sb, "\n**%s**\n", platformLabels[result.Platform])
+			for i, caption := range result.Captions {
+				fmt.Fprintf(&sb, "%s — %s\n", result.optionLabel(i), captionSnippet(caption))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// sendHistory handles /history, showing the newest result.
+func (b *Bot) sendHistory(chatID, userID int64) {
+	entry, total, ok := b.store.HistoryEntry(userID, 0)
+	if !ok {
+		b.sendMessage(chatID, "You don't have any past captions yet. Send a photo to create some! 📸", nil)
+		return
+	}
+	b.sendMessage(chatID, renderHistoryEntry(entry, 0, total), historyKeyboard(0, total))
+}
+
+// handleHistoryCallback handles "history:<n>" (page) and "history:resend:<n>".
+func (b *Bot) handleHistoryCallback(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	if query.Message == nil {
+		return
+	}
+
+	arg := strings.TrimPrefix(query.Data, "history:")
+	resend := strings.HasPrefix(arg, "resend:")
+	n, err := strconv.Atoi(strings.TrimPrefix(arg, "resend:"))
+	if err != nil {
+		return
+	}
+	entry, total, ok := b.store.HistoryEntry(userID, n)
+	if !ok || entry.Content == nil {
+		b.removeInlineKeyboard(userID, query.Message.MessageID)
+		b.sendMessage(userID, "That entry is no longer in your history. Send /history to start again.", nil)
+		return
+	}
+
+	if !resend {
+		b.editMessageID(userID, query.Message.MessageID, renderHistoryEntry(entry, n, total), historyKeyboard(n, total))
+		return
+	}
+
+	// The photo isn't kept, so the result can't be regenerated
+	state := b.getState(userID)
+	state.LastRequest = nil
+	b.deliverResults(userID, state, entry.Content)
+}
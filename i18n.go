@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Output Language ---
+
+// Language is a language captions can be written in.
+type Language struct {
+	Code      string // e.g. "bn"
+	Name      string // English name, used in prompts
+	Label     string // Button label for the language step
+	Bilingual bool   // Each caption in English, then in Bengali
+}
+
+// languages lists the supported output languages; the first is the default.
+var languages = []Language{
+	{Code: "en", Name: "English", Label: "🇬🇧 English"},
+	{Code: "bn", Name: "Bengali", Label: "🇧🇩 বাংলা"},
+	{Code: "en-bn", Name: "English + Bengali", Label: "🌐 English + বাংলা", Bilingual: true},
+}
+
+// defaultCTAText is CTA_TEXT's default; only this default is translated,
+// since a custom CTA_TEXT is already in the operator's chosen words.
+const defaultCTAText = "Get in touch:"
+
+// defaultDisclaimerText is the suggested DISCLAIMER_TEXT; like the CTA, only
+// this exact text is translated.
+const defaultDisclaimerText = "Generated with AI — review before posting"
+
+// messages holds the bot's framing text around the results, per language.
+// Missing entries fall back to English.
+var messages = map[string]map[string]string{
+	"en": {
+		"hashtags":   "Suggested Hashtags",
+		"feedback":   "AI Image Feedback",
+		"cta":        defaultCTAText,
+		"email":      "Email",
+		"whatsapp":   "WhatsApp",
+		"web":        "Web",
+		"disclaimer": defaultDisclaimerText,
+	},
+	"bn": {
+		"hashtags":   "প্রস্তাবিত হ্যাশট্যাগ",
+		"feedback":   "ছবি নিয়ে এআই-এর মতামত",
+		"cta":        "যোগাযোগ করুন:",
+		"email":      "ইমেইল",
+		"whatsapp":   "হোয়াটসঅ্যাপ",
+		"web":        "ওয়েব",
+		"disclaimer": "এআই দিয়ে তৈরি — পোস্ট করার আগে যাচাই করে নিন",
+	},
+}
+
+// tr returns the text for key in lang, falling back to English.
+func tr(lang, key string) string {
+	if text, ok := messages[lang][key]; ok {
+		return text
+	}
+	return messages["en"][key]
+}
+
+// findLanguage returns the language with the given code ("" means the default).
+func findLanguage(code string) (Language, bool) {
+	if code == "" {
+		return languages[0], true
+	}
+	for _, l := range languages {
+		if l.Code == code {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// nextLanguage cycles to the language after code, for the settings button.
+func nextLanguage(code string) string {
+	current, _ := findLanguage(code)
+	for i, l := range languages {
+		if l.Code == current.Code {
+			return languages[(i+1)%len(languages)].Code
+		}
+	}
+	return languages[0].Code
+}
+
+// languageInstruction is appended to the caption and feedback prompts.
+// English needs no instruction, which keeps the default prompts unchanged.
+func languageInstruction(code string) string {
+	lang, ok := findLanguage(code)
+	if !ok || lang.Code == languages[0].Code {
+		return ""
+	}
+	if lang.Bilingual {
+		return "\n- Write each caption in English, then a blank line, then the same caption in natural Bengali (not a word-for-word translation), for local buyers. " +
+			"Write style labels and feedback in English. Mix English hashtags with two or three Bengali ones."
+	}
+	return fmt.Sprintf("\n- Write all text (captions, style labels and feedback) in %s. "+
+		"Write hashtags mostly in English, plus two or three in %s for local buyers.", lang.Name, lang.Name)
+}
+
+// languagePromptText asks for the caption language.
+const languagePromptText = "Which **language** should the captions be in?"
+
+// buildLanguageKeyboard offers each language, marking the selected one.
+func buildLanguageKeyboard(selected string) tgbotapi.InlineKeyboardMarkup {
+	current, _ := findLanguage(selected)
+	var row []tgbotapi.InlineKeyboardButton
+	for _, l := range languages {
+		text := l.Label
+		if l.Code == current.Code {
+			text = "✅ " + text
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, "language:"+l.Code))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row, backRow)
+}
+
+// chooseLanguage records the caption language for this job from a
+// "language:<code>" button. The choice doesn't change the user's default
+// in /settings.
+func chooseLanguage(state *userState, data string) bool {
+	code, ok := strings.CutPrefix(data, "language:")
+	if !ok {
+		return false
+	}
+	lang, ok := findLanguage(code)
+	if !ok {
+		return false
+	}
+	state.Language = lang.Code
+	if lang.Code == languages[0].Code {
+		state.Language = "" // English is stored as ""
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTranslationsCoverEnglish(t *testing.T) {
+	for lang, texts := range messages {
+		for key := range texts {
+			if _, ok := messages["en"][key]; !ok {
+				t.Errorf("%s has %q, which English lacks", lang, key)
+			}
+		}
+		for key := range messages["en"] {
+			if texts[key] == "" {
+				t.Errorf("%s has no text for %q", lang, key)
+			}
+		}
+	}
+	if got := tr("en-bn", "hashtags"); got != messages["en"]["hashtags"] {
+		t.Errorf("tr for a language without labels = %q, want the English fallback", got)
+	}
+}
+
+func TestBengaliCarouselHeaders(t *testing.T) {
+	content := &GeneratedContent{
+		Results:  []PlatformContent{{Platform: "Instagram", Captions: []string{"One", "Two", "Three"}, Hashtags: []string{"#denim"}}},
+		Feedback: FeedbackPoints{{Category: "Lighting", Comment: "Use softer light."}},
+		Language: "bn",
+	}
+	// The feedback is the last carousel page
+	text, _ := renderCarousel(content, len(carouselItems(content))-1, true, nil)
+	first, _ := renderCarousel(content, 0, true, nil)
+	text += first
+	for _, want := range []string{messages["bn"]["hashtags"], messages["bn"]["feedback"]} {
+		if !strings.Contains(text, want) {
+			t.Errorf("carousel is missing the header %q:\n%s", want, text)
+		}
+	}
+	for _, header := range []string{messages["en"]["hashtags"], messages["en"]["feedback"]} {
+		if strings.Contains(text, header) {
+			t.Errorf("carousel still has the English header %q", header)
+		}
+	}
+}
+
+func TestBengaliHeadersInResultMessages(t *testing.T) {
+	b, fake := newTestBot(t)
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, captionsReply
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 1130
+	b.getState(userID).Language = "bn"
+	queueGeneration(t, b, userID)
+	flushQueue(b.queue)
+
+	texts := fake.Texts(userID)
+	for _, header := range []string{messages["bn"]["hashtags"], messages["bn"]["feedback"]} {
+		header = strings.ReplaceAll(header, "-", `\-`) // As sent in MarkdownV2
+		if len(messagesWith(texts, header)) != 1 {
+			t.Errorf("want one message with %q, got messages %q", header, texts)
+		}
+	}
+	for _, header := range []string{"Suggested Hashtags", "AI Image Feedback"} {
+		if found := messagesWith(texts, header); len(found) != 0 {
+			t.Errorf("English header %q still sent in messages %v", header, found)
+		}
+	}
+}
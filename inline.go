@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Inline Mode ---
+
+const (
+	// minInlineQueryLength is the shortest description worth a model call.
+	minInlineQueryLength = 3
+	// inlineDebounce waits for the user to stop typing; every keystroke is
+	// a new inline query, and a newer one cancels the one before it.
+	inlineDebounce = 700 * time.Millisecond
+	// inlineTimeout bounds the model call; Telegram gives up on an answer
+	// after about 10 seconds anyway.
+	inlineTimeout = 9 * time.Second
+	// inlineCacheTime is how long (seconds) Telegram may reuse our answer
+	// for the same query from the same user.
+	inlineCacheTime = 300
+	// inlineStartParameter is the /start parameter of the "open the bot"
+	// button shown above inline results.
+	inlineStartParameter = "inline"
+)
+
+// schemaForInline asks for caption snippets and a few labelled hashtag sets.
+var schemaForInline = &Schema{
+	Type: "OBJECT",
+	Properties: map[string]Property{
+		"snippets": {Type: "ARRAY", Items: &Property{Type: "STRING"}},
+		"hashtagSets": {Type: "ARRAY", Items: &Property{
+			Type: "OBJECT",
+			Properties: map[string]Property{
+				"label":    {Type: "STRING"},
+				"hashtags": {Type: "ARRAY", Items: &Property{Type: "STRING"}},
+			},
+			Required: []string{"label", "hashtags"},
+		}},
+	},
+	Required: []string{"snippets", "hashtagSets"},
+}
+
+// inlineSuggestions is what an inline query is answered with.
+type inlineSuggestions struct {
+	Snippets    []string `json:"snippets"`
+	HashtagSets []struct {
+		Label    string   `json:"label"`
+		Hashtags []string `json:"hashtags"`
+	} `json:"hashtagSets"`
+}
+
+// generateInlineSuggestions writes short caption snippets and hashtag sets
+// for a typed product description. It is a small text-only call on the
+// inline task's models (INLINE_MODELS), so it comes back quickly.
+func generateInlineSuggestions(ctx context.Context, client ContentGenerator, brand *BrandConfig, description string, output OutputSettings) (*inlineSuggestions, UsageMetadata, error) {
+	prompt := fmt.Sprintf("You are a social media copywriter for %s, %s. "+
+		"From the product description, write 3 short caption snippets (one or two sentences each, ready to paste into a post) "+
+		"and 3 hashtag sets of 5-8 hashtags each, labelled by their angle (e.g. \"Product\", \"B2B sourcing\", \"Trending\"). "+
+		"Write in English unless the description is in another language.",
+		brand.Name, brand.description())
+	if len(brand.DefaultHashtags) > 0 {
+		prompt += " Include these brand hashtags in every set: " + strings.Join(brand.DefaultHashtags, ", ") + "."
+	}
+
+	request := GeminiRequest{
+		Contents: []Content{
+			{Role: "user", Parts: []Part{{Text: description}}},
+		},
+		SystemInstruction: SystemInstruction{Parts: []Part{{Text: prompt}}},
+		GenerationConfig: GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schemaForInline,
+		},
+	}
+
+	jsonResponse, usage, err := client.generateContent(withModelTask(ctx, taskInline), request)
+	if err != nil {
+		return nil, usage, fmt.Errorf("error generating inline suggestions: %w", err)
+	}
+	var parsed inlineSuggestions
+	if err := json.Unmarshal([]byte(jsonResponse), &parsed); err != nil {
+		return nil, usage, fmt.Errorf("error parsing inline suggestions JSON: %w", err)
+	}
+	for i := range parsed.HashtagSets {
+		parsed.HashtagSets[i].Hashtags = normalizeHashtags(parsed.HashtagSets[i].Hashtags, output.HashtagMaxLength)
+	}
+	return &parsed, usage, nil
+}
+
+// inlineResults turns suggestions into inline articles: each hashtag set,
+// then each snippet with the first set appended.
+func inlineResults(queryID string, s *inlineSuggestions) []interface{} {
+	var results []interface{}
+	var firstTags string
+	for i, set := range s.HashtagSets {
+		if len(set.Hashtags) == 0 {
+			continue
+		}
+		tags := strings.Join(set.Hashtags, " ")
+		if firstTags == "" {
+			firstTags = tags
+		}
+		article := tgbotapi.NewInlineQueryResultArticle(fmt.Sprintf("%s-tags-%d", queryID, i), "#️⃣ "+set.Label, tags)
+		article.Description = tags
+		results = append(results, article)
+	}
+	for i, snippet := range s.Snippets {
+		snippet = strings.TrimSpace(snippet)
+		if snippet == "" {
+			continue
+		}
+		text := snippet
+		if firstTags != "" {
+			text += "\n\n" + firstTags
+		}
+		article := tgbotapi.NewInlineQueryResultArticle(fmt.Sprintf("%s-snippet-%d", queryID, i), "✍️ "+captionSnippet(snippet), text)
+		article.Description = snippet
+		results = append(results, article)
+	}
+	return results
+}
+
+// inlineQueries tracks the inline query each user has in flight.
+type inlineQueries struct {
+	mu      sync.Mutex
+	running map[int64]*inlineJob
+}
+
+type inlineJob struct {
+	cancel context.CancelFunc
+}
+
+// start cancels the user's previous query, if still running, and returns
+// the context and job for a new one.
+func (q *inlineQueries) start(userID int64) (context.Context, *inlineJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), inlineDebounce+inlineTimeout)
+	job := &inlineJob{cancel: cancel}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running == nil {
+		q.running = make(map[int64]*inlineJob)
+	}
+	if prev, ok := q.running[userID]; ok {
+		prev.cancel()
+	}
+	q.running[userID] = job
+	return ctx, job
+}
+
+// finish releases a job, unless a newer one has already replaced it.
+func (q *inlineQueries) finish(userID int64, job *inlineJob) {
+	job.cancel()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running[userID] == job {
+		delete(q.running, userID)
+	}
+}
+
+// handleInlineQuery answers "@bot <product description>" with hashtag sets
+// and caption snippets. The model call runs in the background so the
+// user's other updates aren't held up; a newer query cancels it.
+func (b *Bot) handleInlineQuery(query *tgbotapi.InlineQuery) {
+	userID := query.From.ID
+	description := strings.Join(strings.Fields(query.Query), " ")
+	if utf8.RuneCountInString(description) < minInlineQueryLength {
+		b.answerInline(tgbotapi.InlineConfig{
+			InlineQueryID:     query.ID,
+			IsPersonal:        true,
+			SwitchPMText:      "Type a product, e.g. premium denim jacket",
+			SwitchPMParameter: inlineStartParameter,
+		})
+		return
+	}
+
+	ctx, job := b.inline.start(userID)
+	logger := b.userLogger(userID, 0).With("generation_id", newGenerationID())
+	go func() {
+		defer b.inline.finish(userID, job)
+
+		select {
+		case <-time.After(inlineDebounce):
+		case <-ctx.Done():
+			return // The user typed on
+		}
+
+		if exceeded := b.quota.check(userID, 1); exceeded != nil {
+			b.answerInline(tgbotapi.InlineConfig{
+				InlineQueryID:     query.ID,
+				IsPersonal:        true,
+				SwitchPMText:      "Generation limit reached, try again later",
+				SwitchPMParameter: inlineStartParameter,
+			})
+			return
+		}
+		b.quota.consume(userID, 1)
+
+		suggestions, usage, err := generateInlineSuggestions(withLogger(ctx, logger), b.llm, b.brandFor(userID), description, b.output)
+		b.recordUsage(userID, usage)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("Error answering inline query", "error", err)
+			b.answerInline(tgbotapi.InlineConfig{
+				InlineQueryID:     query.ID,
+				IsPersonal:        true,
+				SwitchPMText:      "Couldn't generate suggestions, try again",
+				SwitchPMParameter: inlineStartParameter,
+			})
+			return
+		}
+		b.answerInline(tgbotapi.InlineConfig{
+			InlineQueryID: query.ID,
+			Results:       inlineResults(query.ID, suggestions),
+			CacheTime:     inlineCacheTime,
+			IsPersonal:    true, // Answers follow the user's brand
+		})
+	}()
+}
+
+// answerInline sends an answer to an inline query. Like chat actions,
+// Telegram answers it with true rather than a message.
+func (b *Bot) answerInline(answer tgbotapi.InlineConfig) {
+	if answer.Results == nil {
+		answer.Results = []interface{}{}
+	}
+	if b.dryRun {
+		log.Printf("[dry run] Answering inline query %s with %d results", answer.InlineQueryID, len(answer.Results))
+		return
+	}
+	if _, err := b.api.Request(answer); err != nil {
+		log.Printf("Error answering inline query: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// --- Intent From Text ---
+
+// intentToneWords maps words in a free-text request to a tone.
+var intentToneWords = map[string]string{
+	"professional": "Professional",
+	"formal":       "Professional",
+	"corporate":    "Professional",
+	"enthusiastic": "Enthusiastic",
+	"exciting":     "Enthusiastic",
+	"energetic":    "Enthusiastic",
+	"fun":          "Enthusiastic",
+	"luxury":       "Luxury",
+	"luxurious":    "Luxury",
+	"elegant":      "Luxury",
+	"technical":    "Technical",
+	"specs":        "Technical",
+}
+
+// intentServiceWords maps words to the default brand's service keys.
+var intentServiceWords = map[string]string{
+	"oem":       "OEM",
+	"private":   "OEM",
+	"custom":    "Custom",
+	"branding":  "Custom",
+	"bulk":      "Bulk",
+	"wholesale": "Bulk",
+	"fabric":    "Fabric",
+	"fabrics":   "Fabric",
+	"material":  "Fabric",
+	"materials": "Fabric",
+}
+
+// parseIntent picks a platform, tone and services out of a message like
+// "I need an Instagram caption for my new shirt". Anything not mentioned is
+// left empty. Only the first platform and tone mentioned count.
+func parseIntent(text string) (platform, tone string, services []string) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		// A lone "x" is too likely to mean something else (e.g. "2 x 3")
+		if p, ok := startPresetAliases[word]; ok && word != "x" && platform == "" {
+			platform = p
+		}
+		if t, ok := intentToneWords[word]; ok && tone == "" {
+			tone = t
+		}
+		if s := intentServiceWords[word]; s != "" && !containsString(services, s) {
+			services = append(services, s)
+		}
+	}
+	return platform, tone, services
+}
+
+// handleIntent pre-answers the questions for the next photo from a message
+// sent before it. It returns false if the message mentions nothing we use.
+func (b *Bot) handleIntent(chatID int64, state *userState, text string) bool {
+	platform, tone, services := parseIntent(text)
+
+	// Only keep services the user's brand offers
+	brand := b.brandFor(chatID)
+	var offered []string
+	for _, option := range brand.Services {
+		if containsString(services, option.Key) {
+			offered = append(offered, option.Key)
+		}
+	}
+	if platform == "" && tone == "" && len(offered) == 0 {
+		return false
+	}
+
+	if platform != "" {
+		state.DefaultPlatforms = []string{platform}
+	}
+	if tone != "" {
+		state.DefaultTone = tone
+	}
+	if len(offered) > 0 {
+		state.Services = offered // Pre-ticked on the services keyboard
+	}
+
+	post := "post"
+	if tone != "" {
+		post = strings.ToLower(tone) + " post"
+	}
+	if platform != "" {
+		post = platformLabels[platform] + " " + post
+	}
+	msgText := fmt.Sprintf("Got it — send me the **photo** and I'll make your %s. 📸", post)
+	if len(offered) > 0 {
+		labels := make([]string, len(offered))
+		for i, s := range offered {
+			labels[i] = brand.serviceLabel(s)
+		}
+		msgText += fmt.Sprintf("\n\nI'll highlight: %s.", strings.Join(labels, ", "))
+	}
+	b.sendMessage(chatID, msgText, nil)
+	return true
+}
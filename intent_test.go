@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseIntent(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantPlatform string
+		wantTone     string
+		wantServices []string
+	}{
+		{"I need an Instagram caption for my new shirt", "Instagram", "", nil},
+		{"Something fun for FB please!", "Facebook", "Enthusiastic", nil},
+		{"A formal LinkedIn post about our OEM and wholesale work", "LinkedIn", "Professional", []string{"OEM", "Bulk"}},
+		{"Twitter, then Instagram; elegant, then technical", "X", "Luxury", nil},
+		{"Cut 2 x 3 metres of fabric, materials too", "", "", []string{"Fabric"}},
+		{"custom branding for private label", "", "", []string{"Custom", "OEM"}},
+		{"Hello there", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			platform, tone, services := parseIntent(tt.text)
+			if platform != tt.wantPlatform || tone != tt.wantTone || !slices.Equal(services, tt.wantServices) {
+				t.Errorf("parseIntent(%q) = %q, %q, %q; want %q, %q, %q",
+					tt.text, platform, tone, services, tt.wantPlatform, tt.wantTone, tt.wantServices)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// --- Last Photo Reuse ---
+
+// LastPhoto describes the most recent image a user sent. The bytes live in
+// a separate file under the store's "photos" directory to keep the JSON small.
+type LastPhoto struct {
+	MimeType string    `json:"mimeType"`
+	SavedAt  time.Time `json:"savedAt"`
+}
+
+// photoPath returns where a user's last photo is kept on disk.
+func (s *Store) photoPath(userID int64) string {
+	return filepath.Join(filepath.Dir(s.path), "photos", fmt.Sprintf("%d", userID))
+}
+
+// SaveLastPhoto remembers a user's photo so /same can reuse it.
+// Photos larger than maxBytes are not kept. Expired photos of other users
+// are cleaned up at the same time.
+func (s *Store) SaveLastPhoto(userID int64, data []byte, mimeType string, maxBytes int, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLastPhotos(ttl)
+	if len(data) > maxBytes {
+		return
+	}
+
+	path := s.photoPath(userID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Printf("Error creating photo directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Printf("Error saving last photo: %v", err)
+		return
+	}
+
+	s.data.LastPhotos[userID] = LastPhoto{MimeType: mimeType, SavedAt: time.Now()}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// GetLastPhoto returns a user's last photo if it is younger than ttl.
+func (s *Store) GetLastPhoto(userID int64, ttl time.Duration) ([]byte, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, ok := s.data.LastPhotos[userID]
+	if !ok || time.Since(meta.SavedAt) > ttl {
+		return nil, "", false
+	}
+
+	data, err := os.ReadFile(s.photoPath(userID))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading last photo: %v", err)
+		}
+		return nil, "", false
+	}
+	return data, meta.MimeType, true
+}
+
+// pruneLastPhotos deletes photos older than ttl. The caller must hold s.mu.
+func (s *Store) pruneLastPhotos(ttl time.Duration) {
+	for userID, meta := range s.data.LastPhotos {
+		if time.Since(meta.SavedAt) <= ttl {
+			continue
+		}
+		if err := os.Remove(s.photoPath(userID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error removing expired photo: %v", err)
+		}
+		delete(s.data.LastPhotos, userID)
+	}
+}
+
+// reuseLastPhoto starts a fresh conversation with the user's last photo.
+func (b *Bot) reuseLastPhoto(chatID, userID int64) {
+	data, mimeType, ok := b.store.GetLastPhoto(userID, b.lastPhotoTTL)
+	if !ok {
+		b.sendMessage(chatID, "I don't have a recent photo from you. Please send one to get started. 📸", nil)
+		return
+	}
+
+	b.resetState(userID)
+	b.startWithImage(chatID, b.getState(userID), data, mimeType, "Using your last photo again! 🔁")
+}
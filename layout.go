@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Combined Result Layout ---
+
+// Result layouts a user can pick in /settings.
+const (
+	resultLayoutSplit    = "split"    // One message per caption (or the carousel)
+	resultLayoutCombined = "combined" // Everything in as few messages as possible
+)
+
+const (
+	// telegramMessageLimit is the longest text Telegram accepts in one message.
+	telegramMessageLimit = 4096
+	// combinedChunkLimit leaves headroom for the markup formatOutgoing adds.
+	combinedChunkLimit = telegramMessageLimit - 296
+)
+
+// formatCombined renders all results as one text: each platform's captions
+// and hashtags, then the feedback.
+func formatCombined(content *GeneratedContent) string {
+	multi := len(content.Results) > 1
+
+	var sb strings.Builder
+	for i, result := range content.Results {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		if multi {
+			fmt.Fprintf(&sb, "📣 **%s**\n\n", platformLabels[result.Platform])
+		}
+		for n, caption := range result.Captions {
+			fmt.Fprintf(&sb, "--- **%s**%s ---\n\n%s\n\n", result.optionLabel(n), charCountLabel(caption, result.Platform), caption)
+		}
+		fmt.Fprintf(&sb, "👇 **%s** 👇\n`%s`", tr(content.Language, "hashtags"), strings.Join(result.Hashtags, " "))
+	}
+	sb.WriteString(feedbackSection(content))
+	return sb.String()
+}
+
+// splitMessage breaks text into chunks of at most limit characters,
+// preferring paragraph breaks, then line breaks, then spaces.
+func splitMessage(text string, limit int) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > limit {
+		cut := runeOffset(text, limit)
+		head := text[:cut]
+		at := -1
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			if i := strings.LastIndex(head, sep); i > 0 {
+				at = i
+				break
+			}
+		}
+		if at < 0 {
+			at = cut // No break point; cut mid-word
+		}
+		chunks = append(chunks, strings.TrimRight(text[:at], " \n"))
+		text = strings.TrimLeft(text[at:], " \n")
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// sendCombined sends the results in as few messages as Telegram's length
+// limit allows. The markup (if any) is attached to the last one.
+func (b *Bot) sendCombined(userID int64, content *GeneratedContent, markup interface{}) {
+	chunks := splitMessage(formatCombined(content), combinedChunkLimit)
+	for i, chunk := range chunks {
+		if i == len(chunks)-1 {
+			b.sendMessage(userID, chunk, markup)
+			continue
+		}
+		b.sendMessage(userID, chunk, nil)
+	}
+}
+
+// runeOffset returns the byte offset of the n-th rune of s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testContent is a generated result for the given platforms, each with three
+// captions of captionLength characters.
+func testContent(captionLength int, platforms ...string) *GeneratedContent {
+	content := &GeneratedContent{Feedback: FeedbackPoints{{Category: "Lighting", Comment: "Brighten the background a little."}}}
+	for _, platform := range platforms {
+		caption := strings.Repeat("d", captionLength)
+		content.Results = append(content.Results, PlatformContent{
+			Platform: platform,
+			Captions: []string{caption, caption, caption},
+			Hashtags: []string{"#denim"},
+		})
+	}
+	return content
+}
+
+func TestResultMessagesPerLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		content *GeneratedContent
+		want    int
+	}{
+		{"split, one platform", resultLayoutSplit, testContent(100, "Instagram"), 4},        // 3 captions, hashtags
+		{"split, two platforms", resultLayoutSplit, testContent(100, "Instagram", "X"), 10}, // Header, 3 captions, hashtags each
+		{"combined, one platform", resultLayoutCombined, testContent(100, "Instagram"), 1},
+		{"combined, two platforms", resultLayoutCombined, testContent(100, "Instagram", "X"), 1},
+		{"combined, over the length limit", resultLayoutCombined, testContent(2000, "Instagram", "Facebook"), 6}, // No two captions fit in one message
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			userID := int64(1142 + i)
+			b.store.UpdateUserSettings(userID, func(s *UserSettings) { s.Layout = tt.layout })
+
+			unlock := b.sessions.lock(userID)
+			b.deliverResults(userID, b.getState(userID), tt.content)
+			unlock()
+
+			texts := fake.Texts(userID)
+			if len(texts) != tt.want {
+				t.Fatalf("%d messages, want %d", len(texts), tt.want)
+			}
+			for _, text := range texts {
+				if n := len([]rune(text)); n > telegramMessageLimit {
+					t.Errorf("message of %d characters, over Telegram's limit", n)
+				}
+			}
+			if feedback := messagesWith(texts, "Brighten the background"); len(feedback) != 1 || feedback[0] != len(texts)-1 {
+				t.Errorf("feedback in messages %v, want only the last", feedback)
+			}
+		})
+	}
+}
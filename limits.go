@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+// --- Platform Character Limits ---
+
+// maxCompressAttempts is how many times an over-long caption is sent back
+// to the model to be shortened before we give up and flag it.
+const maxCompressAttempts = 2
+
+// platformCharLimits are the longest captions each platform accepts.
+var platformCharLimits = map[string]int{
+	"LinkedIn":  3000,
+	"Instagram": 2200,
+	"Facebook":  63206,
+	"X":         280,
+}
+
+// captionLength counts a caption the way the platforms roughly do, in characters.
+func captionLength(caption string) int {
+	return utf8.RuneCountInString(caption)
+}
+
+// charCountLabel is the " · 123/280" shown next to each option, with a
+// warning if the caption is still over the platform's limit.
+func charCountLabel(caption, platform string) string {
+	limit, ok := platformCharLimits[platform]
+	if !ok {
+		return ""
+	}
+	n := captionLength(caption)
+	if n > limit {
+		return fmt.Sprintf(" · ⚠️ %d/%d", n, limit)
+	}
+	return fmt.Sprintf(" · %d/%d", n, limit)
+}
+
+// withFooterLength records how many characters the contact footer will add
+// to each caption, so enforceCharLimits leaves room for it.
+func (b *Bot) withFooterLength(params GenerationParams, withCTA bool) GenerationParams {
+	if withCTA && b.cta.enabled() {
+		params.FooterLength = captionLength(b.cta.footer(params.Language)) + 2 // The blank line before it
+	}
+	return params
+}
+
+// overCharLimits reports whether any caption, with the footer, is over its
+// platform's limit.
+func overCharLimits(content *GeneratedContent, footerLength int) bool {
+	for _, result := range content.Results {
+		limit, ok := platformCharLimits[result.Platform]
+		if !ok {
+			continue
+		}
+		for _, caption := range result.Captions {
+			if captionLength(caption)+footerLength > limit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enforceCharLimits asks the model to shorten every caption that, with the
+// footer, is over its platform's limit. A caption still too long after
+// maxCompressAttempts is kept (its count is flagged when shown) with a note.
+func enforceCharLimits(ctx context.Context, client ContentGenerator, content *GeneratedContent, footerLength int) {
+	for r := range content.Results {
+		result := &content.Results[r]
+		limit, ok := platformCharLimits[result.Platform]
+		if !ok {
+			continue
+		}
+		budget := limit - footerLength
+		for i, caption := range result.Captions {
+			for attempt := 0; attempt < maxCompressAttempts && captionLength(caption) > budget; attempt++ {
+				logFrom(ctx).Info("Caption over the platform limit, asking for a shorter one",
+					"platform", result.Platform, "option", i+1, "length", captionLength(caption), "budget", budget)
+				instruction := fmt.Sprintf("Shorten this to at most %d characters, hashtags included. Keep the key message and the tone.", budget*9/10)
+				shorter, usage, err := refineCaption(ctx, client, caption, result.Platform, instruction)
+				content.Usage.Add(usage)
+				if err != nil {
+					logFrom(ctx).Warn("Could not shorten the caption", "error", err)
+					break
+				}
+				caption = shorter
+			}
+			result.Captions[i] = caption
+			if captionLength(caption) > budget {
+				content.Notes = append(content.Notes, fmt.Sprintf("⚠️ %s (%s) is still over the %d-character limit; trim it before posting.",
+					result.optionLabel(i), platformLabels[result.Platform], limit))
+			}
+		}
+	}
+}
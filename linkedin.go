@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- LinkedIn Company Page ---
+
+const (
+	linkedinAPIURL   = "https://api.linkedin.com/v2"
+	linkedinTokenURL = "https://www.linkedin.com/oauth/v2/accessToken"
+	linkedinOrgURN   = "urn:li:organization:"
+)
+
+// linkedinOrganization turns LINKEDIN_ORGANIZATION, a company page's
+// numeric ID or its URN, into the URN.
+func linkedinOrganization(raw string) (string, error) {
+	id := strings.TrimPrefix(strings.TrimSpace(raw), linkedinOrgURN)
+	if id == "" || strings.Trim(id, "0123456789") != "" {
+		return "", fmt.Errorf("%q is not an organization ID or %s<id> URN", raw, linkedinOrgURN)
+	}
+	return linkedinOrgURN + id, nil
+}
+
+// linkedinPublisher posts photos to a LinkedIn company page as the
+// organization, with an OAuth token of a page admin that has the
+// w_organization_social scope.
+type linkedinPublisher struct {
+	organization string // urn:li:organization:<id>
+	client       *http.Client
+
+	// With a refresh token (and the app's client ID and secret), expired
+	// access tokens are renewed; without, the access token is used until
+	// LinkedIn rejects it.
+	clientID     string
+	clientSecret string
+	refreshToken string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // Zero for a token of unknown lifetime
+}
+
+// accessToken returns a valid token, refreshing it if needed and possible.
+func (p *linkedinPublisher) accessToken(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && (p.expires.IsZero() || time.Until(p.expires) > tokenRefreshMargin) {
+		return p.token, nil
+	}
+	if p.refreshToken == "" {
+		return "", errors.New("the LinkedIn access token has expired; set a new LINKEDIN_ACCESS_TOKEN")
+	}
+	req, err := postForm(ctx, linkedinTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {p.refreshToken},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating LinkedIn token request: %w", err)
+	}
+	var token tokenResponse
+	if _, err := p.do(req, &token); err != nil {
+		return "", fmt.Errorf("error refreshing LinkedIn access token: %w", err)
+	}
+	p.token = token.AccessToken
+	p.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return p.token, nil
+}
+
+// invalidate drops the access token after LinkedIn rejected it, so the
+// next post refreshes it.
+func (p *linkedinPublisher) invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refreshToken != "" {
+		p.token = ""
+	}
+}
+
+// linkedinError is the error body LinkedIn's REST APIs return.
+type linkedinError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// The OAuth endpoint uses these instead
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// do sends a request and decodes its JSON response into out (if not nil).
+// It returns the response headers, which carry the ID of created posts.
+func (p *linkedinPublisher) do(req *http.Request, out any) (http.Header, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling LinkedIn: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading LinkedIn response: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		p.invalidate()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var liErr linkedinError
+		if json.Unmarshal(raw, &liErr) == nil {
+			if liErr.Message != "" {
+				return nil, fmt.Errorf("LinkedIn error %d: %s", resp.StatusCode, liErr.Message)
+			}
+			if liErr.Description != "" {
+				return nil, fmt.Errorf("LinkedIn error %d: %s", resp.StatusCode, liErr.Description)
+			}
+		}
+		return nil, fmt.Errorf("LinkedIn request failed with status %d: %s", resp.StatusCode, raw)
+	}
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return nil, fmt.Errorf("error parsing LinkedIn response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// newRequest builds an authorized request to the LinkedIn API.
+func (p *linkedinPublisher) newRequest(ctx context.Context, method, target, token string, body io.Reader, contentType string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating LinkedIn request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Restli-Protocol-Version", "2.0.0")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// postJSON sends a JSON body to the LinkedIn API.
+func (p *linkedinPublisher) postJSON(ctx context.Context, target, token string, in, out any) (http.Header, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding LinkedIn request: %w", err)
+	}
+	req, err := p.newRequest(ctx, "POST", target, token, bytes.NewReader(body), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	return p.do(req, out)
+}
+
+// publish implements publisher in three steps: register an image upload
+// for the organization, upload the photo, then create a UGC post with the
+// caption and the image asset.
+func (p *linkedinPublisher) publish(ctx context.Context, req publishRequest) (string, error) {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var registered struct {
+		Value struct {
+			Asset           string `json:"asset"`
+			UploadMechanism struct {
+				HTTPRequest struct {
+					UploadURL string `json:"uploadUrl"`
+				} `json:"com.linkedin.digitalmedia.uploading.MediaUploadHttpRequest"`
+			} `json:"uploadMechanism"`
+		} `json:"value"`
+	}
+	register := map[string]any{
+		"registerUploadRequest": map[string]any{
+			"recipes": []string{"urn:li:digitalmediaRecipe:feedshare-image"},
+			"owner":   p.organization,
+			"serviceRelationships": []map[string]string{
+				{"relationshipType": "OWNER", "identifier": "urn:li:userGeneratedContent"},
+			},
+		},
+	}
+	if _, err := p.postJSON(ctx, linkedinAPIURL+"/assets?action=registerUpload", token, register, &registered); err != nil {
+		return "", fmt.Errorf("error registering LinkedIn image upload: %w", err)
+	}
+	uploadURL := registered.Value.UploadMechanism.HTTPRequest.UploadURL
+	if uploadURL == "" || registered.Value.Asset == "" {
+		return "", errors.New("LinkedIn returned no image upload URL")
+	}
+
+	upload, err := p.newRequest(ctx, "PUT", uploadURL, token, bytes.NewReader(req.Photo), req.MimeType)
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.do(upload, nil); err != nil {
+		return "", fmt.Errorf("error uploading image to LinkedIn: %w", err)
+	}
+
+	post := map[string]any{
+		"author":         p.organization,
+		"lifecycleState": "PUBLISHED",
+		"specificContent": map[string]any{
+			"com.linkedin.ugc.ShareContent": map[string]any{
+				"shareCommentary":    map[string]string{"text": req.text()},
+				"shareMediaCategory": "IMAGE",
+				"media": []map[string]string{
+					{"status": "READY", "media": registered.Value.Asset},
+				},
+			},
+		},
+		"visibility": map[string]string{"com.linkedin.ugc.MemberNetworkVisibility": "PUBLIC"},
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	header, err := p.postJSON(ctx, linkedinAPIURL+"/ugcPosts", token, post, &created)
+	if err != nil {
+		return "", fmt.Errorf("error creating LinkedIn post: %w", err)
+	}
+	id := created.ID
+	if id == "" {
+		id = header.Get("X-RestLi-Id")
+	}
+	return "https://www.linkedin.com/feed/update/" + id + "/", nil
+}
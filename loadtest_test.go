@@ -0,0 +1,99 @@
+//go:build loadtest
+
+package main
+
+import (
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/goleak"
+)
+
+// --- Load Test ---
+
+// TestLoadTestConcurrentUsers walks many users through the whole conversation
+// at once, through the dispatcher and the generation queue, against a fake
+// Telegram and a stub model with some latency. Run it with
+//
+//	go test -race -tags loadtest -run LoadTest .
+//
+// LOADTEST_USERS sets the number of users (default 50).
+func TestLoadTestConcurrentUsers(t *testing.T) {
+	// Registered first, so it runs after the fakes are shut down. The queue
+	// workers run for the life of the process.
+	before := goleak.IgnoreCurrent()
+	t.Cleanup(func() {
+		goleak.VerifyNone(t, before, goleak.IgnoreAnyFunction("github.com/shabbirtoha/telegram-caption-bot.(*fairQueue).work"))
+	})
+
+	users := 50
+	if n, err := strconv.Atoi(os.Getenv("LOADTEST_USERS")); err == nil && n > 0 {
+		users = n
+	}
+
+	b, fake := newTestBot(t)
+	b.maxPlatforms = len(platformOrder)
+	feedbackGemini(t, b, func(bool) { time.Sleep(time.Duration(5+rand.IntN(20)) * time.Millisecond) })
+	servePhoto(t, b, testJPEG(t, 600, 600))
+	b.queue = newFairQueue(0)
+	b.queue.start(8)
+	dispatcher := newUpdateDispatcher(16, b.processUpdate, b.isBanned)
+	message := func(message *tgbotapi.Message) botUpdate { return botUpdate{Update: tgbotapi.Update{Message: message}} }
+	tap := func(userID int64, data string) botUpdate {
+		return botUpdate{Update: tgbotapi.Update{CallbackQuery: callbackQuery(userID, data)}}
+	}
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < users; i++ {
+		userID := int64(10_000 + i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, update := range []botUpdate{
+				message(textMessage(userID, "/start")),
+				message(photoMessage(userID)),
+				tap(userID, "platform:Instagram"),
+				tap(userID, "platform:LinkedIn"),
+				tap(userID, "control:done_platforms"),
+				tap(userID, "tone:Professional"),
+				tap(userID, "intensity:Balanced"),
+				tap(userID, "service:OEM"),
+				tap(userID, "control:done_services"),
+				tap(userID, "language:en"),
+				message(textMessage(userID, "New spring collection")),
+			} {
+				dispatcher.dispatch(update)
+			}
+		}()
+	}
+	wg.Wait()
+	<-dispatcher.finished()
+	<-b.queue.drained()
+	t.Logf("%d users served in %v", users, time.Since(started))
+
+	for i := 0; i < users; i++ {
+		userID := int64(10_000 + i)
+		var captions, feedback int
+		for _, text := range fake.Texts(userID) {
+			if strings.Contains(text, "First caption") {
+				captions++
+			}
+			if strings.Contains(text, "Brighten the background") {
+				feedback++
+			}
+		}
+		if captions == 0 || feedback != 1 {
+			t.Errorf("user %d got %d caption message(s) and %d feedback message(s)", userID, captions, feedback)
+		}
+	}
+	if b.stats.generations != users || b.stats.errors != 0 {
+		t.Errorf("stats: %d generations, %d errors; want %d and none", b.stats.generations, b.stats.errors, users)
+	}
+}
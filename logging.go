@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// --- Structured Logging ---
+
+// setupLogging makes slog's default logger write format ("json" or "text")
+// at the given level. The log package's output goes through it too, so
+// older log.Printf lines come out in the same format.
+func setupLogging(format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL %q: %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q: must be json or text", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// newGenerationID returns a short random ID that ties together the log
+// lines of one generation, across all its model calls.
+func newGenerationID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type loggerKey struct{}
+
+// withLogger attaches a logger to ctx, for the model calls made under it.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// logFrom returns the logger attached to ctx, or the default logger.
+func logFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// userLogger is the logger for a user's conversation, tagged with who they
+// are and where their conversation is.
+func (b *Bot) userLogger(userID, chatID int64) *slog.Logger {
+	b.mu.Lock()
+	state := StateDefault
+	if s, ok := b.userStates[userID]; ok {
+		state = s.State
+	}
+	b.mu.Unlock()
+	return slog.With("user_id", userID, "chat_id", chatID, "state", int(state))
+}
+
+// generationLogger is userLogger plus a new generation ID.
+func (b *Bot) generationLogger(userID int64) *slog.Logger {
+	return b.userLogger(userID, userID).With("generation_id", newGenerationID())
+}
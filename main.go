@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
@@ -24,26 +29,191 @@ const (
 	StateWaitingForTone
 	StateWaitingForServices
 	StateWaitingForContext
+	StateWaitingForScheduleTime
+	StateWaitingForPDFPage
+	StateWaitingForQualityConfirm
+	StateWaitingForToneIntensity
+	StateWaitingForDuplicateChoice
+	StateCollectingBatch
+	StateWaitingForAttributes
+	StateRefining // Results delivered; text is an edit instruction for a caption
+	StateWaitingForLanguage
+	StateWaitingForCustomTone
 )
 
 // userState holds the data for a single user's conversation.
 type userState struct {
-	State     ConversationState
-	PhotoData []byte // Raw image data
-	MimeType  string // e.g., "image/jpeg"
-	Platform  string
-	Tone      string
-	Services  []string
-	Context   string
-	MessageID int // The ID of the message we are editing (e.g., "Please choose...")
+	State         ConversationState
+	PhotoData     []byte // Raw image data
+	MimeType      string // e.g., "image/jpeg"
+	Platforms     []string
+	Tone          string
+	ToneIntensity string // Subtle, Balanced or Strong; "" means Balanced
+	Services      []string
+	Context       string
+	MessageID     int          // The ID of the message we are editing (e.g., "Please choose...")
+	Brand         *BrandConfig // Brand for this job, picked when the photo arrives
+	ImageNote     string       // What ImageRules.fit did to the photo, shown with the results
+	ForwardedFrom string       // Source of a forwarded photo, e.g. "@somechannel"
+	AlbumPhotos   []albumPhoto // The other photos of an album; PhotoData is the first
+	Language      string       // Output language, picked in the language step; defaults to the user's settings
+
+	StyleReference string // A past caption to imitate, set with /style
+	SkipCache      bool   // Generate even if the result cache has this job (Regenerate)
+
+	Attributes *ProductAttributes // Confirmed product details (DETECT_ATTRIBUTES); nil until detected
+
+	LastResult  *GeneratedContent // The most recent result, kept for scheduling
+	LastRequest *userState        // The photo and answers behind LastResult, for Regenerate
+	Refining    *captionRef       // The caption edited last in StateRefining; nil before the first edit
+
+	SchedulingPost *scheduledPost // The channel post waiting for a time in StateWaitingForScheduleTime
+
+	// Position in the carousel view of LastResult (RESULT_STYLE=carousel)
+	CarouselMessageID int
+	CarouselIndex     int
+	CarouselHashtags  bool
+
+	// Answers preset by a /start deep link, applied to the next photo
+	DefaultPlatforms []string
+	DefaultTone      string
+
+	PDFData  []byte // Raw PDF while the user picks a page
+	PDFPages int
+
+	Batch []batchItem // Photos collected in batch mode (/batch)
 }
 
 // Bot holds the API and the state for all users.
 type Bot struct {
 	api        *tgbotapi.BotAPI
 	userStates map[int64]*userState
-	mu         sync.Mutex // Mutex to protect userStates map
-	geminiKey  string
+	lastActive map[int64]time.Time // When each user last sent an update, for SESSION_TTL
+	mu         sync.Mutex          // Mutex to protect userStates and lastActive
+	sessions   sessionLocks        // Held while a user's state changes, see lockSession
+	llm        ContentGenerator    // Gemini or the provider picked with LLM_PROVIDER
+	cache      resultCache         // Finished results by photo and answers; nil disables caching
+	store      *Store
+	queue      *fairQueue              // Generation jobs, shared fairly between users
+	brands     map[string]*BrandConfig // Named presets from BRAND_PRESETS_DIR
+	adminIDs   map[int64]bool
+	// restrictAccess limits the bot to admins, allowedUsers and users
+	// who redeemed an invite
+	restrictAccess bool
+	allowedUsers   map[int64]bool
+	pricing        Pricing
+	monthlyBudget  float64        // MONTHLY_BUDGET_USD; 0 means no budget alerts
+	location       *time.Location // Time zone for interpreting schedule times
+	quota          *quotaLimiter  // Per-user rate limit and daily cap on generations
+	stats          botStats       // Generation counts since start, for /stats
+
+	publishTargets []publishTarget // Social network accounts captions can be posted to
+	publishUsers   map[int64]bool  // Who besides admins may post to them
+
+	albums albumBuffer // Albums whose photos are still arriving
+
+	jobs    map[jobKey]context.CancelFunc // Queued/running generations, for the Cancel button
+	queued  map[jobKey]queuedJobInfo      // Generations still waiting, for position updates
+	jobsMu  sync.Mutex
+	latency durationAverage // Recent generation times, for wait estimates
+
+	cta                CTAConfig // Contact footer appended to captions
+	enforceEmojiPolicy bool      // Post-process captions with applyEmojiPolicy
+	resultStyle        string    // resultStyleMessages or resultStyleCarousel
+	responseFormat     ResponseFormat
+	dryRun             bool // Log outgoing messages instead of sending them (DRY_RUN)
+
+	maxPlatforms int // Most platforms a user may pick for one job
+
+	imageQualityCheck bool       // Warn about tiny/dark/flat photos before generating
+	images            ImageRules // Accepted types and size limits of incoming images
+	output            OutputSettings
+
+	downloadClient   *http.Client // Separate from Gemini's client, with its own timeout
+	downloadAttempts int
+	fileCache        *fileCache // Recently downloaded files by FileID
+
+	inline inlineQueries // Inline queries in flight, so a newer one cancels the last
+
+	lastPhotoTTL      time.Duration // How long /same can reuse a photo
+	lastPhotoMaxBytes int           // Larger photos aren't kept for /same
+
+	maxPDFBytes       int64 // Largest PDF we'll download
+	maxImageFileBytes int64 // Largest image we'll download when sent as a file
+	maxPDFPages       int   // Most pages a PDF may have
+
+	requireServiceSelection bool // Block "Done" until at least one service is picked
+
+	maxBatchSize int // Most photos in one /batch
+
+	ratedExamples int // Top-rated past captions added to the prompt per platform
+
+	detectAttributes bool // Ask the user to confirm the detected product details first
+
+	versionAdminOnly bool // Restrict /version to ADMIN_IDS
+
+	apiToken string // Bearer token for POST /api/generate; "" disables the API
+
+	sheets *sheetsExporter // Appends results to SHEETS_SPREADSHEET_ID; nil if unset
+
+	steps map[ConversationState]*conversationStep // The conversation, see newConversationSteps
+
+	reporter *errorReporter // Sends failures to ERROR_REPORT_CHAT; nil if unset
+
+	states     StateStore    // Saves conversations across restarts; nil keeps them in memory only
+	sessionTTL time.Duration // Idle sessions are cleared after this long; 0 never
+}
+
+// NewBot builds a Bot from its configuration and dependencies.
+func NewBot(api *tgbotapi.BotAPI, cfg Config, llm ContentGenerator, store *Store, brands map[string]*BrandConfig) *Bot {
+	return &Bot{
+		api:                     api,
+		userStates:              make(map[int64]*userState),
+		lastActive:              make(map[int64]time.Time),
+		sessions:                sessionLocks{locks: make(map[int64]*sessionLock)},
+		llm:                     llm,
+		store:                   store,
+		brands:                  brands,
+		queue:                   newFairQueue(cfg.MaxQueued),
+		jobs:                    make(map[jobKey]context.CancelFunc),
+		queued:                  make(map[jobKey]queuedJobInfo),
+		albums:                  albumBuffer{albums: make(map[string]*pendingAlbum)},
+		adminIDs:                cfg.AdminIDs,
+		restrictAccess:          cfg.RestrictAccess,
+		allowedUsers:            cfg.AllowedUsers,
+		publishTargets:          newPublishTargets(cfg),
+		steps:                   newConversationSteps(),
+		publishUsers:            cfg.PublishUsers,
+		pricing:                 cfg.Pricing,
+		monthlyBudget:           cfg.MonthlyBudget,
+		location:                cfg.Location,
+		quota:                   newQuotaLimiter(cfg.Quota, cfg.Location),
+		stats:                   botStats{started: time.Now()},
+		requireServiceSelection: cfg.RequireServiceSelection,
+		enforceEmojiPolicy:      cfg.EnforceEmojiPolicy,
+		cta:                     cfg.CTA,
+		resultStyle:             cfg.ResultStyle,
+		responseFormat:          cfg.ResponseFormat,
+		dryRun:                  cfg.DryRun,
+		maxPlatforms:            cfg.MaxPlatforms,
+		imageQualityCheck:       cfg.ImageQualityCheck,
+		images:                  cfg.Images,
+		output:                  cfg.Output,
+		downloadClient:          &http.Client{Timeout: cfg.DownloadTimeout},
+		downloadAttempts:        cfg.DownloadAttempts,
+		fileCache:               newFileCache(),
+		lastPhotoTTL:            cfg.LastPhotoTTL,
+		lastPhotoMaxBytes:       cfg.LastPhotoMaxBytes,
+		maxPDFBytes:             cfg.MaxPDFBytes,
+		maxImageFileBytes:       cfg.MaxImageFileBytes,
+		maxPDFPages:             cfg.MaxPDFPages,
+		maxBatchSize:            cfg.MaxBatchSize,
+		ratedExamples:           cfg.RatedExamples,
+		detectAttributes:        cfg.DetectAttributes,
+		versionAdminOnly:        cfg.VersionAdminOnly,
+		apiToken:                cfg.APIToken,
+		sessionTTL:              cfg.SessionTTL,
+	}
 }
 
 // --- Main Function ---
@@ -54,47 +224,117 @@ func main() {
 		log.Println("No .env file found, relying on environment variables.")
 	}
 
-	telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	geminiKey := os.Getenv("GEMINI_API_KEY")
+	log.Printf("Starting caption bot %s", versionSummary())
 
-	if telegramToken == "" || geminiKey == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN and GEMINI_API_KEY must be set in .env or environment")
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if err := setupLogging(cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	api, err := tgbotapi.NewBotAPI(telegramToken)
+	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	api.Debug = false
+	api.Debug = cfg.TelegramDebug
 	log.Printf("Authorized on account %s", api.Self.UserName)
 
-	bot := &Bot{
-		api:        api,
-		userStates: make(map[int64]*userState),
-		geminiKey:  geminiKey,
+	store, err := NewStore(cfg.DataFile)
+	if err != nil {
+		log.Fatalf("Could not open data file: %v", err)
+	}
+
+	if cfg.PromptTemplatePath != "" {
+		if captionPromptTemplate, err = loadCaptionPromptTemplate(cfg.PromptTemplatePath); err != nil {
+			log.Fatalf("Could not load CAPTION_PROMPT_TEMPLATE: %v", err)
+		}
+		log.Printf("Using caption prompt template from %s", cfg.PromptTemplatePath)
+	}
+
+	if cfg.ServicesFile != "" {
+		if defaultBrand.Services, err = loadServiceCatalog(cfg.ServicesFile); err != nil {
+			log.Fatalf("Could not load SERVICES_FILE: %v", err)
+		}
+		log.Printf("Loaded %d service(s) from %s", len(defaultBrand.Services), cfg.ServicesFile)
+	}
+
+	brands := make(map[string]*BrandConfig)
+	if cfg.BrandPresetsDir != "" {
+		if brands, err = loadBrandPresets(cfg.BrandPresetsDir); err != nil {
+			log.Fatalf("Could not load brand presets: %v", err)
+		}
 	}
 
+	breaker := newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown)
+	auth := newAuthGuard(cfg.AuthFailureThreshold)
+	provider, err := newModelProvider(cfg)
+	if err != nil {
+		log.Fatalf("Could not set up the LLM provider: %v", err)
+	}
+	llm := NewLLMClient(provider, cfg.Models, cfg.TaskModels, cfg.Retry, breaker, auth)
+	for task, models := range store.ModelOverrides() {
+		llm.setModelOverride(task, models)
+	}
+	log.Printf("Using LLM provider %s with models %v %v", cfg.LLMProvider, cfg.Models, sortedTasks(cfg.TaskModels))
+	if cfg.LLMProvider == providerGemini && cfg.GeminiAuth == geminiAuthVertex {
+		log.Printf("Calling Gemini through Vertex AI in %s", cfg.VertexLocation)
+	}
+
+	bot := NewBot(api, cfg, llm, store, brands)
+	if bot.cache, err = newResultCache(cfg); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if bot.sheets, err = newSheetsExporter(cfg); err != nil {
+		log.Fatalf("Could not set up Google Sheets export: %v", err)
+	}
+	bot.reporter = newErrorReporter(cfg, bot.sendErrorReport)
+	bot.startErrorReports()
+	if cfg.StateDB != "" {
+		states, err := openStateStore(cfg.StateDB)
+		if err != nil {
+			log.Fatalf("Could not open STATE_DB: %v", err)
+		}
+		defer states.Close()
+		bot.states = states
+		if err := bot.restoreStates(); err != nil {
+			log.Printf("Warning: could not restore conversations: %v", err)
+		}
+	}
+	bot.queue.onDequeue = bot.refreshQueuePositions
+	auth.onChange = bot.onAuthChange
+	if bot.dryRun {
+		log.Println("DRY_RUN is on: outgoing messages are logged, not sent")
+	}
+
+	// SIGTERM (sent on redeploy) or Ctrl+C stops taking updates; see shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
-	updates := api.GetUpdatesChan(u)
+	updates := pollUpdates(ctx, api, u)
 
 	// --- NEW: Start the bot logic in a separate goroutine ---
 	// This lets the bot run its long-pollyng loop
 	// while the main thread runs the HTTP server for health checks.
+	// Users are handled concurrently; each user's updates stay in order
+	dispatcher := newUpdateDispatcher(cfg.UpdateHandlers, bot.processUpdate, bot.isBanned)
+	dispatching := make(chan struct{})
 	go func() {
-		// Listen for updates
-		for update := range updates {
-			if update.CallbackQuery != nil {
-				bot.handleCallbackQuery(update.CallbackQuery)
-			} else if update.Message != nil {
-				if update.Message.Photo != nil && len(update.Message.Photo) > 0 { // Added safety check
-					bot.handlePhoto(update.Message)
-				} else if update.Message.IsCommand() {
-					bot.handleCommand(update.Message)
-				} else {
-					bot.handleMessage(update.Message)
+		defer close(dispatching)
+		// Listen for updates until shutdown
+		for {
+			select {
+			case update, ok := <-updates:
+				if !ok {
+					return
 				}
+				dispatcher.dispatch(update)
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
@@ -106,16 +346,40 @@ func main() {
 		fmt.Fprintf(w, "Bot is alive!")
 	})
 
-	// Get the port from the environment (required by hosting platforms)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // Default port for local testing
+	// The generation API for the companion web app is only served with a token set
+	if bot.apiToken != "" {
+		http.HandleFunc("/api/generate", bot.handleAPIGenerate)
+		log.Println("Serving the generation API at /api/generate")
 	}
 
-	log.Printf("Starting health check server on port %s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Panic(err)
+	// Start the generation workers
+	bot.queue.start(cfg.Workers)
+
+	// Deliver scheduled posts in the background
+	go bot.runScheduler()
+
+	// Clear sessions left idle
+	if bot.sessionTTL > 0 {
+		go bot.runSessionSweeper()
 	}
+
+	log.Printf("Starting health check server on port %s", cfg.Port)
+	server := &http.Server{Addr: ":" + cfg.Port}
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Panic(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // A second signal kills the process straight away
+	log.Printf("Shutting down: no longer taking updates, waiting up to %s for work in progress", cfg.ShutdownTimeout)
+	<-dispatching
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	bot.shutdown(shutdownCtx, dispatcher, server)
+	log.Println("Shutdown complete")
 }
 
 // --- State Management Helpers ---
@@ -134,6 +398,32 @@ func (b *Bot) getState(userID int64) *userState {
 	return newState
 }
 
+// brand returns the job's brand, or the default brand if none was set.
+func (s *userState) brand() *BrandConfig {
+	if s.Brand != nil {
+		return s.Brand
+	}
+	return defaultBrand
+}
+
+// generationParams collects the answers for getB2BContent, with the
+// configured output settings.
+func (s *userState) generationParams(output OutputSettings) GenerationParams {
+	return GenerationParams{
+		Platforms:      s.Platforms,
+		Tone:           s.Tone,
+		ToneIntensity:  s.ToneIntensity,
+		Services:       s.Services,
+		Context:        s.Context,
+		Language:       s.Language,
+		StyleReference: s.StyleReference,
+		Attributes:     s.Attributes,
+		AlbumImages:    albumImages(s.AlbumPhotos),
+		Brand:          s.brand(),
+		Output:         output,
+	}
+}
+
 // resetState clears a user's state after a job is done or cancelled.
 func (b *Bot) resetState(userID int64) {
 	b.mu.Lock()
@@ -145,14 +435,79 @@ func (b *Bot) resetState(userID int64) {
 // --- Message & Command Handlers ---
 
 func (b *Bot) handleCommand(message *tgbotapi.Message) {
+	if b.handleAdminCommand(message) {
+		return
+	}
+
 	state := b.getState(message.From.ID)
 
 	switch message.Command() {
 	case "start":
 		msgText := "Welcome to the ARSourcingBD Content Bot! 👋\n\n" +
 			"Please send me a **photo** of your product to get started. I will then guide you through a few questions to generate the perfect social media post."
-		b.sendMessage(message.Chat.ID, msgText, nil)
 		b.resetState(message.From.ID)
+
+		// A deep link like t.me/<bot>?start=linkedin presets answers for the next photo
+		if platforms, tone, ok := parseStartPreset(message.CommandArguments()); ok {
+			newState := b.getState(message.From.ID)
+			newState.DefaultPlatforms = platforms
+			newState.DefaultTone = tone
+			msgText += fmt.Sprintf("\n\n🔗 Preset: **%s**. I'll skip those questions.", describePreset(platforms, tone))
+		} else if arg := message.CommandArguments(); arg != "" && arg != inlineStartParameter {
+			log.Printf("Ignoring unknown start parameter %q", arg)
+		}
+		b.sendMessage(message.Chat.ID, msgText, nil)
+	case "whoami", "chatid":
+		// Available to everyone (it only shows the caller's own IDs) and
+		// leaves any conversation in progress untouched
+		b.sendMessage(message.Chat.ID, whoamiText(message), nil)
+		return
+	case "version":
+		// Like /whoami, this leaves any conversation in progress untouched
+		if b.versionAdminOnly && !b.isAdmin(message.From.ID) {
+			b.sendMessage(message.Chat.ID, "Sorry, that command is for admins only.", nil)
+		} else {
+			b.sendMessage(message.Chat.ID, versionText(), nil)
+		}
+		return
+	case "export":
+		b.sendExport(message.Chat.ID, message.From.ID)
+		return
+	case "forgetme":
+		b.askForget(message.Chat.ID)
+		return
+	case "style":
+		b.handleStyleCommand(message.Chat.ID, state, message.CommandArguments())
+		return
+	case "batch":
+		b.removeInlineKeyboard(message.Chat.ID, state.MessageID)
+		b.startBatchMode(message.Chat.ID, message.From.ID)
+		return
+	case "scheduled":
+		b.listScheduled(message.Chat.ID, message.From.ID)
+	case "settings":
+		b.showSettings(message.Chat.ID, message.From.ID, 0)
+	case "timezone":
+		b.handleTimezone(message.Chat.ID, message.From.ID, message.CommandArguments())
+	case "brand":
+		b.handleBrandCommand(message.Chat.ID, message.From.ID, message.CommandArguments())
+	case "connectchannel":
+		b.handleConnectChannel(message.Chat.ID, message.From.ID, message.CommandArguments())
+	case "disconnectchannel":
+		b.handleDisconnectChannel(message.Chat.ID, message.From.ID)
+	case "history":
+		b.sendHistory(message.Chat.ID, message.From.ID)
+	case "usage":
+		// Like /whoami, this leaves any conversation in progress untouched
+		b.handleUsage(message.Chat.ID, message.From.ID, message.CommandArguments())
+		return
+	case "brands":
+		b.listBrands(message.Chat.ID, message.From.ID)
+	case "same":
+		// Clean up any half-finished conversation before starting over
+		b.removeInlineKeyboard(message.Chat.ID, state.MessageID)
+		b.reuseLastPhoto(message.Chat.ID, message.From.ID)
+		return
 	case "cancel":
 		b.resetState(message.From.ID)
 		b.sendMessage(message.Chat.ID, "Your previous operation has been cancelled. Send a photo to start over.", nil)
@@ -170,53 +525,111 @@ func (b *Bot) handlePhoto(message *tgbotapi.Message) {
 	userID := message.From.ID
 	state := b.getState(userID)
 
-	// Get the largest photo (the last one in the slice is the highest quality)
-	photo := message.Photo[len(message.Photo)-1]
+	if state.State == StateCollectingBatch {
+		b.addBatchPhoto(message, state)
+		return
+	}
 
 	// Download the photo
-	photoData, mimeType, err := b.downloadFile(photo.FileID)
+	photoData, mimeType, err := b.downloadFile(photoFileID(message), true)
+	var unsupported *unsupportedImageError
+	if errors.As(err, &unsupported) {
+		b.sendMessage(message.Chat.ID, unsupported.Error(), nil)
+		return
+	}
 	if err != nil {
 		log.Printf("Error downloading file: %v", err)
 		b.sendMessage(message.Chat.ID, "Sorry, I had trouble downloading your photo. Please try again.", nil)
 		return
 	}
 
-	// Save data to state
-	state.PhotoData = photoData
-	state.MimeType = mimeType
+	// Remember where a forwarded photo came from, to flag it with the results
+	state.ForwardedFrom = forwardAttribution(message)
+
+	// An album's photos arrive one message at a time; gather them first
+	if message.MediaGroupID != "" {
+		b.addAlbumPhoto(message, photoData, mimeType)
+		return
+	}
+
+	// Offer the earlier result if this photo was captioned recently
+	if b.offerPreviousResult(message.Chat.ID, state, photoData, mimeType) {
+		return
+	}
+
+	// Catch thumbnails and accidental uploads before spending API calls
+	if b.imageQualityCheck {
+		report, err := assessImageQuality(photoData, b.images.MinSide)
+		if err != nil {
+			log.Printf("Warning: could not assess image quality: %v", err)
+		} else if !report.OK() {
+			b.confirmLowQuality(message.Chat.ID, state, photoData, mimeType, report)
+			return
+		}
+	}
+
+	b.startWithImage(message.Chat.ID, state, photoData, mimeType, "Great photo! 📸")
+}
+
+// photoFileID returns the file of a photo message: the largest size of a
+// compressed photo (the last one is the highest quality), or an image sent
+// uncompressed as a document.
+func photoFileID(message *tgbotapi.Message) string {
+	if len(message.Photo) > 0 {
+		return message.Photo[len(message.Photo)-1].FileID
+	}
+	return message.Document.FileID
+}
+
+// startWithImage saves the product image and asks the first question,
+// prefixed with a short intro. Photos and rendered PDF pages both enter the flow here.
+func (b *Bot) startWithImage(chatID int64, state *userState, imageData []byte, mimeType, intro string) {
+	// Save data to state, downscaled if it's larger than we need
+	state.PhotoData, state.MimeType, state.ImageNote = b.images.fit(imageData, mimeType)
+	state.AlbumPhotos = nil // Added back by finishAlbum for an album
 	state.State = StateWaitingForPlatform
+	state.Brand = b.brandFor(chatID)
+	state.Language = b.store.GetUserSettings(b.memberID(chatID)).Language
 
-	// Ask the first question
-	msgText := "Great photo! 📸 Now, which platform is this for?"
-	msg := tgbotapi.NewMessage(message.Chat.ID, msgText)
-	msg.ReplyMarkup = platformKeyboard
+	// Keep a copy so /same can start a new job with it later
+	b.store.SaveLastPhoto(chatID, state.PhotoData, state.MimeType, b.lastPhotoMaxBytes, b.lastPhotoTTL)
 
-	sentMsg, err := b.api.Send(msg)
-	if err == nil {
-		// Store the message ID so we can edit it later
-		state.MessageID = sentMsg.MessageID
+	// Ask the first question, skipping any answered by a deep-link preset
+	first := b.firstStep(state, StateWaitingForPlatform)
+	note := intro + " "
+	if first != StateWaitingForPlatform {
+		note = fmt.Sprintf("%s Creating for **%s**. ", intro, describePreset(state.Platforms, state.Tone))
 	}
+	b.showStep(chatID, state, first, note, true)
 }
 
 func (b *Bot) handleMessage(message *tgbotapi.Message) {
 	state := b.getState(message.From.ID)
 
-	if state.State == StateWaitingForContext {
-		// User sent text, this is their optional context
-		state.Context = message.Text
-		state.State = StateDefault // Ready to generate
+	if b.handleStepInput(message.Chat.ID, state, stepInput{Message: message}) {
+		return
+	}
+
+	// Text before the photo, e.g. "I need an Instagram caption", answers
+	// those questions in advance
+	if state.State == StateDefault && len(state.PhotoData) == 0 && b.handleIntent(message.Chat.ID, state, message.Text) {
+		return
+	}
 
-		// Clean up the "Skip" message
-		b.removeInlineKeyboard(message.Chat.ID, state.MessageID)
+	// User sent text out of context
+	msgText := "I'm not sure what to do with that. 🤔\n\n" +
+		"Please send me a **photo** to start generating content, or /cancel to restart."
+	b.sendMessage(message.Chat.ID, msgText, nil)
+}
 
-		// Start the generation process
-		b.generateContent(message.Chat.ID)
-	} else {
-		// User sent text out of context
-		msgText := "I'm not sure what to do with that. 🤔\n\n" +
-			"Please send me a **photo** to start generating content, or /cancel to restart."
-		b.sendMessage(message.Chat.ID, msgText, nil)
+// whoamiText lists the caller's IDs, e.g. for setting up ADMIN_IDS.
+func whoamiText(message *tgbotapi.Message) string {
+	username := "(none)"
+	if message.From.UserName != "" {
+		username = "@" + message.From.UserName
 	}
+	return fmt.Sprintf("🪪 **Your Telegram IDs**\n\nUser ID: `%d`\nUsername: `%s`\nChat ID: `%d`",
+		message.From.ID, username, message.Chat.ID)
 }
 
 // --- Callback (Button) Handler ---
@@ -226,116 +639,413 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	state := b.getState(userID)
 	data := query.Data
 
+	// Some taps are refused with a short notice on the button itself.
+	// This is checked before the normal answer so the notice shows on the callback.
+	if notice := b.stepNotice(state, data); notice != "" {
+		b.send(tgbotapi.NewCallback(query.ID, notice))
+		return
+	}
+	// Ratings are thanked on the button too
+	if strings.HasPrefix(data, "rate:") {
+		b.handleRateCallback(query)
+		return
+	}
+
 	// Answer the callback to remove the "loading" icon on the button
-	b.api.Send(tgbotapi.NewCallback(query.ID, ""))
-
-	switch state.State {
-	case StateWaitingForPlatform:
-		state.Platform = strings.Split(data, ":")[1]
-		state.State = StateWaitingForTone
-		b.editMessage(userID, "Got it. And what's the **tone** you're going for?", toneKeyboard)
-
-	case StateWaitingForTone:
-		state.Tone = strings.Split(data, ":")[1]
-		state.State = StateWaitingForServices
-		b.editMessage(userID, "Perfect. Which **services** should I highlight? (Select all that apply, then 'Done')", buildServicesKeyboard(state.Services))
-
-	case StateWaitingForServices:
-		if strings.HasPrefix(data, "service:") {
-			// User is toggling a service
-			service := strings.Split(data, ":")[1]
-			// Toggle the service in the state
-			var newServices []string
-			found := false
-			for _, s := range state.Services {
-				if s == service {
-					found = true
-				} else {
-					newServices = append(newServices, s)
-				}
-			}
-			if !found {
-				newServices = append(newServices, service)
-			}
-			state.Services = newServices
-			// Re-draw the keyboard with the new checkmarks
-			b.editMessage(userID, "Perfect. Which **services** should I highlight? (Select all that apply, then 'Done')", buildServicesKeyboard(state.Services))
-
-		} else if data == "control:done_services" {
-			// User is done selecting services
-			state.State = StateWaitingForContext
-			b.editMessage(userID, "Last step! Any **additional context**? (e.g., 'This is for our new sustainable line.')\n\nType your answer or press 'Skip'.", contextKeyboard)
+	b.send(tgbotapi.NewCallback(query.ID, ""))
+
+	// Scheduling, "same photo" and "explain" buttons work outside the normal conversation flow
+	if b.handleScheduleCallback(query) {
+		return
+	}
+	if data == "control:same" {
+		b.reuseLastPhoto(userID, userID)
+		return
+	}
+	if data == "control:explain" {
+		b.handleExplainCallback(userID)
+		return
+	}
+	if data == "control:regenerate" {
+		b.handleRegenerate(query)
+		return
+	}
+	if data == "control:done" {
+		b.handleDone(query)
+		return
+	}
+	if data == "control:cancel_gen" {
+		b.handleCancelGeneration(query)
+		return
+	}
+	if strings.HasPrefix(data, "forget:") {
+		b.handleForgetCallback(query)
+		return
+	}
+	if data == "channel:post" {
+		b.handleChannelPost(query)
+		return
+	}
+	if strings.HasPrefix(data, "publish:") {
+		b.handlePublishCallback(query)
+		return
+	}
+	if strings.HasPrefix(data, "history:") {
+		b.handleHistoryCallback(query)
+		return
+	}
+	if strings.HasPrefix(data, "nav:") {
+		b.handleCarouselCallback(query)
+		return
+	}
+	if strings.HasPrefix(data, "setting:") {
+		b.handleSettingsCallback(query)
+		return
+	}
+	if data == "control:back" {
+		b.goBack(userID, state)
+		return
+	}
+
+	b.handleStepInput(userID, state, stepInput{Data: data})
+}
+
+// toggleSelection adds item to list, or removes it if already present.
+func toggleSelection(list []string, item string) []string {
+	var out []string
+	found := false
+	for _, s := range list {
+		if s == item {
+			found = true
+		} else {
+			out = append(out, s)
 		}
+	}
+	if !found {
+		out = append(out, item)
+	}
+	return out
+}
 
-	case StateWaitingForContext:
-		if data == "control:skip_context" {
-			state.Context = ""                              // Explicitly set as empty
-			state.State = StateDefault                      // Ready to generate
-			b.removeInlineKeyboard(userID, state.MessageID) // Clean up the "Skip" message
-			b.generateContent(userID)
+// containsString reports whether list contains item.
+func containsString(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
 		}
 	}
+	return false
 }
 
 // --- Content Generation ---
 
+// generateContent queues generation for the user's finished conversation.
+// The conversation state is copied into the job and reset straight away, so
+// the user can start something new while the job waits for a worker.
 func (b *Bot) generateContent(userID int64) {
-	state := b.getState(userID)
+	// Check the limits before any API call (attribute detection included);
+	// the quota is only used once the job is queued
+	if b.quotaBlocked(userID, generationCount(b.getState(userID))) {
+		b.resetState(userID)
+		return
+	}
 
-	// 1. Send "thinking" message
-	thinkingMsg, _ := b.api.Send(tgbotapi.NewMessage(userID, "Got it! ✨ Analyzing image and your requirements... This might take a moment."))
+	if state := b.getState(userID); b.detectAttributes && state.Attributes == nil && len(state.Batch) == 0 && len(state.PhotoData) > 0 {
+		b.detectProductAttributes(userID, state)
+		return
+	}
+
+	snapshot := *b.getState(userID)
+	b.resetState(userID)
 
-	// 2. Call Gemini
-	content, err := getB2BContent(b.geminiKey, state.PhotoData, state.MimeType, state)
+	// The photo can go missing if the state expired or was corrupted;
+	// don't send Gemini a request without an image.
+	if len(snapshot.PhotoData) == 0 || snapshot.MimeType == "" {
+		b.userLogger(userID, userID).Warn("Reached generation without a photo")
+		b.sendMessage(userID, "I seem to have lost your photo — please send it again. 📸", nil)
+		return
+	}
+
+	if len(snapshot.Batch) > 0 {
+		b.startBatch(userID, &snapshot)
+		return
+	}
+	if b.serveCachedResult(userID, &snapshot) {
+		return
+	}
+
+	// 1. Send "thinking" message, with a button to cancel the job
+	thinking := b.newMessage(userID, b.thinkingText(0))
+	thinking.ReplyMarkup = cancelGenKeyboard
+	thinkingMsg, _ := b.send(thinking)
+
+	key := jobKey{userID: userID, thinkingMsgID: thinkingMsg.MessageID}
+	ctx := withLogger(b.startJob(key), b.generationLogger(userID))
+	position, err := b.submitGeneration(key, func() { b.runGeneration(ctx, key, &snapshot) })
+	if position > 0 {
+		b.editMessageID(userID, key.thinkingMsgID, b.thinkingText(position), cancelGenKeyboard)
+	}
 	if err != nil {
-		log.Printf("Error generating content: %v", err)
-		b.sendMessage(userID, fmt.Sprintf("Oh no! I ran into an error: %s\n\nPlease try again. /cancel", err.Error()), nil)
-		b.api.Send(tgbotapi.NewDeleteMessage(userID, thinkingMsg.MessageID)) // Delete "thinking" msg
-		b.resetState(userID)
+		b.cancelJob(key)
+		b.send(tgbotapi.NewDeleteMessage(userID, thinkingMsg.MessageID))
+		b.sendMessage(userID, "You already have several posts being generated. Please wait for them to finish, then use /same to try again.", nil)
+		return
+	}
+	b.useQuota(userID, generationCount(&snapshot))
+}
+
+// runGeneration calls Gemini and delivers the results. It runs on a queue worker.
+// If the job is cancelled, nothing is sent, even if the results arrive anyway.
+func (b *Bot) runGeneration(ctx context.Context, key jobKey, state *userState) {
+	userID, thinkingMsgID := key.userID, key.thinkingMsgID
+	shownPosition := b.untrackQueuedJob(key)
+	if ctx.Err() != nil {
+		return // Cancelled while waiting in the queue
+	}
+	if shownPosition > 0 {
+		// Our turn: drop the queue position from the message
+		b.editMessageID(userID, thinkingMsgID, b.thinkingText(0), cancelGenKeyboard)
+	}
+
+	// Show "typing…" until the captions are in
+	typingCtx, stopTyping := context.WithCancel(ctx)
+	defer stopTyping()
+	go b.keepChatAction(typingCtx, userID, tgbotapi.ChatTyping)
+
+	// 2. Call Gemini for the captions and, alongside, the feedback. The
+	// captions are sent as soon as they're ready; the feedback follows,
+	// unless the combined layout needs it in the same message. The
+	// feedback has its own context, since finishJob cancels ctx.
+	started := time.Now()
+	logger := logFrom(ctx)
+	logger.Info("Generation started", "platforms", state.Platforms, "language", state.Language)
+	base64Image := base64.StdEncoding.EncodeToString(state.PhotoData)
+	params := b.withFooterLength(b.withRatedExamples(state.generationParams(b.output)), b.store.GetUserSettings(b.memberID(userID)).ctaEnabled())
+	feedbackCtx, cancelFeedback := context.WithCancel(withLogger(context.Background(), logger))
+	defer cancelFeedback()
+	waitFeedback := startFeedback(feedbackCtx, b.llm, base64Image, state.MimeType, params.Language, params.Output)
+	content, err := getCaptionContent(ctx, b.llm, base64Image, state.MimeType, params, b.thinkingProgress(ctx, key))
+	stopTyping()
+	var uncached *GeneratedContent // Before the user's own settings are applied
+	if err == nil && b.cache != nil {
+		uncached = cloneContent(content)
+	}
+	if !b.finishJob(key) {
+		logger.Info("Generation cancelled, discarding result")
+		if content != nil {
+			b.recordUsage(userID, content.Usage)
+		}
 		return
 	}
+	b.stats.record(err)
+	if err != nil {
+		logger.Error("Generation failed", "error", err, "duration", time.Since(started))
+		if errors.Is(err, errCircuitOpen) {
+			b.sendMessage(userID, "The AI service is temporarily unavailable, please try again in a few minutes. 🙏", nil)
+		} else if errors.Is(err, errAuthDegraded) {
+			b.sendMessage(userID, "The bot is misconfigured, please contact the administrator. 🙏", nil)
+		} else {
+			b.sendMessage(userID, fmt.Sprintf("Oh no! I ran into an error: %s\n\nPlease try again. /cancel", err.Error()), nil)
+		}
+		b.send(tgbotapi.NewDeleteMessage(userID, thinkingMsgID)) // Delete "thinking" msg
+		return
+	}
+
+	// 3. Record usage, apply the caption policies and send the captions
+	b.finishContent(userID, state, content)
+	combined := b.store.GetUserSettings(b.memberID(userID)).Layout == resultLayoutCombined
+	if combined {
+		mergeFeedback(content, b.awaitFeedback(userID, waitFeedback))
+	}
+	b.send(tgbotapi.NewDeleteMessage(userID, thinkingMsgID)) // Delete "thinking" msg
+	unlock := b.lockSession(userID)
+	live := b.getState(userID)
+	live.LastRequest = requestSnapshot(state)
+	b.deliverResults(userID, live, content)
+	unlock()
+
+	// 4. Wait for the feedback and send it. The job can no longer be cancelled
+	// (its Cancel button is gone), so this runs to the end.
+	if !combined {
+		b.sendFeedback(userID, content, waitFeedback)
+	}
+	if uncached != nil {
+		uncached.Feedback, uncached.Background = content.Feedback, content.Background
+		b.cacheResult(resultCacheKey(state.PhotoData, params), uncached)
+	}
+	b.rememberResult(userID, state.PhotoData, content)
+	b.recordResult(userID, state, content)
+
+	// The worker was busy until now, so time the whole job for wait estimates
+	b.latency.add(time.Since(started))
+	logger.Info("Generation finished", "duration", time.Since(started), "total_tokens", content.Usage.TotalTokenCount)
+}
 
-	// 3. Format and send the results
-	b.api.Send(tgbotapi.NewDeleteMessage(userID, thinkingMsg.MessageID)) // Delete "thinking" msg
+// sendFeedback waits for the feedback (from startFeedback) on captions that
+// have already been sent, and sends it as a follow-up message. The content
+// is the user's LastResult by now, so the feedback is added under their lock.
+func (b *Bot) sendFeedback(userID int64, content *GeneratedContent, waitFeedback func(*GeneratedContent)) {
+	feedback := b.awaitFeedback(userID, waitFeedback)
+	unlock := b.lockSession(userID)
+	mergeFeedback(content, feedback)
+	unlock()
 
-	// --- Send Caption 1 ---
-	b.sendMessage(userID, fmt.Sprintf("--- **Option 1** ---\n\n%s", content.Captions[0]), nil)
+	b.sendMessage(userID, strings.TrimPrefix(feedbackSection(content), "\n\n"), nil)
+}
 
-	// --- Send Caption 2 ---
-	b.sendMessage(userID, fmt.Sprintf("--- **Option 2** ---\n\n%s", content.Captions[1]), nil)
+// awaitFeedback waits for the feedback from startFeedback and records its
+// usage; the captions' usage is already recorded.
+func (b *Bot) awaitFeedback(userID int64, waitFeedback func(*GeneratedContent)) *GeneratedContent {
+	var feedback GeneratedContent
+	waitFeedback(&feedback)
+	b.recordUsage(userID, feedback.Usage)
+	return &feedback
+}
 
-	// --- Send Caption 3 ---
-	b.sendMessage(userID, fmt.Sprintf("--- **Option 3** ---\n\n%s", content.Captions[2]), nil)
+// mergeFeedback adds the feedback from awaitFeedback to the captions' content.
+func mergeFeedback(content, feedback *GeneratedContent) {
+	content.Feedback, content.Background = feedback.Feedback, feedback.Background
+	content.Usage.Add(feedback.Usage)
+}
 
-	// --- Send Hashtags & Feedback ---
-	hashtagString := ""
-	for _, h := range content.Hashtags {
-		hashtagString += h + " "
+// thinkingProgress edits a job's "thinking" message to show the current
+// stage, keeping its cancel button. It stops once the job is cancelled.
+// Stages may be reported from several goroutines; the edits are made one
+// at a time, and a repeated stage is skipped (Telegram rejects an edit
+// that changes nothing).
+func (b *Bot) thinkingProgress(ctx context.Context, key jobKey) ProgressFunc {
+	var mu sync.Mutex
+	var shown ProgressStage
+	return func(stage ProgressStage) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil || stage == shown {
+			return
+		}
+		shown = stage
+		b.editMessageID(key.userID, key.thinkingMsgID, "Got it! ✨ "+string(stage), cancelGenKeyboard)
 	}
+}
 
-	finalMsg := fmt.Sprintf("👇 **Suggested Hashtags** 👇\n`%s`\n\n", hashtagString)
-	finalMsg += fmt.Sprintf("💡 **AI Image Feedback**\n*%s*", content.Feedback)
+// finishContent records a finished job's token usage and applies the caption
+// post-processing (emoji policy, contact footer).
+func (b *Bot) finishContent(userID int64, state *userState, content *GeneratedContent) {
+	// Record token usage for cost tracking
+	b.recordUsage(userID, content.Usage)
 
-	msg := tgbotapi.NewMessage(userID, finalMsg)
-	msg.ParseMode = "Markdown"
-	b.api.Send(msg)
+	if state.ImageNote != "" {
+		content.Notes = append(content.Notes, state.ImageNote)
+	}
+	if state.ForwardedFrom != "" {
+		content.Notes = append(content.Notes, forwardNote(state.ForwardedFrom))
+	}
 
-	// 4. Reset state
-	b.resetState(userID)
+	b.applyCaptionPolicies(content, b.store.GetUserSettings(b.memberID(userID)).ctaEnabled())
+}
+
+// applyCaptionPolicies post-processes the captions: the emoji policy, then
+// the contact footer (if withCTA), so the emoji policy never touches it.
+func (b *Bot) applyCaptionPolicies(content *GeneratedContent, withCTA bool) {
+	for _, result := range content.Results {
+		for i, caption := range result.Captions {
+			if b.enforceEmojiPolicy {
+				caption = applyEmojiPolicy(caption, result.Platform)
+			}
+			if withCTA && b.cta.enabled() {
+				caption = appendCTA(caption, b.cta, content.Language)
+			}
+			result.Captions[i] = caption
+		}
+	}
+}
+
+// deliverResults keeps the result around for the result buttons and sends it
+// in the configured style, or as one message if the user chose that layout.
+// The caller holds the user's session (see lockSession), which saves the result.
+func (b *Bot) deliverResults(userID int64, state *userState, content *GeneratedContent) {
+	state.LastResult = content
+	state.Refining = nil
+	if state.State == StateDefault {
+		// Keep the session open for edits like "shorter" or "add emojis"
+		state.State = StateRefining
+	}
+	if b.store.GetUserSettings(b.memberID(userID)).Layout == resultLayoutCombined {
+		b.sendCombined(userID, content, resultKeyboard)
+	} else if b.resultStyle == resultStyleCarousel {
+		b.sendCarousel(userID, state, content)
+	} else {
+		b.sendResults(userID, content, resultKeyboard, b.captionMarkup(userID))
+	}
+}
+
+// sendResults sends the captions, hashtags and feedback as separate messages.
+// With several platforms, each platform's captions and hashtags are labeled.
+// The markup (if any) is attached to the final message, and captionMarkup
+// (if any) to each caption.
+func (b *Bot) sendResults(userID int64, content *GeneratedContent, markup, captionMarkup interface{}) {
+	multi := len(content.Results) > 1
+
+	for i, result := range content.Results {
+		label := ""
+		if multi {
+			label = " · " + platformLabels[result.Platform]
+			b.sendMessage(userID, fmt.Sprintf("📣 **%s**", platformLabels[result.Platform]), nil)
+		}
+
+		// --- Send Captions ---
+		for n, caption := range result.Captions {
+			msgID := b.sendMessageID(userID, fmt.Sprintf("--- **%s**%s%s ---\n\n%s", result.optionLabel(n), label, charCountLabel(caption, result.Platform), caption), captionMarkup)
+			if msgID != 0 {
+				b.store.TrackResultMessage(userID, msgID, captionRefFor(content, i, n))
+			}
+		}
+
+		// --- Send Hashtags (and Feedback after the last platform) ---
+		hashtagString := ""
+		for _, h := range result.Hashtags {
+			hashtagString += h + " "
+		}
+		finalMsg := fmt.Sprintf("👇 **%s**%s 👇\n`%s`", tr(content.Language, "hashtags"), label, hashtagString)
+
+		if i < len(content.Results)-1 {
+			b.sendMessage(userID, finalMsg, nil)
+			continue
+		}
+
+		finalMsg += feedbackSection(content)
+		b.sendMessage(userID, finalMsg, markup)
+	}
 }
 
 // --- Bot API Helpers ---
 
-// sendMessage is a simple wrapper to send text.
+// sendMessage is a simple wrapper to send text. If Telegram rejects the
+// formatting (e.g. a stray "_" in a caption), it is resent as plain text.
 func (b *Bot) sendMessage(userID int64, text string, markup interface{}) {
-	msg := tgbotapi.NewMessage(userID, text)
+	b.sendMessageID(userID, text, markup)
+}
+
+// sendMessageID is sendMessage, returning the sent message's ID (0 on failure).
+func (b *Bot) sendMessageID(userID int64, text string, markup interface{}) int {
+	msg := b.newMessage(userID, text)
 	if markup != nil {
 		msg.ReplyMarkup = markup
 	}
-	msg.ParseMode = "Markdown"
-	if _, err := b.api.Send(msg); err != nil {
+	sent, err := b.send(msg)
+	if errors.Is(err, errChatInactive) {
+		return 0
+	}
+	if err != nil && msg.ParseMode != "" {
+		log.Printf("Error sending formatted message, retrying as plain text: %v", err)
+		msg.Text = formatOutgoing(text, formatPlain)
+		msg.ParseMode = ""
+		sent, err = b.send(msg)
+	}
+	if err != nil {
 		log.Printf("Error sending message: %v", err)
+		return 0
 	}
+	return sent.MessageID
 }
 
 // editMessage updates an existing message with new text and keyboard.
@@ -346,12 +1056,22 @@ func (b *Bot) editMessage(userID int64, text string, markup tgbotapi.InlineKeybo
 		b.sendMessage(userID, text, markup) // Fallback to sending a new message
 		return
 	}
+	b.editMessageID(userID, state.MessageID, text, markup)
+}
 
-	msg := tgbotapi.NewEditMessageText(userID, state.MessageID, text)
+// editMessageID updates a specific message with new text and keyboard.
+func (b *Bot) editMessageID(userID int64, messageID int, text string, markup tgbotapi.InlineKeyboardMarkup) {
+	msg := b.newEditMessage(userID, messageID, text)
 	msg.ReplyMarkup = &markup
-	msg.ParseMode = "Markdown"
 
-	if _, err := b.api.Send(msg); err != nil {
+	_, err := b.send(msg)
+	if err != nil && msg.ParseMode != "" && strings.Contains(err.Error(), "can't parse entities") {
+		log.Printf("Error editing formatted message, retrying as plain text: %v", err)
+		msg.Text = formatOutgoing(text, formatPlain)
+		msg.ParseMode = ""
+		_, err = b.send(msg)
+	}
+	if err != nil {
 		log.Printf("Error editing message, might be unchanged: %v", err)
 	}
 }
@@ -362,37 +1082,43 @@ func (b *Bot) removeInlineKeyboard(userID int64, messageID int) {
 		return
 	}
 	edit := tgbotapi.NewEditMessageReplyMarkup(userID, messageID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
-	b.api.Send(edit)
+	b.send(edit)
 }
 
 // downloadFile downloads a file from Telegram and returns its data.
-func (b *Bot) downloadFile(fileID string) ([]byte, string, error) {
-	file, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
-	if err != nil {
-		return nil, "", err
-	}
-
-	fileURL := file.Link(b.api.Token)
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return nil, "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("bad status: %s", resp.Status)
-	}
+// Files downloaded in the last few minutes are served from the cache.
+// With isImage set, types outside the accepted ones are rejected with an
+// *unsupportedImageError, whose message can be shown to the user.
+func (b *Bot) downloadFile(fileID string, isImage bool) ([]byte, string, error) {
+	data, ok := b.fileCache.get(fileID)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), downloadDeadline)
+		defer cancel()
+
+		// Ask for the link inside the retry loop: links expire, and a fresh
+		// GetFile is the only way to get a working one.
+		getLink := func() (string, error) {
+			file, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
+			if err != nil {
+				return "", err
+			}
+			return file.Link(b.api.Token), nil
+		}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", err
+		var err error
+		data, err = fetchFile(ctx, b.downloadClient, getLink, b.downloadAttempts)
+		if err != nil {
+			return nil, "", err
+		}
+		b.fileCache.put(fileID, data)
 	}
 
 	// Get MimeType
 	mimeType := http.DetectContentType(data)
-	if mimeType != "image/jpeg" && mimeType != "image/png" {
-		log.Printf("Warning: Uploaded file is %s, not jpeg/png.", mimeType)
-		// We'll try anyway, Gemini is flexible
+	if isImage {
+		if err := b.images.checkType(mimeType); err != nil {
+			return nil, "", err
+		}
 	}
 
 	return data, mimeType, nil
@@ -400,16 +1126,45 @@ func (b *Bot) downloadFile(fileID string) ([]byte, string, error) {
 
 // --- Inline Keyboards (Buttons) ---
 
-var platformKeyboard = tgbotapi.NewInlineKeyboardMarkup(
-	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("LinkedIn", "platform:LinkedIn"),
-		tgbotapi.NewInlineKeyboardButtonData("Instagram", "platform:Instagram"),
-	),
-	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Facebook", "platform:Facebook"),
-		tgbotapi.NewInlineKeyboardButtonData("X (Twitter)", "platform:X"),
-	),
-)
+// platformPromptText is shown while the user picks platforms.
+const platformPromptText = "Which **platforms** is this for? (Select all that apply, then 'Done')"
+
+// platformOrder and platformLabels define the platform buttons.
+var platformOrder = []string{"LinkedIn", "Instagram", "Facebook", "X"}
+
+var platformLabels = map[string]string{
+	"LinkedIn":  "LinkedIn",
+	"Instagram": "Instagram",
+	"Facebook":  "Facebook",
+	"X":         "X (Twitter)",
+}
+
+// buildPlatformKeyboard creates the platform buttons, two per row, with checkmarks.
+func buildPlatformKeyboard(selectedPlatforms []string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, key := range platformOrder {
+		text := platformLabels[key]
+		if containsString(selectedPlatforms, key) {
+			text = "✅ " + text
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, "platform:"+key))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🌐 All Platforms", "control:all_platforms"),
+	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➡️ Done Selecting ➡️", "control:done_platforms"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
 
 var toneKeyboard = tgbotapi.NewInlineKeyboardMarkup(
 	tgbotapi.NewInlineKeyboardRow(
@@ -420,56 +1175,66 @@ var toneKeyboard = tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardButtonData("Luxury", "tone:Luxury"),
 		tgbotapi.NewInlineKeyboardButtonData("Technical", "tone:Technical"),
 	),
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✏️ Custom tone", "control:custom_tone"),
+	),
+	backRow,
+)
+
+var intensityKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Subtle", "intensity:Subtle"),
+		tgbotapi.NewInlineKeyboardButtonData("Balanced", "intensity:Balanced"),
+		tgbotapi.NewInlineKeyboardButtonData("Strong", "intensity:Strong"),
+	),
+	backRow,
 )
 
 // buildServicesKeyboard dynamically creates the service buttons with checkmarks.
-func buildServicesKeyboard(selectedServices []string) tgbotapi.InlineKeyboardMarkup {
-	services := map[string]string{
-		"OEM":    "OEM / Private Label",
-		"Custom": "Custom Branding",
-		"Bulk":   "Bulk Manufacturing",
-		"Fabric": "Premium Fabric",
-	}
-
-	// Helper to check if a service is selected
-	isSelected := func(key string) bool {
-		for _, s := range selectedServices {
-			if s == key {
-				return true
-			}
+func buildServicesKeyboard(brand *BrandConfig, selectedServices []string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, service := range brand.Services {
+		text := service.Label
+		if containsString(selectedServices, service.Key) {
+			text = "✅ " + text
 		}
-		return false
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, "service:"+service.Key),
+		))
 	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➡️ Done Selecting ➡️", "control:done_services"),
+	))
+	rows = append(rows, backRow)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
 
-	// Helper to create the button text
-	buttonText := func(key, text string) string {
-		if isSelected(key) {
-			return "✅ " + text
-		}
-		return text
-	}
-
-	return tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttonText("OEM", services["OEM"]), "service:OEM"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttonText("Custom", services["Custom"]), "service:Custom"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttonText("Bulk", services["Bulk"]), "service:Bulk"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttonText("Fabric", services["Fabric"]), "service:Fabric"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➡️ Done Selecting ➡️", "control:done_services"),
-		),
-	)
-}
-
-var contextKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+var resultKeyboard = tgbotapi.NewInlineKeyboardMarkup(
 	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Skip This Step", "control:skip_context"),
+		tgbotapi.NewInlineKeyboardButtonData("⏰ Schedule", "control:schedule"),
+		tgbotapi.NewInlineKeyboardButtonData("🔁 Same Photo", "control:same"),
+	),
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🧠 Explain", "control:explain"),
+	),
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔄 Regenerate", "control:regenerate"),
+		tgbotapi.NewInlineKeyboardButtonData("✅ Done", "control:done"),
 	),
 )
+
+// buildContextKeyboard offers the brand's context quick replies and "Skip".
+// Presets are referenced by index ("context_preset:<n>").
+func buildContextKeyboard(brand *BrandConfig) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, preset := range brand.ContextPresets {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(preset.Label, fmt.Sprintf("context_preset:%d", i)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Skip This Step", "control:skip_context"),
+	))
+	rows = append(rows, backRow)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
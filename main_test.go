@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRequireServiceSelection(t *testing.T) {
+	for _, required := range []bool{false, true} {
+		t.Run(fmt.Sprintf("REQUIRE_SERVICE_SELECTION=%v", required), func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.requireServiceSelection = required
+			const userID = 1097
+			state := b.getState(userID)
+			state.State = StateWaitingForServices
+
+			b.handleCallbackQuery(callbackQuery(userID, "control:done_services"))
+			want := StateWaitingForLanguage
+			if required {
+				want = StateWaitingForServices
+			}
+			if state.State != want {
+				t.Fatalf("after Done with no service, state = %v, want %v", state.State, want)
+			}
+			var warned bool
+			for _, call := range fake.Calls("answerCallbackQuery") {
+				warned = warned || call.Params.Get("text") == "Please select at least one service"
+			}
+			if warned != required {
+				t.Errorf("warned about the missing service = %v, want %v", warned, required)
+			}
+
+			// Picking a service lets Done through either way
+			state.State = StateWaitingForServices
+			b.handleCallbackQuery(callbackQuery(userID, "service:OEM"))
+			b.handleCallbackQuery(callbackQuery(userID, "control:done_services"))
+			if state.State != StateWaitingForLanguage || !slices.Equal(state.Services, []string{"OEM"}) {
+				t.Errorf("after Done with a service, state = %v with services %v, want %v with [OEM]", state.State, state.Services, StateWaitingForLanguage)
+			}
+		})
+	}
+}
+
+func TestGenerationWithoutPhotoAsksAgain(t *testing.T) {
+	tests := []struct {
+		name      string
+		photoData []byte
+		mimeType  string
+	}{
+		{"no photo", nil, "image/jpeg"},
+		{"no MIME type", []byte("photo"), ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			var called func() []string
+			b.llm, called = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+				return http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"{}"}]}}]}`
+			})
+			b.queue = newFairQueue(0)
+			b.queue.start(1)
+
+			userID := int64(303 + i)
+			state := b.getState(userID)
+			state.Platforms = []string{"Instagram"}
+			state.PhotoData, state.MimeType = tt.photoData, tt.mimeType
+			b.generateContent(userID)
+
+			if texts := fake.Texts(userID); len(texts) != 1 || !strings.Contains(texts[0], "lost your photo") {
+				t.Errorf("messages = %q, want only the request to send the photo again", texts)
+			}
+			if models := called(); len(models) != 0 {
+				t.Errorf("%d Gemini requests made without a photo", len(models))
+			}
+			if state := b.getState(userID); state.State != StateDefault || len(state.Platforms) != 0 {
+				t.Errorf("state = %+v, want it reset", state)
+			}
+		})
+	}
+}
+
+func TestContextQuickReplyGenerates(t *testing.T) {
+	b, fake := newTestBot(t)
+	captionPrompts := captionGemini(t, b)
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 1128
+	preset := defaultBrand.ContextPresets[2]
+	state := b.getState(userID)
+	state.PhotoData, state.MimeType = testJPEG(t, 600, 600), "image/jpeg"
+	state.Platforms = []string{"Instagram"}
+	state.State = StateWaitingForServices
+	b.handleCallbackQuery(callbackQuery(userID, "control:done_services"))
+	b.handleCallbackQuery(callbackQuery(userID, "language:en"))
+
+	calls := fake.Calls("sendMessage", "editMessageText")
+	if markup := calls[len(calls)-1].Params.Get("reply_markup"); !strings.Contains(markup, "context_preset:2") || !strings.Contains(markup, preset.Label) {
+		t.Errorf("context keyboard = %s, want the brand's quick replies", markup)
+	}
+
+	b.handleCallbackQuery(callbackQuery(userID, "context_preset:2"))
+	flushQueue(b.queue)
+
+	prompts := captionPrompts()
+	if len(prompts) == 0 {
+		t.Fatal("tapping a quick reply made no caption request")
+	}
+	for _, prompt := range prompts {
+		if !strings.Contains(prompt, "**Additional Context:** "+preset.Text) {
+			t.Errorf("caption prompt doesn't carry the quick reply's text %q", preset.Text)
+		}
+	}
+}
+
+// feedbackReply is a Gemini response with one feedback point.
+const feedbackReply = `{"candidates":[{"content":{"parts":[{"text":"{\"feedback\":[{\"category\":\"Lighting\",\"comment\":\"Brighten the background a little.\"}]}"}]}}]}`
+
+// feedbackGemini points b at a Gemini server that answers feedback requests
+// with feedbackReply and the others with captionsReply. Each request calls
+// before first, which may hold it up.
+func feedbackGemini(t testing.TB, b *Bot, before func(feedback bool)) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GeminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		schema := req.GenerationConfig.ResponseSchema
+		feedback := schema != nil && schema.Properties["feedback"].Type != ""
+		before(feedback)
+		if feedback {
+			fmt.Fprint(w, feedbackReply)
+			return
+		}
+		fmt.Fprint(w, captionsReply)
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	provider := &geminiProvider{apiKey: "test-key", httpClient: &http.Client{Transport: redirectTransport{target}}}
+	b.llm = NewLLMClient(provider, []string{"model"}, nil, RetryPolicy{MaxAttempts: 1}, newCircuitBreaker(0, 0), newAuthGuard(0))
+}
+
+func TestCaptionsSentBeforeFeedbackResolves(t *testing.T) {
+	b, fake := newTestBot(t)
+	release := make(chan struct{})
+	feedbackGemini(t, b, func(feedback bool) {
+		if feedback {
+			<-release
+		}
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 301
+	queueGeneration(t, b, userID)
+	waitFor(t, "the captions", func() bool { return len(messagesWith(fake.Texts(userID), "First caption")) > 0 })
+	if got := messagesWith(fake.Texts(userID), "Brighten the background"); len(got) != 0 {
+		t.Error("feedback sent before its call returned")
+	}
+
+	close(release)
+	flushQueue(b.queue)
+	texts := fake.Texts(userID)
+	captions, feedback := messagesWith(texts, "First caption"), messagesWith(texts, "Brighten the background")
+	if len(feedback) != 1 || feedback[0] < captions[len(captions)-1] {
+		t.Errorf("feedback messages at %v, captions at %v; want one feedback message after the captions", feedback, captions)
+	}
+}
+
+func TestCombinedLayoutIncludesFeedback(t *testing.T) {
+	b, fake := newTestBot(t)
+	feedbackGemini(t, b, func(bool) {})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 302
+	b.store.UpdateUserSettings(userID, func(s *UserSettings) { s.Layout = resultLayoutCombined })
+	queueGeneration(t, b, userID)
+	flushQueue(b.queue)
+
+	texts := fake.Texts(userID)
+	captions, feedback := messagesWith(texts, "First caption"), messagesWith(texts, "Brighten the background")
+	if len(captions) != 1 || len(feedback) != 1 || captions[0] != feedback[0] {
+		t.Errorf("captions in messages %v, feedback in %v; want both in one message", captions, feedback)
+	}
+	if state := b.getState(userID); state.LastResult == nil || state.LastResult.Feedback == nil {
+		t.Error("the kept result has no feedback")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// --- Per-Task Models ---
+
+// modelTask picks which model list a request uses. Requests with no task
+// use the default list (LLM_MODELS / GEMINI_MODELS).
+type modelTask string
+
+const (
+	taskDefault  modelTask = "default"
+	taskCaptions modelTask = "captions" // Writing, shortening and editing captions
+	taskFeedback modelTask = "feedback" // Photo feedback and the background check
+	taskInline   modelTask = "inline"   // Hashtags and snippets for inline queries
+)
+
+// modelTasks are the tasks /model accepts, in display order.
+var modelTasks = []modelTask{taskDefault, taskCaptions, taskFeedback, taskInline}
+
+// modelUsage is shown for a malformed /model.
+const modelUsage = "Usage:\n" +
+	"`/model` — show the models in use\n" +
+	"`/model <task> <model>[,<fallback>...]` — switch a task's models\n" +
+	"`/model <task> reset` — go back to the configured models\n\n" +
+	"Tasks: `default`, `captions` (a stronger model is worth it here), `feedback` (a cheap one is fine) and `inline` (pick a fast one)."
+
+type modelTaskKey struct{}
+
+// withModelTask marks the requests made under ctx as belonging to task.
+func withModelTask(ctx context.Context, task modelTask) context.Context {
+	return context.WithValue(ctx, modelTaskKey{}, task)
+}
+
+// modelTaskFrom returns the task ctx was marked with, or taskDefault.
+func modelTaskFrom(ctx context.Context) modelTask {
+	if task, ok := ctx.Value(modelTaskKey{}).(modelTask); ok {
+		return task
+	}
+	return taskDefault
+}
+
+// modelsFor returns the models to try for task, primary first: the
+// admins' override, the task's configured list, or the default list.
+func (c *LLMClient) modelsFor(task modelTask) []string {
+	c.modelsMu.RLock()
+	defer c.modelsMu.RUnlock()
+	if models := c.overrides[task]; len(models) > 0 {
+		return models
+	}
+	if models := c.taskModels[task]; len(models) > 0 {
+		return models
+	}
+	if models := c.overrides[taskDefault]; len(models) > 0 {
+		return models
+	}
+	return c.models
+}
+
+// setModelOverride switches task to models at runtime; nil removes the
+// override.
+func (c *LLMClient) setModelOverride(task modelTask, models []string) {
+	c.modelsMu.Lock()
+	defer c.modelsMu.Unlock()
+	if len(models) == 0 {
+		delete(c.overrides, task)
+		return
+	}
+	c.overrides[task] = models
+}
+
+// modelsReport lists the models each task uses, for /model.
+func (c *LLMClient) modelsReport() string {
+	text := "🤖 **Models in use**\n\n"
+	for _, task := range modelTasks {
+		c.modelsMu.RLock()
+		_, overridden := c.overrides[task]
+		c.modelsMu.RUnlock()
+		note := ""
+		if overridden {
+			note = " (set with /model)"
+		}
+		text += fmt.Sprintf("• `%s`: %s%s\n", task, strings.Join(c.modelsFor(task), " → "), note)
+	}
+	return text
+}
+
+// SetModelOverrides stores the /model overrides, so they survive a restart.
+func (s *Store) SetModelOverrides(overrides map[modelTask][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.ModelOverrides = make(map[string][]string, len(overrides))
+	for task, models := range overrides {
+		s.data.ModelOverrides[string(task)] = models
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// ModelOverrides returns the stored /model overrides.
+func (s *Store) ModelOverrides() map[modelTask][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overrides := make(map[modelTask][]string, len(s.data.ModelOverrides))
+	for task, models := range s.data.ModelOverrides {
+		overrides[modelTask(task)] = models
+	}
+	return overrides
+}
+
+// handleModelCommand handles the admin /model command.
+func (b *Bot) handleModelCommand(chatID int64, args string) {
+	client, ok := b.llm.(*LLMClient)
+	if !ok {
+		b.sendMessage(chatID, "Models can't be changed with this LLM client.", nil)
+		return
+	}
+
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		b.sendMessage(chatID, client.modelsReport(), nil)
+		return
+	case len(fields) != 2:
+		b.sendMessage(chatID, modelUsage, nil)
+		return
+	}
+
+	task := modelTask(strings.ToLower(fields[0]))
+	known := false
+	for _, t := range modelTasks {
+		known = known || t == task
+	}
+	if !known {
+		b.sendMessage(chatID, fmt.Sprintf("I don't know a task called `%s`.\n\n%s", fields[0], modelUsage), nil)
+		return
+	}
+
+	var models []string
+	if !strings.EqualFold(fields[1], "reset") {
+		if models = parseModelList(fields[1], ""); models[0] == "" {
+			b.sendMessage(chatID, modelUsage, nil)
+			return
+		}
+	}
+	client.setModelOverride(task, models)
+
+	client.modelsMu.RLock()
+	overrides := make(map[modelTask][]string, len(client.overrides))
+	for t, m := range client.overrides {
+		overrides[t] = m
+	}
+	client.modelsMu.RUnlock()
+	b.store.SetModelOverrides(overrides)
+
+	log.Printf("Admin set the %s models to %v", task, models)
+	b.sendMessage(chatID, "✅ Done.\n\n"+client.modelsReport(), nil)
+}
+
+// sortedTasks lists the tasks with configured models, for the startup log.
+func sortedTasks(taskModels map[modelTask][]string) []string {
+	var tasks []string
+	for task, models := range taskModels {
+		tasks = append(tasks, fmt.Sprintf("%s=%v", task, models))
+	}
+	sort.Strings(tasks)
+	return tasks
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- PDF Lookbook Pages ---
+
+const (
+	// pdfRenderDPI is high enough for Gemini to see fabric detail without huge uploads.
+	pdfRenderDPI = 150
+	// pdfPageButtons is how many "Page N" buttons we offer; other pages can be typed.
+	pdfPageButtons = 10
+)
+
+// handleDocument handles files sent as documents rather than compressed photos.
+func (b *Bot) handleDocument(message *tgbotapi.Message) {
+	doc := message.Document
+	if doc.MimeType == "application/pdf" || strings.HasSuffix(strings.ToLower(doc.FileName), ".pdf") {
+		b.handlePDF(message)
+		return
+	}
+	if strings.HasPrefix(doc.MimeType, "image/") {
+		// An uncompressed photo; it goes through the same steps as a compressed one
+		if int64(doc.FileSize) > b.maxImageFileBytes {
+			b.sendMessage(message.Chat.ID, fmt.Sprintf("Sorry, that image is too large. Please send a file under %d MB, or send it as a photo.", b.maxImageFileBytes>>20), nil)
+			return
+		}
+		b.handlePhoto(message)
+		return
+	}
+	b.sendMessage(message.Chat.ID, "I can only read **photos** or **PDF** catalog pages. Please send one of those to get started.", nil)
+}
+
+// handlePDF downloads a PDF and either renders it straight away (one page)
+// or asks the user which page to caption.
+func (b *Bot) handlePDF(message *tgbotapi.Message) {
+	state := b.getState(message.From.ID)
+	doc := message.Document
+
+	if int64(doc.FileSize) > b.maxPDFBytes {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("Sorry, that PDF is too large. Please send a file under %d MB.", b.maxPDFBytes>>20), nil)
+		return
+	}
+
+	pdfData, _, err := b.downloadFile(doc.FileID, false)
+	if err != nil {
+		log.Printf("Error downloading PDF: %v", err)
+		b.sendMessage(message.Chat.ID, "Sorry, I had trouble downloading your PDF. Please try again.", nil)
+		return
+	}
+
+	pages, err := countPDFPages(pdfData)
+	if err != nil {
+		log.Printf("Error opening PDF: %v", err)
+		b.sendMessage(message.Chat.ID, "Sorry, I couldn't read that PDF. Please send a photo or screenshot of the page instead.", nil)
+		return
+	}
+	if pages > b.maxPDFPages {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("Sorry, that PDF has %d pages. Please send one with at most %d pages, or just the page you need.", pages, b.maxPDFPages), nil)
+		return
+	}
+
+	if pages == 1 {
+		b.startWithPDFPage(message.Chat.ID, state, pdfData, 1)
+		return
+	}
+
+	// Multi-page: keep the PDF in state and ask which page to use
+	state.PDFData = pdfData
+	state.PDFPages = pages
+	state.State = StateWaitingForPDFPage
+
+	msg := b.newMessage(message.Chat.ID, fmt.Sprintf("This PDF has %d pages. 📖 Which page should I caption?\n\nTap a page or type its number.", pages))
+	msg.ReplyMarkup = buildPDFPageKeyboard(pages)
+	if sentMsg, err := b.send(msg); err == nil {
+		state.MessageID = sentMsg.MessageID
+	}
+}
+
+// handlePDFPageReply handles a typed page number.
+func (b *Bot) handlePDFPageReply(message *tgbotapi.Message) {
+	state := b.getState(message.From.ID)
+
+	page, err := strconv.Atoi(strings.TrimSpace(message.Text))
+	if err != nil || page < 1 || page > state.PDFPages {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("Please enter a page number between 1 and %d, or /cancel.", state.PDFPages), nil)
+		return
+	}
+
+	b.removeInlineKeyboard(message.Chat.ID, state.MessageID)
+	b.startWithPDFPage(message.Chat.ID, state, state.PDFData, page)
+}
+
+// useCallbackPDFPage handles a tapped "Page N" button.
+func (b *Bot) useCallbackPDFPage(userID int64, state *userState, page int) {
+	if page < 1 || page > state.PDFPages {
+		return
+	}
+	b.removeInlineKeyboard(userID, state.MessageID)
+	b.startWithPDFPage(userID, state, state.PDFData, page)
+}
+
+// startWithPDFPage renders a page (1-based) and feeds it into the normal photo flow.
+func (b *Bot) startWithPDFPage(chatID int64, state *userState, pdfData []byte, page int) {
+	imageData, err := renderPDFPage(pdfData, page-1)
+	if err != nil {
+		log.Printf("Error rendering PDF page %d: %v", page, err)
+		b.sendMessage(chatID, "Sorry, I couldn't render that PDF page. Please send a photo or screenshot of it instead.", nil)
+		b.resetState(chatID)
+		return
+	}
+
+	state.PDFData = nil
+	state.PDFPages = 0
+	b.startWithImage(chatID, state, imageData, "image/jpeg",
+		fmt.Sprintf("Got page %d of your catalog! 📄", page))
+}
+
+// countPDFPages returns the number of pages in a PDF.
+func countPDFPages(pdfData []byte) (int, error) {
+	doc, err := fitz.NewFromMemory(pdfData)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+	return doc.NumPage(), nil
+}
+
+// renderPDFPage rasterizes a single page (0-based) to JPEG.
+func renderPDFPage(pdfData []byte, pageIndex int) ([]byte, error) {
+	doc, err := fitz.NewFromMemory(pdfData)
+	if err != nil {
+		return nil, fmt.Errorf("error opening PDF: %w", err)
+	}
+	defer doc.Close()
+
+	img, err := doc.ImageDPI(pageIndex, pdfRenderDPI)
+	if err != nil {
+		return nil, fmt.Errorf("error rasterizing page: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("error encoding page image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// buildPDFPageKeyboard offers a button for each of the first few pages.
+func buildPDFPageKeyboard(pages int) tgbotapi.InlineKeyboardMarkup {
+	if pages > pdfPageButtons {
+		pages = pdfPageButtons
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for i := 1; i <= pages; i++ {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(i), fmt.Sprintf("pdfpage:%d", i)))
+		if len(row) == 5 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
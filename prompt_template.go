@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// --- Caption Prompt Template Override ---
+
+// captionHashtagCount is how many hashtags we ask the model for.
+const captionHashtagCount = 15
+
+// captionPromptTemplate is loaded from CAPTION_PROMPT_TEMPLATE at startup.
+// When nil, buildCaptionSystemPrompt uses its built-in prompt.
+var captionPromptTemplate *template.Template
+
+// CaptionPromptData is the data available to a caption prompt template,
+// e.g. {{.Platform}}, {{.Tone}}, {{.ToneIntensity}}, {{.Services}}, {{.Context}}, {{.Brand}}, {{.BrandDescription}},
+// {{.ContactCTA}}, {{.HashtagCount}}.
+type CaptionPromptData struct {
+	Platform            string
+	PlatformInstruction string
+	Tone                string
+	ToneIntensity       string // e.g. "Apply the Luxury tone with subtle (...) intensity."
+	Services            string
+	Context             string
+	Brand               string
+	BrandDescription    string // The brand's description, or its name
+	ContactCTA          string // "" if the brand has none
+	HashtagCount        int
+}
+
+// loadCaptionPromptTemplate reads and validates a prompt template file.
+// It renders the template once with sample data so that typos such as
+// {{.Platfrom}} are reported at startup rather than on the first job.
+func loadCaptionPromptTemplate(path string) (*template.Template, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading prompt template: %w", err)
+	}
+
+	tmpl, err := template.New("caption-prompt").Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prompt template: %w", err)
+	}
+
+	sample := CaptionPromptData{
+		Platform:            "LinkedIn",
+		PlatformInstruction: "Optimize for LinkedIn.",
+		Tone:                "Professional",
+		ToneIntensity:       toneIntensityInstruction("Professional", ""),
+		Services:            "OEM, Bulk",
+		Context:             "None provided.",
+		Brand:               defaultBrand.Name,
+		BrandDescription:    defaultBrand.description(),
+		HashtagCount:        captionHashtagCount,
+	}
+	if _, err := renderCaptionPromptTemplate(tmpl, sample); err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderCaptionPromptTemplate executes the template with the given data.
+func renderCaptionPromptTemplate(tmpl *template.Template, data CaptionPromptData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
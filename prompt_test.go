@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptionPromptToneIntensity(t *testing.T) {
+	tests := []struct {
+		intensity string
+		want      string
+	}{
+		{"Subtle", "Apply the Luxury tone with subtle (light touch, understated) intensity."},
+		{"Balanced", "Apply the Luxury tone with balanced (clear but not overdone) intensity."},
+		{"Strong", "Apply the Luxury tone with strong (bold and unmistakable) intensity."},
+		{"", "Apply the Luxury tone with balanced (clear but not overdone) intensity."}, // Before intensity could be chosen
+	}
+	for _, tt := range tests {
+		t.Run(tt.intensity, func(t *testing.T) {
+			prompt := buildCaptionSystemPrompt(defaultBrand, "Instagram", "Luxury", tt.intensity, nil, "None provided.", nil, nil)
+			if !strings.Contains(prompt, "**Desired Tone:** Luxury. "+tt.want) {
+				t.Errorf("prompt for %q intensity is missing %q:\n%s", tt.intensity, tt.want, prompt)
+			}
+			if strings.Count(prompt, "intensity.") != 1 {
+				t.Error("prompt gives more than one intensity")
+			}
+		})
+	}
+}
+
+func TestCaptionPromptServiceSnippets(t *testing.T) {
+	brand := &BrandConfig{
+		Name: "Acme Denim",
+		Services: []ServiceOption{
+			{Key: "OEM", Label: "OEM / Private Label", Prompt: "we make it under your label"},
+			{Key: "Wash", Label: "Garment Washing"}, // No prompt: the label alone
+		},
+	}
+	tests := []struct {
+		name     string
+		services []string
+		want     string
+	}{
+		{"with a prompt", []string{"OEM"}, "**Services to Highlight:** OEM / Private Label: we make it under your label\n"},
+		{"label only", []string{"Wash"}, "**Services to Highlight:** Garment Washing\n"},
+		{"several", []string{"Wash", "OEM"}, "**Services to Highlight:** Garment Washing; OEM / Private Label: we make it under your label\n"},
+		{"unknown key", []string{"Dye"}, "**Services to Highlight:** Dye\n"},
+		{"none picked", nil, "**Services to Highlight:** our full range of manufacturing services\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := buildCaptionSystemPrompt(brand, "LinkedIn", "Professional", "", tt.services, "None provided.", nil, nil)
+			if !strings.Contains(prompt, tt.want) {
+				t.Errorf("prompt is missing %q:\n%s", tt.want, prompt)
+			}
+		})
+	}
+}
+
+func TestCaptionPromptRatedExamples(t *testing.T) {
+	b, _ := newTestBot(t)
+	b.ratedExamples = 2
+
+	// Seed ratings as if users had rated earlier results, a minute apart
+	ratedAt := time.Now().Add(-time.Hour)
+	rate := func(raterID int64, messageID int, platform, tone, text string, score int) {
+		ratedAt = ratedAt.Add(time.Minute)
+		ref := captionRef{Platform: platform, Tone: tone, Brand: defaultBrand.Name, Text: text}
+		b.store.SetRating(raterID, messageID, &Rating{UserID: raterID, MessageID: messageID, At: ratedAt, Caption: ref, Score: score})
+	}
+	rate(1, 1, "Instagram", "Professional", "Best loved caption", 1)
+	rate(2, 1, "Instagram", "Professional", "Best loved caption", 1)
+	rate(3, 1, "Instagram", "Professional", "Liked caption", 1)
+	rate(4, 1, "Instagram", "Professional", "Third liked caption", 1)
+	rate(5, 1, "Instagram", "Professional", "Disliked caption", -1)
+	rate(6, 1, "LinkedIn", "Professional", "Other platform caption", 1)
+	rate(7, 1, "Instagram", "Luxury", "Other tone caption", 1)
+
+	params := b.withRatedExamples((&userState{Platforms: []string{"Instagram"}, Tone: "Professional"}).generationParams(defaultOutputSettings))
+	prompt := buildCaptionSystemPrompt(params.Brand, "Instagram", params.Tone, "", nil, "None provided.", params.RatedExamples["Instagram"], nil)
+	_, section, found := strings.Cut(prompt, "**Highly-Rated Past Captions")
+	if !found {
+		t.Fatalf("prompt has no rated examples:\n%s", prompt)
+	}
+	// The top score first, then the most recently rated of the rest
+	if !strings.Contains(section, "---\nBest loved caption\n---\nThird liked caption\n---\n") {
+		t.Errorf("rated examples = %q, want the two best in order", section)
+	}
+	for _, unwanted := range []string{"Liked caption", "Disliked caption", "Other platform caption", "Other tone caption"} {
+		if strings.Contains(section, "\n"+unwanted+"\n") {
+			t.Errorf("rated examples include %q", unwanted)
+		}
+	}
+}
+
+func TestCaptionPromptStyleReference(t *testing.T) {
+	const reference = "Stitched in Dhaka, worn everywhere. 🌍 #madeinBD"
+	tests := []struct {
+		name     string
+		commands []string
+		want     bool
+	}{
+		{"set", []string{"/style " + reference}, true},
+		{"cleared", []string{"/style " + reference, "/style clear"}, false},
+		{"too long", []string{"/style " + strings.Repeat("a", maxStyleReferenceLength+1)}, false},
+		{"never set", nil, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			captionPrompts := captionGemini(t, b)
+			b.queue = newFairQueue(0)
+			b.queue.start(1)
+			userID := int64(1141 + i)
+
+			for _, command := range tt.commands {
+				b.handleCommand(textMessage(userID, command))
+			}
+			queueGeneration(t, b, userID)
+			flushQueue(b.queue)
+
+			prompts := captionPrompts()
+			if len(prompts) != 1 {
+				t.Fatalf("%d caption requests, want 1", len(prompts))
+			}
+			hasSection := strings.Contains(prompts[0], "**Reference Caption (the client's own past post):**")
+			if hasSection != tt.want || (tt.want && !strings.Contains(prompts[0], "---\n"+reference+"\n---\n")) {
+				t.Errorf("prompt has the reference section: %v, want %v:\n%s", hasSection, tt.want, prompts[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// --- LLM Providers ---
+
+// LLM providers (LLM_PROVIDER).
+const (
+	providerGemini    = "gemini"
+	providerOpenAI    = "openai"
+	providerAnthropic = "anthropic"
+	providerOllama    = "ollama"
+)
+
+// defaultProviderModels is the model used when no model list is configured.
+var defaultProviderModels = map[string]string{
+	providerGemini:    defaultGeminiModel,
+	providerOpenAI:    "gpt-4o-mini",
+	providerAnthropic: "claude-3-5-sonnet-latest",
+	providerOllama:    "llava",
+}
+
+const (
+	openAIURL        = "https://api.openai.com/v1/chat/completions"
+	anthropicURL     = "https://api.anthropic.com/v1/messages"
+	anthropicVersion = "2023-06-01"
+	defaultOllamaURL = "http://localhost:11434"
+
+	// anthropicMaxTokens bounds the reply; the API requires a limit.
+	anthropicMaxTokens = 4096
+)
+
+// errUnsupportedInput means the provider can't take this kind of input,
+// e.g. voice notes on anything but Gemini.
+var errUnsupportedInput = errors.New("this LLM provider does not accept this kind of input")
+
+// newModelProvider builds the provider selected in the configuration.
+// It fails only if GEMINI_AUTH=vertex and the Google credentials can't be loaded.
+func newModelProvider(cfg Config) (modelProvider, error) {
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	switch cfg.LLMProvider {
+	case providerOpenAI:
+		return &openAIProvider{apiKey: cfg.OpenAIKey, httpClient: httpClient}, nil
+	case providerAnthropic:
+		return &anthropicProvider{apiKey: cfg.AnthropicKey, httpClient: httpClient}, nil
+	case providerOllama:
+		// Local models can be much slower than hosted ones
+		return &ollamaProvider{baseURL: strings.TrimSuffix(cfg.OllamaURL, "/"), httpClient: &http.Client{Timeout: 5 * time.Minute}}, nil
+	}
+
+	if cfg.GeminiAuth != geminiAuthVertex {
+		return &geminiProvider{apiKey: cfg.GeminiKey, httpClient: httpClient}, nil
+	}
+	auth, err := newVertexAuth(cfg.GoogleCredentials, cloudPlatformScope, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	project := cfg.VertexProject
+	if project == "" {
+		project = auth.projectID()
+	}
+	if project == "" {
+		return nil, errors.New("VERTEX_PROJECT must be set for GEMINI_AUTH=vertex (the credentials don't name a project)")
+	}
+	return &geminiProvider{httpClient: httpClient, vertex: auth, vertexProject: project, vertexLocation: cfg.VertexLocation}, nil
+}
+
+// postJSON sends body to url and returns the response body. Error statuses
+// become an authError or modelUnavailableError where they mean that, so the
+// auth guard and model fallback work the same for every provider.
+func postJSON(ctx context.Context, httpClient *http.Client, url, model string, headers map[string]string, body any) ([]byte, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making API request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		logFrom(ctx).Warn("API error response", "model", model, "status", resp.StatusCode, "body", string(respBody))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, &authError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		}
+		// Anthropic answers "overloaded" with 529
+		if isModelUnavailableStatus(resp.StatusCode) || resp.StatusCode == 529 {
+			return nil, &modelUnavailableError{Model: model, StatusCode: resp.StatusCode, Body: string(respBody),
+				RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// requestText flattens a request's system instruction or turn into text.
+func requestText(parts []Part) string {
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// jsonSchema converts a Gemini response schema (upper-case types) into
+// standard JSON Schema for the other providers.
+func jsonSchema(s *Schema) map[string]any {
+	return jsonSchemaProperty(Property{Type: s.Type, Properties: s.Properties, Required: s.Required})
+}
+
+func jsonSchemaProperty(p Property) map[string]any {
+	out := map[string]any{"type": strings.ToLower(p.Type)}
+	if p.Items != nil {
+		out["items"] = jsonSchemaProperty(*p.Items)
+	}
+	if p.Properties != nil {
+		props := make(map[string]any, len(p.Properties))
+		for name, prop := range p.Properties {
+			props[name] = jsonSchemaProperty(prop)
+		}
+		out["properties"] = props
+	}
+	if len(p.Required) > 0 {
+		out["required"] = p.Required
+	}
+	if len(p.Enum) > 0 {
+		out["enum"] = p.Enum
+	}
+	return out
+}
+
+// jsonOnlyInstruction asks a provider without a schema-constrained JSON
+// mode to answer with bare JSON.
+func jsonOnlyInstruction(s *Schema) string {
+	schema, _ := json.Marshal(jsonSchema(s))
+	return "\n\nRespond with only a JSON object (no code fences, no commentary) matching this JSON Schema:\n" + string(schema)
+}
+
+// stripCodeFence removes a ```json fence a model may wrap its JSON in.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	return strings.TrimSpace(strings.TrimSuffix(text, "```"))
+}
+
+// logUsage logs the token usage of one call.
+func logUsage(ctx context.Context, provider, model string, usage UsageMetadata) {
+	logFrom(ctx).Info("Token usage", "provider", provider, "model", model,
+		"prompt_tokens", usage.PromptTokenCount, "candidate_tokens", usage.CandidatesTokenCount, "total_tokens", usage.TotalTokenCount)
+}
+
+// --- OpenAI ---
+
+// openAIProvider calls the OpenAI Chat Completions API (LLM_PROVIDER=openai).
+type openAIProvider struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+// callModel implements modelProvider.
+func (p *openAIProvider) callModel(ctx context.Context, model string, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	messages := []map[string]any{
+		{"role": "system", "content": requestText(requestBody.SystemInstruction.Parts)},
+	}
+	for _, c := range requestBody.Contents {
+		var content []map[string]any
+		for _, part := range c.Parts {
+			switch {
+			case part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/"):
+				content = append(content, map[string]any{
+					"type":      "image_url",
+					"image_url": map[string]string{"url": "data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data},
+				})
+			case part.InlineData != nil:
+				return "", UsageMetadata{}, errUnsupportedInput
+			default:
+				content = append(content, map[string]any{"type": "text", "text": part.Text})
+			}
+		}
+		messages = append(messages, map[string]any{"role": "user", "content": content})
+	}
+
+	body := map[string]any{"model": model, "messages": messages}
+	if cfg := requestBody.GenerationConfig; cfg.ResponseSchema != nil {
+		body["response_format"] = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "response", "schema": jsonSchema(cfg.ResponseSchema)},
+		}
+	}
+
+	raw, err := postJSON(ctx, p.httpClient, openAIURL, model, map[string]string{"Authorization": "Bearer " + p.apiKey}, body)
+	if err != nil {
+		return "", UsageMetadata{}, err
+	}
+
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+				Refusal string `json:"refusal"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return "", UsageMetadata{}, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	usage := UsageMetadata{
+		PromptTokenCount:     resp.Usage.PromptTokens,
+		CandidatesTokenCount: resp.Usage.CompletionTokens,
+		TotalTokenCount:      resp.Usage.TotalTokens,
+	}
+	logUsage(ctx, "OpenAI", model, usage)
+
+	if len(resp.Choices) == 0 {
+		return "", usage, fmt.Errorf("no content found in API response")
+	}
+	if refusal := resp.Choices[0].Message.Refusal; refusal != "" {
+		return "", usage, fmt.Errorf("prompt was refused: %s", refusal)
+	}
+	return resp.Choices[0].Message.Content, usage, nil
+}
+
+// --- Anthropic ---
+
+// anthropicProvider calls the Anthropic Messages API (LLM_PROVIDER=anthropic).
+// It has no schema-constrained JSON mode, so the schema goes in the prompt.
+type anthropicProvider struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+// callModel implements modelProvider.
+func (p *anthropicProvider) callModel(ctx context.Context, model string, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	system := requestText(requestBody.SystemInstruction.Parts)
+	schema := requestBody.GenerationConfig.ResponseSchema
+	if schema != nil {
+		system += jsonOnlyInstruction(schema)
+	}
+
+	var messages []map[string]any
+	for _, c := range requestBody.Contents {
+		var content []map[string]any
+		for _, part := range c.Parts {
+			switch {
+			case part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/"):
+				content = append(content, map[string]any{
+					"type":   "image",
+					"source": map[string]string{"type": "base64", "media_type": part.InlineData.MimeType, "data": part.InlineData.Data},
+				})
+			case part.InlineData != nil:
+				return "", UsageMetadata{}, errUnsupportedInput
+			default:
+				content = append(content, map[string]any{"type": "text", "text": part.Text})
+			}
+		}
+		messages = append(messages, map[string]any{"role": "user", "content": content})
+	}
+
+	body := map[string]any{
+		"model":      model,
+		"max_tokens": anthropicMaxTokens,
+		"system":     system,
+		"messages":   messages,
+	}
+	headers := map[string]string{"x-api-key": p.apiKey, "anthropic-version": anthropicVersion}
+	raw, err := postJSON(ctx, p.httpClient, anthropicURL, model, headers, body)
+	if err != nil {
+		return "", UsageMetadata{}, err
+	}
+
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return "", UsageMetadata{}, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	usage := UsageMetadata{
+		PromptTokenCount:     resp.Usage.InputTokens,
+		CandidatesTokenCount: resp.Usage.OutputTokens,
+		TotalTokenCount:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+	}
+	logUsage(ctx, "Anthropic", model, usage)
+
+	var sb strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", usage, fmt.Errorf("no content found in API response")
+	}
+	if schema != nil {
+		return stripCodeFence(sb.String()), usage, nil
+	}
+	return sb.String(), usage, nil
+}
+
+// --- Ollama ---
+
+// ollamaProvider calls a local Ollama server (LLM_PROVIDER=ollama). The
+// model must support images (e.g. llava) for photo requests.
+type ollamaProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// callModel implements modelProvider.
+func (p *ollamaProvider) callModel(ctx context.Context, model string, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	messages := []map[string]any{
+		{"role": "system", "content": requestText(requestBody.SystemInstruction.Parts)},
+	}
+	for _, c := range requestBody.Contents {
+		var images []string
+		for _, part := range c.Parts {
+			if part.InlineData == nil {
+				continue
+			}
+			if !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				return "", UsageMetadata{}, errUnsupportedInput
+			}
+			images = append(images, part.InlineData.Data)
+		}
+		message := map[string]any{"role": "user", "content": requestText(c.Parts)}
+		if len(images) > 0 {
+			message["images"] = images
+		}
+		messages = append(messages, message)
+	}
+
+	body := map[string]any{"model": model, "messages": messages, "stream": false}
+	if schema := requestBody.GenerationConfig.ResponseSchema; schema != nil {
+		body["format"] = jsonSchema(schema)
+	}
+
+	raw, err := postJSON(ctx, p.httpClient, p.baseURL+"/api/chat", model, nil, body)
+	if err != nil {
+		return "", UsageMetadata{}, err
+	}
+
+	var resp struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return "", UsageMetadata{}, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	usage := UsageMetadata{
+		PromptTokenCount:     resp.PromptEvalCount,
+		CandidatesTokenCount: resp.EvalCount,
+		TotalTokenCount:      resp.PromptEvalCount + resp.EvalCount,
+	}
+	logUsage(ctx, "Ollama", model, usage)
+
+	if resp.Message.Content == "" {
+		return "", usage, fmt.Errorf("no content found in API response")
+	}
+	return resp.Message.Content, usage, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Publishing to Social Networks ---
+
+// publishTimeout bounds one post, uploads included.
+const publishTimeout = 2 * time.Minute
+
+// publishRequest is one caption to publish, with the photo and the
+// hashtags generated for the caption's platform.
+type publishRequest struct {
+	Photo    []byte
+	MimeType string
+	Caption  string
+	Hashtags []string
+}
+
+// text is the caption with its hashtags on a line of their own.
+func (r publishRequest) text() string {
+	if len(r.Hashtags) == 0 {
+		return r.Caption
+	}
+	return r.Caption + "\n\n" + strings.Join(r.Hashtags, " ")
+}
+
+// publisher posts to one account the operator configured. It returns the
+// URL of the new post.
+type publisher interface {
+	publish(ctx context.Context, req publishRequest) (string, error)
+}
+
+// publishTarget is a configured publisher and its button.
+type publishTarget struct {
+	key       string // In the callback data, "publish:<key>"
+	name      string // Shown to the user, e.g. "Facebook"
+	button    string
+	publisher publisher
+}
+
+// newPublishTargets sets up the publishers whose settings are present.
+func newPublishTargets(cfg Config) []publishTarget {
+	client := &http.Client{Timeout: publishTimeout}
+	var targets []publishTarget
+	if cfg.FacebookPageID != "" {
+		targets = append(targets, publishTarget{
+			key: "facebook", name: "Facebook", button: "📘 Post to Facebook",
+			publisher: &facebookPublisher{pageID: cfg.FacebookPageID, token: cfg.FacebookPageToken, client: client},
+		})
+	}
+	if cfg.LinkedIn.Organization != "" {
+		targets = append(targets, publishTarget{
+			key: "linkedin", name: "LinkedIn", button: "💼 Post to LinkedIn",
+			publisher: &linkedinPublisher{
+				organization: cfg.LinkedIn.Organization,
+				client:       client,
+				token:        cfg.LinkedIn.AccessToken,
+				clientID:     cfg.LinkedIn.ClientID,
+				clientSecret: cfg.LinkedIn.ClientSecret,
+				refreshToken: cfg.LinkedIn.RefreshToken,
+			},
+		})
+	}
+	if cfg.X.APIKey != "" {
+		targets = append(targets, publishTarget{
+			key: "x", name: "X", button: "𝕏 Post to X",
+			publisher: &xPublisher{creds: cfg.X, client: client},
+		})
+	}
+	return targets
+}
+
+// canPublish reports whether a user may post to the configured accounts:
+// admins and PUBLISH_USERS.
+func (b *Bot) canPublish(userID int64) bool {
+	member := b.memberID(userID)
+	return b.isAdmin(member) || b.publishUsers[member]
+}
+
+// postRows are the buttons that post a caption elsewhere: the user's
+// channel, and the configured accounts if they may publish.
+func (b *Bot) postRows(userID int64) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if b.hasChannel(userID) {
+		rows = append(rows, channelPostMarkup.InlineKeyboard...)
+	}
+	if len(b.publishTargets) > 0 && b.canPublish(userID) {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, target := range b.publishTargets {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(target.button, "publish:"+target.key))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// photoFileName names an uploaded photo after its type, which some APIs
+// go by.
+func photoFileName(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return "product.png"
+	case "image/webp":
+		return "product.webp"
+	}
+	return "product.jpg"
+}
+
+// hashtagsFor returns the hashtags generated for a platform.
+func hashtagsFor(content *GeneratedContent, platform string) []string {
+	for _, result := range content.Results {
+		if result.Platform == platform {
+			return result.Hashtags
+		}
+	}
+	return nil
+}
+
+// handlePublishCallback handles the "Post to <network>" buttons.
+func (b *Bot) handlePublishCallback(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	state := b.getState(userID)
+
+	key := strings.TrimPrefix(query.Data, "publish:")
+	var target *publishTarget
+	for i := range b.publishTargets {
+		if b.publishTargets[i].key == key {
+			target = &b.publishTargets[i]
+		}
+	}
+	if target == nil || !b.canPublish(userID) {
+		b.sendMessage(userID, "Sorry, you can't publish there.", nil)
+		return
+	}
+	ref, ok := b.postedCaption(query, state)
+	if !ok || state.LastRequest == nil || len(state.LastRequest.PhotoData) == 0 {
+		b.sendMessage(userID, "Sorry, I only keep the photo of your latest result, so I can't post this one. Send the photo again to get new captions. 📸", nil)
+		return
+	}
+
+	req := publishRequest{
+		Photo:    state.LastRequest.PhotoData,
+		MimeType: state.LastRequest.MimeType,
+		Caption:  ref.Text,
+		Hashtags: hashtagsFor(state.LastResult, ref.Platform),
+	}
+	statusID := b.sendMessageID(userID, fmt.Sprintf("⏳ Posting to %s…", target.name), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	url, err := target.publisher.publish(ctx, req)
+	text := fmt.Sprintf("✅ Posted to %s: %s", target.name, url)
+	if err != nil {
+		log.Printf("Error posting to %s for user %d: %v", target.name, userID, err)
+		text = fmt.Sprintf("⚠️ Posting to %s failed: %v", target.name, err)
+	} else {
+		log.Printf("User %d posted to %s: %s", userID, target.name, url)
+	}
+	if statusID == 0 {
+		b.sendMessage(userID, text, nil)
+		return
+	}
+	b.editMessageID(userID, statusID, text, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // Register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Image Quality Pre-Check ---
+
+const (
+	// minBrightness and minContrast are on a 0-255 luminance scale.
+	minBrightness = 40
+	minContrast   = 20
+	// qualitySamples caps how many pixels we look at, so big photos stay cheap.
+	qualitySamples = 100_000
+)
+
+// QualityReport is the result of a cheap, local look at an image.
+type QualityReport struct {
+	Width, Height int
+	Brightness    float64 // Mean luminance, 0 (black) to 255 (white)
+	Contrast      float64 // Standard deviation of luminance
+	Issues        []string
+}
+
+// OK reports whether no problems were found.
+func (r QualityReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// assessImageQuality decodes an image and flags obvious problems:
+// a resolution under minSide, a mostly dark image, or very low contrast.
+func assessImageQuality(data []byte, minSide int) (QualityReport, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return QualityReport{}, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	report := QualityReport{Width: bounds.Dx(), Height: bounds.Dy()}
+	if report.Width == 0 || report.Height == 0 {
+		return report, fmt.Errorf("image has no pixels")
+	}
+
+	// Sample on a grid so we look at roughly qualitySamples pixels
+	step := int(math.Sqrt(float64(report.Width*report.Height) / qualitySamples))
+	if step < 1 {
+		step = 1
+	}
+
+	var sum, sumSq float64
+	var n int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			lum := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			sum += lum
+			sumSq += lum * lum
+			n++
+		}
+	}
+	report.Brightness = sum / float64(n)
+	report.Contrast = math.Sqrt(math.Max(0, sumSq/float64(n)-report.Brightness*report.Brightness))
+
+	if report.Width < minSide || report.Height < minSide {
+		report.Issues = append(report.Issues, fmt.Sprintf("This image is only %d×%d", report.Width, report.Height))
+	}
+	if report.Brightness < minBrightness {
+		report.Issues = append(report.Issues, "It looks very dark")
+	}
+	if report.Contrast < minContrast {
+		report.Issues = append(report.Issues, "It has very little contrast")
+	}
+	return report, nil
+}
+
+// confirmLowQuality saves the photo and asks whether to go ahead despite the issues.
+func (b *Bot) confirmLowQuality(chatID int64, state *userState, imageData []byte, mimeType string, report QualityReport) {
+	state.PhotoData = imageData
+	state.MimeType = mimeType
+	state.State = StateWaitingForQualityConfirm
+
+	msgText := "⚠️ " + strings.Join(report.Issues, ". ") + " — results may be low quality. Continue?"
+	msg := b.newMessage(chatID, msgText)
+	msg.ReplyMarkup = qualityKeyboard
+	if sentMsg, err := b.send(msg); err == nil {
+		state.MessageID = sentMsg.MessageID
+	}
+}
+
+var qualityKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Continue anyway", "quality:proceed"),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Cancel", "quality:cancel"),
+	),
+)
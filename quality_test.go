@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// solidJPEG is a JPEG of a single grey level.
+func solidJPEG(t *testing.T, width, height int, grey uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = grey
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encoding test JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAssessImageQuality(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		wantIssues []string
+	}{
+		{"normal photo", testJPEG(t, 800, 600), nil},
+		{"tiny photo", testJPEG(t, 120, 90), []string{"only 120×90"}},
+		{"one short side", testJPEG(t, 1200, 300), []string{"only 1200×300"}},
+		{"dark photo", solidJPEG(t, 800, 800, 10), []string{"very dark", "little contrast"}},
+		{"flat grey photo", solidJPEG(t, 800, 800, 128), []string{"little contrast"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := assessImageQuality(tt.data, defaultImageRules.MinSide)
+			if err != nil {
+				t.Fatalf("assessImageQuality: %v", err)
+			}
+			if report.OK() != (len(tt.wantIssues) == 0) || len(report.Issues) != len(tt.wantIssues) {
+				t.Fatalf("issues = %q, want ones mentioning %q", report.Issues, tt.wantIssues)
+			}
+			for i, want := range tt.wantIssues {
+				if !strings.Contains(report.Issues[i], want) {
+					t.Errorf("issue %d = %q, want it to mention %q", i, report.Issues[i], want)
+				}
+			}
+		})
+	}
+
+	if _, err := assessImageQuality([]byte("not an image"), defaultImageRules.MinSide); err == nil {
+		t.Error("assessImageQuality accepted data that isn't an image")
+	}
+}
+
+func TestLowQualityPhotoAsksFirst(t *testing.T) {
+	b, fake := newTestBot(t)
+	const userID = 1105
+	photo := testJPEG(t, 120, 90)
+	report, _ := assessImageQuality(photo, defaultImageRules.MinSide)
+
+	state := b.getState(userID)
+	b.confirmLowQuality(userID, state, photo, "image/jpeg", report)
+	if state.State != StateWaitingForQualityConfirm {
+		t.Fatalf("state = %v, want the quality question", state.State)
+	}
+	if texts := fake.Texts(userID); len(texts) != 1 || !strings.Contains(texts[0], "120×90") {
+		t.Errorf("messages = %q, want one naming the size", texts)
+	}
+
+	b.handleCallbackQuery(callbackQuery(userID, "quality:proceed"))
+	if state.State != StateWaitingForPlatform || len(state.PhotoData) == 0 {
+		t.Errorf("after continuing, state = %v with %d bytes of photo, want the platform question", state.State, len(state.PhotoData))
+	}
+}
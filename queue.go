@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// --- Fair Job Queue ---
+
+// errQueueFull is returned when a user already has too many jobs waiting.
+var errQueueFull = errors.New("too many jobs queued for this user")
+
+// fairQueue runs jobs on a fixed pool of workers, taking turns between users
+// (round-robin). A user who queues many jobs only gets one slot per turn, so
+// someone else's single request is never stuck behind their whole backlog.
+type fairQueue struct {
+	mu         sync.Mutex
+	cond       *sync.Cond            // Signalled when a job is queued
+	idle       *sync.Cond            // Broadcast when a job finishes or is removed, for drained
+	pending    map[int64][]queuedJob // Waiting jobs per user, oldest first
+	turns      []int64               // Users with waiting jobs, in round-robin order
+	maxPerUser int                   // Most waiting jobs per user; 0 means no limit
+	nextID     uint64
+	workers    int
+	running    int
+
+	// onDequeue, if set, is called whenever a job leaves the queue (to run
+	// or because it was removed), so waiting users' positions can be updated.
+	onDequeue func()
+}
+
+// queuedJob is a waiting job with the ID enqueue handed out for it.
+type queuedJob struct {
+	id  uint64
+	run func()
+}
+
+// newFairQueue creates an empty queue. Call start to launch the workers.
+func newFairQueue(maxPerUser int) *fairQueue {
+	q := &fairQueue{
+		pending:    make(map[int64][]queuedJob),
+		maxPerUser: maxPerUser,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+	return q
+}
+
+// start launches the worker goroutines.
+func (q *fairQueue) start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	q.mu.Lock()
+	q.workers = workers
+	q.mu.Unlock()
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+}
+
+// submit queues a job for a user, or returns errQueueFull.
+func (q *fairQueue) submit(userID int64, job func()) error {
+	_, err := q.enqueue(userID, job)
+	return err
+}
+
+// enqueue is submit, also returning an ID for position and remove.
+func (q *fairQueue) enqueue(userID int64, job func()) (uint64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.pending[userID]
+	if q.maxPerUser > 0 && len(jobs) >= q.maxPerUser {
+		return 0, errQueueFull
+	}
+	if len(jobs) == 0 {
+		q.turns = append(q.turns, userID)
+	}
+	q.nextID++
+	q.pending[userID] = append(jobs, queuedJob{id: q.nextID, run: job})
+	q.cond.Signal()
+	return q.nextID, nil
+}
+
+// position returns a waiting job's place in line (1 = runs next), or 0 if
+// it is no longer waiting. Users take turns, so the jobs ahead of a user's
+// n-th job are their own earlier ones, up to n from each user whose turn
+// comes first, and up to n-1 from each user whose turn comes after.
+func (q *fairQueue) position(id uint64) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for turn, userID := range q.turns {
+		for i, job := range q.pending[userID] {
+			if job.id != id {
+				continue
+			}
+			n := i + 1
+			ahead := i
+			for otherTurn, otherID := range q.turns {
+				if otherID == userID {
+					continue
+				}
+				if otherTurn < turn {
+					ahead += min(len(q.pending[otherID]), n)
+				} else {
+					ahead += min(len(q.pending[otherID]), n-1)
+				}
+			}
+			return ahead + 1
+		}
+	}
+	return 0
+}
+
+// busy reports whether every worker is running a job, so new jobs must wait.
+func (q *fairQueue) busy() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.running >= q.workers
+}
+
+// size returns the number of workers, for wait estimates.
+func (q *fairQueue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return max(q.workers, 1)
+}
+
+// remove drops a waiting job. It returns false if the job already started.
+func (q *fairQueue) remove(id uint64) bool {
+	q.mu.Lock()
+	removed := false
+	for turn, userID := range q.turns {
+		jobs := q.pending[userID]
+		for i, job := range jobs {
+			if job.id != id {
+				continue
+			}
+			jobs = append(jobs[:i:i], jobs[i+1:]...)
+			if len(jobs) == 0 {
+				delete(q.pending, userID)
+				q.turns = append(q.turns[:turn:turn], q.turns[turn+1:]...)
+			} else {
+				q.pending[userID] = jobs
+			}
+			removed = true
+			q.idle.Broadcast()
+			break
+		}
+		if removed {
+			break
+		}
+	}
+	onDequeue := q.onDequeue
+	q.mu.Unlock()
+
+	if removed && onDequeue != nil {
+		onDequeue()
+	}
+	return removed
+}
+
+// next blocks until a job is available and returns it with its user. The
+// user whose turn it is gets their oldest job run, then goes to the back of
+// the line. The caller counts as running until it calls done.
+func (q *fairQueue) next() (int64, func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.turns) == 0 {
+		q.cond.Wait()
+	}
+
+	userID := q.turns[0]
+	q.turns = q.turns[1:]
+
+	jobs := q.pending[userID]
+	job := jobs[0]
+	if len(jobs) > 1 {
+		q.pending[userID] = jobs[1:]
+		q.turns = append(q.turns, userID)
+	} else {
+		delete(q.pending, userID)
+	}
+	q.running++
+	return userID, job.run
+}
+
+// done marks a job returned by next as finished.
+func (q *fairQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.idle.Broadcast()
+}
+
+// drained returns a channel that is closed once no job is waiting or
+// running, for a graceful shutdown.
+func (q *fairQueue) drained() <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		q.mu.Lock()
+		for q.running > 0 || len(q.turns) > 0 {
+			q.idle.Wait()
+		}
+		q.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// work runs jobs forever.
+func (q *fairQueue) work() {
+	for {
+		userID, job := q.next()
+		if q.onDequeue != nil {
+			q.onDequeue()
+		}
+		q.run(userID, job)
+	}
+}
+
+// run runs one job. A job that panics is logged as an error, which also
+// reports it to ERROR_REPORT_CHAT, and still counts as done, so the worker
+// carries on and drained still closes at shutdown.
+func (q *fairQueue) run(userID int64, job func()) {
+	defer q.done()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Queued job panicked", "user_id", userID, "error", fmt.Sprint(r), "stack", string(debug.Stack()))
+		}
+	}()
+	job()
+}
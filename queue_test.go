@@ -0,0 +1,160 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jobLog records the order jobs ran in.
+type jobLog struct {
+	mu  sync.Mutex
+	wg  sync.WaitGroup
+	ran []string
+}
+
+// job returns a job that logs label when it runs.
+func (l *jobLog) job(label string) func() {
+	l.wg.Add(1)
+	return func() {
+		defer l.wg.Done()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.ran = append(l.ran, label)
+	}
+}
+
+// order waits for every job and returns the order they ran in.
+func (l *jobLog) order() []string {
+	l.wg.Wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.ran)
+}
+
+// flushQueue waits for the jobs queued so far. It assumes a single worker
+// and at most one job per user, so they all run before the one it adds.
+func flushQueue(q *fairQueue) {
+	done := make(chan struct{})
+	q.submit(-1, func() { close(done) })
+	<-done
+}
+
+func TestFairQueueTakesTurns(t *testing.T) {
+	q := newFairQueue(0)
+	var log jobLog
+
+	// User 1 queues a backlog before user 2's single job arrives
+	for _, label := range []string{"a1", "a2", "a3", "a4", "a5"} {
+		if err := q.submit(1, log.job(label)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.submit(2, log.job("b1")); err != nil {
+		t.Fatal(err)
+	}
+
+	q.start(1)
+	if got, want := log.order(), []string{"a1", "b1", "a2", "a3", "a4", "a5"}; !slices.Equal(got, want) {
+		t.Errorf("ran %v, want %v: the second user waits for one job, not the whole backlog", got, want)
+	}
+}
+
+func TestFairQueueLimitsWaitingJobsPerUser(t *testing.T) {
+	q := newFairQueue(2)
+	for i := 0; i < 2; i++ {
+		if err := q.submit(1, func() {}); err != nil {
+			t.Fatalf("job %d: %v", i+1, err)
+		}
+	}
+	if err := q.submit(1, func() {}); err != errQueueFull {
+		t.Errorf("third job: err = %v, want errQueueFull", err)
+	}
+	if err := q.submit(2, func() {}); err != nil {
+		t.Errorf("another user's job refused: %v", err)
+	}
+}
+
+func TestFairQueuePosition(t *testing.T) {
+	q := newFairQueue(0)
+	var log jobLog
+	ids := make(map[string]uint64)
+	for _, job := range []struct {
+		userID int64
+		label  string
+	}{{1, "a1"}, {1, "a2"}, {1, "a3"}, {2, "b1"}, {2, "b2"}, {3, "c1"}} {
+		// b1 is removed below, so it must never run
+		run := func() { t.Error("removed job b1 ran") }
+		if job.label != "b1" {
+			run = log.job(job.label)
+		}
+		id, err := q.enqueue(job.userID, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[job.label] = id
+	}
+
+	// Every position matches where the job really runs
+	order := []string{"a1", "b1", "c1", "a2", "b2", "a3"}
+	for i, label := range order {
+		if got := q.position(ids[label]); got != i+1 {
+			t.Errorf("position(%s) = %d, want %d", label, got, i+1)
+		}
+	}
+
+	// Removing a job moves everyone behind it up
+	if !q.remove(ids["b1"]) {
+		t.Fatal("remove(b1) = false for a waiting job")
+	}
+	if got := q.position(ids["b1"]); got != 0 {
+		t.Errorf("position of a removed job = %d, want 0", got)
+	}
+	order = []string{"a1", "b2", "c1", "a2", "a3"}
+	for i, label := range order {
+		if got := q.position(ids[label]); got != i+1 {
+			t.Errorf("after remove, position(%s) = %d, want %d", label, got, i+1)
+		}
+	}
+
+	q.start(1)
+	if got := log.order(); !slices.Equal(got, order) {
+		t.Errorf("ran %v, want the order positions promised, %v", got, order)
+	}
+	if got := q.position(ids["a3"]); got != 0 {
+		t.Errorf("position of a finished job = %d, want 0", got)
+	}
+}
+
+func TestFairQueueSurvivesPanickingJob(t *testing.T) {
+	reports := make(chan string, 1)
+	reporter := &errorReporter{chatID: 1, send: func(_ int64, text string) { reports <- text }, recent: make(map[string]*reportedError)}
+	old := slog.Default()
+	slog.SetDefault(slog.New(&reportingHandler{Handler: slog.NewTextHandler(io.Discard, nil), reporter: reporter}))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	q := newFairQueue(0)
+	q.start(1)
+	q.submit(7, func() { panic("boom") })
+	flushQueue(q) // The worker carries on with the next job
+
+	select {
+	case <-q.drained():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queue never drained after a job panicked")
+	}
+	select {
+	case report := <-reports:
+		for _, want := range []string{"Queued job panicked", "User: 7", "boom"} {
+			if !strings.Contains(report, want) {
+				t.Errorf("report = %q, want it to mention %q", report, want)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the panic wasn't reported")
+	}
+}
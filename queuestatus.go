@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// --- Queue Position & Wait Estimate ---
+
+// latencySmoothing weighs each new generation time in the moving average.
+const latencySmoothing = 0.2
+
+// durationAverage is an exponential moving average of job durations.
+type durationAverage struct {
+	mu  sync.Mutex
+	avg time.Duration // Zero until the first sample
+}
+
+// add folds one job's duration into the average.
+func (a *durationAverage) add(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.avg == 0 {
+		a.avg = d
+		return
+	}
+	a.avg = time.Duration(latencySmoothing*float64(d) + (1-latencySmoothing)*float64(a.avg))
+}
+
+// get returns the average, or 0 before any job has finished.
+func (a *durationAverage) get() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.avg
+}
+
+// estimateWait guesses how long until the job at position starts: the
+// workers clear about one job each per average duration.
+func estimateWait(position, workers int, avg time.Duration) time.Duration {
+	if position <= 0 || avg <= 0 {
+		return 0
+	}
+	rounds := math.Ceil(float64(position) / float64(max(workers, 1)))
+	return time.Duration(rounds) * avg
+}
+
+// queueStatusText describes a waiting job's place in line, e.g.
+// "⏳ You're #3 in the queue, about 25s.", or "" if it isn't waiting.
+func queueStatusText(position, workers int, avg time.Duration) string {
+	if position <= 0 {
+		return ""
+	}
+	text := fmt.Sprintf("⏳ You're #%d in the queue", position)
+	if wait := estimateWait(position, workers, avg); wait > 0 {
+		text += ", about " + wait.Round(5*time.Second).String()
+	}
+	return text + "."
+}
+
+// queuedJobInfo is a generation waiting in the queue, with the position
+// last shown on its "thinking" message.
+type queuedJobInfo struct {
+	id       uint64
+	position int
+}
+
+// thinkingText is the "thinking" message for a job at the given position.
+func (b *Bot) thinkingText(position int) string {
+	text := "Got it! ✨ " + string(StageAnalyzing)
+	if status := queueStatusText(position, b.queue.size(), b.latency.get()); status != "" {
+		text += "\n\n" + status
+	}
+	return text
+}
+
+// submitGeneration queues a generation job and remembers it so its
+// position can be kept up to date. It returns the job's position, or 0 if
+// a worker is free to start it straight away.
+func (b *Bot) submitGeneration(key jobKey, job func()) (int, error) {
+	// Hold jobsMu across enqueue, so the job can't start (and untrack
+	// itself) before it is tracked
+	b.jobsMu.Lock()
+	id, err := b.queue.enqueue(key.userID, job)
+	if err != nil {
+		b.jobsMu.Unlock()
+		return 0, err
+	}
+	position := 0
+	if b.queue.busy() {
+		position = b.queue.position(id)
+	}
+	b.queued[key] = queuedJobInfo{id: id, position: position}
+	b.jobsMu.Unlock()
+	return position, nil
+}
+
+// untrackQueuedJob forgets a queued generation once it starts or is
+// cancelled, and removes it from the queue if it hasn't started yet.
+// It returns the position last shown on the job's message (0 if none).
+func (b *Bot) untrackQueuedJob(key jobKey) int {
+	b.jobsMu.Lock()
+	info, ok := b.queued[key]
+	delete(b.queued, key)
+	b.jobsMu.Unlock()
+
+	if !ok {
+		return 0
+	}
+	b.queue.remove(info.id)
+	return info.position
+}
+
+// refreshQueuePositions edits the thinking message of every waiting job
+// whose position changed. It runs whenever a job leaves the queue.
+func (b *Bot) refreshQueuePositions() {
+	type update struct {
+		key      jobKey
+		position int
+	}
+	var updates []update
+
+	b.jobsMu.Lock()
+	for key, info := range b.queued {
+		position := b.queue.position(info.id)
+		if position == 0 || position == info.position {
+			continue // Started (runGeneration takes over the message) or unchanged
+		}
+		info.position = position
+		b.queued[key] = info
+		updates = append(updates, update{key, position})
+	}
+	b.jobsMu.Unlock()
+
+	// Talk to Telegram outside the lock
+	for _, u := range updates {
+		b.editMessageID(u.key.userID, u.key.thinkingMsgID, b.thinkingText(u.position), cancelGenKeyboard)
+	}
+}
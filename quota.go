@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// --- Per-User Rate Limit & Daily Quota ---
+
+// QuotaConfig limits how fast and how much each user can generate.
+type QuotaConfig struct {
+	PerMinute float64 // Token bucket refill rate in generations per minute; 0 turns it off
+	Burst     int     // Bucket size: generations allowed back to back
+	Daily     int     // Generations per user per day, one per platform; 0 means unlimited
+}
+
+// tokenBucket is one user's rate limit state.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// dailyCount is one user's generations on one day.
+type dailyCount struct {
+	day   string // usageDay of the count
+	count int
+}
+
+// quotaLimiter enforces QuotaConfig. Its state is kept in memory, so the
+// counts start afresh when the bot restarts.
+type quotaLimiter struct {
+	cfg      QuotaConfig
+	location *time.Location // The day resets at midnight here
+
+	mu      sync.Mutex
+	buckets map[int64]*tokenBucket
+	daily   map[int64]*dailyCount
+}
+
+func newQuotaLimiter(cfg QuotaConfig, location *time.Location) *quotaLimiter {
+	return &quotaLimiter{
+		cfg:      cfg,
+		location: location,
+		buckets:  make(map[int64]*tokenBucket),
+		daily:    make(map[int64]*dailyCount),
+	}
+}
+
+// quotaExceededError says which limit was hit and when it lifts.
+type quotaExceededError struct {
+	Daily   bool // The daily cap, rather than the rate limit
+	Limit   int  // The daily cap, if Daily
+	ResetAt time.Time
+}
+
+func (e *quotaExceededError) Error() string {
+	if e.Daily {
+		return fmt.Sprintf("daily limit of %d generations reached until %s", e.Limit, e.ResetAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("rate limited until %s", e.ResetAt.Format(time.RFC3339))
+}
+
+// bucket returns the user's bucket, refilled up to now. The caller must
+// hold q.mu.
+func (q *quotaLimiter) bucket(userID int64, now time.Time) *tokenBucket {
+	b, ok := q.buckets[userID]
+	if !ok {
+		b = &tokenBucket{tokens: float64(q.cfg.Burst), updated: now}
+		q.buckets[userID] = b
+	}
+	b.tokens = min(float64(q.cfg.Burst), b.tokens+now.Sub(b.updated).Minutes()*q.cfg.PerMinute)
+	b.updated = now
+	return b
+}
+
+// dailyUsed returns the user's generations today. The caller must hold q.mu.
+func (q *quotaLimiter) dailyUsed(userID int64, now time.Time) *dailyCount {
+	day := usageDay(now.In(q.location))
+	d, ok := q.daily[userID]
+	if !ok || d.day != day {
+		d = &dailyCount{day: day}
+		q.daily[userID] = d
+	}
+	return d
+}
+
+// check reports whether the user may start a job of n generations now,
+// without using up any quota. It returns nil if they may.
+func (q *quotaLimiter) check(userID int64, n int) *quotaExceededError {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if q.cfg.Daily > 0 && q.dailyUsed(userID, now).count+n > q.cfg.Daily {
+		local := now.In(q.location)
+		midnight := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, q.location)
+		return &quotaExceededError{Daily: true, Limit: q.cfg.Daily, ResetAt: midnight}
+	}
+	if q.cfg.PerMinute > 0 {
+		if b := q.bucket(userID, now); b.tokens < 1 {
+			wait := time.Duration((1 - b.tokens) / q.cfg.PerMinute * float64(time.Minute))
+			return &quotaExceededError{ResetAt: now.Add(wait)}
+		}
+	}
+	return nil
+}
+
+// consume uses up one rate limit token and n daily generations. A batch is
+// one job but counts each photo against the daily cap.
+func (q *quotaLimiter) consume(userID int64, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if q.cfg.Daily > 0 {
+		q.dailyUsed(userID, now).count += n
+	}
+	if q.cfg.PerMinute > 0 {
+		q.bucket(userID, now).tokens--
+	}
+}
+
+// remainingToday returns how many generations the user has left today, and
+// false if there is no daily limit.
+func (q *quotaLimiter) remainingToday(userID int64) (int, bool) {
+	if q.cfg.Daily <= 0 {
+		return 0, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return max(0, q.cfg.Daily-q.dailyUsed(userID, time.Now()).count), true
+}
+
+// quotaMessage tells the user which limit they hit and when to try again.
+func (b *Bot) quotaMessage(err *quotaExceededError) string {
+	wait := time.Until(err.ResetAt).Round(time.Second)
+	if err.Daily {
+		return fmt.Sprintf("⏳ You've reached your limit of %d generations for today. It resets at %s (in %s). Use /same to pick up where you left off then.",
+			err.Limit, err.ResetAt.In(b.location).Format("15:04"), wait.Round(time.Minute))
+	}
+	return fmt.Sprintf("⏳ You're going a little fast! You can generate again in %s. Use /same to pick up where you left off then.", max(wait, time.Second))
+}
+
+// quotaBlocked checks the limits before a job of n generations and, if one
+// is hit, tells the user and returns true. Admins are exempt.
+func (b *Bot) quotaBlocked(userID int64, n int) bool {
+	if b.isAdmin(b.memberID(userID)) {
+		return false
+	}
+	exceeded := b.quota.check(b.memberID(userID), n)
+	if exceeded == nil {
+		return false
+	}
+	log.Printf("User %d hit a quota: %v", userID, exceeded)
+	b.sendMessage(userID, b.quotaMessage(exceeded), nil)
+	return true
+}
+
+// useQuota counts a queued job of n generations against the user's limits.
+func (b *Bot) useQuota(userID int64, n int) {
+	if member := b.memberID(userID); !b.isAdmin(member) {
+		b.quota.consume(member, n)
+	}
+}
+
+// generationCount is how many generations a job counts against the daily
+// limit: one per platform, for each photo of a batch.
+func generationCount(state *userState) int {
+	return max(len(state.Batch), 1) * max(len(state.Platforms), 1)
+}
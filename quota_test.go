@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaCountsPlatformsAndFollowUps(t *testing.T) {
+	b, fake := newTestBot(t)
+	captionGemini(t, b)
+	b.quota, b.location = newQuotaLimiter(QuotaConfig{Daily: 4}, time.UTC), time.UTC
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+	const userID = 1263
+
+	state := b.getState(userID)
+	state.PhotoData, state.MimeType = testJPEG(t, 600, 600), "image/jpeg"
+	state.Platforms = []string{"Instagram", "LinkedIn", "X"}
+	b.generateContent(userID)
+	flushQueue(b.queue)
+	if left, _ := b.quota.remainingToday(userID); left != 1 {
+		t.Fatalf("%d generations left after three platforms, want 1", left)
+	}
+
+	// The explanation takes the last one, so the edit is refused
+	b.handleExplainCallback(userID)
+	flushQueue(b.queue)
+	b.handleRefinement(textMessage(userID, "shorter"), b.getState(userID))
+	flushQueue(b.queue)
+	if left, _ := b.quota.remainingToday(userID); left != 0 {
+		t.Errorf("%d generations left after the explanation, want 0", left)
+	}
+	if texts := fake.Texts(userID); len(messagesWith(texts, "limit of 4 generations")) != 1 {
+		t.Errorf("messages = %q, want the edit refused with the daily limit", texts)
+	}
+
+	// A job that doesn't fit in what's left isn't started
+	b.quota = newQuotaLimiter(QuotaConfig{Daily: 2}, time.UTC)
+	state = b.getState(userID)
+	state.PhotoData, state.MimeType = testJPEG(t, 600, 600), "image/jpeg"
+	state.Platforms = []string{"Instagram", "LinkedIn", "X"}
+	b.generateContent(userID)
+	if b.hasActiveJob(userID) {
+		t.Error("three platforms were queued with two generations left")
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Caption Ratings ---
+
+const (
+	// maxTrackedResultMessages caps how many caption messages we remember per
+	// user for mapping reactions back to captions; older ones are forgotten.
+	maxTrackedResultMessages = 200
+
+	// maxRatings caps the stored ratings; the oldest are dropped first.
+	maxRatings = 10000
+
+	// maxRatedExamples caps RATED_EXAMPLES, so past captions never crowd out
+	// the brand's own examples.
+	maxRatedExamples = 2
+)
+
+// Rating sources.
+const (
+	ratingSourceReaction = "reaction" // A 👍/👎/❤️ reaction on the caption message
+	ratingSourceButton   = "button"   // The 👍/👎 buttons under the caption
+)
+
+// ratingRow is the 👍/👎 row under each caption.
+var ratingRow = tgbotapi.NewInlineKeyboardRow(
+	tgbotapi.NewInlineKeyboardButtonData("👍", "rate:up"),
+	tgbotapi.NewInlineKeyboardButtonData("👎", "rate:down"),
+)
+
+// reactionScores maps the reactions we count to a rating; others are ignored.
+var reactionScores = map[string]int{
+	"👍":  1,
+	"❤":  1,
+	"❤️": 1,
+	"🔥":  1,
+	"👎":  -1,
+}
+
+// captionRef identifies which caption a sent message showed, with enough
+// context to reuse well-rated captions as examples.
+type captionRef struct {
+	Platform string `json:"platform"`
+	Option   int    `json:"option"` // Zero-based index into the platform's captions
+	Style    string `json:"style,omitempty"`
+	Tone     string `json:"tone,omitempty"`
+	Brand    string `json:"brand,omitempty"`
+	Language string `json:"language,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+// Rating is one user's verdict on one caption message.
+type Rating struct {
+	UserID    int64      `json:"userId"`
+	MessageID int        `json:"messageId"`
+	At        time.Time  `json:"at"`
+	Caption   captionRef `json:"caption"`
+	Score     int        `json:"score"` // +1 or -1
+	Source    string     `json:"source"`
+}
+
+// captionRefFor describes caption n of the r-th platform's results.
+func captionRefFor(content *GeneratedContent, r, n int) captionRef {
+	result := content.Results[r]
+	ref := captionRef{
+		Platform: result.Platform,
+		Option:   n,
+		Tone:     content.Tone,
+		Brand:    content.Brand,
+		Language: content.Language,
+		Text:     result.Captions[n],
+	}
+	if n < len(result.Styles) {
+		ref.Style = result.Styles[n]
+	}
+	return ref
+}
+
+// TrackResultMessage remembers which caption a sent message shows.
+func (s *Store) TrackResultMessage(userID int64, messageID int, ref captionRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs, ok := s.data.ResultMessages[userID]
+	if !ok {
+		msgs = make(map[int]captionRef)
+		s.data.ResultMessages[userID] = msgs
+	}
+	msgs[messageID] = ref
+
+	// Message IDs grow over time within a chat, so the lowest are the oldest
+	if len(msgs) > maxTrackedResultMessages {
+		ids := make([]int, 0, len(msgs))
+		for id := range msgs {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, id := range ids[:len(ids)-maxTrackedResultMessages] {
+			delete(msgs, id)
+		}
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving result message: %v", err)
+	}
+}
+
+// ResultMessage returns the caption a tracked message shows.
+func (s *Store) ResultMessage(userID int64, messageID int) (captionRef, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ref, ok := s.data.ResultMessages[userID][messageID]
+	return ref, ok
+}
+
+// SetRating records a user's rating of a message, replacing any earlier one.
+// A nil rating removes it (e.g. the reaction was taken back).
+func (s *Store) SetRating(userID int64, messageID int, rating *Rating) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.data.Ratings[:0]
+	for _, r := range s.data.Ratings {
+		if r.UserID != userID || r.MessageID != messageID {
+			kept = append(kept, r)
+		}
+	}
+	if rating != nil {
+		kept = append(kept, *rating)
+	}
+	if len(kept) > maxRatings {
+		kept = kept[len(kept)-maxRatings:]
+	}
+	s.data.Ratings = kept
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving rating: %v", err)
+	}
+}
+
+// Ratings returns a copy of all stored ratings.
+func (s *Store) Ratings() []Rating {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Rating(nil), s.data.Ratings...)
+}
+
+// TopRatedCaptions returns up to limit past captions for a brand, platform
+// and tone, best net score first. Only captions rated above zero overall
+// count, so it returns nothing until there is enough positive feedback.
+func (s *Store) TopRatedCaptions(brand, platform, tone string, limit int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type candidate struct {
+		text  string
+		score int
+		last  time.Time
+	}
+	byText := make(map[string]*candidate)
+	for _, r := range s.data.Ratings {
+		ref := r.Caption
+		if ref.Text == "" || ref.Brand != brand || ref.Platform != platform || ref.Tone != tone {
+			continue
+		}
+		c, ok := byText[ref.Text]
+		if !ok {
+			c = &candidate{text: ref.Text}
+			byText[ref.Text] = c
+		}
+		c.score += r.Score
+		if r.At.After(c.last) {
+			c.last = r.At
+		}
+	}
+
+	var candidates []*candidate
+	for _, c := range byText {
+		if c.score > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	// Ties go to the most recently rated, so the examples don't go stale
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].last.After(candidates[j].last)
+	})
+
+	var texts []string
+	for _, c := range candidates {
+		if len(texts) == limit {
+			break
+		}
+		texts = append(texts, c.text)
+	}
+	return texts
+}
+
+// withRatedExamples adds each platform's top-rated past captions to the
+// params (RATED_EXAMPLES), to be shown to the model as extra examples.
+func (b *Bot) withRatedExamples(params GenerationParams) GenerationParams {
+	if b.ratedExamples <= 0 {
+		return params
+	}
+	params.RatedExamples = make(map[string][]string)
+	for _, platform := range params.Platforms {
+		if examples := b.store.TopRatedCaptions(params.Brand.Name, platform, params.Tone, b.ratedExamples); len(examples) > 0 {
+			params.RatedExamples[platform] = examples
+		}
+	}
+	return params
+}
+
+// reactionScore returns the rating implied by a set of reactions. The first
+// reaction we recognize wins; ok is false if none count.
+func reactionScore(reactions []reactionEmoji) (score int, ok bool) {
+	for _, r := range reactions {
+		if r.Type != "emoji" {
+			continue
+		}
+		if score, ok := reactionScores[r.Emoji]; ok {
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+// handleReaction turns a reaction on a caption message into a rating.
+func (b *Bot) handleReaction(reaction *messageReactionUpdated) {
+	if reaction.User == nil {
+		return
+	}
+	userID := reaction.User.ID
+
+	ref, ok := b.ratedCaption(userID, reaction.Chat.ID, reaction.MessageID)
+	if !ok {
+		return
+	}
+
+	score, ok := reactionScore(reaction.NewReaction)
+	if !ok {
+		b.store.SetRating(userID, reaction.MessageID, nil)
+		return
+	}
+	b.store.SetRating(userID, reaction.MessageID, &Rating{
+		UserID:    userID,
+		MessageID: reaction.MessageID,
+		At:        time.Unix(int64(reaction.Date), 0),
+		Caption:   ref,
+		Score:     score,
+		Source:    ratingSourceReaction,
+	})
+	log.Printf("User %d rated %s option %d: %+d", userID, ref.Platform, ref.Option+1, score)
+}
+
+// ratedCaption returns the caption a message shows, for rating it.
+func (b *Bot) ratedCaption(userID, chatID int64, messageID int) (captionRef, bool) {
+	if ref, ok := b.store.ResultMessage(chatID, messageID); ok {
+		return ref, true
+	}
+	// A carousel message shows whichever caption the user browsed to
+	state := b.getState(userID)
+	if state.LastResult == nil || state.CarouselMessageID != messageID {
+		return captionRef{}, false
+	}
+	item := carouselItems(state.LastResult)[state.CarouselIndex]
+	return captionRefFor(state.LastResult, item.result, item.caption), true
+}
+
+// handleRateCallback records a tap on a caption's 👍/👎 button, replacing
+// any earlier rating of that message. It answers the callback itself, so
+// the thanks shows on the button.
+func (b *Bot) handleRateCallback(query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		b.send(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	userID, messageID := query.From.ID, query.Message.MessageID
+
+	ref, ok := b.ratedCaption(userID, query.Message.Chat.ID, messageID)
+	if !ok {
+		b.send(tgbotapi.NewCallback(query.ID, "Sorry, I no longer have that caption."))
+		return
+	}
+	score, thanks := 1, "Thanks! 👍 I'll write more like this."
+	if query.Data == "rate:down" {
+		score, thanks = -1, "Thanks, noted. 👎"
+	}
+	b.store.SetRating(userID, messageID, &Rating{
+		UserID:    userID,
+		MessageID: messageID,
+		At:        time.Now(),
+		Caption:   ref,
+		Score:     score,
+		Source:    ratingSourceButton,
+	})
+	log.Printf("User %d rated %s option %d: %+d (button)", userID, ref.Platform, ref.Option+1, score)
+	b.send(tgbotapi.NewCallback(query.ID, thanks))
+}
+
+// buildRatingsReport renders the admin /ratings (or /feedbackstats) summary.
+func buildRatingsReport(ratings []Rating) string {
+	if len(ratings) == 0 {
+		return "No ratings yet. Users rate captions with the 👍 / 👎 buttons under them, or by reacting 👍 / 👎 / ❤️."
+	}
+
+	type tally struct{ up, down int }
+	count := func(key func(Rating) string) (map[string]*tally, []string) {
+		tallies := make(map[string]*tally)
+		var keys []string
+		for _, r := range ratings {
+			k := key(r)
+			t, ok := tallies[k]
+			if !ok {
+				t = &tally{}
+				tallies[k] = t
+				keys = append(keys, k)
+			}
+			if r.Score > 0 {
+				t.up++
+			} else {
+				t.down++
+			}
+		}
+		sort.Strings(keys)
+		return tallies, keys
+	}
+	section := func(title string, key func(Rating) string) string {
+		tallies, keys := count(key)
+		text := "\n**" + title + ":**\n"
+		for _, k := range keys {
+			text += fmt.Sprintf("%s: 👍 %d / 👎 %d\n", k, tallies[k].up, tallies[k].down)
+		}
+		return text
+	}
+
+	report := fmt.Sprintf("⭐ **Caption Ratings** (%d)\n", len(ratings))
+	report += section("By platform", func(r Rating) string { return platformLabels[r.Caption.Platform] })
+	report += section("By style", func(r Rating) string {
+		if r.Caption.Style == "" {
+			return "(unlabelled)"
+		}
+		return r.Caption.Style
+	})
+	report += section("By option", func(r Rating) string { return fmt.Sprintf("Option %d", r.Caption.Option+1) })
+	report += section("By tone", func(r Rating) string {
+		if r.Caption.Tone == "" {
+			return "(unknown)"
+		}
+		return r.Caption.Tone
+	})
+	report += section("By source", func(r Rating) string { return r.Source })
+	return report
+}
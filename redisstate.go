@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Redis State Store ---
+
+const (
+	redisStatePrefix = "caption-bot:state:"
+	redisLockPrefix  = "caption-bot:lock:"
+
+	// sessionLockTTL is the longest an instance may hold a user's session;
+	// a crashed instance's lock lapses after it. It's also how long another
+	// instance waits for the lock before going ahead without it.
+	sessionLockTTL = 2 * time.Minute
+	// sessionLockPoll is how often a busy lock is retried.
+	sessionLockPoll = 50 * time.Millisecond
+)
+
+// redisUnlockScript deletes a lock only if it's still ours, so a lock that
+// lapsed and was taken by another instance isn't released by mistake.
+const redisUnlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// sharedStateStore is a StateStore several instances use at once. Each
+// update is handled under the user's session lock, after picking up any
+// change another instance made to the conversation.
+type sharedStateStore interface {
+	StateStore
+	// LockSession waits for the user's session lock and returns the
+	// function that releases it.
+	LockSession(ctx context.Context, userID int64) (func(), error)
+	// RefreshState returns the stored state if the caller should adopt it:
+	// another instance saved (or deleted) it since this one last did, or
+	// the caller has no copy (have is false) and one is stored.
+	RefreshState(userID int64, have bool) (*userState, bool, error)
+}
+
+// redisStateRecord is a stored state. Version tells instances whether the
+// state changed since they last saw it.
+type redisStateRecord struct {
+	Version int64      `json:"version"`
+	SavedAt time.Time  `json:"savedAt"`
+	State   *userState `json:"state"`
+}
+
+// redisStateStore is a sharedStateStore in Redis (STATE_DB=redis://...).
+// States are stored as JSON, photo bytes included, and expire after
+// maxRestoredStateAge.
+type redisStateStore struct {
+	*redisClient
+
+	mu       sync.Mutex
+	versions map[int64]int64 // The version of each state this instance last saved or loaded
+}
+
+// newRedisStateStore connects the state store to a Redis URL.
+func newRedisStateStore(rawURL string) (*redisStateStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STATE_DB: %w", err)
+	}
+	s := &redisStateStore{redisClient: client, versions: make(map[int64]int64)}
+	if _, err := s.do("PING"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// get reads a user's stored state; nil if there is none.
+func (s *redisStateStore) get(userID int64) (*redisStateRecord, error) {
+	reply, err := s.do("GET", redisStatePrefix+strconv.FormatInt(userID, 10))
+	if err != nil {
+		return nil, fmt.Errorf("error reading state: %w", err)
+	}
+	raw, ok := reply.(string)
+	if !ok {
+		return nil, nil
+	}
+	var record redisStateRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return nil, fmt.Errorf("error decoding state: %w", err)
+	}
+	if record.State == nil {
+		return nil, errors.New("error decoding state: no state in record")
+	}
+	return &record, nil
+}
+
+// LoadStates implements StateStore.
+func (s *redisStateStore) LoadStates(maxAge time.Duration) (map[int64]*userState, error) {
+	states := make(map[int64]*userState)
+	cursor := "0"
+	for {
+		reply, err := s.do("SCAN", cursor, "MATCH", redisStatePrefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, fmt.Errorf("error listing states: %w", err)
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, errors.New("unexpected SCAN reply from Redis")
+		}
+		keys, _ := page[1].([]any)
+		for _, key := range keys {
+			userID, err := strconv.ParseInt(strings.TrimPrefix(fmt.Sprint(key), redisStatePrefix), 10, 64)
+			if err != nil {
+				continue
+			}
+			record, err := s.get(userID)
+			if err != nil {
+				log.Printf("Warning: skipping unreadable state of user %d: %v", userID, err)
+				continue
+			}
+			if record == nil || time.Since(record.SavedAt) > maxAge {
+				continue
+			}
+			states[userID] = record.State
+			s.setVersion(userID, record.Version)
+		}
+		if cursor, _ = page[0].(string); cursor == "0" || cursor == "" {
+			return states, nil
+		}
+	}
+}
+
+// SaveState implements StateStore.
+func (s *redisStateStore) SaveState(userID int64, state *userState) error {
+	record := redisStateRecord{Version: time.Now().UnixNano(), SavedAt: time.Now(), State: state}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+	_, err = s.do("SET", redisStatePrefix+strconv.FormatInt(userID, 10), string(raw),
+		"PX", strconv.FormatInt(maxRestoredStateAge.Milliseconds(), 10))
+	if err != nil {
+		return fmt.Errorf("error saving state: %w", err)
+	}
+	s.setVersion(userID, record.Version)
+	return nil
+}
+
+// DeleteState implements StateStore.
+func (s *redisStateStore) DeleteState(userID int64) error {
+	if _, err := s.do("DEL", redisStatePrefix+strconv.FormatInt(userID, 10)); err != nil {
+		return fmt.Errorf("error deleting state: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.versions, userID)
+	return nil
+}
+
+// RefreshState implements sharedStateStore.
+func (s *redisStateStore) RefreshState(userID int64, have bool) (*userState, bool, error) {
+	record, err := s.get(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen, known := s.versions[userID]
+	switch {
+	case record == nil && known:
+		// Deleted elsewhere, e.g. the session expired there
+		delete(s.versions, userID)
+		return &userState{State: StateDefault}, true, nil
+	case record == nil:
+		return nil, false, nil
+	case have && known && record.Version == seen:
+		return nil, false, nil
+	}
+	s.versions[userID] = record.Version
+	return record.State, true, nil
+}
+
+// setVersion records the version of a state this instance has.
+func (s *redisStateStore) setVersion(userID, version int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[userID] = version
+}
+
+// LockSession implements sharedStateStore with a Redis lock that expires
+// after sessionLockTTL.
+func (s *redisStateStore) LockSession(ctx context.Context, userID int64) (func(), error) {
+	var raw [16]byte
+	rand.Read(raw[:])
+	token := hex.EncodeToString(raw[:])
+	key := redisLockPrefix + strconv.FormatInt(userID, 10)
+
+	for {
+		reply, err := s.do("SET", key, token, "NX", "PX", strconv.FormatInt(sessionLockTTL.Milliseconds(), 10))
+		if err != nil {
+			return nil, fmt.Errorf("error taking session lock: %w", err)
+		}
+		if reply != nil {
+			break // "OK"; a nil reply means another instance holds it
+		}
+		select {
+		case <-time.After(sessionLockPoll):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("session lock still held by another instance: %w", ctx.Err())
+		}
+	}
+	return func() {
+		if _, err := s.do("EVAL", redisUnlockScript, "1", key, token); err != nil {
+			log.Printf("Error releasing session lock of user %d: %v", userID, err)
+		}
+	}, nil
+}
+
+// claimSession makes sure no other instance handles the user's updates
+// while this one does, and picks the conversation up where another
+// instance left it. It returns the function that releases the claim; with
+// a store that isn't shared, there is nothing to claim.
+func (b *Bot) claimSession(userID int64) func() {
+	shared, ok := b.states.(sharedStateStore)
+	if !ok || userID == 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionLockTTL)
+	defer cancel()
+	unlock, err := shared.LockSession(ctx, userID)
+	if err != nil {
+		// Better a rare race than a user who can't get an answer
+		log.Printf("Warning: handling user %d without their session lock: %v", userID, err)
+		unlock = func() {}
+	}
+
+	b.mu.Lock()
+	_, have := b.userStates[userID]
+	b.mu.Unlock()
+	state, changed, err := shared.RefreshState(userID, have)
+	if err != nil {
+		log.Printf("Error refreshing state of user %d: %v", userID, err)
+	} else if changed {
+		b.mu.Lock()
+		b.userStates[userID] = state
+		b.mu.Unlock()
+	}
+	return unlock
+}
+
+// movedElsewhere reports whether another instance has carried on (or
+// cleared) a conversation this one is about to expire, in which case it
+// isn't this instance's to expire.
+func (b *Bot) movedElsewhere(userID int64) bool {
+	shared, ok := b.states.(sharedStateStore)
+	if !ok {
+		return false
+	}
+	_, changed, err := shared.RefreshState(userID, true)
+	return err == nil && changed
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sharedMemory stands in for the Redis server several instances share.
+type sharedMemory struct {
+	mu       sync.Mutex
+	states   map[int64][]byte
+	versions map[int64]int64
+	version  int64
+	locks    sessionLocks
+}
+
+func newSharedMemory() *sharedMemory {
+	return &sharedMemory{
+		states:   make(map[int64][]byte),
+		versions: make(map[int64]int64),
+		locks:    sessionLocks{locks: make(map[int64]*sessionLock)},
+	}
+}
+
+// stored decodes a user's stored state; nil if there is none.
+func (m *sharedMemory) stored(t *testing.T, userID int64) *userState {
+	t.Helper()
+	m.mu.Lock()
+	raw, ok := m.states[userID]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	var state userState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		t.Fatalf("decoding stored state: %v", err)
+	}
+	return &state
+}
+
+// fakeSharedStore is one instance's connection to a sharedMemory, with the
+// version bookkeeping of redisStateStore.
+type fakeSharedStore struct {
+	mem  *sharedMemory
+	mu   sync.Mutex
+	seen map[int64]int64
+}
+
+func newFakeSharedStore(mem *sharedMemory) *fakeSharedStore {
+	return &fakeSharedStore{mem: mem, seen: make(map[int64]int64)}
+}
+
+func (s *fakeSharedStore) LoadStates(time.Duration) (map[int64]*userState, error) {
+	return map[int64]*userState{}, nil
+}
+
+func (s *fakeSharedStore) SaveState(userID int64, state *userState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s.mem.mu.Lock()
+	s.mem.version++
+	version := s.mem.version
+	s.mem.states[userID], s.mem.versions[userID] = raw, version
+	s.mem.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[userID] = version
+	return nil
+}
+
+func (s *fakeSharedStore) DeleteState(userID int64) error {
+	s.mem.mu.Lock()
+	delete(s.mem.states, userID)
+	delete(s.mem.versions, userID)
+	s.mem.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, userID)
+	return nil
+}
+
+func (s *fakeSharedStore) Close() error { return nil }
+
+func (s *fakeSharedStore) LockSession(ctx context.Context, userID int64) (func(), error) {
+	return s.mem.locks.lock(userID), nil
+}
+
+func (s *fakeSharedStore) RefreshState(userID int64, have bool) (*userState, bool, error) {
+	s.mem.mu.Lock()
+	raw, stored := s.mem.states[userID]
+	version := s.mem.versions[userID]
+	s.mem.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen, known := s.seen[userID]
+	switch {
+	case !stored && known:
+		delete(s.seen, userID)
+		return &userState{State: StateDefault}, true, nil
+	case !stored:
+		return nil, false, nil
+	case have && known && version == seen:
+		return nil, false, nil
+	}
+	var state userState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, false, err
+	}
+	s.seen[userID] = version
+	return &state, true, nil
+}
+
+// TestWorkerSavesOntoLatestState has a generation finish on one instance
+// after the user moved the conversation on through another. The result
+// must be added to the conversation as it is now, not to the copy the
+// first instance had when the job started.
+func TestWorkerSavesOntoLatestState(t *testing.T) {
+	mem := newSharedMemory()
+	release := make(chan struct{})
+	first, _ := newTestBot(t)
+	first.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		<-release
+		return http.StatusOK, captionsReply
+	})
+	first.states = newFakeSharedStore(mem)
+	first.queue = newFairQueue(0)
+	first.queue.start(1)
+	second, _ := newTestBot(t)
+	second.states = newFakeSharedStore(mem)
+
+	const userID = 202
+	queueGeneration(t, first, userID)
+	first.persistState(userID)
+
+	// Meanwhile the user sends a new photo, handled by the other instance
+	newPhoto := testJPEG(t, 500, 500)
+	servePhoto(t, second, newPhoto)
+	second.processUpdate(botUpdate{Update: tgbotapi.Update{Message: photoMessage(userID)}})
+	if got := mem.stored(t, userID); got == nil || got.State != StateWaitingForPlatform {
+		t.Fatalf("the other instance didn't save the new conversation: %+v", got)
+	}
+
+	close(release)
+	flushQueue(first.queue)
+
+	state := mem.stored(t, userID)
+	if state.State != StateWaitingForPlatform {
+		t.Errorf("stored state = %v, want the other instance's %v", state.State, StateWaitingForPlatform)
+	}
+	if want, _, _ := second.images.fit(newPhoto, "image/jpeg"); string(state.PhotoData) != string(want) {
+		t.Error("the worker's save replaced the new photo with the old one")
+	}
+	if state.LastRequest == nil || len(state.LastRequest.PhotoData) == 0 {
+		t.Error("LastRequest wasn't saved, so Regenerate would find nothing")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Caption Refinement ---
+
+// schemaForRefinement asks for the edited caption only.
+var schemaForRefinement = &Schema{
+	Type: "OBJECT",
+	Properties: map[string]Property{
+		"caption": {Type: "STRING"},
+	},
+	Required: []string{"caption"},
+}
+
+// buildRefineSystemPrompt asks for a minimal edit that follows the user's
+// instruction and keeps everything else about the caption.
+func buildRefineSystemPrompt(platform string) string {
+	return fmt.Sprintf(`You are a senior B2B social media copywriter editing a %s caption for a client.
+You get the current caption and the client's instruction (e.g. "shorter", "more formal", "add emojis", "translate to Bangla").
+- Apply the instruction and change nothing else: keep the facts, the call to action and the overall message.
+- Keep contact details, links and hashtags exactly as they are, unless the instruction is about them.
+- Write in the caption's current language, unless the instruction asks for another one.
+- Return only the edited caption in "caption", with no commentary.`, platform)
+}
+
+// refineCaption edits one caption following a free-text instruction. It is a
+// text-only call, like explainCaptions.
+func refineCaption(ctx context.Context, client ContentGenerator, caption, platform, instruction string) (string, UsageMetadata, error) {
+	request := GeminiRequest{
+		Contents: []Content{
+			{Role: "user", Parts: []Part{{Text: fmt.Sprintf("Current caption:\n%s\n\nInstruction: %s", caption, instruction)}}},
+		},
+		SystemInstruction: SystemInstruction{
+			Parts: []Part{{Text: buildRefineSystemPrompt(platform)}},
+		},
+		GenerationConfig: GenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   schemaForRefinement,
+		},
+	}
+
+	jsonResponse, usage, err := client.generateContent(withModelTask(ctx, taskCaptions), request)
+	if err != nil {
+		return "", usage, fmt.Errorf("error refining caption: %w", err)
+	}
+
+	var parsed struct {
+		Caption string `json:"caption"`
+	}
+	if err := json.Unmarshal([]byte(jsonResponse), &parsed); err != nil {
+		return "", usage, fmt.Errorf("error parsing refinement JSON: %w", err)
+	}
+	edited := strings.TrimSpace(parsed.Caption)
+	if edited == "" {
+		return "", usage, fmt.Errorf("refinement returned an empty caption")
+	}
+	return edited, usage, nil
+}
+
+// refineTarget picks the caption an instruction applies to: the caption the
+// message replies to, else the one edited last, else the first caption.
+func (b *Bot) refineTarget(message *tgbotapi.Message, state *userState) (captionRef, bool) {
+	if reply := message.ReplyToMessage; reply != nil {
+		if ref, ok := b.store.ResultMessage(message.From.ID, reply.MessageID); ok && ref.Text != "" {
+			return ref, true
+		}
+	}
+	if state.Refining != nil {
+		return *state.Refining, true
+	}
+	content := state.LastResult
+	if content == nil || len(content.Results) == 0 || len(content.Results[0].Captions) == 0 {
+		return captionRef{}, false
+	}
+	return captionRefFor(content, 0, 0), true
+}
+
+// handleRefinement applies a follow-up instruction ("shorter", "remove
+// emojis", ...) to a delivered caption and sends the edited version. Edits
+// build on each other until the user replies to a different caption.
+func (b *Bot) handleRefinement(message *tgbotapi.Message, state *userState) {
+	userID := message.From.ID
+	instruction := strings.TrimSpace(message.Text)
+	target, ok := b.refineTarget(message, state)
+	if instruction == "" || !ok {
+		b.sendMessage(message.Chat.ID, "Send me a **photo** to start generating content, or /cancel to restart.", nil)
+		return
+	}
+	if b.quotaBlocked(userID, 1) {
+		return
+	}
+
+	err := b.queue.submit(userID, func() {
+		edited, usage, err := refineCaption(context.Background(), b.llm, target.Text, target.Platform, instruction)
+		b.recordUsage(userID, usage)
+		if err != nil {
+			log.Printf("Error refining caption: %v", err)
+			b.sendMessage(message.Chat.ID, "Sorry, I couldn't edit the caption right now. Please try again.", nil)
+			return
+		}
+
+		ref := target
+		ref.Text = edited
+		unlock := b.lockSession(userID)
+		if state := b.getState(userID); state.State == StateRefining {
+			state.Refining = &ref
+		}
+		unlock()
+		msgID := b.sendMessageID(message.Chat.ID, fmt.Sprintf("--- ✏️ **Edited** · %s%s ---\n\n%s", platformLabels[ref.Platform], charCountLabel(edited, ref.Platform), edited), b.captionMarkup(userID))
+		if msgID != 0 {
+			b.store.TrackResultMessage(userID, msgID, ref)
+		}
+	})
+	if err != nil {
+		b.sendMessage(message.Chat.ID, "You already have several requests in progress. Please try again in a moment.", nil)
+		return
+	}
+	b.useQuota(userID, 1)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Regenerate / Done ---
+
+// requestSnapshot is the part of a finished job's state needed to run it
+// again: the photo and the answers, without any earlier results.
+func requestSnapshot(state *userState) *userState {
+	req := *state
+	req.State = StateDefault
+	req.MessageID = 0
+	req.LastResult = nil
+	req.LastRequest = nil
+	req.CarouselMessageID, req.CarouselIndex, req.CarouselHashtags = 0, 0, false
+	req.PDFData, req.PDFPages = nil, 0
+	req.Batch = nil
+	req.SkipCache = false
+	req.SchedulingPost = nil
+	return &req
+}
+
+// handleRegenerate runs the last job again with the same photo and answers.
+func (b *Bot) handleRegenerate(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	state := b.getState(userID)
+	if state.LastRequest == nil {
+		if query.Message != nil {
+			b.removeInlineKeyboard(userID, query.Message.MessageID)
+		}
+		b.sendMessage(userID, "These results have expired. Send a photo (or use /same) to generate new ones. 📸", nil)
+		return
+	}
+
+	// Start from the saved request; generateContent resets the state again
+	// as soon as it has taken its copy
+	req := *state.LastRequest
+	req.SkipCache = true // The point is new captions, so never serve a cached copy
+	b.mu.Lock()
+	b.userStates[userID] = &req
+	b.mu.Unlock()
+	b.generateContent(userID)
+}
+
+// handleDone ends the conversation about the last result, dropping the
+// photo and answers kept for Regenerate.
+func (b *Bot) handleDone(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	if query.Message != nil {
+		b.removeInlineKeyboard(userID, query.Message.MessageID)
+	}
+	b.resetState(userID)
+	b.sendMessage(userID, "🎉 Great, good luck with the post! Send another photo whenever you're ready.", nil)
+}
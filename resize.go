@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"slices"
+
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+)
+
+// --- Image Size Limits ---
+
+// ImageRules are the checks and resizing applied to incoming images.
+type ImageRules struct {
+	MinSide       int      // MIN_IMAGE_SIDE: smaller images get a warning; 0 disables it
+	MaxSide       int      // MAX_IMAGE_SIDE: larger images are downscaled first, to cut upload size and token cost; 0 disables it
+	JPEGQuality   int      // JPEG_QUALITY, for re-encoded images; 0 means jpeg.DefaultQuality
+	AcceptedTypes []string // ACCEPTED_MIME_TYPES: the image types we send to the model; empty means defaultAcceptedMimeTypes
+}
+
+// defaultImageRules apply when nothing is configured.
+var defaultImageRules = ImageRules{MinSide: 400, MaxSide: 1024, JPEGQuality: 85, AcceptedTypes: defaultAcceptedMimeTypes}
+
+// jpegOptions are the options for re-encoding an image as a JPEG.
+func (r ImageRules) jpegOptions() *jpeg.Options {
+	if r.JPEGQuality <= 0 {
+		return &jpeg.Options{Quality: jpeg.DefaultQuality}
+	}
+	return &jpeg.Options{Quality: r.JPEGQuality}
+}
+
+// fit checks an image against MinSide and MaxSide. Images above the maximum
+// are downscaled, preserving the aspect ratio. The note describes what
+// happened (for the results), or is "" if nothing did. If the image can't
+// be decoded, it is returned unchanged.
+func (r ImageRules) fit(data []byte, mimeType string) ([]byte, string, string) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return data, mimeType, ""
+	}
+	w, h := cfg.Width, cfg.Height
+
+	if w < r.MinSide || h < r.MinSide {
+		return data, mimeType, fmt.Sprintf("Note: the image is only %d×%d (under %dpx), so results may be weaker.", w, h, r.MinSide)
+	}
+	if r.MaxSide <= 0 || (w <= r.MaxSide && h <= r.MaxSide) {
+		data, mimeType = r.reencodeJPEG(data, mimeType)
+		return data, mimeType, ""
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, mimeType, ""
+	}
+	nw, nh := scaledSize(w, h, r.MaxSide)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscale(img, nw, nh), r.jpegOptions()); err != nil {
+		return data, mimeType, ""
+	}
+	return buf.Bytes(), "image/jpeg", fmt.Sprintf("Note: the image was downscaled from %d×%d to %d×%d for processing.", w, h, nw, nh)
+}
+
+// convertedMimeTypes are always re-encoded as JPEG, since not every model
+// provider reads them.
+var convertedMimeTypes = []string{"image/webp"}
+
+// reencodeJPEG re-encodes an image that is already small enough as a JPEG at
+// JPEGQuality, if that makes it smaller (e.g. a screenshot-sized PNG), or if
+// its type is in convertedMimeTypes. Images with transparency, and any the
+// re-encode doesn't shrink, are kept.
+func (r ImageRules) reencodeJPEG(data []byte, mimeType string) ([]byte, string) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, mimeType
+	}
+	if o, ok := img.(interface{ Opaque() bool }); mimeType != "image/jpeg" && (!ok || !o.Opaque()) {
+		return data, mimeType
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, r.jpegOptions()); err != nil {
+		return data, mimeType
+	}
+	if buf.Len() >= len(data) && !slices.Contains(convertedMimeTypes, mimeType) {
+		return data, mimeType
+	}
+	return buf.Bytes(), "image/jpeg"
+}
+
+// scaledSize fits w×h inside a maxSide square, keeping the aspect ratio.
+func scaledSize(w, h, maxSide int) (int, int) {
+	if w >= h {
+		return maxSide, max(1, h*maxSide/w)
+	}
+	return max(1, w*maxSide/h), maxSide
+}
+
+// downscale shrinks img to w×h by averaging each destination pixel's source
+// area, which avoids the aliasing of nearest-neighbour sampling.
+func downscale(img image.Image, w, h int) *image.RGBA {
+	src := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		y0 := src.Min.Y + y*src.Dy()/h
+		y1 := max(y0+1, src.Min.Y+(y+1)*src.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := src.Min.X + x*src.Dx()/w
+			x1 := max(x0+1, src.Min.X+(x+1)*src.Dx()/w)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(b / n >> 8), A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
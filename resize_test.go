@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestImageRulesFit(t *testing.T) {
+	rules := ImageRules{MinSide: 400, MaxSide: 1024, JPEGQuality: 85}
+	tests := []struct {
+		name          string
+		width, height int
+		wantW, wantH  int
+		wantNote      string
+	}{
+		{"below the minimum", 300, 500, 300, 500, "only 300×500 (under 400px)"},
+		{"within range", 800, 600, 800, 600, ""},
+		{"at the maximum", 1024, 1024, 1024, 1024, ""},
+		{"above the maximum, landscape", 2048, 1536, 1024, 768, "downscaled from 2048×1536 to 1024×768"},
+		{"above the maximum, portrait", 1000, 3000, 341, 1024, "downscaled from 1000×3000 to 341×1024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, mimeType, note := rules.fit(testJPEG(t, tt.width, tt.height), "image/jpeg")
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decoding the result: %v", err)
+			}
+			if cfg.Width != tt.wantW || cfg.Height != tt.wantH || mimeType != "image/jpeg" {
+				t.Errorf("result is a %d×%d %s, want a %d×%d JPEG", cfg.Width, cfg.Height, mimeType, tt.wantW, tt.wantH)
+			}
+			if (note == "") != (tt.wantNote == "") || !strings.Contains(note, tt.wantNote) {
+				t.Errorf("note = %q, want one mentioning %q", note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestImageRulesFitWithoutLimits(t *testing.T) {
+	data, _, note := ImageRules{}.fit(testJPEG(t, 2048, 2048), "image/jpeg")
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width != 2048 || note != "" {
+		t.Errorf("with no limits, got %d×%d and note %q (err %v); want the image kept at 2048×2048", cfg.Width, cfg.Height, note, err)
+	}
+
+	garbage := []byte("not an image")
+	if data, mimeType, _ := defaultImageRules.fit(garbage, "image/jpeg"); !bytes.Equal(data, garbage) || mimeType != "image/jpeg" {
+		t.Error("data that doesn't decode was changed")
+	}
+}
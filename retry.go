@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- LLM Retry Policy ---
+
+// maxRetryAfter caps how long we honour a Retry-After header. A longer wait
+// isn't worth holding the job for; we move on to the next model instead.
+const maxRetryAfter = 30 * time.Second
+
+// RetryPolicy controls how often a transient failure (rate limit, overload,
+// server or connection error) is retried on the same model.
+type RetryPolicy struct {
+	MaxAttempts int           // Tries per model, including the first
+	BaseDelay   time.Duration // Wait before the first retry; doubles each time
+	Jitter      float64       // Random spread of each wait, e.g. 0.2 for ±20%
+}
+
+// delay is the wait before retry n (1-based). A Retry-After from the API
+// wins over the backoff.
+func (p RetryPolicy) delay(n int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := p.BaseDelay << (n - 1)
+	if p.Jitter > 0 {
+		// Spread the retries so concurrent jobs don't hit the API in lockstep
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// parseRetryAfter reads a Retry-After header: either seconds or an HTTP
+// date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(header string) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// callWithRetry calls one model, retrying transient failures with
+// exponential backoff. Permanent errors (bad request, blocked prompt, auth)
+// are returned straight away.
+func (c *LLMClient) callWithRetry(ctx context.Context, model string, requestBody GeminiRequest) (string, UsageMetadata, error) {
+	for attempt := 1; ; attempt++ {
+		text, usage, err := c.provider.callModel(ctx, model, requestBody)
+		if err == nil || attempt >= c.retry.MaxAttempts || !isOutageError(err) || ctx.Err() != nil {
+			return text, usage, err
+		}
+
+		var retryAfter time.Duration
+		var unavailable *modelUnavailableError
+		if errors.As(err, &unavailable) {
+			retryAfter = unavailable.RetryAfter
+		}
+		if retryAfter > maxRetryAfter {
+			logFrom(ctx).Warn("Model asked us to wait too long, not retrying", "model", model, "retry_after", retryAfter)
+			return text, usage, err
+		}
+
+		wait := c.retry.delay(attempt, retryAfter)
+		logFrom(ctx).Warn("Model call failed, retrying", "model", model, "attempt", attempt, "error", err, "wait", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return "", UsageMetadata{}, err
+		}
+	}
+}
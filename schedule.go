@@ -0,0 +1,379 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Scheduled Delivery ---
+
+// schedulerInterval is how often the background scheduler checks for due posts.
+const schedulerInterval = 30 * time.Second
+
+// scheduleTimeFormat shows when a scheduled post goes out.
+const scheduleTimeFormat = "Mon 2 Jan 15:04 MST"
+
+// ScheduledDelivery is either a generated result waiting to be sent back to
+// a user, or (with Channel set) a caption waiting to be posted to their
+// channel with the photo, which is kept on disk like /same's photos.
+type ScheduledDelivery struct {
+	ID      int               `json:"id"`
+	UserID  int64             `json:"userId"`
+	SendAt  time.Time         `json:"sendAt"`
+	Content *GeneratedContent `json:"content,omitempty"`
+	Channel *LinkedChannel    `json:"channel,omitempty"`
+	Caption string            `json:"caption,omitempty"`
+}
+
+// scheduledPost is a channel post waiting for the user to say when.
+type scheduledPost struct {
+	Caption   string
+	PhotoData []byte
+}
+
+// scheduledPhotoPath returns where a scheduled channel post's photo is kept.
+func (s *Store) scheduledPhotoPath(id int) string {
+	return filepath.Join(filepath.Dir(s.path), "scheduled", strconv.Itoa(id))
+}
+
+// removeScheduledPhoto deletes a scheduled post's photo, if it has one.
+func (s *Store) removeScheduledPhoto(id int) {
+	if err := os.Remove(s.scheduledPhotoPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error removing scheduled photo: %v", err)
+	}
+}
+
+// AddScheduled saves a pending delivery, with the photo of a channel post,
+// and returns its ID.
+func (s *Store) AddScheduled(d ScheduledDelivery, photo []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.NextScheduleID++
+	d.ID = s.data.NextScheduleID
+	if photo != nil {
+		path := s.scheduledPhotoPath(d.ID)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return 0, fmt.Errorf("error creating scheduled photo directory: %w", err)
+		}
+		if err := os.WriteFile(path, photo, 0o600); err != nil {
+			return 0, fmt.Errorf("error saving scheduled photo: %w", err)
+		}
+	}
+	s.data.Scheduled = append(s.data.Scheduled, d)
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving scheduled delivery: %v", err)
+	}
+	return d.ID, nil
+}
+
+// TakeScheduledPhoto reads and deletes the photo of a channel post that is
+// due.
+func (s *Store) TakeScheduledPhoto(id int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.scheduledPhotoPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("error reading scheduled photo: %w", err)
+	}
+	s.removeScheduledPhoto(id)
+	return data, nil
+}
+
+// ScheduledForUser returns a user's pending deliveries, soonest first.
+func (s *Store) ScheduledForUser(userID int64) []ScheduledDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []ScheduledDelivery
+	for _, d := range s.data.Scheduled {
+		if d.UserID == userID {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SendAt.Before(out[j].SendAt) })
+	return out
+}
+
+// RemoveScheduled deletes a pending delivery owned by userID.
+// It returns false if no such delivery exists.
+func (s *Store) RemoveScheduled(userID int64, id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, d := range s.data.Scheduled {
+		if d.ID == id && d.UserID == userID {
+			s.data.Scheduled = append(s.data.Scheduled[:i], s.data.Scheduled[i+1:]...)
+			s.removeScheduledPhoto(id)
+			if err := s.save(); err != nil {
+				log.Printf("Error saving store: %v", err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// TakeDueScheduled removes and returns every delivery due at or before now.
+func (s *Store) TakeDueScheduled(now time.Time) []ScheduledDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due, pending []ScheduledDelivery
+	for _, d := range s.data.Scheduled {
+		if d.SendAt.After(now) {
+			pending = append(pending, d)
+		} else {
+			due = append(due, d)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	s.data.Scheduled = pending
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+	return due
+}
+
+// runScheduler delivers due posts until the process exits.
+// Deliveries live in the store, so anything pending survives a restart.
+func (b *Bot) runScheduler() {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, d := range b.store.TakeDueScheduled(now) {
+			if d.Channel != nil {
+				b.deliverScheduledPost(d)
+				continue
+			}
+			if b.store.IsChatInactive(d.UserID) {
+				log.Printf("Skipping scheduled post #%d: user %d has blocked the bot", d.ID, d.UserID)
+				continue
+			}
+			log.Printf("Delivering scheduled post #%d to user %d", d.ID, d.UserID)
+			b.sendMessage(d.UserID, "⏰ **Reminder:** here's the content you scheduled. Time to post!", nil)
+			b.sendResults(d.UserID, d.Content, nil, nil)
+		}
+	}
+}
+
+// deliverScheduledPost posts a scheduled caption to its channel and tells
+// the user how it went.
+func (b *Bot) deliverScheduledPost(d ScheduledDelivery) {
+	log.Printf("Posting scheduled post #%d of user %d to channel %d", d.ID, d.UserID, d.Channel.ID)
+	photo, err := b.store.TakeScheduledPhoto(d.ID)
+	if err == nil {
+		err = b.postToChannel(*d.Channel, photo, d.Caption)
+	}
+	if err != nil {
+		log.Printf("Error posting scheduled post #%d: %v", d.ID, err)
+		b.sendMessage(d.UserID, fmt.Sprintf("⚠️ Scheduled post #%d couldn't be posted to **%s**: %s", d.ID, d.Channel.Title, channelPostError(err)), nil)
+		return
+	}
+	b.sendMessage(d.UserID, fmt.Sprintf("📢 Scheduled post #%d is live on **%s**.", d.ID, d.Channel.Title), nil)
+}
+
+// handleScheduleCallback handles the "Schedule" and "Cancel scheduled" buttons.
+// It returns false if the callback isn't scheduling-related.
+func (b *Bot) handleScheduleCallback(query *tgbotapi.CallbackQuery) bool {
+	userID := query.From.ID
+	data := query.Data
+
+	switch {
+	case data == "control:schedule":
+		state := b.getState(userID)
+		if state.LastResult == nil {
+			b.sendMessage(userID, "Sorry, I no longer have that result. Please generate it again.", nil)
+			return true
+		}
+		state.State = StateWaitingForScheduleTime
+		state.SchedulingPost = nil
+		b.sendMessage(userID, "When should I send this back to you?\n\n"+scheduleTimeHelp(b.userLocation(userID)), nil)
+		return true
+
+	case data == "channel:schedule":
+		state := b.getState(userID)
+		channel, ok := b.store.Channel(userID)
+		if !ok {
+			b.sendMessage(userID, "You don't have a channel connected. Use /connectchannel to set one up.", nil)
+			return true
+		}
+		ref, ok := b.postedCaption(query, state)
+		if !ok || state.LastRequest == nil || len(state.LastRequest.PhotoData) == 0 {
+			b.sendMessage(userID, "Sorry, I only keep the photo of your latest result, so I can't schedule this one. Send the photo again to get new captions. 📸", nil)
+			return true
+		}
+		state.State = StateWaitingForScheduleTime
+		state.SchedulingPost = &scheduledPost{Caption: ref.Text, PhotoData: state.LastRequest.PhotoData}
+		b.sendMessage(userID, fmt.Sprintf("🗓 When should I post this to **%s**?\n\n%s", channel.Title, scheduleTimeHelp(b.userLocation(userID))), nil)
+		return true
+
+	case strings.HasPrefix(data, "unschedule:"):
+		id, err := strconv.Atoi(strings.TrimPrefix(data, "unschedule:"))
+		if err != nil || !b.store.RemoveScheduled(userID, id) {
+			b.sendMessage(userID, "That scheduled post was already sent or removed.", nil)
+			return true
+		}
+		b.sendMessage(userID, fmt.Sprintf("🗑 Scheduled post #%d cancelled.", id), nil)
+		b.listScheduled(userID, userID)
+		return true
+	}
+	return false
+}
+
+// scheduleTimeHelp lists the time formats handleScheduleTime accepts.
+func scheduleTimeHelp(loc *time.Location) string {
+	return fmt.Sprintf("Examples: `in 3 hours`, `in 45 minutes`, `18:00`, `tomorrow 09:30`, `2025-01-31 18:00`\n\n"+
+		"_Times are in %s; change it with /timezone._", loc)
+}
+
+// handleScheduleTime parses the user's reply to "When should I send this
+// back?" or "When should I post this?".
+func (b *Bot) handleScheduleTime(message *tgbotapi.Message) {
+	userID := message.From.ID
+	state := b.getState(userID)
+
+	sendAt, err := parseScheduleTime(message.Text, time.Now().In(b.userLocation(userID)))
+	if err != nil {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("Sorry, I couldn't use that time (%s).\n\n"+
+			"Please try again, e.g. `in 3 hours` or `18:00`, or /cancel.", err.Error()), nil)
+		return
+	}
+
+	d := ScheduledDelivery{UserID: userID, SendAt: sendAt, Content: state.LastResult}
+	var photo []byte
+	where := ""
+	if post := state.SchedulingPost; post != nil {
+		channel, ok := b.store.Channel(userID)
+		if !ok {
+			state.State, state.SchedulingPost = StateDefault, nil
+			b.sendMessage(message.Chat.ID, "Your channel was disconnected in the meantime. Use /connectchannel to set one up.", nil)
+			return
+		}
+		d.Content, d.Channel, d.Caption = nil, &channel, post.Caption
+		photo = post.PhotoData
+		where = fmt.Sprintf(" to **%s**", channel.Title)
+	}
+	id, err := b.store.AddScheduled(d, photo)
+	if err != nil {
+		log.Printf("Error scheduling post for user %d: %v", userID, err)
+		b.sendMessage(message.Chat.ID, "Sorry, I couldn't save that scheduled post. Please try again.", nil)
+		return
+	}
+	state.State, state.SchedulingPost = StateDefault, nil
+
+	b.sendMessage(message.Chat.ID, fmt.Sprintf("✅ Scheduled post #%d%s for %s. Use /scheduled to see or cancel it.",
+		id, where, sendAt.Format(scheduleTimeFormat)), nil)
+}
+
+// listScheduled shows a user's pending deliveries with a cancel button for each.
+func (b *Bot) listScheduled(chatID, userID int64) {
+	pending := b.store.ScheduledForUser(userID)
+	if len(pending) == 0 {
+		b.sendMessage(chatID, "You have no scheduled posts.", nil)
+		return
+	}
+
+	loc := b.userLocation(userID)
+	text := "⏰ **Your scheduled posts:**\n\n"
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, d := range pending {
+		what := "reminder"
+		if d.Channel != nil {
+			what = "📢 " + d.Channel.Title + ": " + captionSnippet(d.Caption)
+		}
+		text += fmt.Sprintf("#%d — %s — %s\n", d.ID, d.SendAt.In(loc).Format(scheduleTimeFormat), what)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("❌ Cancel #%d", d.ID), fmt.Sprintf("unschedule:%d", d.ID)),
+		))
+	}
+	b.sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// --- Time Parsing ---
+
+var (
+	relativeTimeRe = regexp.MustCompile(`^in\s+(\d+)\s*(m|min|mins|minute|minutes|h|hr|hrs|hour|hours|d|day|days)$`)
+	clockTimeRe    = regexp.MustCompile(`^(today|tomorrow)?\s*(\d{1,2}):(\d{2})$`)
+)
+
+// maxScheduleAhead is how far ahead a post may be scheduled. It also keeps
+// relative delays well clear of time.Duration's ~292-year range.
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// errScheduleTooFar is returned for times past maxScheduleAhead.
+var errScheduleTooFar = errors.New("that's too far ahead; I can schedule up to a year in advance")
+
+// parseScheduleTime understands relative delays ("in 3 hours"), a clock time
+// ("18:00", rolled over to tomorrow if already past), "tomorrow 09:30" and
+// absolute dates ("2025-01-31 18:00"). Times are interpreted in now's location
+// and may be at most maxScheduleAhead away.
+func parseScheduleTime(input string, now time.Time) (time.Time, error) {
+	text := strings.ToLower(strings.TrimSpace(input))
+
+	if m := relativeTimeRe.FindStringSubmatch(text); m != nil {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		var unit time.Duration
+		switch m[2][0] {
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			unit = 24 * time.Hour
+		}
+		if err != nil || n > int64(maxScheduleAhead/unit) {
+			return time.Time{}, errScheduleTooFar // Too many digits to parse, or past the cap
+		}
+		if n <= 0 {
+			return time.Time{}, fmt.Errorf("delay must be greater than zero")
+		}
+		return now.Add(time.Duration(n) * unit), nil
+	}
+
+	if m := clockTimeRe.FindStringSubmatch(text); m != nil {
+		hour, _ := strconv.Atoi(m[2])
+		minute, _ := strconv.Atoi(m[3])
+		if hour > 23 || minute > 59 {
+			return time.Time{}, fmt.Errorf("%q isn't a valid time of day", input)
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		switch {
+		case m[1] == "tomorrow":
+			t = t.AddDate(0, 0, 1)
+		case !t.After(now) && m[1] == "today":
+			return time.Time{}, fmt.Errorf("that time has already passed today")
+		case !t.After(now):
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02 15:04", text, now.Location()); err == nil {
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("that time is in the past")
+		}
+		if t.Sub(now) > maxScheduleAhead {
+			return time.Time{}, errScheduleTooFar
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q", input)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScheduleTime(t *testing.T) {
+	dhaka := time.FixedZone("Asia/Dhaka", 6*60*60)
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, dhaka)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr string
+	}{
+		{input: "in 45 minutes", want: now.Add(45 * time.Minute)},
+		{input: "in 3 hours", want: now.Add(3 * time.Hour)},
+		{input: "In 2 Days", want: now.Add(48 * time.Hour)},
+		{input: "in 365 days", want: now.Add(maxScheduleAhead)},
+		{input: "18:00", want: time.Date(2025, 1, 15, 18, 0, 0, 0, dhaka)},
+		{input: "09:00", want: time.Date(2025, 1, 16, 9, 0, 0, 0, dhaka)}, // Already past today
+		{input: "tomorrow 09:30", want: time.Date(2025, 1, 16, 9, 30, 0, 0, dhaka)},
+		{input: "2025-03-01 10:00", want: time.Date(2025, 3, 1, 10, 0, 0, 0, dhaka)},
+
+		{input: "in 0 hours", wantErr: "greater than zero"},
+		{input: "in 366 days", wantErr: "too far ahead"},
+		{input: "in 525601 minutes", wantErr: "too far ahead"},
+		// Would overflow time.Duration without the cap
+		{input: "in 300000 days", wantErr: "too far ahead"},
+		{input: "in 9223372036854775807 hours", wantErr: "too far ahead"},
+		{input: "in 99999999999999999999 minutes", wantErr: "too far ahead"},
+		{input: "2027-01-01 10:00", wantErr: "too far ahead"},
+		{input: "today 09:00", wantErr: "already passed"},
+		{input: "24:00", wantErr: "valid time of day"},
+		{input: "2024-12-31 10:00", wantErr: "in the past"},
+		{input: "next week", wantErr: "unrecognized"},
+	}
+	for _, tt := range tests {
+		got, err := parseScheduleTime(tt.input, now)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseScheduleTime(%q) = %v, %v; want an error containing %q", tt.input, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseScheduleTime(%q) = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
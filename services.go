@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Services Catalog ---
+
+// maxServiceKeyLength keeps "service:<key>" inside Telegram's 64-byte
+// callback data limit.
+const maxServiceKeyLength = 32
+
+// addServiceUsage is shown for a malformed /addservice.
+const addServiceUsage = "Add a service to the default brand's keyboard:\n\n" +
+	"`/addservice <key> <label> | <what it means, for the prompt>`\n\n" +
+	"e.g. `/addservice Eco Eco-Friendly Production | organic cotton and low-impact dyes`. " +
+	"The part after `|` is optional. Remove one with `/removeservice <key>`."
+
+// loadServiceCatalog reads a JSON list of services (SERVICES_FILE), in the
+// same format as a brand preset's "services".
+func loadServiceCatalog(path string) ([]ServiceOption, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading services file: %w", err)
+	}
+	var services []ServiceOption
+	if err := json.Unmarshal(raw, &services); err != nil {
+		return nil, fmt.Errorf("error parsing services file: %w", err)
+	}
+	if err := validateServices(services); err != nil {
+		return nil, fmt.Errorf("invalid services file: %w", err)
+	}
+	return services, nil
+}
+
+// validateServices checks a catalog the way a brand preset's is checked.
+func validateServices(services []ServiceOption) error {
+	candidate := BrandConfig{Name: defaultBrand.Name, Services: services}
+	if err := candidate.validate(); err != nil {
+		return err
+	}
+	for _, s := range services {
+		if len(s.Key) > maxServiceKeyLength {
+			return fmt.Errorf("service key %q is longer than %d bytes", s.Key, maxServiceKeyLength)
+		}
+	}
+	return nil
+}
+
+// SetServiceCatalog stores the default brand's services as edited by admins.
+func (s *Store) SetServiceCatalog(services []ServiceOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Services = services
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// ServiceCatalog returns the admins' services catalog, if they have edited it.
+func (s *Store) ServiceCatalog() ([]ServiceOption, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.data.Services) == 0 {
+		return nil, false
+	}
+	return append([]ServiceOption(nil), s.data.Services...), true
+}
+
+// defaultBrand returns the default brand with the current services
+// catalog: the admins' edits if any, else the built-in list (or
+// SERVICES_FILE).
+func (b *Bot) defaultBrand() *BrandConfig {
+	services, ok := b.store.ServiceCatalog()
+	if !ok {
+		return defaultBrand
+	}
+	bc := *defaultBrand
+	bc.Services = services
+	return &bc
+}
+
+// handleAddService handles the admin "/addservice <key> <label> | <prompt>".
+// Adding a key that already exists replaces that service.
+func (b *Bot) handleAddService(chatID int64, args string) {
+	spec, prompt, _ := strings.Cut(args, "|")
+	key, label, _ := strings.Cut(strings.TrimSpace(spec), " ")
+	service := ServiceOption{Key: key, Label: strings.TrimSpace(label), Prompt: strings.TrimSpace(prompt)}
+	if service.Key == "" || service.Label == "" {
+		b.sendMessage(chatID, addServiceUsage+"\n\n"+formatServices(b.defaultBrand().Services), nil)
+		return
+	}
+	if utf8.RuneCountInString(service.Label) > 60 {
+		b.sendMessage(chatID, "That label is too long for a button. Please keep it under 60 characters.", nil)
+		return
+	}
+
+	services := b.defaultBrand().Services
+	replaced := false
+	updated := make([]ServiceOption, 0, len(services)+1)
+	for _, s := range services {
+		if s.Key == service.Key {
+			s, replaced = service, true
+		}
+		updated = append(updated, s)
+	}
+	if !replaced {
+		updated = append(updated, service)
+	}
+	if err := validateServices(updated); err != nil {
+		b.sendMessage(chatID, fmt.Sprintf("Couldn't add that service: %v.", err), nil)
+		return
+	}
+
+	b.store.SetServiceCatalog(updated)
+	log.Printf("Admin set service %q (%s)", service.Key, service.Label)
+	verb := "Added"
+	if replaced {
+		verb = "Updated"
+	}
+	b.sendMessage(chatID, fmt.Sprintf("✅ %s **%s**. It shows on the services keyboard from the next photo.\n\n%s",
+		verb, service.Label, formatServices(updated)), nil)
+}
+
+// handleRemoveService handles the admin "/removeservice <key>".
+func (b *Bot) handleRemoveService(chatID int64, key string) {
+	key = strings.TrimSpace(key)
+	services := b.defaultBrand().Services
+	if key == "" {
+		b.sendMessage(chatID, "Usage: `/removeservice <key>`\n\n"+formatServices(services), nil)
+		return
+	}
+
+	updated := make([]ServiceOption, 0, len(services))
+	for _, s := range services {
+		if !strings.EqualFold(s.Key, key) {
+			updated = append(updated, s)
+		}
+	}
+	switch {
+	case len(updated) == len(services):
+		b.sendMessage(chatID, fmt.Sprintf("There's no service with the key `%s`.\n\n%s", key, formatServices(services)), nil)
+		return
+	case len(updated) == 0:
+		b.sendMessage(chatID, "That's the last service; add another before removing it.", nil)
+		return
+	}
+
+	b.store.SetServiceCatalog(updated)
+	log.Printf("Admin removed service %q", key)
+	b.sendMessage(chatID, fmt.Sprintf("🗑 Removed `%s`.\n\n%s", key, formatServices(updated)), nil)
+}
+
+// formatServices lists a catalog for the admin commands.
+func formatServices(services []ServiceOption) string {
+	text := "**Current services:**\n"
+	for _, s := range services {
+		text += fmt.Sprintf("• `%s` — %s\n", s.Key, s.Label)
+	}
+	return text
+}
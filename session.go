@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// --- Session Locks ---
+
+// sessionLocks serializes the changes to each user's conversation. The
+// dispatcher handles one update per user at a time, but queue workers
+// deliver results alongside it, so both take the user's lock first.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[int64]*sessionLock
+}
+
+// sessionLock is one user's lock; it is dropped once nobody holds or waits for it.
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock waits for the user's lock and returns its release.
+func (l *sessionLocks) lock(userID int64) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[userID]
+	if !ok {
+		lock = &sessionLock{}
+		l.locks[userID] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(l.locks, userID)
+		}
+	}
+}
+
+// lockSession is how work outside the user's own updates (a queue worker, a
+// timer) changes their conversation: under this process's lock and the
+// claim across instances (see claimSession), which also picks up the latest
+// copy of the state. The release saves the state before letting go, so
+// nobody, here or on another instance, works from a copy without the change.
+func (b *Bot) lockSession(userID int64) func() {
+	unlock := b.sessions.lock(userID)
+	release := b.claimSession(userID)
+	return func() {
+		b.persistState(userID)
+		release()
+		unlock()
+	}
+}
+
+// --- Session Timeout ---
+
+// sessionSweepInterval is how often expired sessions are looked for.
+const sessionSweepInterval = time.Minute
+
+// touchSession records activity in a user's session (SESSION_TTL).
+func (b *Bot) touchSession(userID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastActive[userID] = time.Now()
+}
+
+// hasActiveJob reports whether a generation of the user's is queued or running.
+func (b *Bot) hasActiveJob(userID int64) bool {
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+	for key := range b.jobs {
+		if key.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// expireSessions removes the sessions idle for longer than the TTL and
+// returns them by user. Sessions with a generation in flight are kept, and
+// sessions with no recorded activity (restored at startup) start their
+// clock now.
+func (b *Bot) expireSessions(now time.Time) map[int64]*userState {
+	var idle []int64
+	b.mu.Lock()
+	for userID := range b.userStates {
+		last, ok := b.lastActive[userID]
+		if !ok {
+			b.lastActive[userID] = now
+			continue
+		}
+		if now.Sub(last) >= b.sessionTTL {
+			idle = append(idle, userID)
+		}
+	}
+	b.mu.Unlock()
+
+	expired := make(map[int64]*userState)
+	for _, userID := range idle {
+		if b.hasActiveJob(userID) {
+			continue
+		}
+		b.mu.Lock()
+		expired[userID] = b.userStates[userID]
+		delete(b.userStates, userID)
+		delete(b.lastActive, userID)
+		b.mu.Unlock()
+	}
+	return expired
+}
+
+// runSessionSweeper clears idle sessions until the process exits, freeing
+// their photos. Users who were partway through a job get their buttons
+// removed and a note that the session expired.
+func (b *Bot) runSessionSweeper() {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		expired := b.expireSessions(now)
+		for userID, state := range expired {
+			if b.movedElsewhere(userID) {
+				continue
+			}
+			if b.states != nil {
+				if err := b.states.DeleteState(userID); err != nil {
+					log.Printf("Error deleting expired state of user %d: %v", userID, err)
+				}
+			}
+			if state.State == StateDefault {
+				continue // Nothing in progress; drop it quietly
+			}
+			b.removeInlineKeyboard(userID, state.MessageID)
+			b.sendMessage(userID, fmt.Sprintf("⌛ Your session expired after %d minutes without activity, so I've cleared it. "+
+				"Send a photo whenever you want to start again.", max(int(b.sessionTTL.Minutes()), 1)), nil)
+		}
+		if len(expired) > 0 {
+			log.Printf("Expired %d idle session(s)", len(expired))
+		}
+	}
+}
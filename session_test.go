@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionLocksSerializeAndClean(t *testing.T) {
+	locks := sessionLocks{locks: make(map[int64]*sessionLock)}
+
+	var wg sync.WaitGroup
+	holders := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.lock(7)
+			holders++ // Racy unless the lock is exclusive
+			if holders != 1 {
+				t.Errorf("%d holders of one user's lock", holders)
+			}
+			holders--
+			unlock()
+		}()
+	}
+	// Another user's lock doesn't wait for user 7's
+	locks.lock(8)()
+	wg.Wait()
+
+	if len(locks.locks) != 0 {
+		t.Errorf("%d locks left after every release, want none", len(locks.locks))
+	}
+}
+
+// TestResultsWaitForTheUsersUpdate checks that a queue worker doesn't
+// deliver results while one of the user's updates is being handled.
+func TestResultsWaitForTheUsersUpdate(t *testing.T) {
+	b, fake := newTestBot(t)
+	generated := make(chan struct{}, 2)
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		generated <- struct{}{}
+		return http.StatusOK, captionsReply
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+
+	const userID = 101
+	job := userState{PhotoData: []byte("photo"), MimeType: "image/jpeg", Platforms: []string{"Instagram"}}
+	key := jobKey{userID: userID, thinkingMsgID: 5}
+	ctx := b.startJob(key)
+	done := make(chan struct{})
+	unlock := b.sessions.lock(userID) // An update is being handled
+	if err := b.queue.submit(userID, func() {
+		defer close(done)
+		b.runGeneration(ctx, key, &job)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	<-generated // The captions; the feedback follows the results
+	select {
+	case <-done:
+		t.Fatal("results delivered while the user's update was being handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if b.getState(userID).LastResult != nil {
+		t.Error("LastResult set while the user's update was being handled")
+	}
+
+	unlock()
+	<-done
+	if b.getState(userID).LastResult == nil {
+		t.Error("LastResult not set after the update finished")
+	}
+	var captions bool
+	for _, text := range fake.Texts(userID) {
+		captions = captions || strings.Contains(text, "First caption")
+	}
+	if !captions {
+		t.Error("the captions were never sent")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Per-User Settings ---
+
+// UserSettings holds a user's preferences. Pointer fields are nil until the
+// user changes them, so the operator's default applies.
+type UserSettings struct {
+	CTA      *bool  `json:"cta,omitempty"`          // Append the contact footer
+	Language string `json:"language,omitempty"`     // Output language code; "" means English
+	Layout   string `json:"resultLayout,omitempty"` // resultLayoutSplit or resultLayoutCombined; "" means split
+	Timezone string `json:"timezone,omitempty"`     // IANA name for scheduling, e.g. "Asia/Dhaka"; "" means TIMEZONE
+}
+
+// ctaEnabled reports whether the contact footer is on (default: on).
+func (s UserSettings) ctaEnabled() bool {
+	return s.CTA == nil || *s.CTA
+}
+
+// GetUserSettings returns a copy of a user's settings.
+func (s *Store) GetUserSettings(userID int64) UserSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if settings, ok := s.data.Settings[userID]; ok {
+		return *settings
+	}
+	return UserSettings{}
+}
+
+// UpdateUserSettings applies fn to a user's settings and saves them.
+func (s *Store) UpdateUserSettings(userID int64, fn func(*UserSettings)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.data.Settings[userID]
+	if !ok {
+		settings = &UserSettings{}
+		s.data.Settings[userID] = settings
+	}
+	fn(settings)
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving settings: %v", err)
+	}
+}
+
+// showSettings sends (or, with messageID set, updates) the /settings panel.
+func (b *Bot) showSettings(chatID, userID int64, messageID int) {
+	settings := b.store.GetUserSettings(userID)
+
+	text := fmt.Sprintf("⚙️ **Your Settings**\n\nTap a setting to change it.\n\n🕒 Time zone for scheduling: %s (change it with `/timezone Area/City`)", b.userLocation(userID))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if b.cta.enabled() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📩 Contact footer: "+onOff(settings.ctaEnabled()), "setting:cta"),
+		))
+	}
+	lang, _ := findLanguage(settings.Language)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🌐 Caption language: "+lang.Name, "setting:language"),
+	))
+	layout := "Separate messages"
+	if settings.Layout == resultLayoutCombined {
+		layout = "One message"
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🧾 Results: "+layout, "setting:layout"),
+	))
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if messageID != 0 {
+		b.editMessageID(chatID, messageID, text, markup)
+		return
+	}
+	b.sendMessage(chatID, text, markup)
+}
+
+// handleSettingsCallback toggles the tapped setting and redraws the panel.
+func (b *Bot) handleSettingsCallback(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+
+	switch query.Data {
+	case "setting:cta":
+		b.store.UpdateUserSettings(userID, func(s *UserSettings) {
+			on := !s.ctaEnabled()
+			s.CTA = &on
+		})
+	case "setting:language":
+		b.store.UpdateUserSettings(userID, func(s *UserSettings) {
+			s.Language = nextLanguage(s.Language)
+		})
+	case "setting:layout":
+		b.store.UpdateUserSettings(userID, func(s *UserSettings) {
+			if s.Layout == resultLayoutCombined {
+				s.Layout = resultLayoutSplit
+			} else {
+				s.Layout = resultLayoutCombined
+			}
+		})
+	}
+
+	if query.Message != nil {
+		b.showSettings(query.Message.Chat.ID, userID, query.Message.MessageID)
+	}
+}
+
+// userLocation is the time zone a user's schedule times are read in: their
+// own (/timezone), else TIMEZONE.
+func (b *Bot) userLocation(userID int64) *time.Location {
+	if name := b.store.GetUserSettings(b.memberID(userID)).Timezone; name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return b.location
+}
+
+// handleTimezone handles "/timezone [Area/City]".
+func (b *Bot) handleTimezone(chatID, userID int64, arg string) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		b.sendMessage(chatID, fmt.Sprintf("🕒 Your scheduled posts use **%s** (it's %s there now).\n\n"+
+			"To change it, send `/timezone` with a zone name like `Asia/Dhaka`, `Europe/London` or `UTC`; `/timezone default` goes back to the bot's.",
+			b.userLocation(userID), time.Now().In(b.userLocation(userID)).Format("15:04")), nil)
+		return
+	}
+	if strings.EqualFold(arg, "default") {
+		b.store.UpdateUserSettings(userID, func(s *UserSettings) { s.Timezone = "" })
+		b.sendMessage(chatID, fmt.Sprintf("✅ Back to the bot's time zone, %s.", b.location), nil)
+		return
+	}
+	loc, err := time.LoadLocation(arg)
+	if err != nil || arg == "Local" {
+		b.sendMessage(chatID, fmt.Sprintf("Sorry, I don't know the time zone `%s`. Use a name like `Asia/Dhaka` or `Europe/London`.", arg), nil)
+		return
+	}
+	b.store.UpdateUserSettings(userID, func(s *UserSettings) { s.Timezone = loc.String() })
+	b.sendMessage(chatID, fmt.Sprintf("✅ Time zone set to **%s** (it's %s there now).", loc, time.Now().In(loc).Format("15:04")), nil)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "On ✅"
+	}
+	return "Off"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Google Sheets Export ---
+
+const (
+	sheetsAPIURL = "https://sheets.googleapis.com/v4/spreadsheets"
+	// sheetsTimeout bounds one append; a slow Sheets API never holds up a result.
+	sheetsTimeout = 30 * time.Second
+)
+
+// sheetsExporter appends every result to a Google Sheet
+// (SHEETS_SPREADSHEET_ID), one row per platform, as a content log for the
+// marketing team.
+type sheetsExporter struct {
+	spreadsheetID string
+	sheet         string // SHEETS_SHEET, the tab rows go to
+	location      *time.Location
+	auth          *vertexAuth
+	httpClient    *http.Client
+}
+
+// newSheetsExporter sets up the export, or returns nil if it's off.
+func newSheetsExporter(cfg Config) (*sheetsExporter, error) {
+	if cfg.SheetsSpreadsheetID == "" {
+		return nil, nil
+	}
+	httpClient := &http.Client{Timeout: sheetsTimeout}
+	auth, err := newVertexAuth(cfg.GoogleCredentials, spreadsheetsScope, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &sheetsExporter{
+		spreadsheetID: cfg.SheetsSpreadsheetID,
+		sheet:         cfg.SheetsSheet,
+		location:      cfg.Location,
+		auth:          auth,
+		httpClient:    httpClient,
+	}, nil
+}
+
+// sheetRows are the rows for one result: date, user, platform, tone,
+// captions (one per paragraph) and hashtags.
+func (e *sheetsExporter) sheetRows(userID int64, entry HistoryEntry) [][]string {
+	date := entry.At.In(e.location).Format("2006-01-02 15:04")
+	tone := entry.Tone
+	if entry.ToneIntensity != "" {
+		tone += " (" + entry.ToneIntensity + ")"
+	}
+	var rows [][]string
+	for _, result := range entry.Content.Results {
+		rows = append(rows, []string{
+			date,
+			strconv.FormatInt(userID, 10),
+			result.Platform,
+			tone,
+			strings.Join(result.Captions, "\n\n"),
+			strings.Join(result.Hashtags, " "),
+		})
+	}
+	return rows
+}
+
+// appendRows adds rows after the last row of the sheet. Values are
+// written as typed (RAW), so a caption starting with "=" stays text.
+func (e *sheetsExporter) appendRows(ctx context.Context, rows [][]string) error {
+	token, err := e.auth.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{"values": rows})
+	if err != nil {
+		return fmt.Errorf("error encoding rows: %w", err)
+	}
+	target := fmt.Sprintf("%s/%s/values/%s:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS",
+		sheetsAPIURL, url.PathEscape(e.spreadsheetID), url.PathEscape(e.sheet))
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating Sheets request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Sheets API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		e.auth.invalidate()
+	}
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("Sheets API error %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("Sheets API request failed with status %d: %s", resp.StatusCode, raw)
+	}
+	return nil
+}
+
+// recordResult saves a finished result to the user's history and, if
+// SHEETS_SPREADSHEET_ID is set, exports it in the background.
+func (b *Bot) recordResult(userID int64, state *userState, content *GeneratedContent) {
+	member := b.memberID(userID)
+	entry := newHistoryEntry(state, content)
+	b.store.AddHistory(member, entry)
+	if b.sheets == nil || b.dryRun {
+		return
+	}
+	rows := b.sheets.sheetRows(member, entry)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sheetsTimeout)
+		defer cancel()
+		if err := b.sheets.appendRows(ctx, rows); err != nil {
+			log.Printf("Error exporting result of user %d to Google Sheets: %v", member, err)
+		}
+	}()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// --- Graceful Shutdown ---
+
+// shutdown runs once polling has stopped: it waits for the updates being
+// handled and the queued generations to finish, saves every conversation
+// and stops the HTTP server. Work still running when ctx expires is
+// abandoned.
+func (b *Bot) shutdown(ctx context.Context, dispatcher *updateDispatcher, server *http.Server) {
+	select {
+	case <-dispatcher.finished():
+	case <-ctx.Done():
+		log.Println("Shutdown timeout reached while handling updates")
+	}
+
+	select {
+	case <-b.queue.drained():
+		log.Println("All generations finished")
+	case <-ctx.Done():
+		log.Println("Shutdown timeout reached; abandoning the generations still running")
+	}
+
+	b.persistAllStates()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down the HTTP server: %v", err)
+	}
+}
+
+// persistAllStates saves every conversation in memory, so nothing changed
+// since the last update (e.g. by a generation finishing) is lost.
+func (b *Bot) persistAllStates() {
+	if b.states == nil {
+		return
+	}
+	b.mu.Lock()
+	userIDs := make([]int64, 0, len(b.userStates))
+	for userID := range b.userStates {
+		userIDs = append(userIDs, userID)
+	}
+	b.mu.Unlock()
+
+	for _, userID := range userIDs {
+		b.persistState(userID)
+	}
+	log.Printf("Saved %d conversation(s)", len(userIDs))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver
+)
+
+// --- Conversation State Persistence ---
+
+// maxRestoredStateAge is how old a saved conversation may be and still be
+// restored at startup; older ones are deleted instead.
+const maxRestoredStateAge = 24 * time.Hour
+
+// StateStore keeps users' conversation state across restarts.
+type StateStore interface {
+	// LoadStates returns every saved state updated within maxAge.
+	LoadStates(maxAge time.Duration) (map[int64]*userState, error)
+	// SaveState saves (or replaces) a user's state.
+	SaveState(userID int64, state *userState) error
+	// DeleteState removes a user's saved state, if any.
+	DeleteState(userID int64) error
+	Close() error
+}
+
+// openStateStore opens STATE_DB: a redis:// URL, for a store shared by
+// several instances, or else a SQLite file.
+func openStateStore(path string) (StateStore, error) {
+	if strings.HasPrefix(path, "redis://") {
+		return newRedisStateStore(path)
+	}
+	return openSQLiteStateStore(path)
+}
+
+// sqliteStateStore is a StateStore in a SQLite file (STATE_DB). Each state
+// is stored as JSON, photo bytes included.
+type sqliteStateStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStateStore opens (or creates) the state database at path.
+func openSQLiteStateStore(path string) (*sqliteStateStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening state database: %w", err)
+	}
+	// One connection: writes are serialized anyway, and it avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS user_states (
+		user_id    INTEGER PRIMARY KEY,
+		state      BLOB NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating state table: %w", err)
+	}
+	return &sqliteStateStore{db: db}, nil
+}
+
+// LoadStates implements StateStore. Expired states are deleted, and any that
+// can't be decoded are skipped with a warning.
+func (s *sqliteStateStore) LoadStates(maxAge time.Duration) (map[int64]*userState, error) {
+	cutoff := time.Now().Add(-maxAge).Unix()
+	if _, err := s.db.Exec(`DELETE FROM user_states WHERE updated_at < ?`, cutoff); err != nil {
+		return nil, fmt.Errorf("error deleting expired states: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT user_id, state FROM user_states`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading states: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[int64]*userState)
+	for rows.Next() {
+		var userID int64
+		var raw []byte
+		if err := rows.Scan(&userID, &raw); err != nil {
+			return nil, fmt.Errorf("error reading state: %w", err)
+		}
+		var state userState
+		if err := json.Unmarshal(raw, &state); err != nil {
+			log.Printf("Warning: skipping unreadable state of user %d: %v", userID, err)
+			continue
+		}
+		states[userID] = &state
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading states: %w", err)
+	}
+	return states, nil
+}
+
+// SaveState implements StateStore.
+func (s *sqliteStateStore) SaveState(userID int64, state *userState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+	_, err = s.db.Exec(`INSERT INTO user_states (user_id, state, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET state = excluded.state, updated_at = excluded.updated_at`,
+		userID, raw, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("error saving state: %w", err)
+	}
+	return nil
+}
+
+// DeleteState implements StateStore.
+func (s *sqliteStateStore) DeleteState(userID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM user_states WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("error deleting state: %w", err)
+	}
+	return nil
+}
+
+// Close implements StateStore.
+func (s *sqliteStateStore) Close() error {
+	return s.db.Close()
+}
+
+// restoreStates loads the saved conversations into the bot. A conversation
+// that was waiting on work lost in the restart (product details being
+// detected) is started over.
+func (b *Bot) restoreStates() error {
+	states, err := b.states.LoadStates(maxRestoredStateAge)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if state.State == StateWaitingForAttributes && state.Attributes == nil {
+			state.State = StateDefault
+			state.PhotoData, state.MimeType = nil, ""
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for userID, state := range states {
+		b.userStates[userID] = state
+	}
+	log.Printf("Restored %d conversation(s) from the state database", len(states))
+	return nil
+}
+
+// persistState saves a user's state after an update changed it. Failures
+// are only logged; the conversation carries on in memory.
+func (b *Bot) persistState(userID int64) {
+	if b.states == nil || userID == 0 {
+		return
+	}
+	state := b.getState(userID)
+	if err := b.states.SaveState(userID, state); err != nil {
+		log.Printf("Error persisting state of user %d: %v", userID, err)
+	}
+}
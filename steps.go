@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Conversation Steps ---
+
+// stepInput is an answer to a step: the data of a tapped button, or a
+// typed message.
+type stepInput struct {
+	Data    string            // Callback data; "" for a message
+	Message *tgbotapi.Message // The typed message; nil for a button
+}
+
+// text is the typed text, or "" for a button.
+func (in stepInput) text() string {
+	if in.Message == nil {
+		return ""
+	}
+	return in.Message.Text
+}
+
+// stepOutcome is what the conversation does after a step took an input.
+type stepOutcome int
+
+const (
+	stepIgnored stepOutcome = iota // Not an answer to this step
+	stepAsk                        // Ask the current step (again), e.g. after a toggle; a step may set another state first
+	stepNext                       // Answered; go on to the next step
+	stepHandled                    // The step moved the conversation on itself
+)
+
+// conversationStep is one state of the conversation. The questions of the
+// caption flow have a Prompt and a Next; states entered by other flows
+// (batch, PDF pages, ...) only handle input. A new question is a new entry
+// in newConversationSteps, with a Next pointing at it.
+type conversationStep struct {
+	// Prompt is the question; Keyboard its buttons (backKeyboard if nil)
+	Prompt   func(b *Bot, s *userState) string
+	Keyboard func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup
+	// HandleInput takes a button tap or typed message in this state
+	HandleInput func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome
+	// Notice refuses a tap with a short notice on the button, or returns ""
+	Notice func(b *Bot, s *userState, data string) string
+	// Note, if set, goes above the next question once this one is answered
+	Note func(b *Bot, s *userState) string
+	// Skip fills in the answer and returns true if it's already known (e.g.
+	// from a deep-link preset). Skipped steps are still asked on the way back.
+	Skip func(b *Bot, s *userState) bool
+	// Next is the step after this one; StateDefault generates
+	Next ConversationState
+	// Back is where "⬅️ Back" returns to; StateDefault for nowhere
+	Back ConversationState
+}
+
+// newConversationSteps defines the conversation, one entry per state.
+func newConversationSteps() map[ConversationState]*conversationStep {
+	return map[ConversationState]*conversationStep{
+		StateWaitingForPlatform: {
+			Prompt:   func(b *Bot, s *userState) string { return platformPromptText },
+			Keyboard: func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup { return buildPlatformKeyboard(s.Platforms) },
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				switch {
+				case strings.HasPrefix(in.Data, "platform:"):
+					s.Platforms = toggleSelection(s.Platforms, strings.TrimPrefix(in.Data, "platform:"))
+					return stepAsk
+				case in.Data == "control:done_platforms":
+					return stepNext
+				case in.Data == "control:all_platforms":
+					// Campaign mode: one set of captions per platform, past MAX_PLATFORMS
+					s.Platforms = append([]string(nil), platformOrder...)
+					return stepNext
+				}
+				return stepIgnored
+			},
+			Notice: func(b *Bot, s *userState, data string) string {
+				if data == "control:done_platforms" && len(s.Platforms) == 0 {
+					return "Please select at least one platform"
+				}
+				if platform, ok := strings.CutPrefix(data, "platform:"); ok && len(s.Platforms) >= b.maxPlatforms && !containsString(s.Platforms, platform) {
+					return fmt.Sprintf("You can pick up to %d platforms", b.maxPlatforms)
+				}
+				return ""
+			},
+			// Each extra platform is another caption request, so say so up front
+			Note: func(b *Bot, s *userState) string {
+				if n := len(s.Platforms); n > 1 {
+					return fmt.Sprintf("⚠️ You picked %d platforms, so I'll write %d sets of captions. "+
+						"This takes a little longer and uses more AI credits.\n\n", n, n)
+				}
+				return ""
+			},
+			Skip: func(b *Bot, s *userState) bool {
+				if len(s.DefaultPlatforms) == 0 {
+					return false
+				}
+				s.Platforms = s.DefaultPlatforms
+				return true
+			},
+			Next: StateWaitingForTone,
+		},
+
+		StateWaitingForTone: {
+			Prompt:   func(b *Bot, s *userState) string { return "What's the **tone** you're going for?" },
+			Keyboard: func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup { return toneKeyboard },
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				switch {
+				case in.Data == "control:custom_tone":
+					s.State = StateWaitingForCustomTone
+					return stepAsk
+				case strings.HasPrefix(in.Data, "tone:"):
+					s.Tone = strings.TrimPrefix(in.Data, "tone:")
+					return stepNext
+				}
+				return stepIgnored
+			},
+			Skip: func(b *Bot, s *userState) bool {
+				if s.DefaultTone == "" {
+					return false
+				}
+				s.Tone = s.DefaultTone
+				return true
+			},
+			Next: StateWaitingForToneIntensity,
+			Back: StateWaitingForPlatform,
+		},
+
+		StateWaitingForCustomTone: {
+			Prompt: func(b *Bot, s *userState) string { return customTonePromptText },
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				if in.Message == nil {
+					return stepIgnored
+				}
+				if !b.takeCustomTone(userID, s, in.text()) {
+					return stepHandled
+				}
+				return stepNext
+			},
+			Next: StateWaitingForToneIntensity,
+			Back: StateWaitingForTone,
+		},
+
+		StateWaitingForToneIntensity: {
+			Prompt:   func(b *Bot, s *userState) string { return fmt.Sprintf("How strong should the **%s** tone be?", s.Tone) },
+			Keyboard: func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup { return intensityKeyboard },
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				intensity, ok := strings.CutPrefix(in.Data, "intensity:")
+				if !ok {
+					return stepIgnored
+				}
+				s.ToneIntensity = intensity
+				return stepNext
+			},
+			// A preset tone comes without an intensity question
+			Skip: func(b *Bot, s *userState) bool { return s.DefaultTone != "" && s.Tone == s.DefaultTone },
+			Next: StateWaitingForServices,
+			Back: StateWaitingForTone,
+		},
+
+		StateWaitingForServices: {
+			Prompt: func(b *Bot, s *userState) string {
+				return "Which **services** should I highlight? (Select all that apply, then 'Done')"
+			},
+			Keyboard: func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup {
+				return buildServicesKeyboard(s.brand(), s.Services)
+			},
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				switch {
+				case strings.HasPrefix(in.Data, "service:"):
+					s.Services = toggleSelection(s.Services, strings.TrimPrefix(in.Data, "service:"))
+					return stepAsk
+				case in.Data == "control:done_services":
+					return stepNext
+				}
+				return stepIgnored
+			},
+			// With REQUIRE_SERVICE_SELECTION on, "Done" needs at least one service
+			Notice: func(b *Bot, s *userState, data string) string {
+				if data == "control:done_services" && b.requireServiceSelection && len(s.Services) == 0 {
+					return "Please select at least one service"
+				}
+				return ""
+			},
+			Next: StateWaitingForLanguage,
+			Back: StateWaitingForToneIntensity,
+		},
+
+		StateWaitingForLanguage: {
+			Prompt:   func(b *Bot, s *userState) string { return languagePromptText },
+			Keyboard: func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup { return buildLanguageKeyboard(s.Language) },
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				if !chooseLanguage(s, in.Data) {
+					return stepIgnored
+				}
+				return stepNext
+			},
+			Next: StateWaitingForContext,
+			Back: StateWaitingForServices,
+		},
+
+		StateWaitingForContext: {
+			Prompt: func(b *Bot, s *userState) string {
+				return "Last step! Any **additional context**? (e.g., 'This is for our new sustainable line.')\n\nType your answer, tap a quick reply, or press 'Skip'."
+			},
+			Keyboard: func(b *Bot, s *userState) tgbotapi.InlineKeyboardMarkup { return buildContextKeyboard(s.brand()) },
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				switch {
+				case in.Message != nil:
+					s.Context = in.text()
+				case in.Data == "control:skip_context":
+					s.Context = "" // Explicitly set as empty
+				case strings.HasPrefix(in.Data, "context_preset:"):
+					// A quick reply behaves exactly like typing its text
+					i, err := strconv.Atoi(strings.TrimPrefix(in.Data, "context_preset:"))
+					presets := s.brand().ContextPresets
+					if err != nil || i < 0 || i >= len(presets) {
+						return stepIgnored
+					}
+					s.Context = presets[i].Text
+				default:
+					return stepIgnored
+				}
+				return stepNext
+			},
+			// Already described in a voice note before the photo arrived
+			Skip: func(b *Bot, s *userState) bool { return s.Context != "" },
+			Next: StateDefault,
+			Back: StateWaitingForLanguage,
+		},
+
+		// States entered outside the question flow
+		StateCollectingBatch: {
+			HandleInput: callbackStep("batch:", (*Bot).handleBatchCallback),
+		},
+		StateWaitingForDuplicateChoice: {
+			HandleInput: callbackStep("duplicate:", (*Bot).handleDuplicateChoice),
+		},
+		StateWaitingForQualityConfirm: {
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				if in.Message != nil {
+					return stepIgnored
+				}
+				b.removeInlineKeyboard(userID, s.MessageID)
+				if in.Data == "quality:proceed" {
+					b.startWithImage(userID, s, s.PhotoData, s.MimeType, "Okay, let's go! 📸")
+				} else if in.Data == "quality:cancel" {
+					b.resetState(userID)
+					b.sendMessage(userID, "No problem. Send a better photo whenever you're ready.", nil)
+				}
+				return stepHandled
+			},
+		},
+		StateWaitingForPDFPage: {
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				if in.Message != nil {
+					b.handlePDFPageReply(in.Message)
+					return stepHandled
+				}
+				page, ok := strings.CutPrefix(in.Data, "pdfpage:")
+				if !ok {
+					return stepIgnored
+				}
+				n, _ := strconv.Atoi(page)
+				b.useCallbackPDFPage(userID, s, n)
+				return stepHandled
+			},
+		},
+		StateWaitingForAttributes: {
+			HandleInput: func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+				if in.Message != nil {
+					b.handleAttributeEdits(in.Message, s)
+					return stepHandled
+				}
+				return callbackStep("attr:", (*Bot).handleAttributesCallback)(b, userID, s, in)
+			},
+		},
+		StateWaitingForScheduleTime: {
+			HandleInput: messageStep(func(b *Bot, message *tgbotapi.Message, s *userState) { b.handleScheduleTime(message) }),
+		},
+		StateRefining: {
+			HandleInput: messageStep((*Bot).handleRefinement),
+		},
+	}
+}
+
+// callbackStep handles the buttons whose data starts with prefix.
+func callbackStep(prefix string, handle func(b *Bot, userID int64, s *userState, data string)) func(*Bot, int64, *userState, stepInput) stepOutcome {
+	return func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+		if !strings.HasPrefix(in.Data, prefix) {
+			return stepIgnored
+		}
+		handle(b, userID, s, in.Data)
+		return stepHandled
+	}
+}
+
+// messageStep handles typed messages.
+func messageStep(handle func(b *Bot, message *tgbotapi.Message, s *userState)) func(*Bot, int64, *userState, stepInput) stepOutcome {
+	return func(b *Bot, userID int64, s *userState, in stepInput) stepOutcome {
+		if in.Message == nil {
+			return stepIgnored
+		}
+		handle(b, in.Message, s)
+		return stepHandled
+	}
+}
+
+// stepNotice returns a short refusal to show on the tapped button, or ""
+// if the tap should be handled normally.
+func (b *Bot) stepNotice(state *userState, data string) string {
+	if step, ok := b.steps[state.State]; ok && step.Notice != nil {
+		return step.Notice(b, state, data)
+	}
+	return ""
+}
+
+// handleStepInput passes a button tap or typed message to the current
+// step. It returns false if the step doesn't take it.
+func (b *Bot) handleStepInput(userID int64, state *userState, in stepInput) bool {
+	step, ok := b.steps[state.State]
+	if !ok || step.HandleInput == nil {
+		return false
+	}
+	// After a typed answer the question goes below it, in a new message
+	typed := in.Message != nil
+	switch step.HandleInput(b, userID, state, in) {
+	case stepIgnored:
+		return false
+	case stepAsk:
+		b.showStep(userID, state, state.State, "", typed)
+	case stepNext:
+		note := ""
+		if step.Note != nil {
+			note = step.Note(b, state)
+		}
+		b.askStep(userID, state, step.Next, note, typed)
+	}
+	return true
+}
+
+// firstStep returns st, or the first step after it that isn't skipped.
+func (b *Bot) firstStep(state *userState, st ConversationState) ConversationState {
+	for st != StateDefault {
+		step := b.steps[st]
+		if step == nil || step.Skip == nil || !step.Skip(b, state) {
+			return st
+		}
+		st = step.Next
+	}
+	return st
+}
+
+// askStep moves the conversation on to st (see firstStep) and asks it.
+// Past the last step, the job is generated.
+func (b *Bot) askStep(userID int64, state *userState, st ConversationState, note string, asNew bool) {
+	st = b.firstStep(state, st)
+	if st == StateDefault {
+		state.State = StateDefault
+		b.removeInlineKeyboard(userID, state.MessageID)
+		b.generateContent(userID)
+		return
+	}
+	b.showStep(userID, state, st, note, asNew)
+}
+
+// showStep asks a step's question with note above it, editing the
+// conversation's message, or in a new message if asNew.
+func (b *Bot) showStep(userID int64, state *userState, st ConversationState, note string, asNew bool) {
+	step := b.steps[st]
+	state.State = st
+	text := note + step.Prompt(b, state)
+	markup := backKeyboard
+	if step.Keyboard != nil {
+		markup = step.Keyboard(b, state)
+	}
+	if asNew {
+		state.MessageID = b.sendMessageID(userID, text, markup)
+		return
+	}
+	b.editMessage(userID, text, markup)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestStepInput(t *testing.T) {
+	typed := func(text string) stepInput {
+		return stepInput{Message: &tgbotapi.Message{From: testUser(1), Chat: testChat(1), Text: text}}
+	}
+	tapped := func(data string) stepInput { return stepInput{Data: data} }
+
+	tests := []struct {
+		name    string
+		state   userState
+		in      stepInput
+		handled bool
+		want    ConversationState
+		check   func(t *testing.T, s *userState)
+	}{
+		{
+			name:  "platform toggled on",
+			state: userState{State: StateWaitingForPlatform},
+			in:    tapped("platform:Instagram"), handled: true, want: StateWaitingForPlatform,
+			check: func(t *testing.T, s *userState) { wantStrings(t, s.Platforms, "Instagram") },
+		},
+		{
+			name:  "platform toggled off",
+			state: userState{State: StateWaitingForPlatform, Platforms: []string{"Instagram", "X"}},
+			in:    tapped("platform:Instagram"), handled: true, want: StateWaitingForPlatform,
+			check: func(t *testing.T, s *userState) { wantStrings(t, s.Platforms, "X") },
+		},
+		{
+			name:  "platforms done",
+			state: userState{State: StateWaitingForPlatform, Platforms: []string{"X"}},
+			in:    tapped("control:done_platforms"), handled: true, want: StateWaitingForTone,
+		},
+		{
+			name:  "all platforms",
+			state: userState{State: StateWaitingForPlatform},
+			in:    tapped("control:all_platforms"), handled: true, want: StateWaitingForTone,
+			check: func(t *testing.T, s *userState) { wantStrings(t, s.Platforms, platformOrder...) },
+		},
+		{
+			name:  "next skips a preset tone and its intensity",
+			state: userState{State: StateWaitingForPlatform, Platforms: []string{"X"}, DefaultTone: "Luxury"},
+			in:    tapped("control:done_platforms"), handled: true, want: StateWaitingForServices,
+			check: func(t *testing.T, s *userState) { wantString(t, "tone", s.Tone, "Luxury") },
+		},
+		{
+			name:  "tone picked",
+			state: userState{State: StateWaitingForTone},
+			in:    tapped("tone:Luxury"), handled: true, want: StateWaitingForToneIntensity,
+			check: func(t *testing.T, s *userState) { wantString(t, "tone", s.Tone, "Luxury") },
+		},
+		{
+			name:  "custom tone asked",
+			state: userState{State: StateWaitingForTone},
+			in:    tapped("control:custom_tone"), handled: true, want: StateWaitingForCustomTone,
+		},
+		{
+			name:  "custom tone typed",
+			state: userState{State: StateWaitingForCustomTone},
+			in:    typed("  warm   and   witty "), handled: true, want: StateWaitingForToneIntensity,
+			check: func(t *testing.T, s *userState) { wantString(t, "tone", s.Tone, "warm and witty") },
+		},
+		{
+			name:  "custom tone ignores buttons",
+			state: userState{State: StateWaitingForCustomTone},
+			in:    tapped("tone:Luxury"), handled: false, want: StateWaitingForCustomTone,
+		},
+		{
+			name:  "intensity picked",
+			state: userState{State: StateWaitingForToneIntensity, Tone: "Luxury"},
+			in:    tapped("intensity:Strong"), handled: true, want: StateWaitingForServices,
+			check: func(t *testing.T, s *userState) { wantString(t, "intensity", s.ToneIntensity, "Strong") },
+		},
+		{
+			name:  "service toggled",
+			state: userState{State: StateWaitingForServices},
+			in:    tapped("service:OEM"), handled: true, want: StateWaitingForServices,
+			check: func(t *testing.T, s *userState) { wantStrings(t, s.Services, "OEM") },
+		},
+		{
+			name:  "services done",
+			state: userState{State: StateWaitingForServices},
+			in:    tapped("control:done_services"), handled: true, want: StateWaitingForLanguage,
+		},
+		{
+			name:  "language picked",
+			state: userState{State: StateWaitingForLanguage},
+			in:    tapped("language:bn"), handled: true, want: StateWaitingForContext,
+			check: func(t *testing.T, s *userState) { wantString(t, "language", s.Language, "bn") },
+		},
+		{
+			name:  "unknown language ignored",
+			state: userState{State: StateWaitingForLanguage},
+			in:    tapped("language:xx"), handled: false, want: StateWaitingForLanguage,
+		},
+		{
+			name:  "next skips known context and generates",
+			state: userState{State: StateWaitingForLanguage, Context: "From a voice note", PhotoData: []byte("photo"), MimeType: "image/jpeg"},
+			in:    tapped("language:en"), handled: true, want: StateDefault,
+		},
+		{
+			name:  "context skipped",
+			state: userState{State: StateWaitingForContext, PhotoData: []byte("photo"), MimeType: "image/jpeg"},
+			in:    tapped("control:skip_context"), handled: true, want: StateDefault,
+		},
+		{
+			name:  "unknown quick reply ignored",
+			state: userState{State: StateWaitingForContext},
+			in:    tapped("context_preset:99"), handled: false, want: StateWaitingForContext,
+		},
+		{
+			name:  "button from another step ignored",
+			state: userState{State: StateWaitingForTone},
+			in:    tapped("platform:X"), handled: false, want: StateWaitingForTone,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			b.maxPlatforms = len(platformOrder)
+			b.queue = newFairQueue(0) // Never started: a generation stays queued
+			userID := int64(500 + i)
+			state := b.getState(userID)
+			*state = tt.state
+
+			if got := b.handleStepInput(userID, state, tt.in); got != tt.handled {
+				t.Errorf("handled = %v, want %v", got, tt.handled)
+			}
+			// Generating hands the job to the queue and starts a new conversation
+			state = b.getState(userID)
+			if state.State != tt.want {
+				t.Errorf("state = %v, want %v", state.State, tt.want)
+			}
+			if tt.want == StateDefault && !b.hasActiveJob(userID) {
+				t.Error("answering the last question queued no generation")
+			}
+			if tt.check != nil {
+				tt.check(t, state)
+			}
+		})
+	}
+}
+
+func TestFirstStepSkipsPresetAnswers(t *testing.T) {
+	b, _ := newTestBot(t)
+	tests := []struct {
+		name  string
+		state userState
+		want  ConversationState
+	}{
+		{"nothing preset", userState{}, StateWaitingForPlatform},
+		{"preset platforms", userState{DefaultPlatforms: []string{"X"}}, StateWaitingForTone},
+		{"preset platforms and tone", userState{DefaultPlatforms: []string{"X"}, DefaultTone: "Luxury"}, StateWaitingForServices},
+		{"preset tone only", userState{DefaultTone: "Luxury"}, StateWaitingForPlatform},
+	}
+	for _, tt := range tests {
+		state := tt.state
+		if got := b.firstStep(&state, StateWaitingForPlatform); got != tt.want {
+			t.Errorf("%s: firstStep = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Context known from a voice note: the last question is skipped too
+	state := userState{Context: "Spoken context"}
+	if got := b.firstStep(&state, StateWaitingForContext); got != StateDefault {
+		t.Errorf("firstStep with the context known = %v, want generation", got)
+	}
+}
+
+func TestBackKeepsAnswers(t *testing.T) {
+	tests := []struct {
+		name  string
+		state userState
+		want  ConversationState
+	}{
+		{"tone back to platforms", userState{State: StateWaitingForTone, Platforms: []string{"X"}}, StateWaitingForPlatform},
+		{"custom tone back to tones", userState{State: StateWaitingForCustomTone}, StateWaitingForTone},
+		{"services back to intensity", userState{State: StateWaitingForServices, Tone: "Luxury"}, StateWaitingForToneIntensity},
+		// Skipped on the way forward, but asked on the way back
+		{"preset intensity asked going back", userState{State: StateWaitingForServices, Tone: "Luxury", DefaultTone: "Luxury"}, StateWaitingForToneIntensity},
+		{"context back to language", userState{State: StateWaitingForContext}, StateWaitingForLanguage},
+		{"nowhere before platforms", userState{State: StateWaitingForPlatform}, StateWaitingForPlatform},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			userID := int64(600 + i)
+			*b.getState(userID) = tt.state
+
+			b.handleCallbackQuery(callbackQuery(userID, "control:back"))
+			state := b.getState(userID)
+			if state.State != tt.want {
+				t.Errorf("state = %v, want %v", state.State, tt.want)
+			}
+			if !slices.Equal(state.Platforms, tt.state.Platforms) || state.Tone != tt.state.Tone {
+				t.Error("going back lost an answer")
+			}
+		})
+	}
+}
+
+func TestStepNotice(t *testing.T) {
+	tests := []struct {
+		name           string
+		requireService bool
+		state          userState
+		data           string
+		want           string
+	}{
+		{"no platform picked", false, userState{State: StateWaitingForPlatform}, "control:done_platforms", "Please select at least one platform"},
+		{"platform picked", false, userState{State: StateWaitingForPlatform, Platforms: []string{"X"}}, "control:done_platforms", ""},
+		{"past the platform limit", false, userState{State: StateWaitingForPlatform, Platforms: []string{"X", "LinkedIn"}}, "platform:Instagram", "You can pick up to 2 platforms"},
+		{"unpicking at the limit", false, userState{State: StateWaitingForPlatform, Platforms: []string{"X", "LinkedIn"}}, "platform:X", ""},
+		{"services optional", false, userState{State: StateWaitingForServices}, "control:done_services", ""},
+		{"services required", true, userState{State: StateWaitingForServices}, "control:done_services", "Please select at least one service"},
+		{"service picked", true, userState{State: StateWaitingForServices, Services: []string{"OEM"}}, "control:done_services", ""},
+		{"step without notices", false, userState{State: StateWaitingForTone}, "tone:Luxury", ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.maxPlatforms = 2
+			b.requireServiceSelection = tt.requireService
+			userID := int64(700 + i)
+			*b.getState(userID) = tt.state
+
+			b.handleCallbackQuery(callbackQuery(userID, tt.data))
+			answers := fake.Calls("answerCallbackQuery")
+			if len(answers) == 0 {
+				t.Fatal("the tap wasn't answered")
+			}
+			if got := answers[0].Params.Get("text"); got != tt.want {
+				t.Errorf("notice = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && b.getState(userID).State != tt.state.State {
+				t.Error("a refused tap moved the conversation on")
+			}
+		})
+	}
+}
+
+func wantStrings(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func wantString(t *testing.T, what, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %q, want %q", what, got, want)
+	}
+}
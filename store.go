@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// --- Persistent Store ---
+
+// Store holds the bot's long-lived data (usage stats, etc.) and persists it
+// to a single JSON file. It is small enough that we simply rewrite the whole
+// file after every change.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	data storeData
+}
+
+// storeData is the on-disk layout of the store file.
+type storeData struct {
+	// Usage maps a day ("2006-01-02") to the per-user token totals for that day.
+	Usage map[string]map[int64]*UsageRecord `json:"usage"`
+
+	// BudgetAlerts maps a month ("2006-01") to the highest budget alert
+	// level (percent) sent in it.
+	BudgetAlerts map[string]int `json:"budgetAlerts,omitempty"`
+
+	// Scheduled holds results waiting to be delivered back to users.
+	Scheduled      []ScheduledDelivery `json:"scheduled"`
+	NextScheduleID int                 `json:"nextScheduleId"`
+
+	// LastPhotos tracks each user's most recent photo for /same.
+	LastPhotos map[int64]LastPhoto `json:"lastPhotos"`
+
+	// UserBrands maps a user to their active brand preset name.
+	UserBrands map[int64]string `json:"userBrands"`
+
+	// CustomBrands holds the brands users set up with "/brand set".
+	CustomBrands map[int64]*BrandConfig `json:"customBrands"`
+
+	// Settings holds each user's /settings choices.
+	Settings map[int64]*UserSettings `json:"settings"`
+
+	// RecentResults remembers recent results by photo hash, newest last.
+	RecentResults map[int64][]RecentResult `json:"recentResults"`
+
+	// History holds each user's past results for /history, newest last.
+	History map[int64][]HistoryEntry `json:"history"`
+
+	// ResultMessages maps each user's caption messages to the caption shown,
+	// so reactions can be tied back to it.
+	ResultMessages map[int64]map[int]captionRef `json:"resultMessages"`
+
+	// Ratings holds users' verdicts on individual captions.
+	Ratings []Rating `json:"ratings"`
+
+	// Channels maps a user to the channel their captions are posted to.
+	Channels map[int64]LinkedChannel `json:"channels"`
+
+	// InactiveChats are chats that blocked the bot, with when we noticed.
+	InactiveChats map[int64]time.Time `json:"inactiveChats"`
+
+	// KnownUsers maps everyone who has written to the bot to when they were
+	// last seen (to the day), for /stats and /broadcast.
+	KnownUsers map[int64]time.Time `json:"knownUsers"`
+
+	// Banned maps users the bot ignores to when they were banned.
+	Banned map[int64]time.Time `json:"banned"`
+
+	// ChatMigrations maps group chats to the supergroup they moved to.
+	ChatMigrations map[int64]int64 `json:"chatMigrations"`
+
+	// Services is the default brand's services catalog as edited with
+	// /addservice and /removeservice; empty until first edited.
+	Services []ServiceOption `json:"services,omitempty"`
+
+	// ModelOverrides maps a task to the models an admin switched it to with /model.
+	ModelOverrides map[string][]string `json:"modelOverrides,omitempty"`
+
+	// Allowed maps users given access with an invite or /allow to when.
+	Allowed map[int64]time.Time `json:"allowed"`
+
+	// GroupSessions maps the session keys of group members to their chat.
+	GroupSessions map[int64]GroupSession `json:"groupSessions"`
+
+	// Invites holds the unused invite codes.
+	Invites map[string]Invite `json:"invites"`
+}
+
+// NewStore opens (or creates) the store file at path.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading store file: %w", err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("error parsing store file: %w", err)
+		}
+	}
+
+	if s.data.Usage == nil {
+		s.data.Usage = make(map[string]map[int64]*UsageRecord)
+	}
+	if s.data.BudgetAlerts == nil {
+		s.data.BudgetAlerts = make(map[string]int)
+	}
+	if s.data.LastPhotos == nil {
+		s.data.LastPhotos = make(map[int64]LastPhoto)
+	}
+	if s.data.UserBrands == nil {
+		s.data.UserBrands = make(map[int64]string)
+	}
+	if s.data.CustomBrands == nil {
+		s.data.CustomBrands = make(map[int64]*BrandConfig)
+	}
+	if s.data.Settings == nil {
+		s.data.Settings = make(map[int64]*UserSettings)
+	}
+	if s.data.RecentResults == nil {
+		s.data.RecentResults = make(map[int64][]RecentResult)
+	}
+	if s.data.History == nil {
+		s.data.History = make(map[int64][]HistoryEntry)
+	}
+	if s.data.ResultMessages == nil {
+		s.data.ResultMessages = make(map[int64]map[int]captionRef)
+	}
+	if s.data.Channels == nil {
+		s.data.Channels = make(map[int64]LinkedChannel)
+	}
+	if s.data.InactiveChats == nil {
+		s.data.InactiveChats = make(map[int64]time.Time)
+	}
+	if s.data.KnownUsers == nil {
+		s.data.KnownUsers = make(map[int64]time.Time)
+	}
+	if s.data.Banned == nil {
+		s.data.Banned = make(map[int64]time.Time)
+	}
+	if s.data.ChatMigrations == nil {
+		s.data.ChatMigrations = make(map[int64]int64)
+	}
+	if s.data.Allowed == nil {
+		s.data.Allowed = make(map[int64]time.Time)
+	}
+	if s.data.GroupSessions == nil {
+		s.data.GroupSessions = make(map[int64]GroupSession)
+	}
+	if s.data.Invites == nil {
+		s.data.Invites = make(map[string]Invite)
+	}
+	return s, nil
+}
+
+// save writes the store to disk. The caller must hold s.mu.
+// We write to a temp file first so a crash never leaves a half-written store.
+func (s *Store) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling store: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
+		return fmt.Errorf("error writing store file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("error replacing store file: %w", err)
+	}
+	return nil
+}
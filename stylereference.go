@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Reference Caption (/style) ---
+
+// maxStyleReferenceLength caps a pasted reference caption, in characters.
+const maxStyleReferenceLength = 1500
+
+// handleStyleCommand sets, shows or clears the reference caption for the
+// next post. It leaves the conversation where it was, so it can be sent
+// before the photo or at any step before generating.
+func (b *Bot) handleStyleCommand(chatID int64, state *userState, arg string) {
+	arg = strings.TrimSpace(arg)
+	switch {
+	case arg == "" && state.StyleReference == "":
+		b.sendMessage(chatID, "Paste one of your past posts after the command, e.g. `/style <your caption>`, and I'll match its voice and structure for the next post.", nil)
+	case arg == "":
+		b.sendMessage(chatID, fmt.Sprintf("✍️ Matching this reference for the next post:\n\n%s\n\nSend `/style clear` to drop it.", state.StyleReference), nil)
+	case strings.EqualFold(arg, "clear"):
+		state.StyleReference = ""
+		b.sendMessage(chatID, "Reference caption cleared. I'll use the usual style.", nil)
+	case utf8.RuneCountInString(arg) > maxStyleReferenceLength:
+		b.sendMessage(chatID, fmt.Sprintf("That reference is too long. Please paste a caption under %d characters.", maxStyleReferenceLength), nil)
+	default:
+		state.StyleReference = arg
+		b.sendMessage(chatID, "✍️ Got it! I'll match that caption's voice and structure for your next post.", nil)
+	}
+}
+
+// styleReferenceSection asks the model to write like the user's reference.
+func styleReferenceSection(reference string) string {
+	if reference == "" {
+		return ""
+	}
+	return "\n**Reference Caption (the client's own past post):**\n---\n" + reference + "\n---\n" +
+		"- Closely match the reference's voice, structure, length and emoji use. It takes priority over the gold-standard example for style, but write new content about this product.\n"
+}
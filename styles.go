@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Caption Style Labels ---
+
+// parseCaptionStyles parses a comma-separated style list (CAPTION_STYLES).
+func parseCaptionStyles(raw string) ([]string, error) {
+	var styles []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			styles = append(styles, s)
+		}
+	}
+	if len(styles) != 0 && len(styles) != 3 {
+		return nil, fmt.Errorf("need exactly 3 styles, one per caption (got %d)", len(styles))
+	}
+	return styles, nil
+}
+
+// captionStyleInstruction tells the model how to fill the style fields.
+// captionStyles (CAPTION_STYLES), if set, fixes the style of each caption in
+// order (caption1 gets the first style, and so on); otherwise the model
+// picks and labels a style for each caption itself.
+func captionStyleInstruction(captionStyles []string) string {
+	if len(captionStyles) == 3 {
+		return fmt.Sprintf("\n- Write caption1 as a %s caption, caption2 as %s and caption3 as %s, and set style1, style2 and style3 to those labels.",
+			captionStyles[0], captionStyles[1], captionStyles[2])
+	}
+	return "\n- Give each caption a different approach, and label it in style1, style2 and style3 with a short 1-3 word name (e.g. \"Hook-led\", \"Benefit-led\", \"Story-led\")."
+}
+
+// captionStylesFor returns the style labels for a response, preferring the
+// configured styles, then the model's labels. Missing labels are "".
+func captionStylesFor(resp APIJSONResponse, captionStyles []string) []string {
+	if len(captionStyles) == 3 {
+		return append([]string{}, captionStyles...)
+	}
+	styles := []string{strings.TrimSpace(resp.Style1), strings.TrimSpace(resp.Style2), strings.TrimSpace(resp.Style3)}
+	if styles[0] == "" && styles[1] == "" && styles[2] == "" {
+		return nil
+	}
+	return styles
+}
+
+// optionLabel names caption i, e.g. "Option 1 · Hook-led", or just
+// "Option 1" when it has no style label.
+func (p PlatformContent) optionLabel(i int) string {
+	if i < len(p.Styles) && p.Styles[i] != "" {
+		return fmt.Sprintf("Option %d · %s", i+1, p.Styles[i])
+	}
+	return fmt.Sprintf("Option %d", i+1)
+}
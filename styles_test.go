@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCaptionSchemaMarshalling(t *testing.T) {
+	raw, err := json.Marshal(GenerationConfig{ResponseMimeType: "application/json", ResponseSchema: schemaForCaptions})
+	if err != nil {
+		t.Fatalf("marshalling the schema: %v", err)
+	}
+	var got struct {
+		ResponseSchema struct {
+			Type       string                     `json:"type"`
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"responseSchema"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("unmarshalling %s: %v", raw, err)
+	}
+	schema := got.ResponseSchema
+	for _, field := range []string{"style1", "style2", "style3"} {
+		if string(schema.Properties[field]) != `{"type":"STRING"}` {
+			t.Errorf("%s = %s, want a plain string property", field, schema.Properties[field])
+		}
+		if slices.Contains(schema.Required, field) {
+			t.Errorf("%s is required; older replies without it must still parse", field)
+		}
+	}
+	if string(schema.Properties["hashtags"]) != `{"type":"ARRAY","items":{"type":"STRING"}}` {
+		t.Errorf("hashtags = %s, want an array of strings", schema.Properties["hashtags"])
+	}
+}
+
+func TestCaptionStyleLabels(t *testing.T) {
+	labelled := APIJSONResponse{Style1: " Hook-led ", Style2: "Benefit-led", Style3: ""}
+	tests := []struct {
+		name       string
+		resp       APIJSONResponse
+		configured []string
+		want       []string
+	}{
+		{"model's labels", labelled, nil, []string{"Hook-led", "Benefit-led", ""}},
+		{"configured styles win", labelled, []string{"Short", "Story", "List"}, []string{"Short", "Story", "List"}},
+		{"no labels", APIJSONResponse{}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			styles := captionStylesFor(tt.resp, tt.configured)
+			if !slices.Equal(styles, tt.want) {
+				t.Fatalf("captionStylesFor = %q, want %q", styles, tt.want)
+			}
+			result := PlatformContent{Captions: []string{"a", "b", "c"}, Styles: styles}
+			for i, want := range tt.want {
+				label := fmt.Sprintf("Option %d", i+1)
+				if want != "" {
+					label += " · " + want
+				}
+				if got := result.optionLabel(i); got != label {
+					t.Errorf("optionLabel(%d) = %q, want %q", i, got, label)
+				}
+			}
+			if tt.want == nil && result.optionLabel(0) != "Option 1" {
+				t.Errorf("optionLabel(0) = %q, want %q", result.optionLabel(0), "Option 1")
+			}
+		})
+	}
+}
+
+func TestCaptionStylesInResults(t *testing.T) {
+	b, fake := newTestBot(t)
+	b.llm, _ = fakeGemini(t, []string{"model"}, func(string) (int, string) {
+		return http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"{\"caption1\":\"First caption\",\"caption2\":\"Second caption\",\"caption3\":\"Third caption\",` +
+			`\"style1\":\"Hook-led\",\"style2\":\"Benefit-led\",\"style3\":\"Story-led\",\"hashtags\":[\"#denim\"]}"}]}}]}`
+	})
+	b.queue = newFairQueue(0)
+	b.queue.start(1)
+	const userID = 1126
+
+	queueGeneration(t, b, userID)
+	flushQueue(b.queue)
+
+	texts := fake.Texts(userID)
+	for _, label := range []string{"Option 1 · Hook\\-led", "Option 2 · Benefit\\-led", "Option 3 · Story\\-led"} {
+		if len(messagesWith(texts, label)) != 1 {
+			t.Errorf("no message labelled %q in %q", label, texts)
+		}
+	}
+	if prompt := buildCaptionSystemPrompt(defaultBrand, "Instagram", "Luxury", "", nil, "None provided.", nil, nil); !strings.Contains(prompt, "label it in style1, style2 and style3") {
+		t.Error("the prompt doesn't ask the model to label its styles")
+	}
+}
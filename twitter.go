@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- X (Twitter) ---
+
+const (
+	xTweetsURL      = "https://api.x.com/2/tweets"
+	xMediaUploadURL = "https://api.x.com/2/media/upload"
+	// xThreadNumberSpace is kept free in every tweet of a thread for its
+	// " 12/15" number.
+	xThreadNumberSpace = 6
+)
+
+// tweetLength counts text the way X does: most characters count once,
+// while CJK, emoji and other characters outside the common ranges count
+// twice.
+func tweetLength(text string) int {
+	n := 0
+	for _, r := range text {
+		switch {
+		case r <= 0x10FF, r >= 0x2000 && r <= 0x200D, r >= 0x2010 && r <= 0x201F, r >= 0x2032 && r <= 0x2037:
+			n++
+		default:
+			n += 2
+		}
+	}
+	return n
+}
+
+// splitThread splits a caption into tweets of at most limit, breaking
+// between lines or words, and numbers them if there is more than one.
+// The hashtags go on the last tweet, or one of their own if they don't fit.
+func splitThread(caption string, hashtags []string, limit int) []string {
+	tags := strings.Join(hashtags, " ")
+	single := strings.TrimSpace(caption)
+	if tags != "" {
+		single += "\n\n" + tags
+	}
+	if tweetLength(single) <= limit {
+		return []string{strings.TrimSpace(single)}
+	}
+
+	room := limit - xThreadNumberSpace
+	var tweets []string
+	var current string
+	flush := func() {
+		if current != "" {
+			tweets = append(tweets, current)
+			current = ""
+		}
+	}
+	add := func(sep, piece string) {
+		if current != "" && tweetLength(current+sep+piece) <= room {
+			current += sep + piece
+			return
+		}
+		flush()
+		for tweetLength(piece) > room { // A "word" longer than a tweet, e.g. a URL
+			cut := cutToLength(piece, room)
+			tweets = append(tweets, cut)
+			piece = piece[len(cut):]
+		}
+		current = piece
+	}
+	lineBreak := "\n"
+	for _, line := range strings.Split(caption, "\n") {
+		if strings.TrimSpace(line) == "" {
+			lineBreak = "\n\n"
+			continue
+		}
+		sep := lineBreak
+		for _, word := range strings.Fields(line) {
+			add(sep, word)
+			sep = " "
+		}
+		lineBreak = "\n"
+	}
+	if tags != "" {
+		if current != "" && tweetLength(current+"\n\n"+tags) <= room {
+			current += "\n\n" + tags
+		} else {
+			flush()
+			current = cutToLength(tags, room)
+		}
+	}
+	flush()
+
+	if len(tweets) > 1 {
+		for i := range tweets {
+			tweets[i] += fmt.Sprintf(" %d/%d", i+1, len(tweets))
+		}
+	}
+	return tweets
+}
+
+// cutToLength returns the longest prefix of text within limit by tweetLength.
+func cutToLength(text string, limit int) string {
+	n := 0
+	for i, r := range text {
+		w := tweetLength(string(r))
+		if n+w > limit {
+			return text[:i]
+		}
+		n += w
+	}
+	return text
+}
+
+// xPublisher posts photos to an X account, as a thread if the caption
+// doesn't fit one tweet. It signs requests with OAuth 1.0a user context
+// (X_API_KEY, X_API_SECRET, X_ACCESS_TOKEN, X_ACCESS_TOKEN_SECRET).
+type xPublisher struct {
+	creds  XConfig
+	client *http.Client
+}
+
+// publish implements publisher: it uploads the photo, posts the first
+// tweet with it and replies to each tweet with the next. The URL is the
+// first tweet's.
+func (p *xPublisher) publish(ctx context.Context, req publishRequest) (string, error) {
+	mediaID, err := p.uploadMedia(ctx, req.Photo, req.MimeType)
+	if err != nil {
+		return "", err
+	}
+
+	var first, previous string
+	tweets := splitThread(req.Caption, req.Hashtags, platformCharLimits["X"])
+	for i, text := range tweets {
+		tweet := map[string]any{"text": text}
+		if i == 0 {
+			tweet["media"] = map[string][]string{"media_ids": {mediaID}}
+		} else {
+			tweet["reply"] = map[string]string{"in_reply_to_tweet_id": previous}
+		}
+		id, err := p.postTweet(ctx, tweet)
+		if err != nil {
+			if first != "" {
+				return "", fmt.Errorf("only %d of %d tweets were posted (https://x.com/i/web/status/%s): %w", i, len(tweets), first, err)
+			}
+			return "", err
+		}
+		if first == "" {
+			first = id
+		}
+		previous = id
+	}
+	return "https://x.com/i/web/status/" + first, nil
+}
+
+// uploadMedia uploads a photo and returns its media ID.
+func (p *xPublisher) uploadMedia(ctx context.Context, photo []byte, mimeType string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("media_category", "tweet_image")
+	part, err := form.CreateFormFile("media", photoFileName(mimeType))
+	if err != nil {
+		return "", fmt.Errorf("error building X upload: %w", err)
+	}
+	part.Write(photo)
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("error building X upload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", xMediaUploadURL, &body)
+	if err != nil {
+		return "", fmt.Errorf("error creating X upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	var uploaded struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := p.do(req, &uploaded); err != nil {
+		return "", fmt.Errorf("error uploading image to X: %w", err)
+	}
+	if uploaded.Data.ID == "" {
+		return "", errors.New("X returned no media ID")
+	}
+	return uploaded.Data.ID, nil
+}
+
+// postTweet creates a tweet and returns its ID.
+func (p *xPublisher) postTweet(ctx context.Context, tweet map[string]any) (string, error) {
+	raw, err := json.Marshal(tweet)
+	if err != nil {
+		return "", fmt.Errorf("error encoding tweet: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", xTweetsURL, bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("error creating X request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := p.do(req, &created); err != nil {
+		return "", fmt.Errorf("error posting tweet: %w", err)
+	}
+	return created.Data.ID, nil
+}
+
+// xError is the problem body X returns for failed requests.
+type xError struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// do signs and sends a request, decoding its JSON response into out.
+func (p *xPublisher) do(req *http.Request, out any) error {
+	req.Header.Set("Authorization", p.authorization(req.Method, req.URL))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling X: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading X response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var xErr xError
+		if json.Unmarshal(raw, &xErr) == nil {
+			switch {
+			case xErr.Detail != "":
+				return fmt.Errorf("X error %d: %s", resp.StatusCode, xErr.Detail)
+			case len(xErr.Errors) > 0 && xErr.Errors[0].Message != "":
+				return fmt.Errorf("X error %d: %s", resp.StatusCode, xErr.Errors[0].Message)
+			}
+		}
+		return fmt.Errorf("X request failed with status %d: %s", resp.StatusCode, raw)
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("error parsing X response: %w", err)
+	}
+	return nil
+}
+
+// authorization is the OAuth 1.0a header for a request. JSON and
+// multipart bodies aren't part of the signature, so only the URL's query
+// parameters are.
+func (p *xPublisher) authorization(method string, target *url.URL) string {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	oauth := map[string]string{
+		"oauth_consumer_key":     p.creds.APIKey,
+		"oauth_nonce":            hex.EncodeToString(nonce[:]),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_token":            p.creds.AccessToken,
+		"oauth_version":          "1.0",
+	}
+
+	var params []string
+	for key, value := range oauth {
+		params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+	}
+	for key, values := range target.Query() {
+		for _, value := range values {
+			params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+		}
+	}
+	sort.Strings(params)
+	base := *target
+	base.RawQuery, base.Fragment = "", ""
+	signatureBase := method + "&" + oauthEscape(base.String()) + "&" + oauthEscape(strings.Join(params, "&"))
+
+	mac := hmac.New(sha1.New, []byte(oauthEscape(p.creds.APISecret)+"&"+oauthEscape(p.creds.AccessTokenSecret)))
+	mac.Write([]byte(signatureBase))
+	oauth["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	var header []string
+	for key, value := range oauth {
+		header = append(header, fmt.Sprintf("%s=%q", oauthEscape(key), oauthEscape(value)))
+	}
+	sort.Strings(header)
+	return "OAuth " + strings.Join(header, ", ")
+}
+
+// oauthEscape percent-encodes as OAuth 1.0a requires (RFC 3986).
+func oauthEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Typing Indicator ---
+
+// chatActionInterval re-sends a chat action before Telegram stops showing
+// it (after about 5 seconds).
+const chatActionInterval = 4 * time.Second
+
+// keepChatAction shows action (e.g. "typing…") at the top of the chat until
+// ctx is done. Run it in its own goroutine.
+func (b *Bot) keepChatAction(ctx context.Context, chatID int64, action string) {
+	ticker := time.NewTicker(chatActionInterval)
+	defer ticker.Stop()
+	for {
+		b.sendChatAction(chatID, action)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendChatAction sends one chat action. Telegram answers these with true
+// rather than a message, so it goes through Request instead of send.
+func (b *Bot) sendChatAction(chatID int64, action string) {
+	if session, ok := b.store.GroupSession(chatID); ok {
+		chatID = session.ChatID
+	}
+	chatID = b.store.CurrentChatID(chatID)
+	if b.dryRun || b.store.IsChatInactive(chatID) {
+		return
+	}
+	if _, err := b.api.Request(tgbotapi.NewChatAction(chatID, action)); err != nil {
+		slog.Debug("Could not send chat action", "chat_id", chatID, "action", action, "error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Update Polling ---
+
+// allowedUpdates are the update types we ask Telegram for. Reactions are
+// only delivered when requested explicitly.
+var allowedUpdates = []string{"message", "callback_query", "message_reaction", "inline_query"}
+
+// botUpdate is a Telegram update plus the fields the library doesn't decode yet.
+type botUpdate struct {
+	tgbotapi.Update
+	MessageReaction *messageReactionUpdated `json:"message_reaction"`
+}
+
+// messageReactionUpdated is a change to a user's reactions on one message.
+type messageReactionUpdated struct {
+	Chat        tgbotapi.Chat   `json:"chat"`
+	MessageID   int             `json:"message_id"`
+	User        *tgbotapi.User  `json:"user"` // Nil for anonymous reactions
+	Date        int             `json:"date"`
+	OldReaction []reactionEmoji `json:"old_reaction"`
+	NewReaction []reactionEmoji `json:"new_reaction"`
+}
+
+// reactionEmoji is one reaction; custom emoji reactions leave Emoji empty.
+type reactionEmoji struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji"`
+}
+
+// pollUpdates long-polls getUpdates like tgbotapi's GetUpdatesChan, but
+// decodes into botUpdate so reaction updates aren't dropped. Once ctx is
+// done it stops and closes the channel; updates fetched but not yet handed
+// over are never confirmed, so Telegram delivers them again after a restart.
+// The channel is unbuffered, so every update handed over has a receiver.
+func pollUpdates(ctx context.Context, api *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) <-chan botUpdate {
+	ch := make(chan botUpdate)
+	config.AllowedUpdates = allowedUpdates
+
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			resp, err := api.Request(config)
+			var updates []botUpdate
+			if err == nil {
+				err = json.Unmarshal(resp.Result, &updates)
+			}
+			if err != nil {
+				log.Printf("Failed to get updates, retrying in 3 seconds: %v", err)
+				select {
+				case <-time.After(3 * time.Second):
+				case <-ctx.Done():
+				}
+				continue
+			}
+
+			for _, update := range updates {
+				if update.UpdateID < config.Offset {
+					continue
+				}
+				select {
+				case ch <- update:
+					config.Offset = update.UpdateID + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// processUpdate routes one update to its handler. It is the single entry
+// point for updates, so anything that can produce a botUpdate (the poller,
+// or a harness feeding synthetic updates) drives the same code paths.
+// The user's session lock is held throughout, so a queue worker delivering
+// results doesn't change the conversation underneath the handler.
+func (b *Bot) processUpdate(update botUpdate) {
+	if !b.routeGroupUpdate(&update) {
+		return
+	}
+	key := updateKey(update)
+	if key != 0 {
+		defer b.sessions.lock(key)()
+	}
+	if b.accessGate(update) {
+		return
+	}
+
+	if key != 0 {
+		b.userLogger(key, updateChatID(update)).Debug("Handling update", "update_id", update.UpdateID)
+	}
+	// Save the conversation the update moved on, so it survives a restart
+	if update.CallbackQuery != nil {
+		defer b.claimSession(update.CallbackQuery.From.ID)()
+		b.touchSession(update.CallbackQuery.From.ID)
+		defer b.persistState(update.CallbackQuery.From.ID)
+	} else if update.Message != nil && update.Message.From != nil {
+		defer b.claimSession(update.Message.From.ID)()
+		b.touchSession(update.Message.From.ID)
+		defer b.persistState(update.Message.From.ID)
+	}
+
+	switch {
+	case update.InlineQuery != nil:
+		b.handleInlineQuery(update.InlineQuery)
+	case update.MessageReaction != nil:
+		b.handleReaction(update.MessageReaction)
+	case update.CallbackQuery != nil:
+		b.handleCallbackQuery(update.CallbackQuery)
+	case update.Message != nil:
+		message := update.Message
+		// A user writing again has unblocked the bot
+		b.store.MarkChatActive(message.Chat.ID)
+		if message.From != nil && !isGroupSession(message.From.ID) {
+			b.store.TouchUser(message.From.ID) // Group members were touched by routeGroupUpdate
+		}
+		if message.MigrateToChatID != 0 {
+			b.store.SetChatMigration(message.Chat.ID, message.MigrateToChatID)
+			return
+		}
+		switch {
+		case len(message.Photo) > 0:
+			b.handlePhoto(message)
+		case message.Voice != nil:
+			b.handleVoice(message)
+		case message.Document != nil:
+			b.handleDocument(message)
+		case message.IsCommand():
+			b.handleCommand(message)
+		default:
+			b.handleMessage(message)
+		}
+	}
+}
+
+// updateChatID is the chat an update happened in, or 0 if it has none.
+func updateChatID(update botUpdate) int64 {
+	switch {
+	case update.MessageReaction != nil:
+		return update.MessageReaction.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	}
+	return 0
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Token Usage & Cost Tracking ---
+
+// UsageRecord is a running token total for one user on one day.
+type UsageRecord struct {
+	Jobs             int   `json:"jobs"`
+	PromptTokens     int64 `json:"promptTokens"`
+	CandidatesTokens int64 `json:"candidatesTokens"`
+}
+
+// Pricing holds the configured price in USD per one million tokens.
+type Pricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// add accumulates another record into r.
+func (r *UsageRecord) add(other UsageRecord) {
+	r.Jobs += other.Jobs
+	r.PromptTokens += other.PromptTokens
+	r.CandidatesTokens += other.CandidatesTokens
+}
+
+// Cost estimates the spend in USD for the given record.
+func (p Pricing) Cost(r UsageRecord) float64 {
+	return float64(r.PromptTokens)/1e6*p.InputPerMillion +
+		float64(r.CandidatesTokens)/1e6*p.OutputPerMillion
+}
+
+// usageDay returns the store key for the day t falls on.
+func usageDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// monthStart returns the store key for the first day of t's month.
+func monthStart(t time.Time) string {
+	return t.Format("2006-01") + "-01"
+}
+
+// AddUsage adds the tokens of one finished job to today's total for a user.
+func (s *Store) AddUsage(userID int64, usage UsageMetadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := usageDay(time.Now())
+	users, ok := s.data.Usage[day]
+	if !ok {
+		users = make(map[int64]*UsageRecord)
+		s.data.Usage[day] = users
+	}
+	rec, ok := users[userID]
+	if !ok {
+		rec = &UsageRecord{}
+		users[userID] = rec
+	}
+
+	rec.Jobs++
+	rec.PromptTokens += int64(usage.PromptTokenCount)
+	rec.CandidatesTokens += int64(usage.CandidatesTokenCount)
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving usage: %v", err)
+	}
+}
+
+// UsageForDay returns a copy of the per-user totals for a given day.
+func (s *Store) UsageForDay(day string) map[int64]UsageRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[int64]UsageRecord)
+	for userID, rec := range s.data.Usage[day] {
+		out[userID] = *rec
+	}
+	return out
+}
+
+// UsageSince sums the totals of all users from the given day (inclusive) onwards.
+func (s *Store) UsageSince(day string) UsageRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total UsageRecord
+	for d, users := range s.data.Usage {
+		if d < day {
+			continue
+		}
+		for _, rec := range users {
+			total.add(*rec)
+		}
+	}
+	return total
+}
+
+// UsageByUserSince sums each user's totals from the given day (inclusive)
+// onwards.
+func (s *Store) UsageByUserSince(day string) map[int64]UsageRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[int64]UsageRecord)
+	for d, users := range s.data.Usage {
+		if d < day {
+			continue
+		}
+		for userID, rec := range users {
+			total := out[userID]
+			total.add(*rec)
+			out[userID] = total
+		}
+	}
+	return out
+}
+
+// UserUsageSince sums one user's totals from the given day (inclusive)
+// onwards; "" means all time.
+func (s *Store) UserUsageSince(userID int64, day string) UsageRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total UsageRecord
+	for d, users := range s.data.Usage {
+		if rec, ok := users[userID]; ok && d >= day {
+			total.add(*rec)
+		}
+	}
+	return total
+}
+
+// buildCostReport renders the admin /cost summary.
+func buildCostReport(store *Store, pricing Pricing) string {
+	now := time.Now()
+	today := store.UsageForDay(usageDay(now))
+
+	var todayTotal UsageRecord
+	userIDs := make([]int64, 0, len(today))
+	for userID, rec := range today {
+		todayTotal.add(rec)
+		userIDs = append(userIDs, userID)
+	}
+	// Heaviest users first
+	sort.Slice(userIDs, func(i, j int) bool {
+		return pricing.Cost(today[userIDs[i]]) > pricing.Cost(today[userIDs[j]])
+	})
+
+	week := store.UsageSince(usageDay(now.AddDate(0, 0, -6)))
+	month := store.UsageSince(usageDay(now.AddDate(0, 0, -29)))
+
+	line := func(label string, r UsageRecord) string {
+		return usageLine(label, r, pricing)
+	}
+
+	report := "💰 **Estimated Gemini Spend**\n\n"
+	report += line("Today", todayTotal)
+	report += line("Last 7 days", week)
+	report += line("Last 30 days", month)
+
+	if len(userIDs) > 0 {
+		report += "\n**Today by user:**\n"
+		for _, userID := range userIDs {
+			report += line(fmt.Sprintf("`%d`", userID), today[userID])
+		}
+	}
+
+	report += fmt.Sprintf("\n_Prices: $%.2f / $%.2f per 1M input / output tokens_",
+		pricing.InputPerMillion, pricing.OutputPerMillion)
+	return report
+}
+
+// usageLine renders one row of a usage report.
+func usageLine(label string, r UsageRecord, pricing Pricing) string {
+	return fmt.Sprintf("%s: %d jobs, %d in / %d out tokens, ~$%.4f\n",
+		label, r.Jobs, r.PromptTokens, r.CandidatesTokens, pricing.Cost(r))
+}
+
+// --- /usage & Monthly Budget ---
+
+// budgetAlertLevels are the shares of MONTHLY_BUDGET_USD, in percent, at
+// which the admins are alerted; each fires once a month.
+var budgetAlertLevels = []int{80, 100}
+
+// recordUsage stores the tokens of one finished job and alerts the admins
+// if the month's spend has crossed a budget level.
+func (b *Bot) recordUsage(userID int64, usage UsageMetadata) {
+	b.store.AddUsage(b.memberID(userID), usage)
+	if b.monthlyBudget <= 0 {
+		return
+	}
+
+	now := time.Now()
+	spent := b.pricing.Cost(b.store.UsageSince(monthStart(now)))
+	percent := int(spent / b.monthlyBudget * 100)
+	for i := len(budgetAlertLevels) - 1; i >= 0; i-- {
+		level := budgetAlertLevels[i]
+		if percent < level {
+			continue
+		}
+		if b.store.MarkBudgetAlert(now.Format("2006-01"), level) {
+			log.Printf("Monthly budget %d%% used: ~$%.2f of $%.2f", percent, spent, b.monthlyBudget)
+			b.alertAdmins(fmt.Sprintf("💸 **Budget alert:** about $%.2f of the $%.2f monthly budget (%d%%) has been spent this month. See `/usage all` for who used it.",
+				spent, b.monthlyBudget, percent))
+		}
+		return
+	}
+}
+
+// MarkBudgetAlert records that the alert for a budget level went out in a
+// month ("2006-01"). It returns false if it (or a higher one) already had.
+func (s *Store) MarkBudgetAlert(month string, level int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.BudgetAlerts[month] >= level {
+		return false
+	}
+	s.data.BudgetAlerts[month] = level
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+	return true
+}
+
+// handleUsage handles /usage. Everyone sees their own usage; admins can add
+// "all" for this month's breakdown by user, or a user ID for that user's.
+func (b *Bot) handleUsage(chatID, userID int64, arg string) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" || !b.isAdmin(userID) {
+		b.sendMessage(chatID, b.userUsageReport(userID, "📊 **Your Usage**"), nil)
+		return
+	}
+	if arg == "all" {
+		b.sendMessage(chatID, b.usageBreakdown(), nil)
+		return
+	}
+	target, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, "Usage: `/usage`, `/usage all` or `/usage <userID>`", nil)
+		return
+	}
+	b.sendMessage(chatID, b.userUsageReport(target, fmt.Sprintf("📊 **Usage of `%d`**", target)), nil)
+}
+
+// userUsageReport renders one user's usage today, this month and overall.
+func (b *Bot) userUsageReport(userID int64, title string) string {
+	now := time.Now()
+	report := title + "\n\n"
+	report += usageLine("Today", b.store.UserUsageSince(userID, usageDay(now)), b.pricing)
+	report += usageLine("This month", b.store.UserUsageSince(userID, monthStart(now)), b.pricing)
+	report += usageLine("All time", b.store.UserUsageSince(userID, ""), b.pricing)
+	if left, ok := b.quota.remainingToday(userID); ok {
+		report += fmt.Sprintf("\nGenerations left today: %d of %d", left, b.quota.cfg.Daily)
+	}
+	return report
+}
+
+// usageBreakdown renders this month's usage by user for admins, heaviest
+// first, with the budget if one is set.
+func (b *Bot) usageBreakdown() string {
+	now := time.Now()
+	byUser := b.store.UsageByUserSince(monthStart(now))
+
+	var total UsageRecord
+	userIDs := make([]int64, 0, len(byUser))
+	for userID, rec := range byUser {
+		total.add(rec)
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		return b.pricing.Cost(byUser[userIDs[i]]) > b.pricing.Cost(byUser[userIDs[j]])
+	})
+
+	report := fmt.Sprintf("📊 **Usage in %s**\n\n", now.Format("January 2006"))
+	report += usageLine("Total", total, b.pricing)
+	if b.monthlyBudget > 0 {
+		spent := b.pricing.Cost(total)
+		report += fmt.Sprintf("Budget: ~$%.2f of $%.2f (%.0f%%)\n", spent, b.monthlyBudget, spent/b.monthlyBudget*100)
+	}
+	if len(userIDs) > 0 {
+		report += "\n**By user:**\n"
+		for _, userID := range userIDs {
+			label := fmt.Sprintf("`%d`", userID)
+			if userID == apiUsageUserID {
+				label = "API"
+			}
+			report += usageLine(label, byUser[userID], b.pricing)
+		}
+	}
+	return report
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- User Data Export & Deletion ---
+
+// forgetKeyboard confirms /forgetme, which can't be undone.
+var forgetKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+	tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🗑 Yes, delete my data", "forget:confirm"),
+		tgbotapi.NewInlineKeyboardButtonData("Keep it", "forget:cancel"),
+	),
+)
+
+// UserExport is everything the store holds about one user, as sent by /export.
+type UserExport struct {
+	UserID        int64                  `json:"userId"`
+	ExportedAt    time.Time              `json:"exportedAt"`
+	Settings      *UserSettings          `json:"settings,omitempty"`
+	Brand         string                 `json:"brand,omitempty"`
+	CustomBrand   *BrandConfig           `json:"customBrand,omitempty"`
+	Usage         map[string]UsageRecord `json:"usage"` // By day
+	RecentResults []RecentResult         `json:"recentResults"`
+	History       []HistoryEntry         `json:"history"`
+	Scheduled     []ScheduledDelivery    `json:"scheduled"`
+	Ratings       []Rating               `json:"ratings"`
+	RatedMessages map[int]captionRef     `json:"ratedMessages,omitempty"` // Caption messages that can be rated
+	Channel       *LinkedChannel         `json:"channel,omitempty"`
+	LastPhoto     *LastPhoto             `json:"lastPhoto,omitempty"` // Kept for /same; the image itself isn't included
+	BlockedSince  *time.Time             `json:"blockedSince,omitempty"`
+	LastSeen      *time.Time             `json:"lastSeen,omitempty"`
+}
+
+// ExportUser collects a user's data from every part of the store.
+func (s *Store) ExportUser(userID int64) UserExport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export := UserExport{
+		UserID:        userID,
+		ExportedAt:    time.Now(),
+		Brand:         s.data.UserBrands[userID],
+		Usage:         make(map[string]UsageRecord),
+		RecentResults: append([]RecentResult{}, s.data.RecentResults[userID]...),
+		History:       append([]HistoryEntry{}, s.data.History[userID]...),
+		Scheduled:     []ScheduledDelivery{},
+		Ratings:       []Rating{},
+	}
+	if settings, ok := s.data.Settings[userID]; ok {
+		copied := *settings
+		export.Settings = &copied
+	}
+	if bc, ok := s.data.CustomBrands[userID]; ok {
+		copied := *bc
+		export.CustomBrand = &copied
+	}
+	if msgs := s.data.ResultMessages[userID]; len(msgs) > 0 {
+		export.RatedMessages = make(map[int]captionRef, len(msgs))
+		for id, ref := range msgs {
+			export.RatedMessages[id] = ref
+		}
+	}
+	for day, users := range s.data.Usage {
+		if record, ok := users[userID]; ok {
+			export.Usage[day] = *record
+		}
+	}
+	for _, d := range s.data.Scheduled {
+		if d.UserID == userID {
+			export.Scheduled = append(export.Scheduled, d)
+		}
+	}
+	for _, r := range s.data.Ratings {
+		if r.UserID == userID {
+			export.Ratings = append(export.Ratings, r)
+		}
+	}
+	if channel, ok := s.data.Channels[userID]; ok {
+		export.Channel = &channel
+	}
+	if photo, ok := s.data.LastPhotos[userID]; ok {
+		export.LastPhoto = &photo
+	}
+	if since, ok := s.data.InactiveChats[userID]; ok {
+		export.BlockedSince = &since
+	}
+	if seen, ok := s.data.KnownUsers[userID]; ok {
+		export.LastSeen = &seen
+	}
+	return export
+}
+
+// ForgetUser deletes everything the store holds about a user, including
+// their saved photo.
+func (s *Store) ForgetUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for day, users := range s.data.Usage {
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(s.data.Usage, day)
+		}
+	}
+	for key, session := range s.data.GroupSessions {
+		if session.UserID == userID {
+			delete(s.data.GroupSessions, key)
+		}
+	}
+
+	scheduled := s.data.Scheduled[:0]
+	for _, d := range s.data.Scheduled {
+		if d.UserID != userID {
+			scheduled = append(scheduled, d)
+		} else {
+			s.removeScheduledPhoto(d.ID)
+		}
+	}
+	s.data.Scheduled = scheduled
+
+	ratings := s.data.Ratings[:0]
+	for _, r := range s.data.Ratings {
+		if r.UserID != userID {
+			ratings = append(ratings, r)
+		}
+	}
+	s.data.Ratings = ratings
+
+	if err := os.Remove(s.photoPath(userID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error removing photo of user %d: %v", userID, err)
+	}
+	delete(s.data.LastPhotos, userID)
+	delete(s.data.UserBrands, userID)
+	delete(s.data.CustomBrands, userID)
+	delete(s.data.Settings, userID)
+	delete(s.data.RecentResults, userID)
+	delete(s.data.History, userID)
+	delete(s.data.ResultMessages, userID)
+	delete(s.data.Channels, userID)
+	delete(s.data.InactiveChats, userID)
+	delete(s.data.KnownUsers, userID) // Bans are kept, so /forgetme can't lift one
+
+	if err := s.save(); err != nil {
+		log.Printf("Error saving store: %v", err)
+	}
+}
+
+// sendExport sends the user's data as a JSON document.
+func (b *Bot) sendExport(chatID, userID int64) {
+	raw, err := json.MarshalIndent(b.store.ExportUser(userID), "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling export for user %d: %v", userID, err)
+		b.sendMessage(chatID, "Sorry, I couldn't put your data together. Please try again later.", nil)
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fmt.Sprintf("my-data-%d.json", userID), Bytes: raw})
+	doc.Caption = "📦 Here's everything I've stored about you. Use /forgetme to delete it."
+	if _, err := b.send(doc); err != nil {
+		log.Printf("Error sending export to user %d: %v", userID, err)
+	}
+}
+
+// askForget asks the user to confirm deleting their data.
+func (b *Bot) askForget(chatID int64) {
+	b.sendMessage(chatID, "⚠️ This deletes your settings, history, ratings, scheduled posts and saved photo. It can't be undone.\n\n"+
+		"Use /export first if you'd like a copy.", forgetKeyboard)
+}
+
+// handleForgetCallback handles the /forgetme confirmation buttons.
+func (b *Bot) handleForgetCallback(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	if query.Message != nil {
+		b.removeInlineKeyboard(query.Message.Chat.ID, query.Message.MessageID)
+	}
+
+	if query.Data != "forget:confirm" {
+		b.sendMessage(userID, "Okay, nothing was deleted.", nil)
+		return
+	}
+	b.store.ForgetUser(userID)
+	b.resetState(userID)
+	log.Printf("Deleted the stored data of user %d", userID)
+	b.sendMessage(userID, "🗑 Done — everything I stored about you has been deleted.", nil)
+}
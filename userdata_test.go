@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fillUserData gives userID something in every part of the store a user's
+// data lives in.
+func fillUserData(t *testing.T, s *Store, userID int64) {
+	t.Helper()
+	now := time.Now()
+	content := &GeneratedContent{Results: []PlatformContent{{Platform: "Instagram", Captions: []string{"Indigo denim"}}}}
+
+	s.SaveLastPhoto(userID, testJPEG(t, 8, 8), "image/jpeg", 1<<20, time.Hour)
+	if _, err := s.AddScheduled(ScheduledDelivery{UserID: userID, SendAt: now.Add(time.Hour), Caption: "Later"}, []byte("photo")); err != nil {
+		t.Fatalf("AddScheduled: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := now.Format("2006-01-02")
+	if s.data.Usage[day] == nil {
+		s.data.Usage[day] = make(map[int64]*UsageRecord)
+	}
+	s.data.Usage[day][userID] = &UsageRecord{Jobs: 2, PromptTokens: 20, CandidatesTokens: 10}
+	s.data.UserBrands[userID] = "acme"
+	s.data.CustomBrands[userID] = &BrandConfig{Name: "Acme Denim"}
+	s.data.Settings[userID] = &UserSettings{Language: "bn"}
+	s.data.RecentResults[userID] = []RecentResult{{Hash: 1, At: now, Content: content}}
+	s.data.History[userID] = []HistoryEntry{{At: now, Platforms: []string{"Instagram"}, Tone: "Professional", Content: content}}
+	s.data.ResultMessages[userID] = map[int]captionRef{7: {Platform: "Instagram", Text: "Indigo denim"}}
+	s.data.Ratings = append(s.data.Ratings, Rating{UserID: userID, MessageID: 7, At: now, Score: 1})
+	s.data.Channels[userID] = LinkedChannel{ID: -100, Title: "Acme"}
+	s.data.InactiveChats[userID] = now
+	s.data.GroupSessions[groupSessionBit+userID] = GroupSession{ChatID: -5, UserID: userID}
+}
+
+func TestExportUser(t *testing.T) {
+	s, err := NewStore(filepath.Join(t.TempDir(), "bot_data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillUserData(t, s, 1)
+	fillUserData(t, s, 2)
+
+	export := s.ExportUser(1)
+	raw, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("marshalling the export: %v", err)
+	}
+	for _, section := range []string{"settings", "brand", "customBrand", "usage", "recentResults", "history",
+		"scheduled", "ratings", "ratedMessages", "channel", "lastPhoto", "blockedSince"} {
+		if !strings.Contains(string(raw), `"`+section+`":`) {
+			t.Errorf("export has no %q section", section)
+		}
+	}
+	if len(export.Scheduled) != 1 || len(export.Ratings) != 1 || len(export.Usage) != 1 {
+		t.Errorf("export has %d scheduled, %d ratings, %d usage days; want only user 1's one each",
+			len(export.Scheduled), len(export.Ratings), len(export.Usage))
+	}
+	for _, r := range export.Ratings {
+		if r.UserID != 1 {
+			t.Errorf("export includes user %d's rating", r.UserID)
+		}
+	}
+}
+
+func TestForgetUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot_data.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillUserData(t, s, 1)
+	fillUserData(t, s, 2)
+	scheduledPhoto := s.scheduledPhotoPath(1) // User 1's delivery was added first
+
+	s.ForgetUser(1)
+
+	for _, file := range []string{s.photoPath(1), scheduledPhoto} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%s still exists (err %v)", file, err)
+		}
+	}
+	if _, err := os.Stat(s.photoPath(2)); err != nil {
+		t.Errorf("user 2's photo was removed too: %v", err)
+	}
+
+	// What's left must also be what was saved
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reloading the store: %v", err)
+	}
+	empty := UserExport{UserID: 1, Usage: map[string]UsageRecord{}, RecentResults: []RecentResult{},
+		History: []HistoryEntry{}, Scheduled: []ScheduledDelivery{}, Ratings: []Rating{}}
+	for name, store := range map[string]*Store{"in memory": s, "on disk": reloaded} {
+		export := store.ExportUser(1)
+		export.ExportedAt = time.Time{}
+		got, _ := json.Marshal(export)
+		want, _ := json.Marshal(empty)
+		if string(got) != string(want) {
+			t.Errorf("%s, user 1's data after ForgetUser = %s, want none", name, got)
+		}
+		if _, ok := store.data.GroupSessions[groupSessionBit+1]; ok {
+			t.Errorf("%s, user 1's group session is left", name)
+		}
+		if other := store.ExportUser(2); len(other.History) != 1 || len(other.Ratings) != 1 || other.Channel == nil {
+			t.Errorf("%s, user 2's data was deleted too: %+v", name, other)
+		}
+	}
+}
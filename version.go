@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// --- Build Info ---
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// orDev returns s, or "dev" for a build without ldflags.
+func orDev(s string) string {
+	if s == "" {
+		return "dev"
+	}
+	return s
+}
+
+// versionSummary is the one-line build description logged at startup.
+func versionSummary() string {
+	return fmt.Sprintf("version %s (commit %s, built %s)", orDev(version), orDev(commit), orDev(buildTime))
+}
+
+// versionText renders the /version reply.
+func versionText() string {
+	return fmt.Sprintf("🏷️ **Build Info**\n\nVersion: `%s`\nCommit: `%s`\nBuilt: `%s`",
+		orDev(version), orDev(commit), orDev(buildTime))
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Vertex AI Authentication ---
+
+// Gemini authentication modes (GEMINI_AUTH).
+const (
+	geminiAuthKey    = "key"    // GEMINI_API_KEY in the URL, against the Gemini API
+	geminiAuthVertex = "vertex" // OAuth tokens from Google credentials, against Vertex AI
+)
+
+const (
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	spreadsheetsScope  = "https://www.googleapis.com/auth/spreadsheets"
+	googleTokenURL     = "https://oauth2.googleapis.com/token"
+	metadataTokenURL   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// tokenRefreshMargin renews a token this long before it expires, so a
+	// request never goes out with one about to lapse.
+	tokenRefreshMargin = time.Minute
+)
+
+// vertexEndpoint is the generateContent URL of a model on Vertex AI.
+func vertexEndpoint(project, location, model string) string {
+	host := location + "-aiplatform.googleapis.com"
+	if location == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:generateContent",
+		host, url.PathEscape(project), url.PathEscape(location), url.PathEscape(model))
+}
+
+// googleCredentials is a credentials JSON file: a service account key, or
+// the user credentials "gcloud auth application-default login" writes.
+type googleCredentials struct {
+	Type         string `json:"type"` // "service_account" or "authorized_user"
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenResponse is what Google's token endpoints (and the metadata server) return.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"` // Seconds
+}
+
+// vertexAuth hands out OAuth access tokens for Vertex AI (or, with another
+// scope, other Google APIs), fetching a new one when the cached token is
+// about to expire.
+type vertexAuth struct {
+	httpClient *http.Client
+	scope      string             // Service accounts only; other credentials carry their own
+	creds      *googleCredentials // nil means the metadata server
+	signer     *rsa.PrivateKey    // Service accounts only
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newVertexAuth finds credentials the way Google's Application Default
+// Credentials do: the file in path (GOOGLE_APPLICATION_CREDENTIALS), then
+// gcloud's application-default file, then the metadata server of the
+// Google Cloud machine we run on.
+func newVertexAuth(path, scope string, httpClient *http.Client) (*vertexAuth, error) {
+	auth := &vertexAuth{httpClient: httpClient, scope: scope}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			wellKnown := filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(wellKnown); err == nil {
+				path = wellKnown
+			}
+		}
+	}
+	if path == "" {
+		return auth, nil // Metadata server
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Google credentials: %w", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("error parsing Google credentials %s: %w", path, err)
+	}
+	switch creds.Type {
+	case "service_account":
+		if auth.signer, err = parseServiceAccountKey(creds.PrivateKey); err != nil {
+			return nil, fmt.Errorf("invalid service account key in %s: %w", path, err)
+		}
+		if creds.TokenURI == "" {
+			creds.TokenURI = googleTokenURL
+		}
+	case "authorized_user":
+		if creds.RefreshToken == "" {
+			return nil, fmt.Errorf("Google credentials %s have no refresh token", path)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Google credentials type %q in %s", creds.Type, path)
+	}
+	auth.creds = &creds
+	return auth, nil
+}
+
+// parseServiceAccountKey decodes a service account's PEM private key.
+func parseServiceAccountKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// projectID is the project named in the credentials file, if any.
+func (a *vertexAuth) projectID() string {
+	if a.creds == nil {
+		return ""
+	}
+	return a.creds.ProjectID
+}
+
+// accessToken returns a valid token, fetching a new one if needed.
+// Failures are authErrors, so they count towards the auth guard.
+func (a *vertexAuth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Until(a.expires) > tokenRefreshMargin {
+		return a.token, nil
+	}
+	resp, err := a.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	a.token = resp.AccessToken
+	a.expires = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return a.token, nil
+}
+
+// invalidate drops the cached token, e.g. after Vertex AI rejected it.
+func (a *vertexAuth) invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+}
+
+// fetchToken gets a new token from wherever the credentials point.
+func (a *vertexAuth) fetchToken(ctx context.Context) (*tokenResponse, error) {
+	var req *http.Request
+	var err error
+	switch {
+	case a.creds == nil:
+		req, err = http.NewRequestWithContext(ctx, "GET", metadataTokenURL, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case a.creds.Type == "service_account":
+		var assertion string
+		if assertion, err = a.signedJWT(); err == nil {
+			req, err = postForm(ctx, a.creds.TokenURI, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}
+	default:
+		req, err = postForm(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {a.creds.ClientID},
+			"client_secret": {a.creds.ClientSecret},
+			"refresh_token": {a.creds.RefreshToken},
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating token request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching Google access token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &authError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("error parsing token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, &authError{StatusCode: resp.StatusCode, Body: "no access token in response"}
+	}
+	return &token, nil
+}
+
+// signedJWT is the RS256-signed assertion a service account trades for a token.
+func (a *vertexAuth) signedJWT() (string, error) {
+	now := time.Now()
+	encode := func(v any) (string, error) {
+		raw, err := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(raw), err
+	}
+	header, err := encode(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := encode(map[string]any{
+		"iss":   a.creds.ClientEmail,
+		"scope": a.scope,
+		"aud":   a.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + claims
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing token request: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// postForm builds a form-encoded POST request.
+func postForm(ctx context.Context, target string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
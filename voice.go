@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// --- Voice Notes ---
+
+// maxVoiceSeconds caps how long a voice note we'll send for transcription.
+const maxVoiceSeconds = 300
+
+// unintelligibleMarker is what the model returns when it can't make out any speech.
+const unintelligibleMarker = "[UNINTELLIGIBLE]"
+
+// handleVoice transcribes a voice note and uses it as the product description.
+// During the context step it's treated exactly like typed context; before a
+// photo arrives it's kept and used as the context for the next job.
+func (b *Bot) handleVoice(message *tgbotapi.Message) {
+	userID := message.From.ID
+	state := b.getState(userID)
+
+	if state.State != StateWaitingForContext && state.State != StateDefault && state.State != StateRefining {
+		b.sendMessage(message.Chat.ID, "Please finish the current step first (or /cancel), then send your voice note.", nil)
+		return
+	}
+	if message.Voice.Duration > maxVoiceSeconds {
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("Sorry, that voice note is too long. Please keep it under %d minutes.", maxVoiceSeconds/60), nil)
+		return
+	}
+
+	audioData, _, err := b.downloadFile(message.Voice.FileID, false)
+	if err != nil {
+		log.Printf("Error downloading voice note: %v", err)
+		b.sendMessage(message.Chat.ID, "Sorry, I had trouble downloading your voice note. Please try again.", nil)
+		return
+	}
+
+	mimeType := message.Voice.MimeType
+	if mimeType == "" {
+		mimeType = "audio/ogg"
+	}
+
+	transcript, usage, err := transcribeAudio(context.Background(), b.llm, audioData, mimeType)
+	b.recordUsage(userID, usage)
+	if errors.Is(err, errUnsupportedInput) {
+		b.sendMessage(message.Chat.ID, "Sorry, voice notes aren't available with this bot's AI provider. Please type your description instead.", nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Error transcribing voice note: %v", err)
+		b.sendMessage(message.Chat.ID, "Sorry, I couldn't understand the audio. 🎧 Please try again or type your description instead.", nil)
+		return
+	}
+
+	if state.State == StateWaitingForContext {
+		state.Context = transcript
+		state.State = StateDefault
+		b.removeInlineKeyboard(message.Chat.ID, state.MessageID)
+		b.sendMessage(message.Chat.ID, fmt.Sprintf("📝 Got it: _%s_", transcript), nil)
+		b.generateContent(message.Chat.ID)
+		return
+	}
+
+	// No photo yet: remember the description for the next job
+	state.Context = transcript
+	b.sendMessage(message.Chat.ID, fmt.Sprintf("📝 Got it: _%s_\n\nNow send me a **photo** of the product and I'll use this as the description.", transcript), nil)
+}
+
+// transcribeAudio asks Gemini for a verbatim transcript of a voice note.
+func transcribeAudio(ctx context.Context, client ContentGenerator, audioData []byte, mimeType string) (string, UsageMetadata, error) {
+	request := GeminiRequest{
+		Contents: []Content{
+			{
+				Role: "user",
+				Parts: []Part{
+					{Text: "Transcribe this voice note."},
+					{InlineData: &InlineData{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(audioData)}},
+				},
+			},
+		},
+		SystemInstruction: SystemInstruction{
+			Parts: []Part{{Text: "You transcribe short voice notes in which a business owner describes a product. " +
+				"Reply with the transcript only, in the language spoken, with no commentary. " +
+				"If there is no intelligible speech, reply with exactly " + unintelligibleMarker + "."}},
+		},
+	}
+
+	text, usage, err := client.generateContent(ctx, request)
+	if err != nil {
+		return "", usage, fmt.Errorf("error transcribing audio: %w", err)
+	}
+
+	transcript := strings.TrimSpace(text)
+	if transcript == "" || strings.Contains(transcript, unintelligibleMarker) {
+		return "", usage, fmt.Errorf("no intelligible speech in audio")
+	}
+	return transcript, usage, nil
+}